@@ -301,3 +301,18 @@ func spanAttributes(span sdktrace.ReadOnlySpan) map[string]attribute.Value {
 	}
 	return attrs
 }
+
+func TestAppMiddlewareAllowsFlush(t *testing.T) {
+	handler := appMiddleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("expected flush through middleware, got %v", err)
+		}
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "https://careme.cooking/recipes/abc/events", nil))
+
+	if !rec.Flushed {
+		t.Fatal("expected response to be flushed")
+	}
+}
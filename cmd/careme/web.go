@@ -13,6 +13,8 @@ import (
 	"careme/internal/actowiz"
 	"careme/internal/admin"
 	"careme/internal/ai"
+	"careme/internal/ai/fixture"
+	"careme/internal/ai/spend"
 	"careme/internal/auth"
 	"careme/internal/campaigns"
 	"careme/internal/config"
@@ -54,6 +56,10 @@ func runServer(cfg *config.Config, addr string) error {
 	if err != nil {
 		return fmt.Errorf("failed to create auth client: %w", err)
 	}
+	// API tokens work anywhere a session does except admin, which wants a real sign in.
+	sessionAuth := authClient
+	apiTokens := auth.NewTokenStore(cache)
+	authClient = auth.WithTokens(sessionAuth, apiTokens)
 
 	rootMux := http.NewServeMux()
 	appRoutes := routing.Wrap(rootMux, func(h http.Handler) http.Handler {
@@ -68,7 +74,14 @@ func runServer(cfg *config.Config, addr string) error {
 	userStorage := users.NewStorage(cache)
 	ro := &readyOnce{}
 	watchdogServer := watchdog.Server{}
-	aiHTTPClient := &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)}
+	aiTransport, err := fixture.FromEnv(http.DefaultTransport, ai.VolatilePrompts...)
+	if err != nil {
+		return fmt.Errorf("failed to set up AI fixtures: %w", err)
+	}
+	aiHTTPClient := &http.Client{Transport: otelhttp.NewTransport(aiTransport)}
+	spendLedger := spend.NewLedger(cache, cfg.Spend)
+	spendLedger.Start()
+	ai.SetUsageMeter(spendLedger)
 	// TODO  make the mock more transparent?
 	grader := ingredientgrading.NewManager(cfg, cache, aiHTTPClient)
 
@@ -92,10 +105,13 @@ func runServer(cfg *config.Config, addr string) error {
 		critiquer := critique.NewManager(cfg, cache, aiHTTPClient)
 		ro.add(critiquer)
 
-		aiclient := ai.NewClient(cfg.AI.APIKey, "TODOMODEL", aiHTTPClient, prompts.NewCacheRecorder(cache))
-		imageGen = aiclient
-		marketExtractor = aiclient
+		aiclient := ai.NewFromConfig(cfg, aiHTTPClient, prompts.NewCacheConversationStore(cache), prompts.NewCacheRecorder(cache))
 		ro.add(aiclient)
+		// images and farmers market photos only go through OpenAI; with just a local
+		// model they fail when asked for.
+		openAIClient := ai.NewClient(cfg.AI.APIKey, "TODOMODEL", aiHTTPClient, prompts.NewCacheRecorder(cache))
+		imageGen = openAIClient
+		marketExtractor = openAIClient
 		staples, err := recipes.NewCachedStaplesService(cfg, cache, grader)
 		if err != nil {
 			return fmt.Errorf("failed to create staples service: %w", err)
@@ -110,12 +126,13 @@ func runServer(cfg *config.Config, addr string) error {
 	}
 	watchdogServer.Register(infraRoutes)
 
-	userHandler := users.NewHandler(userStorage, locationStorage, authClient, users.NewUnsubscribeTokenFactory(*cfg), cfg.ResolvedPublicOrigin())
+	userHandler := users.NewHandler(userStorage, locationStorage, authClient, users.NewUnsubscribeTokenFactory(*cfg), apiTokens, cfg.ResolvedPublicOrigin())
 	userHandler.Register(appRoutes)
 
 	locationServer := locations.NewServer(locationStorage, centroids, userStorage, recipes.NewCachedProduceScorer(recipes.IO(cache)))
 	ro.add(locationServer)
 	locationServer.Register(appRoutes, authClient)
+	locationServer.RegisterAPI(appRoutes, authClient)
 
 	farmersMarketCache, err := cachepkg.EnsureCache(farmersmarket.Container)
 	if err != nil {
@@ -130,8 +147,9 @@ func runServer(cfg *config.Config, addr string) error {
 	sitemapHandler := sitemap.New(cache, cfg.ResolvedPublicOrigin(), locationStorage)
 	sitemapHandler.Register(infraRoutes)
 
-	recipeHandler := recipes.NewHandler(cfg, userStorage, generator, locationStorage, cache, imageCache, authClient, imageGen)
+	recipeHandler := recipes.NewHandler(cfg, userStorage, generator, locationStorage, cache, imageCache, authClient, imageGen, aiHTTPClient)
 	recipeHandler.Register(appRoutes)
+	recipeHandler.RegisterAPI(appRoutes)
 	waiters = append([]waiter{recipeHandler}, waiters...)
 	campaigns.RegisterAdvertisedRecipeGeneration(infraRoutes, locationStorage, recipeHandler)
 
@@ -140,6 +158,7 @@ func runServer(cfg *config.Config, addr string) error {
 	adminMux := http.NewServeMux()
 	adminMux.Handle("/{$}", admin.Page())
 	adminMux.Handle("/users", users.AdminUsersPage(userStorage))
+	adminMux.Handle("/spend", spend.AdminPage(spendLedger))
 	recipeIO := recipes.IO(cache)
 	adminMux.Handle("/params/{hash}", recipes.AdminParamsJSON(cache))
 	adminMux.Handle("/prompt/menu/{hash}", prompts.AdminMenuPromptJSON(cache))
@@ -147,7 +166,7 @@ func runServer(cfg *config.Config, addr string) error {
 	adminMux.Handle("/mealplan/{hash}", recipes.AdminMealPlanPage(recipeIO))
 	ingredientsHandler := ingredients.NewHandler(cache)
 	ingredientsHandler.Register(adminMux)
-	appRoutes.Handle("/admin/", admin.New(cfg, authClient).Enforce(http.StripPrefix("/admin", adminMux)))
+	appRoutes.Handle("/admin/", admin.New(cfg, sessionAuth).Enforce(http.StripPrefix("/admin", adminMux)))
 	appRoutes.Handle("/critiques/{hash}", critique.CritiquePage(critique.NewStore(cache), recipeIO))
 
 	appRoutes.HandleFunc("/about", func(w http.ResponseWriter, r *http.Request) {
@@ -171,6 +190,8 @@ func runServer(cfg *config.Config, addr string) error {
 	// no logging for readyiness too noisy.
 	rootMux.Handle("/ready", &recoverer{ro})
 
+	// last, so spend recorded by everything above is written.
+	waiters = append(waiters, spendLedger)
 	return serve(addr, rootMux, waiters)
 }
 
@@ -2,17 +2,23 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"hash/fnv"
 	"io"
+	"io/fs"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
 	"testing"
 	"time"
 
+	"careme/internal/ai"
+	"careme/internal/ai/fixture"
 	"careme/internal/auth"
 	"careme/internal/cache"
 	"careme/internal/config"
@@ -40,6 +46,25 @@ func TestWebEndToEndFlowWithMocks(t *testing.T) {
 	srv := newTestServer(t)
 	defer srv.Close()
 
+	runWebEndToEndFlow(t, srv, func(prompt string) string { return "Mock answer: " + prompt })
+}
+
+// TestWebEndToEndFlowWithReplayedAI runs the same flow through the real
+// generator, critiquer and question answering, replaying golden fixtures
+// instead of calling a model.
+func TestWebEndToEndFlowWithReplayedAI(t *testing.T) {
+	srv := newTestServerWithGenerator(t, replayedGenerator)
+	defer srv.Close()
+
+	runWebEndToEndFlow(t, srv, nil)
+}
+
+// runWebEndToEndFlow walks a user from finding a store through generating,
+// picking and finalizing recipes to asking a question and leaving feedback.
+// wantAnswer is the answer expected for the question as sent to the model, or
+// nil to take whatever a real model answered.
+func runWebEndToEndFlow(t *testing.T, srv *httptest.Server, wantAnswer func(prompt string) string) {
+	t.Helper()
 	client := newTestClient(t)
 	resp := mustGet(t, client, srv.URL+"/ready") // our readiness probe works even with mocks?
 	if resp.StatusCode != http.StatusOK {
@@ -110,8 +135,8 @@ func TestWebEndToEndFlowWithMocks(t *testing.T) {
 		t.Fatalf("expected question thread to include question %q", question)
 	}
 	expectedPrompt := "Regarding " + savedTitle + ": " + question
-	if !strings.Contains(questionBody, "Mock answer: "+expectedPrompt) {
-		t.Fatalf("expected question thread to include mock answer for %q", expectedPrompt)
+	if wantAnswer != nil && !strings.Contains(questionBody, wantAnswer(expectedPrompt)) {
+		t.Fatalf("expected question thread to include the answer for %q, got: %s", expectedPrompt, questionBody)
 	}
 
 	// Step 7: submit cooked feedback and ensure it persists on the recipe page.
@@ -192,6 +217,13 @@ func TestHomeRoutesAreExplicit(t *testing.T) {
 
 func newTestServer(t *testing.T) *httptest.Server {
 	t.Helper()
+	return newTestServerWithGenerator(t, func(_ *testing.T, cacheStore cache.ListCache) recipes.ExtGenerator {
+		return recipes.NewMockGenerator(recipes.IO(cacheStore), critique.NewMock(cacheStore))
+	})
+}
+
+func newTestServerWithGenerator(t *testing.T, newGenerator func(t *testing.T, cacheStore cache.ListCache) recipes.ExtGenerator) *httptest.Server {
+	t.Helper()
 
 	cfg := &config.Config{Mocks: config.MockConfig{Enable: true}}
 	err := templates.Init(cfg, "dummyhash") // initialize templates so they don't hit the file system during tests
@@ -202,7 +234,7 @@ func newTestServer(t *testing.T) *httptest.Server {
 	cacheDir := filepath.Join(t.TempDir(), "cache")
 	cacheStore := cache.NewFileCache(cacheDir)
 	userStorage := users.NewStorage(cacheStore)
-	generator := recipes.NewMockGenerator(recipes.IO(cacheStore), critique.NewMock(cacheStore))
+	generator := newGenerator(t, cacheStore)
 	centroids := locations.LoadCentroids()
 	locationStorage, err := locations.New(cfg, cacheStore, centroids)
 	if err != nil {
@@ -219,8 +251,8 @@ func newTestServer(t *testing.T) *httptest.Server {
 	locationServer := locations.NewServer(locationStorage, centroids, userStorage, fakeProduceScorer{})
 	locationServer.Register(appRoutes, mockAuth)
 	utfactory := users.FakeUnsubscribeTokenFactory()
-	users.NewHandler(userStorage, locationStorage, mockAuth, utfactory, "http://example.com").Register(appRoutes)
-	recipes.NewHandler(cfg, userStorage, generator, locationStorage, cacheStore, cacheStore, mockAuth, generator).Register(appRoutes)
+	users.NewHandler(userStorage, locationStorage, mockAuth, utfactory, auth.NewTokenStore(cacheStore), "http://example.com").Register(appRoutes)
+	recipes.NewHandler(cfg, userStorage, generator, locationStorage, cacheStore, cacheStore, mockAuth, recipes.NewMockImageGen(), http.DefaultClient).Register(appRoutes)
 	farmersMarketStore := farmersmarket.NewStore(cacheStore)
 	farmersMarketUploader := farmersmarket.NewUploader(farmersMarketStore)
 	farmersmarket.NewHandler(farmersMarketUploader, cacheStore, mockAuth, farmersmarket.MockExtractor{}).Register(appRoutes)
@@ -514,3 +546,99 @@ func hasElement(n *html.Node, name string) bool {
 	}
 	return false
 }
+
+// goldenFixtures are real OpenAI and OpenRouter exchanges replayed without the
+// network. Record them again after changing what the generator sends with
+// AI_FIXTURES=record and real AI_API_KEY and OPENROUTER_API_KEY.
+const goldenFixtures = "testdata/ai-fixtures"
+
+// storeDayPrompts are the parts of a request that change with the day the
+// test runs, on top of the prompts that are random on purpose.
+var storeDayPrompts = append([]*regexp.Regexp{
+	regexp.MustCompile(`for the current date and user's state location .*$`),
+	regexp.MustCompile(`^careme:store-day:v1:[0-9a-f]+$`),
+}, ai.VolatilePrompts...)
+
+func replayedGenerator(t *testing.T, cacheStore cache.ListCache) recipes.ExtGenerator {
+	t.Helper()
+	var transport http.RoundTripper
+	if fixture.Mode(os.Getenv("AI_FIXTURES")) == fixture.Record {
+		if err := os.RemoveAll(goldenFixtures); err != nil {
+			t.Fatalf("failed to clear fixtures: %v", err)
+		}
+		transport = fixture.NewTransport(fixture.Record, goldenFixtures, http.DefaultTransport, storeDayPrompts...)
+	} else {
+		if _, err := os.Stat(goldenFixtures); errors.Is(err, fs.ErrNotExist) {
+			t.Skip("no golden AI fixtures recorded yet")
+		}
+		transport = fixture.NewTransport(fixture.Replay, goldenFixtures, offlineTransport(t), storeDayPrompts...)
+	}
+	// a model takes a moment, which is what gets the spinner shown.
+	httpClient := &http.Client{Transport: slowTransport{next: transport, delay: 20 * time.Millisecond}}
+
+	cfg := &config.Config{
+		AI:         config.AIConfig{APIKey: recordingKey(t, "AI_API_KEY")},
+		OpenRouter: config.OpenRouterConfig{APIKey: recordingKey(t, "OPENROUTER_API_KEY")},
+	}
+	critiquer := critique.NewManager(cfg, cacheStore, httpClient)
+	t.Cleanup(critiquer.Wait)
+	generator, err := recipes.NewGenerator(ai.NewClient(cfg.AI.APIKey, "", httpClient, nil), critiquer, fixedStaples{}, recipes.StatusStore(cacheStore), recipes.IO(cacheStore))
+	if err != nil {
+		t.Fatalf("failed to create generator: %v", err)
+	}
+	return generator
+}
+
+// recordingKey is the real key from env when recording. Keys aren't part of a
+// recording, so replays get by with a placeholder.
+func recordingKey(t *testing.T, env string) string {
+	t.Helper()
+	if fixture.Mode(os.Getenv("AI_FIXTURES")) != fixture.Record {
+		return "test-key"
+	}
+	key := os.Getenv(env)
+	if key == "" {
+		t.Fatalf("%s is needed to record golden fixtures", env)
+	}
+	return key
+}
+
+// offlineTransport fails any request that would leave the process.
+func offlineTransport(t *testing.T) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		t.Errorf("unexpected network request to %s", req.URL)
+		return nil, fmt.Errorf("network disabled in tests")
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+type slowTransport struct {
+	next  http.RoundTripper
+	delay time.Duration
+}
+
+func (s slowTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	time.Sleep(s.delay)
+	return s.next.RoundTrip(req)
+}
+
+// fixedStaples is what's on sale at every store.
+type fixedStaples struct{}
+
+func (fixedStaples) FetchStaples(context.Context, *recipes.GeneratorParams) ([]ai.InputIngredient, error) {
+	chicken, beans := float32(6.99), float32(2.49)
+	return []ai.InputIngredient{
+		{ProductID: "chicken-1", Description: "Chicken Thighs", PriceRegular: &chicken, Grade: &ai.IngredientGrade{Score: 9}},
+		{ProductID: "beans-1", Description: "Green Beans", PriceRegular: &beans, Grade: &ai.IngredientGrade{Score: 9}},
+	}, nil
+}
+
+func (fixedStaples) FetchWines(context.Context, string, []string, time.Time) ([]ai.InputIngredient, error) {
+	price := float32(14.99)
+	return []ai.InputIngredient{{ProductID: "wine-1", Description: "Dry Riesling", PriceRegular: &price}}, nil
+}
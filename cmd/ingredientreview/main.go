@@ -1,40 +1,93 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
 	"time"
 
 	"careme/internal/ai"
 	"careme/internal/cache"
+	"careme/internal/config"
 	"careme/internal/ingredients/gradereview"
 
 	"github.com/paulgmiller/kage/pkg/kage"
+	"github.com/samber/lo"
 )
 
+const usage = `usage: ingredientreview [command] [flags]
+
+commands:
+  serve      review cached grades in the browser (default)
+  report     reviewer accuracy per grader version and category
+  dataset    write reviews as labeled JSON lines
+  calibrate  write a grading prompt calibration from reviews
+  ab         regrade reviewed ingredients with a calibration and compare
+`
+
+type candidateGrader interface {
+	gradereview.Grader
+	CacheVersion() string
+}
+
 func main() {
-	if err := run(os.Args[1:]); err != nil {
+	if err := run(context.Background(), os.Args[1:], os.Stdout); err != nil {
 		log.Fatal(err)
 	}
 }
 
-func run(args []string) error {
-	fs := flag.NewFlagSet("ingredientreview", flag.ContinueOnError)
-	addr := fs.String("addr", ":8090", "address for the ingredient grade review app")
-	if err := fs.Parse(args); err != nil {
-		return err
+func run(ctx context.Context, args []string, out io.Writer) error {
+	command := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
 	}
-	if err := kage.Load(); err != nil {
-		return fmt.Errorf("load environment: %w", err)
+	switch command {
+	case "serve":
+		return runServe(args)
+	case "report":
+		return runReport(ctx, args, out)
+	case "dataset":
+		return runDataset(ctx, args, out)
+	case "calibrate":
+		return runCalibrate(ctx, args, out)
+	case "ab":
+		return runAB(ctx, args, out)
+	default:
+		_, _ = io.WriteString(out, usage)
+		return fmt.Errorf("unknown command %q", command)
 	}
+}
 
+func loadCache() (cache.ListCache, error) {
+	if err := kage.Load(); err != nil {
+		return nil, fmt.Errorf("load environment: %w", err)
+	}
 	cacheStore, err := cache.MakeCache()
 	if err != nil {
-		return fmt.Errorf("create cache: %w", err)
+		return nil, fmt.Errorf("create cache: %w", err)
+	}
+	return cacheStore, nil
+}
+
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("ingredientreview serve", flag.ContinueOnError)
+	addr := fs.String("addr", ":8090", "address for the ingredient grade review app")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cacheStore, err := loadCache()
+	if err != nil {
+		return err
 	}
 
 	server := &http.Server{
@@ -48,3 +101,246 @@ func run(args []string) error {
 	}
 	return nil
 }
+
+func runReport(ctx context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("ingredientreview report", flag.ContinueOnError)
+	fs.SetOutput(out)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cacheStore, err := loadCache()
+	if err != nil {
+		return err
+	}
+	labeled, err := gradereview.LoadReviews(ctx, cacheStore)
+	if err != nil {
+		return err
+	}
+	return printReport(out, gradereview.Measure(labeled), ai.IngredientGradeCacheVersion(os.Getenv("INGREDIENT_GRADING_MODEL")))
+}
+
+func runDataset(ctx context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("ingredientreview dataset", flag.ContinueOnError)
+	fs.SetOutput(out)
+	output := fs.String("o", "", "file to write instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cacheStore, err := loadCache()
+	if err != nil {
+		return err
+	}
+	labeled, err := gradereview.LoadReviews(ctx, cacheStore)
+	if err != nil {
+		return err
+	}
+	return writeOutput(*output, out, func(w io.Writer) error {
+		return writeDataset(w, labeled)
+	})
+}
+
+func runCalibrate(ctx context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("ingredientreview calibrate", flag.ContinueOnError)
+	fs.SetOutput(out)
+	examples := fs.Int("examples", 20, "most reviewed grades to include as examples")
+	output := fs.String("o", "", "file to write instead of stdout, like internal/ai/ingredient_grade_calibration.txt")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *examples < 0 {
+		return errors.New("-examples must not be negative")
+	}
+	cacheStore, err := loadCache()
+	if err != nil {
+		return err
+	}
+	labeled, err := gradereview.LoadReviews(ctx, cacheStore)
+	if err != nil {
+		return err
+	}
+	calibration := gradereview.Calibrate(labeled, *examples)
+	return writeOutput(*output, out, func(w io.Writer) error {
+		_, err := fmt.Fprintln(w, calibration)
+		return err
+	})
+}
+
+func runAB(ctx context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("ingredientreview ab", flag.ContinueOnError)
+	fs.SetOutput(out)
+	calibrationFile := fs.String("calibration", "", "calibration to try, as written by calibrate")
+	model := fs.String("model", "", "grading model to try instead of the configured one")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if strings.TrimSpace(*calibrationFile) == "" {
+		return errors.New("-calibration is required")
+	}
+	calibration, err := os.ReadFile(*calibrationFile)
+	if err != nil {
+		return fmt.Errorf("read calibration: %w", err)
+	}
+	cacheStore, err := loadCache()
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	grader, err := newCandidateGrader(cfg, strings.TrimSpace(*model), string(calibration))
+	if err != nil {
+		return err
+	}
+	labeled, err := gradereview.LoadReviews(ctx, cacheStore)
+	if err != nil {
+		return err
+	}
+	comparison, err := gradereview.Compare(ctx, grader, labeled)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(out, "Candidate cache version: %s\n", grader.CacheVersion()); err != nil {
+		return err
+	}
+	return printComparison(out, comparison)
+}
+
+// newCandidateGrader picks a grader the way the grading manager does, so the
+// candidate differs from what's running only by its calibration and model.
+func newCandidateGrader(cfg *config.Config, model, calibration string) (candidateGrader, error) {
+	switch {
+	case cfg.LocalAI.IsEnabled():
+		return ai.NewLocalIngredientGrader(cfg.LocalAI.BaseURL, cfg.LocalAI.APIKey, cmp.Or(model, cfg.LocalAI.Model), http.DefaultClient).WithCalibration(calibration), nil
+	case strings.TrimSpace(cfg.AI.APIKey) != "":
+		return ai.NewIngredientGrader(cfg.AI.APIKey, cmp.Or(model, cfg.IngredientGrading.Model), http.DefaultClient).WithCalibration(calibration), nil
+	default:
+		return nil, errors.New("no grading model configured")
+	}
+}
+
+func writeOutput(path string, out io.Writer, write func(io.Writer) error) error {
+	if path == "" {
+		return write(out)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeDataset(w io.Writer, labeled []gradereview.LabeledReview) error {
+	enc := json.NewEncoder(w)
+	for _, l := range labeled {
+		if err := enc.Encode(l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printReport(out io.Writer, report gradereview.Report, currentVersion string) error {
+	if _, err := fmt.Fprintf(out, "Reviewed %d grades\n", report.Overall.Reviewed); err != nil {
+		return err
+	}
+	if report.Overall.Reviewed == 0 {
+		return nil
+	}
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	if err := writeAccuracyHeader(tw, "\nGRADER VERSION"); err != nil {
+		return err
+	}
+	for _, version := range sortedKeys(report.ByVersion) {
+		label := version
+		if version == currentVersion {
+			label += " (current)"
+		}
+		if err := writeAccuracy(tw, label, *report.ByVersion[version]); err != nil {
+			return err
+		}
+	}
+	if err := writeAccuracy(tw, "all", report.Overall); err != nil {
+		return err
+	}
+	if err := writeAccuracyHeader(tw, "\nCATEGORY"); err != nil {
+		return err
+	}
+	for _, category := range sortedKeys(report.ByCategory) {
+		if err := writeAccuracy(tw, category, *report.ByCategory[category]); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+func printComparison(out io.Writer, c *gradereview.Comparison) error {
+	if _, err := fmt.Fprintf(out, "Regraded %d reviewed ingredients\n", c.Candidate.Reviewed); err != nil {
+		return err
+	}
+	if c.Candidate.Reviewed == 0 {
+		return nil
+	}
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	if err := writeAccuracyHeader(tw, "\nGRADER"); err != nil {
+		return err
+	}
+	if err := writeAccuracy(tw, "reviewed", c.Baseline); err != nil {
+		return err
+	}
+	if err := writeAccuracy(tw, "candidate", c.Candidate); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(tw, "\nCATEGORY\tREVIEWED\tBASELINE\tCANDIDATE\tCHANGE"); err != nil {
+		return err
+	}
+	for _, category := range c.Categories {
+		if _, err := fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%+.0f pts\n",
+			category.Category,
+			category.Candidate.Reviewed,
+			percent(category.Baseline.Rate()),
+			percent(category.Candidate.Rate()),
+			100*(category.Candidate.Rate()-category.Baseline.Rate())); err != nil {
+			return err
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	changed := c.Changed()
+	if _, err := fmt.Fprintf(out, "\n%d verdicts changed\n", len(changed)); err != nil {
+		return err
+	}
+	for _, r := range changed {
+		if _, err := fmt.Fprintf(out, "%s (%s): graded %d, reviewed %s; candidate %d, %s. %s\n",
+			r.Ingredient.Description, r.Ingredient.ProductID, r.Grade, r.LabeledReview.Verdict, r.Score, r.Verdict, r.Reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeAccuracyHeader(tw *tabwriter.Writer, first string) error {
+	_, err := fmt.Fprintf(tw, "%s\tREVIEWED\tCORRECT\tTOO HIGH\tTOO LOW\tACCURACY\n", first)
+	return err
+}
+
+func writeAccuracy(tw *tabwriter.Writer, label string, a gradereview.Accuracy) error {
+	_, err := fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%s\n", label, a.Reviewed, a.Correct, a.TooHigh, a.TooLow, percent(a.Rate()))
+	return err
+}
+
+func percent(rate float64) string {
+	return fmt.Sprintf("%.0f%%", 100*rate)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := lo.Keys(m)
+	slices.Sort(keys)
+	return keys
+}
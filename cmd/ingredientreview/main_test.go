@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"careme/internal/ai"
+	"careme/internal/ingredients/gradereview"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunRejectsUnknownCommand(t *testing.T) {
+	var out bytes.Buffer
+	err := run(t.Context(), []string{"regrade"}, &out)
+	require.Error(t, err)
+	assert.Contains(t, out.String(), "calibrate")
+}
+
+func TestPrintReportMarksCurrentVersion(t *testing.T) {
+	report := gradereview.Report{
+		Overall:    gradereview.Accuracy{Reviewed: 4, Correct: 3, TooHigh: 1},
+		ByVersion:  map[string]*gradereview.Accuracy{"old": {Reviewed: 1, TooHigh: 1}, "new": {Reviewed: 3, Correct: 3}},
+		ByCategory: map[string]*gradereview.Accuracy{"Produce": {Reviewed: 4, Correct: 3, TooHigh: 1}},
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, printReport(&out, report, "new"))
+	assert.Contains(t, out.String(), "Reviewed 4 grades")
+	assert.Regexp(t, `new \(current\)\s+3\s+3\s+0\s+0\s+100%`, out.String())
+	assert.Regexp(t, `Produce\s+4\s+3\s+1\s+0\s+75%`, out.String())
+}
+
+func TestPrintComparisonListsChangedVerdicts(t *testing.T) {
+	labeled := gradereview.LabeledReview{
+		Review: gradereview.Review{
+			GradeKey:   "v/dip",
+			Ingredient: ai.InputIngredient{ProductID: "dip", Description: "Dip"},
+			Verdict:    gradereview.VerdictTooHigh,
+		},
+		Grade: 8,
+	}
+	comparison := &gradereview.Comparison{
+		Baseline:  gradereview.Accuracy{Reviewed: 1, TooHigh: 1},
+		Candidate: gradereview.Accuracy{Reviewed: 1, Correct: 1},
+		Categories: []gradereview.CategoryComparison{{
+			Category:  "Deli",
+			Baseline:  gradereview.Accuracy{Reviewed: 1, TooHigh: 1},
+			Candidate: gradereview.Accuracy{Reviewed: 1, Correct: 1},
+		}},
+	}
+	comparison.Regrades = []gradereview.Regrade{{LabeledReview: labeled, Score: 4, Reason: "Ready to eat.", Verdict: gradereview.VerdictCorrect}}
+
+	var out bytes.Buffer
+	require.NoError(t, printComparison(&out, comparison))
+	assert.Regexp(t, `Deli\s+1\s+0%\s+100%\s+\+100 pts`, out.String())
+	assert.Contains(t, out.String(), "1 verdicts changed")
+	assert.Contains(t, out.String(), "Dip (dip): graded 8, reviewed too_high; candidate 4, correct. Ready to eat.")
+}
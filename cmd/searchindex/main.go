@@ -0,0 +1,48 @@
+// Command searchindex indexes every cached recipe for search. New recipes are
+// indexed as they're saved; this catches up the ones saved before that.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"careme/internal/cache"
+	"careme/internal/recipes"
+
+	"github.com/paulgmiller/kage/pkg/kage"
+)
+
+func main() {
+	if err := run(context.Background(), os.Args[1:], os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("searchindex", flag.ContinueOnError)
+	fs.SetOutput(out)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := kage.Load(); err != nil {
+		return fmt.Errorf("load environment: %w", err)
+	}
+	cacheStore, err := cache.MakeCache()
+	if err != nil {
+		return fmt.Errorf("create cache: %w", err)
+	}
+	return index(ctx, cacheStore, out)
+}
+
+func index(ctx context.Context, c cache.ListCache, out io.Writer) error {
+	indexed, err := recipes.RebuildSearch(ctx, c)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "Indexed %d recipes\n", indexed)
+	return err
+}
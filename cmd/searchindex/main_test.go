@@ -0,0 +1,25 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"careme/internal/ai"
+	"careme/internal/cache"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexReportsCount(t *testing.T) {
+	c := cache.NewFileCache(t.TempDir())
+	recipe := ai.Recipe{Title: "Braised Leeks"}
+	body, err := json.Marshal(recipe)
+	require.NoError(t, err)
+	require.NoError(t, c.Put(t.Context(), "recipe/"+recipe.ComputeHash(), string(body), cache.Unconditional()))
+
+	var out bytes.Buffer
+	require.NoError(t, index(t.Context(), c, &out))
+	assert.Equal(t, "Indexed 1 recipes\n", out.String())
+}
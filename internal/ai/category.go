@@ -9,4 +9,29 @@ const (
 	aiCategoryWine              = "wine"
 	aiCategoryIngredientGrading = "ingredient_grading"
 	aiCategoryCritique          = "critique"
+	aiCategoryEmbedding         = "embedding"
 )
+
+// Categories lists every ai_category in the order reports show them.
+var Categories = []string{
+	aiCategoryMenu,
+	aiCategoryRecipe,
+	aiCategoryRecipeQuestion,
+	aiCategoryImage,
+	aiCategoryWine,
+	aiCategoryIngredientGrading,
+	aiCategoryCritique,
+	aiCategoryFarmersMarket,
+	aiCategoryEmbedding,
+}
+
+// OptionalCategory reports whether pages still work without the category, so
+// it's the first thing to go when spend has to be cut back.
+func OptionalCategory(category string) bool {
+	switch category {
+	case aiCategoryImage, aiCategoryWine, aiCategoryCritique, aiCategoryFarmersMarket, aiCategoryEmbedding:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,395 @@
+package ai
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"careme/internal/config"
+	locationtypes "careme/internal/locations/types"
+
+	openai "github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
+)
+
+// Client is what recipe generation needs from a model provider.
+type Client interface {
+	CreateMenuPlan(ctx context.Context, location *locationtypes.Location, saleIngredients []InputIngredient, instructions []string, date time.Time, lastRecipes []string, count int) (*MenuPlan, error)
+	RegenerateMenuPlan(ctx context.Context, instructions []string, previous ResponseRef, count int) (*MenuPlan, error)
+	GenerateRecipe(ctx context.Context, instructions []string, menu ResponseRef) (*Recipe, error)
+	Regenerate(ctx context.Context, instructions []string, previous ResponseRef) (*Recipe, error)
+	AskQuestion(ctx context.Context, question string, previous ResponseRef) (*QuestionResponse, error)
+	PickWine(ctx context.Context, recipe Recipe, wines []InputIngredient) (*WineSelection, error)
+	StructureRecipe(ctx context.Context, text string) (*Recipe, error)
+	Ready(ctx context.Context) error
+}
+
+var (
+	_ Client = (*client)(nil)
+	_ Client = (*chatClient)(nil)
+)
+
+// NewFromConfig uses the local chat-completions server when one is configured and OpenAI otherwise.
+func NewFromConfig(cfg *config.Config, httpClient *http.Client, conversations ConversationStore, promptRecorder PromptRecorder) Client {
+	if cfg.LocalAI.IsEnabled() {
+		return NewChatClient(cfg.LocalAI.BaseURL, cfg.LocalAI.APIKey, cfg.LocalAI.Model, httpClient, conversations, promptRecorder)
+	}
+	return NewClient(cfg.AI.APIKey, "TODOMODEL", httpClient, promptRecorder)
+}
+
+// ErrConversationNotFound is returned for a response ID with no stored conversation.
+var ErrConversationNotFound = errors.New("conversation not found")
+
+// ConversationStore keeps the chat history behind each response ID since
+// chat-completions servers, unlike the Responses API, don't store anything.
+type ConversationStore interface {
+	SaveConversation(ctx context.Context, responseID string, messages []PromptMessage) error
+	LoadConversation(ctx context.Context, responseID string) ([]PromptMessage, error)
+}
+
+type memoryConversationStore struct {
+	mu            sync.Mutex
+	conversations map[string][]PromptMessage
+}
+
+// NewMemoryConversationStore is for tools and tests; conversations are gone on restart.
+func NewMemoryConversationStore() ConversationStore {
+	return &memoryConversationStore{conversations: make(map[string][]PromptMessage)}
+}
+
+func (s *memoryConversationStore) SaveConversation(_ context.Context, responseID string, messages []PromptMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conversations[responseID] = slices.Clone(messages)
+	return nil
+}
+
+func (s *memoryConversationStore) LoadConversation(_ context.Context, responseID string) ([]PromptMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	messages, ok := s.conversations[responseID]
+	if !ok {
+		return nil, ErrConversationNotFound
+	}
+	return slices.Clone(messages), nil
+}
+
+// chatClient generates against any OpenAI-compatible chat-completions server
+// (llama.cpp, Ollama, vLLM). It hands out its own response IDs and replays the
+// stored conversation where the Responses API would use PreviousResponseID.
+type chatClient struct {
+	recipeSchema   map[string]any
+	wineSchema     map[string]any
+	menuSchema     map[string]any
+	model          string
+	oai            openai.Client
+	conversations  ConversationStore
+	promptRecorder PromptRecorder
+}
+
+func NewChatClient(baseURL, apiKey, model string, httpClient *http.Client, conversations ConversationStore, promptRecorder PromptRecorder) *chatClient {
+	if conversations == nil {
+		conversations = NewMemoryConversationStore()
+	}
+	if promptRecorder == nil {
+		promptRecorder = noopPromptRecorder{}
+	}
+	return &chatClient{
+		oai:            newLocalOpenAIClient(baseURL, apiKey, httpClient),
+		recipeSchema:   reflectSchema(&Recipe{}),
+		wineSchema:     reflectSchema(&WineSelection{}),
+		menuSchema:     reflectSchema(&MenuPlan{}),
+		model:          strings.TrimSpace(model),
+		conversations:  conversations,
+		promptRecorder: promptRecorder,
+	}
+}
+
+func newLocalOpenAIClient(baseURL, apiKey string, httpClient *http.Client) openai.Client {
+	opts := []option.RequestOption{
+		option.WithBaseURL(strings.TrimSpace(baseURL)),
+		option.WithAPIKey(apiKey),
+	}
+	if httpClient != nil {
+		opts = append(opts, option.WithHTTPClient(httpClient))
+	}
+	return openai.NewClient(opts...)
+}
+
+func (c *chatClient) Ready(ctx context.Context) error {
+	_, err := c.oai.Models.List(ctx)
+	return err
+}
+
+func (c *chatClient) CreateMenuPlan(ctx context.Context, location *locationtypes.Location, saleIngredients []InputIngredient,
+	instructions []string, date time.Time, lastRecipes []string, count int,
+) (*MenuPlan, error) {
+	if err := allowUsage(ctx, aiCategoryMenu); err != nil {
+		return nil, err
+	}
+	if count < 1 {
+		return nil, fmt.Errorf("menu plan count must be greater than zero")
+	}
+	promptMessages, err := buildMenuPlanMessages(location, saleIngredients, instructions, date, lastRecipes, count)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build menu plan messages: %w", err)
+	}
+	plan, err := c.menuPlan(ctx, "", promptMessages)
+	if err != nil {
+		return nil, err
+	}
+	if err := alignMenuPlanIngredients(plan, saleIngredients); err != nil {
+		slog.ErrorContext(ctx, "generated menu plan used unavailable ingredient", "error", err, "response_id", plan.ResponseID)
+		plan, err = c.menuPlan(ctx, plan.ResponseID, buildRegenerateMenuPlanMessages([]string{ingredientMismatchFeedback(err)}, count))
+		if err != nil {
+			return nil, fmt.Errorf("failed to regenerate menu plan after ingredient mismatch: %w", err)
+		}
+		if err := alignMenuPlanIngredients(plan, saleIngredients); err != nil {
+			return nil, fmt.Errorf("regenerated menu plan still used unavailable ingredient: %w", err)
+		}
+	}
+	return plan, nil
+}
+
+func (c *chatClient) RegenerateMenuPlan(ctx context.Context, instructions []string, previous ResponseRef, count int) (*MenuPlan, error) {
+	if err := allowUsage(ctx, aiCategoryMenu); err != nil {
+		return nil, err
+	}
+	if previous.ID == "" {
+		return nil, fmt.Errorf("response ID is required for menu plan regeneration")
+	}
+	if count < 1 {
+		return nil, fmt.Errorf("menu plan count must be greater than zero")
+	}
+	plan, err := c.menuPlan(ctx, previous.ID, buildRegenerateMenuPlanMessages(instructions, count))
+	if err != nil {
+		return nil, fmt.Errorf("failed to regenerate menu plan: %w", err)
+	}
+	return plan, nil
+}
+
+func (c *chatClient) menuPlan(ctx context.Context, previousID string, input []PromptMessage) (*MenuPlan, error) {
+	id, output, err := c.converse(ctx, aiCategoryMenu, menuPlanSystemMessage, previousID, input, c.menuSchema)
+	if err != nil {
+		return nil, err
+	}
+	var plan MenuPlan
+	if err := json.Unmarshal([]byte(output), &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse variety plan: %w", err)
+	}
+	plan.ResponseID = id
+	return &plan, nil
+}
+
+func (c *chatClient) GenerateRecipe(ctx context.Context, instructions []string, menu ResponseRef) (*Recipe, error) {
+	menu.ID = strings.TrimSpace(menu.ID)
+	if menu.ID == "" {
+		return nil, fmt.Errorf("response ID is required for menu response generation")
+	}
+	recipe, err := c.recipe(ctx, menu.ID, instructions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate recipe from menu response: %w", err)
+	}
+	return recipe, nil
+}
+
+func (c *chatClient) Regenerate(ctx context.Context, instructions []string, previous ResponseRef) (*Recipe, error) {
+	if previous.ID == "" {
+		return nil, fmt.Errorf("response ID is required for regeneration")
+	}
+	recipe, err := c.recipe(ctx, previous.ID, instructions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to regenerate recipes: %w", err)
+	}
+	return recipe, nil
+}
+
+func (c *chatClient) recipe(ctx context.Context, previousID string, instructions []string) (*Recipe, error) {
+	id, output, err := c.converse(ctx, aiCategoryRecipe, systemMessage, previousID, cleanInstructionMessages(instructions), c.recipeSchema)
+	if err != nil {
+		return nil, err
+	}
+	recipe, err := parseRecipe(output)
+	if err != nil {
+		return nil, err
+	}
+	recipe.ResponseID = id
+	return recipe, nil
+}
+
+func (c *chatClient) AskQuestion(ctx context.Context, question string, previous ResponseRef) (*QuestionResponse, error) {
+	if err := allowUsage(ctx, aiCategoryRecipeQuestion); err != nil {
+		return nil, err
+	}
+	question = strings.TrimSpace(question)
+	if question == "" {
+		return nil, fmt.Errorf("question is required")
+	}
+	id, answer, err := c.converse(ctx, aiCategoryRecipeQuestion, questionInstructions, previous.ID, []PromptMessage{userPromptMessage(question)}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to answer question: %w", err)
+	}
+	return &QuestionResponse{Answer: answer, ResponseID: id}, nil
+}
+
+func (c *chatClient) PickWine(ctx context.Context, recipe Recipe, wines []InputIngredient) (*WineSelection, error) {
+	if err := allowUsage(ctx, aiCategoryWine); err != nil {
+		return nil, err
+	}
+	prompt, err := buildWineSelectionPrompt(recipe, wines)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build wine selection prompt: %w", err)
+	}
+	output, err := completeChat(ctx, c.oai, c.model, aiCategoryWine, []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage(winePrompt),
+		openai.UserMessage(prompt),
+	}, c.wineSchema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pick wine: %w", err)
+	}
+	var selection WineSelection
+	if err := json.Unmarshal([]byte(output), &selection); err != nil {
+		return nil, fmt.Errorf("failed to parse wine selection: %w", err)
+	}
+	return &selection, nil
+}
+
+// converse continues the conversation behind previousID, if any, and stores the
+// result under a new response ID. Like PreviousResponseID, instructions aren't
+// carried over; each call passes its own.
+func (c *chatClient) converse(ctx context.Context, category, instructions, previousID string, input []PromptMessage, schema map[string]any) (string, string, error) {
+	var history []PromptMessage
+	if previousID != "" {
+		var err error
+		history, err = c.conversations.LoadConversation(ctx, previousID)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to load conversation %s: %w", previousID, err)
+		}
+	}
+
+	messages := []openai.ChatCompletionMessageParamUnion{openai.SystemMessage(instructions)}
+	for _, msg := range slices.Concat(history, input) {
+		if msg.Role == "assistant" {
+			messages = append(messages, openai.AssistantMessage(msg.Content))
+			continue
+		}
+		messages = append(messages, openai.UserMessage(msg.Content))
+	}
+	output, err := completeChat(ctx, c.oai, c.model, category, messages, schema)
+	if err != nil {
+		return "", "", err
+	}
+
+	id := "chat_" + rand.Text()
+	conversation := slices.Concat(history, input, []PromptMessage{{Role: "assistant", Content: output}})
+	if err := c.conversations.SaveConversation(ctx, id, conversation); err != nil {
+		return "", "", fmt.Errorf("failed to save conversation: %w", err)
+	}
+	if category != aiCategoryRecipeQuestion {
+		record := &PromptRecord{
+			ResponseID:         id,
+			Model:              c.model,
+			Instructions:       strings.TrimSpace(instructions),
+			PreviousResponseID: previousID,
+			Input:              slices.Clone(input),
+		}
+		if err := c.promptRecorder.RecordPrompt(ctx, record); err != nil {
+			slog.ErrorContext(ctx, "failed to record recipe prompt", "response_id", id, "error", err)
+		}
+	}
+	return id, output, nil
+}
+
+// completeChat returns the text of the first choice, constrained to schema when one is given.
+func completeChat(ctx context.Context, oai openai.Client, model, category string, messages []openai.ChatCompletionMessageParamUnion, schema map[string]any) (string, error) {
+	params := openai.ChatCompletionNewParams{
+		Model:    model,
+		Messages: messages,
+	}
+	if schema != nil {
+		params.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONSchema: &openai.ResponseFormatJSONSchemaParam{
+				JSONSchema: openai.ResponseFormatJSONSchemaJSONSchemaParam{
+					Name:   category,
+					Schema: schema,
+				},
+			},
+		}
+	}
+	resp, err := oai.Chat.Completions.New(ctx, params)
+	if err != nil {
+		return "", err
+	}
+	slog.InfoContext(ctx, "API usage", "ai_category", category, "model", model, openRouterUsageLogAttr(resp))
+	recordUsage(ctx, chatUsage(category, model, resp, true))
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("empty response from model")
+	}
+	output := strings.TrimSpace(resp.Choices[0].Message.Content)
+	if output == "" {
+		return "", fmt.Errorf("empty response from model")
+	}
+	return output, nil
+}
+
+// chatIngredientGrader grades with the same prompt as ingredientGrader over chat completions.
+type chatIngredientGrader struct {
+	model        string
+	instructions string
+	cacheVersion string
+	schema       map[string]any
+	oai          openai.Client
+}
+
+func NewLocalIngredientGrader(baseURL, apiKey, model string, httpClient *http.Client) *chatIngredientGrader {
+	model = strings.TrimSpace(model)
+	return &chatIngredientGrader{
+		oai:          newLocalOpenAIClient(baseURL, apiKey, httpClient),
+		model:        model,
+		instructions: IngredientGradeInstructions(ingredientGradeCalibration),
+		cacheVersion: IngredientGradeCacheVersion(model),
+		schema:       ingredientGradeJSONSchema(),
+	}
+}
+
+func (g *chatIngredientGrader) CacheVersion() string {
+	return g.cacheVersion
+}
+
+// WithCalibration is the grader with a different calibration; see ingredientGrader.WithCalibration.
+func (g *chatIngredientGrader) WithCalibration(calibration string) *chatIngredientGrader {
+	calibrated := *g
+	calibrated.instructions = IngredientGradeInstructions(calibration)
+	calibrated.cacheVersion = ingredientGradeCacheVersion(g.model, calibrated.instructions)
+	return &calibrated
+}
+
+func (g *chatIngredientGrader) GradeIngredients(ctx context.Context, ingredients []InputIngredient) ([]InputIngredient, error) {
+	if len(ingredients) == 0 {
+		return nil, nil
+	}
+	items, err := normalizeUngradedIngredients(ingredients)
+	if err != nil {
+		return nil, err
+	}
+	prompt, err := buildIngredientGradePrompt(items)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build ingredient grading prompt: %w", err)
+	}
+	output, err := completeChat(ctx, g.oai, g.model, aiCategoryIngredientGrading, []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage(g.instructions),
+		openai.UserMessage(prompt),
+	}, g.schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to grade ingredients: %w", err)
+	}
+	return parseIngredientGrades(ctx, output, items)
+}
@@ -0,0 +1,173 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	locationtypes "careme/internal/locations/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type chatRequest struct {
+	Model    string `json:"model"`
+	Messages []struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	} `json:"messages"`
+	ResponseFormat *struct {
+		Type       string `json:"type"`
+		JSONSchema struct {
+			Name string `json:"name"`
+		} `json:"json_schema"`
+	} `json:"response_format"`
+	Provider any `json:"provider"`
+}
+
+// fakeChatServer answers chat completions from replies, keyed by the response_format
+// schema name ("" for plain text), and keeps every request it got.
+type fakeChatServer struct {
+	t        *testing.T
+	replies  map[string]string
+	mu       sync.Mutex
+	requests []chatRequest
+}
+
+func (f *fakeChatServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Path == "/v1/models" {
+		_, _ = fmt.Fprint(w, `{"object":"list","data":[{"id":"local-model","object":"model"}]}`)
+		return
+	}
+	assert.Equal(f.t, "/v1/chat/completions", r.URL.Path)
+	var req chatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	f.mu.Unlock()
+
+	schema := ""
+	if req.ResponseFormat != nil {
+		schema = req.ResponseFormat.JSONSchema.Name
+	}
+	reply, ok := f.replies[schema]
+	if !ok {
+		http.Error(w, "no reply for "+schema, http.StatusBadRequest)
+		return
+	}
+	_, _ = fmt.Fprintf(w, `{
+		"id": "chatcmpl-1",
+		"object": "chat.completion",
+		"created": 1778529600,
+		"model": %q,
+		"choices": [{"index": 0, "finish_reason": "stop", "message": {"role": "assistant", "content": %q}}],
+		"usage": {"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15}
+	}`, req.Model, reply)
+}
+
+func newFakeChatServer(t *testing.T, replies map[string]string) (*fakeChatServer, string) {
+	t.Helper()
+	fake := &fakeChatServer{t: t, replies: replies}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	return fake, srv.URL + "/v1"
+}
+
+func TestChatClientKeepsConversationLocally(t *testing.T) {
+	fake, baseURL := newFakeChatServer(t, map[string]string{
+		aiCategoryMenu:   `{"plans":[{"cuisine":"Thai","anchor_ingredient":"Chicken Thighs","technique":"stir-fry","side_vegetable":"Green Beans","fancy":false,"day":"","leftovers":"","recipe_instructions":[]}],"chef_note_suggestion":"less spicy"}`,
+		aiCategoryRecipe: `{"title":"Basil Chicken","description":"Quick.","cook_time":"25 minutes","servings":2,"cost_estimate":"$10","ingredients":[{"id":"1","name":"Chicken Thighs","quantity":"1 lb"}],"instructions":["Stir-fry."],"health":"500 calories","drink_pairing":"Riesling","wine_styles":["Riesling"]}`,
+		"":               "Yes, use tofu.",
+	})
+	recorder := &capturePromptRecorder{}
+	client := NewChatClient(baseURL, "", "local-model", nil, nil, recorder)
+	require.NoError(t, client.Ready(t.Context()))
+
+	ingredients := []InputIngredient{{ProductID: "1", Description: "Chicken Thighs"}, {ProductID: "2", Description: "Green Beans"}}
+	plan, err := client.CreateMenuPlan(t.Context(), &locationtypes.Location{State: "WA"}, ingredients, nil, time.Date(2026, time.May, 11, 0, 0, 0, 0, time.UTC), nil, 1)
+	require.NoError(t, err)
+	require.Len(t, plan.Plans, 1)
+	assert.Regexp(t, `^chat_\w+$`, plan.ResponseID)
+
+	recipe, err := client.GenerateRecipe(t.Context(), plan.Plans[0].Instructions(), plan.ResponseRef())
+	require.NoError(t, err)
+	assert.Equal(t, "Basil Chicken", recipe.Title)
+	assert.NotEqual(t, plan.ResponseID, recipe.ResponseID)
+	require.NotNil(t, recipe.Nutrition, "computed after generation like the OpenAI client")
+	require.NotNil(t, recorder.record)
+	assert.Equal(t, recipe.ResponseID, recorder.record.ResponseID)
+	assert.Equal(t, plan.ResponseID, recorder.record.PreviousResponseID)
+
+	answer, err := client.AskQuestion(t.Context(), "Can I use tofu?", recipe.ResponseRef())
+	require.NoError(t, err)
+	assert.Equal(t, "Yes, use tofu.", answer.Answer)
+
+	require.Len(t, fake.requests, 3)
+	recipeReq := fake.requests[1]
+	assert.Equal(t, "local-model", recipeReq.Model)
+	assert.Equal(t, "system", recipeReq.Messages[0].Role)
+	assert.Equal(t, systemMessage, recipeReq.Messages[0].Content, "instructions are replaced, not replayed")
+	var roles []string
+	for _, msg := range recipeReq.Messages {
+		roles = append(roles, msg.Role)
+	}
+	assert.Contains(t, roles, "assistant", "the menu plan is replayed to the recipe request")
+
+	questionReq := fake.requests[2]
+	assert.Nil(t, questionReq.ResponseFormat)
+	assert.Nil(t, questionReq.Provider)
+	last := questionReq.Messages[len(questionReq.Messages)-1]
+	assert.Equal(t, "Can I use tofu?", last.Content)
+	assert.Equal(t, fake.replies[aiCategoryRecipe], questionReq.Messages[len(questionReq.Messages)-2].Content)
+}
+
+func TestChatClientUnknownResponseID(t *testing.T) {
+	fake, baseURL := newFakeChatServer(t, nil)
+	client := NewChatClient(baseURL, "", "local-model", nil, nil, nil)
+
+	_, err := client.Regenerate(t.Context(), []string{"less salt"}, ResponseRef{ID: "resp-from-openai"})
+
+	assert.ErrorIs(t, err, ErrConversationNotFound)
+	assert.Empty(t, fake.requests)
+}
+
+func TestLocalIngredientGrader(t *testing.T) {
+	fake, baseURL := newFakeChatServer(t, map[string]string{
+		aiCategoryIngredientGrading: `{"grades":[{"id":"1","score":9,"reason":"whole vegetable"}]}`,
+	})
+	grader := NewLocalIngredientGrader(baseURL, "", "local-model", nil)
+
+	graded, err := grader.GradeIngredients(t.Context(), []InputIngredient{{ProductID: "1", Description: " Rutabaga "}})
+
+	require.NoError(t, err)
+	require.Len(t, graded, 1)
+	assert.Equal(t, 9, graded[0].Grade.Score)
+	assert.Equal(t, "Rutabaga", graded[0].Description)
+	assert.Equal(t, IngredientGradeCacheVersion("local-model"), grader.CacheVersion())
+	assert.Equal(t, ingredientGradeSystemInstruction, fake.requests[0].Messages[0].Content)
+}
+
+func TestLocalCritiquer(t *testing.T) {
+	fake, baseURL := newFakeChatServer(t, map[string]string{
+		"recipe_critique": `{"schema_version":"recipe-critique-v1","overall_score":7,"summary":"Needs salt.","strengths":[],"issues":[],"suggested_fixes":[]}`,
+	})
+	critiquer := NewLocalCritiquer(baseURL, "", "local-model", nil)
+	require.NoError(t, critiquer.Ready(t.Context()))
+
+	got, err := critiquer.CritiqueRecipe(t.Context(), Recipe{Title: "Roast Chicken"})
+
+	require.NoError(t, err)
+	assert.Equal(t, 7, got.OverallScore)
+	assert.Equal(t, "local-model", got.Model)
+	require.Len(t, fake.requests, 1)
+	assert.Nil(t, fake.requests[0].Provider, "OpenRouter routing options stay off local servers")
+}
@@ -32,23 +32,6 @@ func NewClient(apiKey, _ string, httpClient *http.Client, promptRecorder PromptR
 	if promptRecorder == nil {
 		promptRecorder = noopPromptRecorder{}
 	}
-	r := jsonschema.Reflector{
-		DoNotReference: true, // no $defs and no $ref
-		ExpandedStruct: true, // put the root type inline (not a $ref)
-	}
-	recipeSchema := r.Reflect(&Recipe{})
-	recipeSchemaJSON, _ := json.Marshal(recipeSchema)
-	wineSchema := r.Reflect(&WineSelection{})
-	wineSchemaJSON, _ := json.Marshal(wineSchema)
-	menuSchema := r.Reflect(&MenuPlan{})
-	menuSchemaJson, _ := json.Marshal(menuSchema)
-	var recipe map[string]any
-	_ = json.Unmarshal(recipeSchemaJSON, &recipe)
-	var wine map[string]any
-	_ = json.Unmarshal(wineSchemaJSON, &wine)
-	var menu map[string]any
-	_ = json.Unmarshal(menuSchemaJson, &menu)
-
 	opts := []option.RequestOption{option.WithAPIKey(apiKey)}
 	if httpClient != nil {
 		opts = append(opts, option.WithHTTPClient(httpClient))
@@ -57,15 +40,26 @@ func NewClient(apiKey, _ string, httpClient *http.Client, promptRecorder PromptR
 
 	return &client{
 		oai:            aiClient,
-		recipeSchema:   recipe,
-		wineSchema:     wine,
-		menuSchema:     menu,
+		recipeSchema:   reflectSchema(&Recipe{}),
+		wineSchema:     reflectSchema(&WineSelection{}),
+		menuSchema:     reflectSchema(&MenuPlan{}),
 		model:          defaultRecipeModel,
 		wineModel:      defaultWineModel,
 		promptRecorder: promptRecorder,
 	}
 }
 
+func reflectSchema(v any) map[string]any {
+	r := jsonschema.Reflector{
+		DoNotReference: true, // no $defs and no $ref
+		ExpandedStruct: true, // put the root type inline (not a $ref)
+	}
+	schemaJSON, _ := json.Marshal(r.Reflect(v))
+	var schema map[string]any
+	_ = json.Unmarshal(schemaJSON, &schema)
+	return schema
+}
+
 func scheme(schema map[string]any) responses.ResponseTextConfigParam {
 	return responses.ResponseTextConfigParam{
 		Format: responses.ResponseFormatTextConfigUnionParam{
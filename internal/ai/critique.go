@@ -37,6 +37,7 @@ Judge the recipe like an experienced chef helping create recipes to teach home c
 - account for ingredients that are already brined or cured and user requests to reduce sodium; because salt crystal sizes vary, evaluate salt by weight when available rather than assuming equal volume measures across salt types
 - report a material deviation from these salt starting points as a flavor issue and suggest a corrected amount at the proper cooking stage; if it leaves a main component substantially underseasoned or oversalted, keep the overall score below 8 so the recipe is revised
 - are the timing and cost estimates plausible
+- when the recipe includes nutrition, it was computed per serving from the ingredient quantities with a USDA nutrient table and leaves out anything listed under uncounted; report a nutrition issue when the health sentence's calories or macros clearly disagree with it
 - does the stated cook_time match the total time implied by all instruction steps, including prep, resting, and passive cooking
 - does the dish sound balanced, appealing, and well plated
 - are there any food safety or recipe logic issues
@@ -65,6 +66,8 @@ type critiquer struct {
 	model  string
 	schema map[string]any
 	client openai.Client
+	// local servers get neither OpenRouter's routing options nor its key check.
+	local bool
 }
 
 func NewCritiquer(apiKey, model string, httpClient *http.Client) *critiquer {
@@ -90,7 +93,24 @@ func NewCritiquer(apiKey, model string, httpClient *http.Client) *critiquer {
 	}
 }
 
+// NewLocalCritiquer critiques through an OpenAI-compatible chat-completions server
+// such as llama.cpp, Ollama or vLLM instead of OpenRouter.
+func NewLocalCritiquer(baseURL, apiKey, model string, httpClient *http.Client) *critiquer {
+	return &critiquer{
+		client: newLocalOpenAIClient(baseURL, apiKey, httpClient),
+		model:  strings.TrimSpace(model),
+		schema: recipeCritiqueJSONSchema(),
+		local:  true,
+	}
+}
+
 func (c *critiquer) Ready(ctx context.Context) error {
+	if c.local {
+		if _, err := c.client.Models.List(ctx); err != nil {
+			return fmt.Errorf("check local critique server: %w", err)
+		}
+		return nil
+	}
 	if err := c.client.Get(ctx, "key", nil, nil); err != nil {
 		return fmt.Errorf("check OpenRouter API key: %w", err)
 	}
@@ -98,11 +118,18 @@ func (c *critiquer) Ready(ctx context.Context) error {
 }
 
 func (c *critiquer) CritiqueRecipe(ctx context.Context, recipe Recipe) (*RecipeCritique, error) {
+	if err := allowUsage(ctx, aiCategoryCritique); err != nil {
+		return nil, err
+	}
 	prompt, err := buildRecipeCritiquePrompt(recipe)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build recipe critique prompt: %w", err)
 	}
 
+	var opts []option.RequestOption
+	if !c.local {
+		opts = append(opts, option.WithJSONSet("provider.require_parameters", true))
+	}
 	start := time.Now()
 	resp, err := c.client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
 		Model: c.model,
@@ -119,7 +146,7 @@ func (c *critiquer) CritiqueRecipe(ctx context.Context, recipe Recipe) (*RecipeC
 				},
 			},
 		},
-	}, option.WithJSONSet("provider.require_parameters", true))
+	}, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to critique recipe: %w", err)
 	}
@@ -131,6 +158,7 @@ func (c *critiquer) CritiqueRecipe(ctx context.Context, recipe Recipe) (*RecipeC
 		"latencyMS", time.Since(start).Milliseconds(),
 		openRouterUsageLogAttr(resp),
 	)
+	recordUsage(ctx, chatUsage(aiCategoryCritique, c.model, resp, c.local))
 
 	if len(resp.Choices) == 0 {
 		return nil, fmt.Errorf("empty response from OpenRouter critique model")
@@ -53,6 +53,22 @@ func TestBuildRecipeCritiquePrompt(t *testing.T) {
 	}
 }
 
+func TestBuildRecipeCritiquePromptIncludesComputedNutrition(t *testing.T) {
+	recipe := Recipe{
+		Title:       "Rice Bowl",
+		Servings:    2,
+		Ingredients: []Ingredient{{Name: "Jasmine rice", Quantity: "1 cup"}, {Name: "Gochujang", Quantity: "2 tbsp"}},
+		Health:      "About 150 calories per serving.",
+	}
+	recipe.ComputeNutrition(recipe.Servings)
+
+	prompt, err := buildRecipeCritiquePrompt(recipe)
+	require.NoError(t, err)
+	assert.Contains(t, prompt, `"calories": 338`)
+	assert.Contains(t, prompt, `"uncounted": [`)
+	assert.Contains(t, recipeCritiqueSystemInstruction, "report a nutrition issue when the health sentence's calories or macros clearly disagree with it")
+}
+
 func TestRecipeCritiqueSystemInstructionChecksPrepFirstAndTotalTiming(t *testing.T) {
 	for _, want := range []string{
 		"do the instructions begin with preparation before active cooking starts",
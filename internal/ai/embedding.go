@@ -0,0 +1,187 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"careme/internal/config"
+
+	openai "github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
+)
+
+// Embedder turns texts into vectors that point the same way when the texts are
+// about the same thing. Vectors from different Versions can't be compared.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	Version() string
+}
+
+var (
+	_ Embedder = (*embedder)(nil)
+	_ Embedder = HashingEmbedder{}
+)
+
+// NewEmbedderFromConfig uses the configured embedding model, from the local
+// server when there is one, and falls back to HashingEmbedder without one.
+func NewEmbedderFromConfig(cfg *config.Config, httpClient *http.Client) Embedder {
+	if cfg == nil {
+		return NewHashingEmbedder()
+	}
+	model := strings.TrimSpace(cfg.Embedding.Model)
+	switch {
+	case model == "":
+		return NewHashingEmbedder()
+	case cfg.LocalAI.IsEnabled():
+		return NewLocalEmbedder(cfg.LocalAI.BaseURL, cfg.LocalAI.APIKey, model, httpClient)
+	case strings.TrimSpace(cfg.AI.APIKey) != "":
+		return NewEmbedder(cfg.AI.APIKey, model, httpClient)
+	default:
+		return NewHashingEmbedder()
+	}
+}
+
+// embedder calls an OpenAI-compatible embeddings endpoint.
+type embedder struct {
+	oai   openai.Client
+	model string
+}
+
+func NewEmbedder(apiKey, model string, httpClient *http.Client) *embedder {
+	opts := []option.RequestOption{option.WithAPIKey(apiKey)}
+	if httpClient != nil {
+		opts = append(opts, option.WithHTTPClient(httpClient))
+	}
+	return &embedder{oai: openai.NewClient(opts...), model: strings.TrimSpace(model)}
+}
+
+func NewLocalEmbedder(baseURL, apiKey, model string, httpClient *http.Client) *embedder {
+	return &embedder{oai: newLocalOpenAIClient(baseURL, apiKey, httpClient), model: strings.TrimSpace(model)}
+}
+
+func (e *embedder) Version() string {
+	return e.model
+}
+
+func (e *embedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	if err := allowUsage(ctx, aiCategoryEmbedding); err != nil {
+		return nil, err
+	}
+	resp, err := e.oai.Embeddings.New(ctx, openai.EmbeddingNewParams{
+		Input: openai.EmbeddingNewParamsInputUnion{OfArrayOfStrings: texts},
+		Model: e.model,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed %d texts: %w", len(texts), err)
+	}
+	recordUsage(ctx, Usage{Category: aiCategoryEmbedding, Model: e.model, InputTokens: resp.Usage.PromptTokens})
+
+	vectors := make([][]float32, len(texts))
+	for _, d := range resp.Data {
+		if d.Index < 0 || int(d.Index) >= len(texts) {
+			return nil, fmt.Errorf("embedding index %d out of range", d.Index)
+		}
+		vector := make([]float32, len(d.Embedding))
+		for i, f := range d.Embedding {
+			vector[i] = float32(f)
+		}
+		vectors[d.Index] = vector
+	}
+	for i, v := range vectors {
+		if len(v) == 0 {
+			return nil, fmt.Errorf("no embedding for text %d", i)
+		}
+	}
+	return vectors, nil
+}
+
+const defaultHashingDimensions = 512
+
+// HashingEmbedder hashes each word of a text into one of Dimensions buckets. It
+// needs no model, so it always works, but it only knows two recipes share
+// words, not that salmon and trout are both fish.
+type HashingEmbedder struct {
+	Dimensions int
+}
+
+func NewHashingEmbedder() HashingEmbedder {
+	return HashingEmbedder{Dimensions: defaultHashingDimensions}
+}
+
+func (h HashingEmbedder) Version() string {
+	return "hashing-" + strconv.Itoa(h.Dimensions)
+}
+
+func (h HashingEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	if h.Dimensions <= 0 {
+		return nil, errors.New("hashing embedder needs dimensions")
+	}
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = h.embed(text)
+	}
+	return vectors, nil
+}
+
+func (h HashingEmbedder) embed(text string) []float32 {
+	vector := make([]float32, h.Dimensions)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		// short words are mostly "a", "of" and "to"; they'd make everything alike.
+		if len(word) < 3 {
+			continue
+		}
+		word = strings.TrimSuffix(word, "s")
+		f := fnv.New32a()
+		_, _ = f.Write([]byte(word))
+		sum := f.Sum32()
+		// a sign bit keeps collisions from only ever adding up.
+		sign := float32(1)
+		if sum&(1<<31) != 0 {
+			sign = -1
+		}
+		vector[int(sum%uint32(h.Dimensions))] += sign
+	}
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v * v)
+	}
+	if norm == 0 {
+		return vector
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for i := range vector {
+		vector[i] *= scale
+	}
+	return vector
+}
+
+// CosineSimilarity is 1 for vectors pointing the same way and 0 for unrelated
+// ones. Vectors of different lengths aren't comparable and get 0.
+func CosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
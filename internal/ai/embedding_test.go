@@ -0,0 +1,28 @@
+package ai
+
+import (
+	"testing"
+
+	"careme/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashingEmbedder(t *testing.T) {
+	vectors, err := NewHashingEmbedder().Embed(t.Context(), []string{"Salmon tacos & a lime", "salmon taco, lime", "Braised leeks"})
+	require.NoError(t, err)
+	assert.InDelta(t, 1, CosineSimilarity(vectors[0], vectors[1]), 0.0001, "short words and plurals don't count")
+	assert.Less(t, CosineSimilarity(vectors[0], vectors[2]), 0.5)
+	assert.Zero(t, CosineSimilarity(vectors[0], vectors[0][:10]), "different lengths don't compare")
+}
+
+func TestNewEmbedderFromConfig(t *testing.T) {
+	assert.Equal(t, "hashing-512", NewEmbedderFromConfig(nil, nil).Version())
+	assert.Equal(t, "hashing-512", NewEmbedderFromConfig(&config.Config{AI: config.AIConfig{APIKey: "key"}}, nil).Version(), "no model, no calls")
+	assert.Equal(t, "hashing-512", NewEmbedderFromConfig(&config.Config{Embedding: config.EmbeddingConfig{Model: "text-embedding-3-small"}}, nil).Version(), "nothing to call it on")
+	assert.Equal(t, "text-embedding-3-small", NewEmbedderFromConfig(&config.Config{
+		AI:        config.AIConfig{APIKey: "key"},
+		Embedding: config.EmbeddingConfig{Model: " text-embedding-3-small "},
+	}, nil).Version())
+}
@@ -42,6 +42,9 @@ Do not invent prices, brands, farms, or ingredients that are not visible. Return
 // url here can be public remote or data: url that bin64 encodes the image
 // https://developers.openai.com/api/docs/guides/images-vision?format=base64-encoded
 func (c *client) ExtractFarmersMarketIngredients(ctx context.Context, imageDataURL string) ([]InputIngredient, error) {
+	if err := allowUsage(ctx, aiCategoryFarmersMarket); err != nil {
+		return nil, err
+	}
 	imageDataURL = strings.TrimSpace(imageDataURL)
 	if imageDataURL == "" {
 		return nil, fmt.Errorf("image data URL is required")
@@ -71,6 +74,7 @@ func (c *client) ExtractFarmersMarketIngredients(ctx context.Context, imageDataU
 		return nil, fmt.Errorf("extract farmers market ingredients: %w", err)
 	}
 	slog.InfoContext(ctx, "API usage", "ai_category", aiCategoryFarmersMarket, "model", farmersMarketIngredientModel, responseUsageLogAttr(farmersMarketIngredientModel, resp.Usage))
+	recordUsage(ctx, responseUsage(aiCategoryFarmersMarket, farmersMarketIngredientModel, resp.Usage))
 
 	var parsed farmersMarketIngredientResponse
 	if err := json.Unmarshal([]byte(resp.OutputText()), &parsed); err != nil {
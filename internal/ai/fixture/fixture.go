@@ -0,0 +1,269 @@
+// Package fixture records model API traffic to disk and replays it without the
+// network, so the real generation paths can run in tests against golden fixtures.
+package fixture
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+type Mode string
+
+const (
+	// Record passes requests through and writes each exchange to the fixture dir.
+	Record Mode = "record"
+	// Replay serves only what was recorded and never touches the network.
+	Replay Mode = "replay"
+)
+
+const (
+	modeEnv    = "AI_FIXTURES"
+	dirEnv     = "AI_FIXTURES_DIR"
+	defaultDir = "ai-fixtures"
+)
+
+// Exchange is one recorded request and its response as written to disk.
+type Exchange struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method string          `json:"method"`
+	URL    string          `json:"url"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+type RecordedResponse struct {
+	Status      int             `json:"status"`
+	ContentType string          `json:"content_type,omitempty"`
+	Body        json.RawMessage `json:"body,omitempty"`
+	// BodyText holds bodies that aren't JSON.
+	BodyText string `json:"body_text,omitempty"`
+}
+
+// Transport records or replays exchanges keyed by a hash of the normalized request.
+type Transport struct {
+	mode   Mode
+	dir    string
+	next   http.RoundTripper
+	redact []*regexp.Regexp
+}
+
+// NewTransport wraps next. Headers are never part of the key, and JSON bodies
+// are compared with their keys sorted and every match of redact blanked out of
+// their strings, for prompt text that varies between otherwise identical requests.
+func NewTransport(mode Mode, dir string, next http.RoundTripper, redact ...*regexp.Regexp) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Transport{mode: mode, dir: dir, next: next, redact: redact}
+}
+
+// FromEnv wraps next when AI_FIXTURES is record or replay, with recordings under
+// AI_FIXTURES_DIR. Otherwise it returns next untouched.
+func FromEnv(next http.RoundTripper, redact ...*regexp.Regexp) (http.RoundTripper, error) {
+	mode := Mode(strings.TrimSpace(os.Getenv(modeEnv)))
+	switch mode {
+	case "":
+		return next, nil
+	case Record, Replay:
+	default:
+		return nil, fmt.Errorf("%s must be %q or %q, got %q", modeEnv, Record, Replay, mode)
+	}
+	dir := strings.TrimSpace(os.Getenv(dirEnv))
+	if dir == "" {
+		dir = defaultDir
+	}
+	slog.Info("AI fixtures enabled", "mode", mode, "dir", dir)
+	return NewTransport(mode, dir, next, redact...), nil
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		if err != nil {
+			return nil, fmt.Errorf("read request body: %w", err)
+		}
+		_ = req.Body.Close()
+	}
+	recorded, err := t.normalize(req, body)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(t.dir, fixtureName(recorded))
+
+	if t.mode == Replay {
+		return t.replay(req, path, recorded)
+	}
+
+	outgoing := req.Clone(req.Context())
+	outgoing.Body = io.NopCloser(bytes.NewReader(body))
+	outgoing.ContentLength = int64(len(body))
+	resp, err := t.next.RoundTrip(outgoing)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	exchange := Exchange{
+		Request: recorded,
+		Response: RecordedResponse{
+			Status:      resp.StatusCode,
+			ContentType: resp.Header.Get("Content-Type"),
+		},
+	}
+	if json.Valid(respBody) {
+		exchange.Response.Body = respBody
+	} else {
+		exchange.Response.BodyText = string(respBody)
+	}
+	if err := writeExchange(path, exchange); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (t *Transport) replay(req *http.Request, path string, recorded RecordedRequest) (*http.Response, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// a 404 rather than an error so clients report it instead of retrying.
+			slog.ErrorContext(req.Context(), "no AI fixture for request", "method", recorded.Method, "url", recorded.URL, "path", path)
+			return newResponse(req, http.StatusNotFound, "application/json",
+				fmt.Sprintf(`{"error":{"message":%q}}`, "no recorded fixture "+filepath.Base(path)+" for "+recorded.Method+" "+recorded.URL)), nil
+		}
+		return nil, err
+	}
+	var exchange Exchange
+	if err := json.Unmarshal(data, &exchange); err != nil {
+		return nil, fmt.Errorf("decode fixture %s: %w", path, err)
+	}
+	body := exchange.Response.BodyText
+	if len(exchange.Response.Body) > 0 {
+		body = string(exchange.Response.Body)
+	}
+	return newResponse(req, exchange.Response.Status, exchange.Response.ContentType, body), nil
+}
+
+func newResponse(req *http.Request, status int, contentType, body string) *http.Response {
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+func (t *Transport) normalize(req *http.Request, body []byte) (RecordedRequest, error) {
+	u := *req.URL
+	u.RawQuery = u.Query().Encode() // sorted
+	u.User = nil
+	recorded := RecordedRequest{Method: req.Method, URL: u.String()}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return recorded, nil
+	}
+
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		// not JSON, so keep it as a JSON string.
+		text, _ := json.Marshal(string(body))
+		recorded.Body = text
+		return recorded, nil
+	}
+	normalized, err := json.Marshal(t.redactStrings(v))
+	if err != nil {
+		return RecordedRequest{}, fmt.Errorf("normalize request body: %w", err)
+	}
+	recorded.Body = normalized
+	return recorded, nil
+}
+
+func (t *Transport) redactStrings(v any) any {
+	switch v := v.(type) {
+	case string:
+		for _, re := range t.redact {
+			v = re.ReplaceAllString(v, "<redacted>")
+		}
+		return v
+	case []any:
+		for i := range v {
+			v[i] = t.redactStrings(v[i])
+		}
+		return v
+	case map[string]any:
+		for k := range v {
+			v[k] = t.redactStrings(v[k])
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+// fixtureName leads with the host and path so a fixture dir is browsable.
+func fixtureName(r RecordedRequest) string {
+	sum := sha256.Sum256([]byte(r.Method + "\n" + r.URL + "\n" + string(r.Body)))
+	slug := r.URL
+	if i := strings.Index(slug, "://"); i >= 0 {
+		slug = slug[i+3:]
+	}
+	if i := strings.IndexByte(slug, '?'); i >= 0 {
+		slug = slug[:i]
+	}
+	slug = strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(slug), "-"), "-")
+	return slug + "-" + hex.EncodeToString(sum[:12]) + ".json"
+}
+
+func writeExchange(path string, exchange Exchange) error {
+	data, err := json.MarshalIndent(exchange, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode fixture: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create fixture dir: %w", err)
+	}
+	// write then rename so a parallel replay never reads half a fixture.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".fixture-*")
+	if err != nil {
+		return fmt.Errorf("create fixture: %w", err)
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("write fixture: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("write fixture: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
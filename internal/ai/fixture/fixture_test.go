@@ -0,0 +1,107 @@
+package fixture
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func post(t *testing.T, client *http.Client, url, body string, header http.Header) (int, string) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodPost, url, strings.NewReader(body))
+	require.NoError(t, err)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, resp.Body.Close())
+	}()
+	got, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(got)
+}
+
+func TestRecordThenReplayWithoutNetwork(t *testing.T) {
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"echo":` + string(body) + `}`))
+	}))
+	dir := t.TempDir()
+	cuisines := regexp.MustCompile(`^cuisines: .*$`)
+
+	recorder := &http.Client{Transport: NewTransport(Record, dir, upstream.Client().Transport, cuisines)}
+	status, body := post(t, recorder, upstream.URL+"/v1/responses?b=2&a=1", `{"model":"m","input":["cuisines: Thai, Greek"]}`, http.Header{"Authorization": {"Bearer real-key"}})
+	require.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"echo":{"model":"m","input":["cuisines: Thai, Greek"]}}`, body)
+	upstream.Close()
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.True(t, strings.HasPrefix(files[0].Name(), "127-0-0-1-"), files[0].Name())
+	data, err := os.ReadFile(dir + "/" + files[0].Name())
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "real-key", "headers aren't recorded")
+
+	replayer := &http.Client{Transport: NewTransport(Replay, dir, nil, cuisines)}
+	// key order, query order, headers and the redacted prompt don't matter.
+	status, body = post(t, replayer, upstream.URL+"/v1/responses?a=1&b=2", `{"input":["cuisines: Basque, Cuban"],"model":"m"}`, http.Header{"Authorization": {"Bearer other-key"}})
+	require.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"echo":{"model":"m","input":["cuisines: Thai, Greek"]}}`, body)
+	assert.Equal(t, int32(1), calls.Load())
+
+	status, body = post(t, replayer, upstream.URL+"/v1/responses", `{"model":"other"}`, nil)
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Contains(t, body, "no recorded fixture")
+}
+
+func TestRecordKeepsNonJSONBodies(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte("slow down"))
+	}))
+	defer upstream.Close()
+	dir := t.TempDir()
+
+	status, _ := post(t, &http.Client{Transport: NewTransport(Record, dir, upstream.Client().Transport)}, upstream.URL+"/v1/key", "plain", nil)
+	require.Equal(t, http.StatusTooManyRequests, status)
+
+	status, body := post(t, &http.Client{Transport: NewTransport(Replay, dir, nil)}, upstream.URL+"/v1/key", "plain", nil)
+	assert.Equal(t, http.StatusTooManyRequests, status)
+	assert.Equal(t, "slow down", body)
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv(modeEnv, "")
+	next := http.DefaultTransport
+	got, err := FromEnv(next)
+	require.NoError(t, err)
+	assert.Equal(t, next, got)
+
+	t.Setenv(modeEnv, "replay")
+	t.Setenv(dirEnv, "testdata/golden")
+	got, err = FromEnv(next)
+	require.NoError(t, err)
+	transport, ok := got.(*Transport)
+	require.True(t, ok, "got %T", got)
+	assert.Equal(t, Replay, transport.mode)
+	assert.Equal(t, "testdata/golden", transport.dir)
+
+	t.Setenv(modeEnv, "rewind")
+	_, err = FromEnv(next)
+	assert.Error(t, err)
+}
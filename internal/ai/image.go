@@ -34,6 +34,9 @@ const (
 )
 
 func (c *client) GenerateRecipeImage(ctx context.Context, recipe Recipe) (*GeneratedImage, error) {
+	if err := allowUsage(ctx, aiCategoryImage); err != nil {
+		return nil, err
+	}
 	prompt, err := buildRecipeImagePrompt(recipe)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build recipe image prompt: %w", err)
@@ -52,6 +55,7 @@ func (c *client) GenerateRecipeImage(ctx context.Context, recipe Recipe) (*Gener
 	}
 
 	slog.InfoContext(ctx, "API usage", "ai_category", aiCategoryImage, "model", string(recipeImageModel), imageUsageLogAttr(string(recipeImageModel), resp.Usage))
+	recordUsage(ctx, imageUsage(string(recipeImageModel), resp.Usage))
 	if len(resp.Data) == 0 {
 		return nil, fmt.Errorf("image generation returned no images")
 	}
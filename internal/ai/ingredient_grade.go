@@ -2,6 +2,7 @@ package ai
 
 import (
 	"context"
+	_ "embed"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -72,6 +73,22 @@ Important calibration:
 
 Return JSON only. Preserve each input id/index exactly. Be concise.`
 
+// ingredientGradeCalibration is appended to the grading prompt. It's written by
+// `ingredientreview calibrate` from reviewed grades and checked in, so a new
+// calibration is a new cache version and items get regraded under it.
+//
+//go:embed ingredient_grade_calibration.txt
+var ingredientGradeCalibration string
+
+// IngredientGradeInstructions is the grading prompt with calibration appended.
+func IngredientGradeInstructions(calibration string) string {
+	calibration = strings.TrimSpace(calibration)
+	if calibration == "" {
+		return ingredientGradeSystemInstruction
+	}
+	return ingredientGradeSystemInstruction + "\n\n" + calibration
+}
+
 type InputIngredient struct {
 	ProductID    string           `json:"id,omitempty"`
 	AisleNumber  string           `json:"number,omitempty"` // this is a dumb json name fix it later
@@ -82,6 +99,19 @@ type InputIngredient struct {
 	PriceSale    *float32         `json:"salePrice,omitempty"`
 	Categories   []string         `json:"categories,omitempty"`
 	Grade        *IngredientGrade `json:"grade,omitempty"`
+	PriceHistory *PriceHistory    `json:"priceHistory,omitempty"`
+	// LocationID is the store an item came from when a list draws on more than one.
+	LocationID string `json:"locationId,omitempty"`
+}
+
+// PriceHistory is what the store charged for an item on earlier staples
+// fetches. Deal means today's price is well under the usual one, which a
+// store's own sale price doesn't always mean.
+type PriceHistory struct {
+	Days    int     `json:"days"`
+	Low     float32 `json:"low"`
+	Average float32 `json:"average"`
+	Deal    bool    `json:"deal,omitempty"`
 }
 
 func (ii InputIngredient) PercentOff() float32 {
@@ -91,6 +121,27 @@ func (ii InputIngredient) PercentOff() float32 {
 	return (1.0 - (*ii.PriceSale / *ii.PriceRegular)) * 100
 }
 
+// Price is what the item costs today: the sale price if there is one.
+func (ii InputIngredient) Price() *float32 {
+	if ii.PriceSale != nil {
+		return ii.PriceSale
+	}
+	return ii.PriceRegular
+}
+
+func (ii InputIngredient) Deal() bool {
+	return ii.PriceHistory != nil && ii.PriceHistory.Deal
+}
+
+// PercentBelowUsual compares today's price with the average of earlier fetches.
+func (ii InputIngredient) PercentBelowUsual() float32 {
+	price := ii.Price()
+	if price == nil || ii.PriceHistory == nil || ii.PriceHistory.Average <= 0 {
+		return 0
+	}
+	return (1.0 - (*price / ii.PriceHistory.Average)) * 100
+}
+
 type IngredientGrade struct {
 	Score  int    `json:"score"`
 	Reason string `json:"reason"`
@@ -134,6 +185,7 @@ type ingredientBatchGradeResponse struct {
 
 type ingredientGrader struct {
 	model        string
+	instructions string
 	cacheVersion string
 	schema       map[string]any
 	oai          openai.Client
@@ -152,7 +204,7 @@ func IngredientGradeCacheVersion(model string) string {
 	if model == "" {
 		model = defaultIngredientGradeModel
 	}
-	return ingredientGradeCacheVersion(model, ingredientGradeSystemInstruction)
+	return ingredientGradeCacheVersion(model, IngredientGradeInstructions(ingredientGradeCalibration))
 }
 
 func NewIngredientGrader(apiKey, model string, httpClient *http.Client) *ingredientGrader {
@@ -169,6 +221,7 @@ func NewIngredientGrader(apiKey, model string, httpClient *http.Client) *ingredi
 	return &ingredientGrader{
 		oai:          aiClient,
 		model:        model,
+		instructions: IngredientGradeInstructions(ingredientGradeCalibration),
 		cacheVersion: IngredientGradeCacheVersion(model),
 		schema:       ingredientGradeJSONSchema(),
 	}
@@ -178,20 +231,24 @@ func (g *ingredientGrader) CacheVersion() string {
 	return g.cacheVersion
 }
 
+// WithCalibration is the grader with a different calibration, for trying one
+// against reviewed grades before it's checked in.
+func (g *ingredientGrader) WithCalibration(calibration string) *ingredientGrader {
+	calibrated := *g
+	calibrated.instructions = IngredientGradeInstructions(calibration)
+	calibrated.cacheVersion = ingredientGradeCacheVersion(g.model, calibrated.instructions)
+	return &calibrated
+}
+
 func (g *ingredientGrader) GradeIngredients(ctx context.Context, ingredients []InputIngredient) ([]InputIngredient, error) {
 	if len(ingredients) == 0 {
 		return nil, nil
 	}
 
-	items := make([]InputIngredient, len(ingredients))
-	for i, ingredient := range ingredients {
-		item := NormalizeInputIngredient(ingredient)
-		if item.Grade != nil {
-			return nil, fmt.Errorf("already graded ingredient %s", item.ProductID)
-		}
-		items[i] = item
+	items, err := normalizeUngradedIngredients(ingredients)
+	if err != nil {
+		return nil, err
 	}
-
 	prompt, err := buildIngredientGradePrompt(items)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build ingredient grading prompt: %w", err)
@@ -200,7 +257,7 @@ func (g *ingredientGrader) GradeIngredients(ctx context.Context, ingredients []I
 	resp, err := g.oai.Responses.New(ctx, responses.ResponseNewParams{
 		Model:        g.model,
 		Reasoning:    noReasoning(),
-		Instructions: openai.String(ingredientGradeSystemInstruction),
+		Instructions: openai.String(g.instructions),
 		Input: responses.ResponseNewParamsInputUnion{
 			OfInputItemList: []responses.ResponseInputItemUnionParam{user(prompt)},
 		},
@@ -210,10 +267,23 @@ func (g *ingredientGrader) GradeIngredients(ctx context.Context, ingredients []I
 		return nil, fmt.Errorf("failed to grade ingredients: %w", err)
 	}
 	slog.InfoContext(ctx, "Ingredient grading usage", "ai_category", aiCategoryIngredientGrading, "model", g.model, responseUsageLogAttr(g.model, resp.Usage))
+	recordUsage(ctx, responseUsage(aiCategoryIngredientGrading, g.model, resp.Usage))
 
 	return parseIngredientGrades(ctx, resp.OutputText(), items)
 }
 
+func normalizeUngradedIngredients(ingredients []InputIngredient) ([]InputIngredient, error) {
+	items := make([]InputIngredient, len(ingredients))
+	for i, ingredient := range ingredients {
+		item := NormalizeInputIngredient(ingredient)
+		if item.Grade != nil {
+			return nil, fmt.Errorf("already graded ingredient %s", item.ProductID)
+		}
+		items[i] = item
+	}
+	return items, nil
+}
+
 func buildIngredientGradePrompt(items []InputIngredient) (string, error) {
 	type ingredientGradePromptItem struct {
 		ProductID   string `json:"id"`
@@ -4,28 +4,44 @@ import (
 	"encoding/csv"
 	"fmt"
 	"io"
+
+	"github.com/samber/lo"
 )
 
 // InputIngredientsToTSV writes only the ingredient information useful in AI prompts.
+// UsualPrice and Deal columns are only added once some ingredient has a price history.
 func InputIngredientsToTSV(ingredients []InputIngredient, w io.Writer) error {
 	csvw := csv.NewWriter(w)
 	csvw.Comma = '\t'
 	header := []string{"ProductId", "Brand", "Description", "Size", "PriceRegular", "PriceSale"}
+	withHistory := lo.ContainsBy(ingredients, func(ingredient InputIngredient) bool {
+		return ingredient.PriceHistory != nil
+	})
+	if withHistory {
+		header = append(header, "UsualPrice", "Deal")
+	}
 	if err := csvw.Write(header); err != nil {
 		return err
 	}
 	for _, ingredient := range ingredients {
-		priceSale := ingredient.PriceSale
-		if priceSale == nil {
-			priceSale = ingredient.PriceRegular
-		}
 		row := []string{
 			ingredient.ProductID,
 			ingredient.Brand,
 			ingredient.Description,
 			ingredient.Size,
 			priceToString(ingredient.PriceRegular),
-			priceToString(priceSale),
+			priceToString(ingredient.Price()),
+		}
+		if withHistory {
+			var usualPrice *float32
+			if ingredient.PriceHistory != nil {
+				usualPrice = &ingredient.PriceHistory.Average
+			}
+			deal := ""
+			if ingredient.Deal() {
+				deal = "yes"
+			}
+			row = append(row, priceToString(usualPrice), deal)
 		}
 		if len(header) != len(row) {
 			return fmt.Errorf("header and row length mismatch: %d vs %d", len(header), len(row))
@@ -30,3 +30,35 @@ func TestInputIngredientsToTSV_UsesRegularPriceWhenSaleMissing(t *testing.T) {
 		t.Fatalf("expected regular price copied into sale column, got %q", got)
 	}
 }
+
+func TestInputIngredientsToTSV_AddsUsualPriceOnceThereIsHistory(t *testing.T) {
+	var buf strings.Builder
+	err := InputIngredientsToTSV([]InputIngredient{
+		{
+			ProductID:    "item-1",
+			Description:  "Asparagus",
+			PriceRegular: new(float32(4.99)),
+			PriceSale:    new(float32(2.99)),
+			PriceHistory: &PriceHistory{Days: 5, Low: 3.99, Average: 4.59, Deal: true},
+		},
+		{
+			ProductID:    "item-2",
+			Description:  "Leeks",
+			PriceRegular: new(float32(3.49)),
+			PriceSale:    new(float32(2.99)),
+			PriceHistory: &PriceHistory{Days: 5, Low: 2.99, Average: 3.09},
+		},
+		{ProductID: "item-3", Description: "Ramps", PriceRegular: new(float32(8.99))},
+	}, &buf)
+	if err != nil {
+		t.Fatalf("InputIngredientsToTSV returned error: %v", err)
+	}
+
+	want := "ProductId\tBrand\tDescription\tSize\tPriceRegular\tPriceSale\tUsualPrice\tDeal\n" +
+		"item-1\t\tAsparagus\t\t4.99\t2.99\t4.59\tyes\n" +
+		"item-2\t\tLeeks\t\t3.49\t2.99\t3.09\t\n" +
+		"item-3\t\tRamps\t\t8.99\t8.99\t\t\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("unexpected TSV:\n%s", got)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log/slog"
 	"math/rand/v2"
+	"regexp"
 	"slices"
 	"strings"
 	"time"
@@ -46,6 +47,9 @@ type RecipePlan struct {
 	Technique        string `json:"technique"`
 	SideVegetable    string `json:"side_vegetable"`
 	Fancy            bool   `json:"fancy"`
+	// Day and Leftovers are only filled in for week plans.
+	Day       string `json:"day"`
+	Leftovers string `json:"leftovers"`
 	// so generic this is directive, user instructions, servings, time? Split it up?
 	RecipeInstructions []string `json:"recipe_instructions"`
 }
@@ -60,6 +64,12 @@ func (p RecipePlan) Instructions() []string {
 	if p.Fancy {
 		instructions = append(instructions, "This meal should be fancier, so it can be more expensive, longer, or richer.")
 	}
+	if day := strings.TrimSpace(p.Day); day != "" {
+		instructions = append(instructions, fmt.Sprintf("This recipe is for %s dinner.", day))
+	}
+	if leftovers := strings.TrimSpace(p.Leftovers); leftovers != "" {
+		instructions = append(instructions, "Leftover plan for this recipe: "+leftovers)
+	}
 	for _, instruction := range p.RecipeInstructions {
 		if trimmed := strings.TrimSpace(instruction); trimmed != "" {
 			instructions = append(instructions, "User direction for this recipe: "+trimmed)
@@ -123,6 +133,14 @@ var cuisineList = []string{
 	"Yucatecan",
 }
 
+const cuisineVarietyPrompt = "For extra variety, loosely draw from one of these cuisine styles if it fits the ingredients: "
+
+// VolatilePrompts match prompt text that is random on purpose, so recorded
+// fixtures can ignore it when matching requests.
+var VolatilePrompts = []*regexp.Regexp{
+	regexp.MustCompile(`^` + regexp.QuoteMeta(cuisineVarietyPrompt) + `.*$`),
+}
+
 func pickN(xs []string, n int) []string {
 	if n > len(xs) || n < 0 {
 		panic("can't pick negative or more than we got")
@@ -145,18 +163,23 @@ Example plan: {"cuisine":"French Bistro","anchor_ingredient":"chicken thighs","t
 Try and ensure variety across cuisines, anchor ingredients, techniques, and side vegetables.
 Choose anchor_ingredient and side_vegetable from the provided TSV ingredients. Use the exact ingredient Description text from the TSV. Do not choose an unavailable related ingredient; use the available ingredient's name instead.
 Prioritize seasonal ingredients, sale value, practical weeknight cooking.
+UsualPrice is what the store has charged lately and Deal is yes when today's price is well under it. Favor Deal items over a PriceSale that is no cheaper than usual.
 Assign user directions to recipe_instructions only for the specific recipe plans where they belong. If a user direction applies to every dish, repeat it in every recipe plan's recipe_instructions. If the user mentions having a limited ingredient without asking for it in every dish, assign it to only one fitting recipe.
 Return one chef_note_suggestion: concise example feedback the cook could type before asking for a new menu. Tailor it to the planned dishes, available ingredients, seasonality, and likely tradeoffs. It must be 24 characters or fewer, fit in a mobile text box, and be a fragment, not a sentence. Good examples: "less spicy", "faster dinners", "more vegetables", "no seafood".
+Leave day and leftovers empty unless asked for a week plan. For a week plan, set day to the weekday the plan is cooked and use leftovers for a short note on what carries over from or to another day, e.g. "roast extra chicken for Wednesday tacos".
 Do not write recipe steps, prep instructions, shopping lists, rationale, or prose notes.`
 
 func (c *client) CreateMenuPlan(ctx context.Context, location *locationtypes.Location, saleIngredients []InputIngredient,
 	instructions []string, date time.Time, lastRecipes []string, count int,
 ) (*MenuPlan, error) {
+	if err := allowUsage(ctx, aiCategoryMenu); err != nil {
+		return nil, err
+	}
 	if count < 1 {
 		return nil, fmt.Errorf("menu plan count must be greater than zero")
 	}
 
-	promptMessages, err := c.buildMenuPlanMessages(location, saleIngredients, instructions, date, lastRecipes, count)
+	promptMessages, err := buildMenuPlanMessages(location, saleIngredients, instructions, date, lastRecipes, count)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build menu plan messages: %w", err)
 	}
@@ -191,8 +214,7 @@ func (c *client) CreateMenuPlan(ctx context.Context, location *locationtypes.Loc
 }
 
 func (c *client) regenerateMenuPlanForIngredientMismatch(ctx context.Context, previous ResponseRef, saleIngredients []InputIngredient, validationErr error, count int) (*MenuPlan, error) {
-	feedback := fmt.Sprintf("The previous menu plan used an ingredient that was not available: %v. Regenerate the menu plan. Every anchor_ingredient and side_vegetable must exactly match a Description value from the ingredient TSV already provided.", validationErr)
-	promptMessages := buildRegenerateMenuPlanMessages([]string{feedback}, count)
+	promptMessages := buildRegenerateMenuPlanMessages([]string{ingredientMismatchFeedback(validationErr)}, count)
 	params := responses.ResponseNewParams{
 		Model:              recipePlanModel,
 		PreviousResponseID: openai.String(previous.ID),
@@ -222,7 +244,14 @@ func (c *client) regenerateMenuPlanForIngredientMismatch(ctx context.Context, pr
 	return plan, nil
 }
 
+func ingredientMismatchFeedback(validationErr error) string {
+	return fmt.Sprintf("The previous menu plan used an ingredient that was not available: %v. Regenerate the menu plan. Every anchor_ingredient and side_vegetable must exactly match a Description value from the ingredient TSV already provided.", validationErr)
+}
+
 func (c *client) RegenerateMenuPlan(ctx context.Context, instructions []string, previous ResponseRef, count int) (*MenuPlan, error) {
+	if err := allowUsage(ctx, aiCategoryMenu); err != nil {
+		return nil, err
+	}
 	if previous.ID == "" {
 		return nil, fmt.Errorf("response ID is required for menu plan regeneration")
 	}
@@ -264,6 +293,7 @@ func responseToMenuPlan(ctx context.Context, category, model string, resp *respo
 	plan.ResponseID = resp.ID
 	plan.PromptCacheKey = cacheKey
 	slog.InfoContext(ctx, "API usage", "ai_category", category, "model", model, "plan", lo.Must(json.Marshal(plan)), responseUsageLogAttr(model, resp.Usage))
+	recordUsage(ctx, responseUsage(category, model, resp.Usage))
 	return &plan, nil
 }
 
@@ -300,7 +330,7 @@ func normalizeMenuIngredientName(name string) string {
 	return strings.ToLower(strings.Join(strings.Fields(name), " "))
 }
 
-func (c *client) buildMenuPlanMessages(location *locationtypes.Location, saleIngredients []InputIngredient,
+func buildMenuPlanMessages(location *locationtypes.Location, saleIngredients []InputIngredient,
 	instructions []string, date time.Time, lastRecipes []string, count int,
 ) ([]PromptMessage, error) {
 	var messages []PromptMessage
@@ -317,10 +347,10 @@ func (c *client) buildMenuPlanMessages(location *locationtypes.Location, saleIng
 	messages = append(messages, ingredientsPrompt)
 
 	messages = append(messages,
-		userPromptMessage(fmt.Sprintf("Build %d distinct recipe plans by default. If the user's directions clearly ask for a different number of recipes, return that many plans instead. Keep the plan count between 1 and 6. Fit the available ingredients, seasonality, and price.", count)),
+		userPromptMessage(fmt.Sprintf("Build %d distinct recipe plans by default. If the user's directions clearly ask for a different number of recipes, return that many plans instead. Keep the plan count between 1 and %d. Fit the available ingredients, seasonality, and price.", count, maxPlanCount(count))),
 	)
 	cuisines := pickN(cuisineList, 6)
-	messages = append(messages, userPromptMessage("For extra variety, loosely draw from one of these cuisine styles if it fits the ingredients: "+strings.Join(cuisines, ", ")))
+	messages = append(messages, userPromptMessage(cuisineVarietyPrompt+strings.Join(cuisines, ", ")))
 	// messages = append(messages, userPromptMessage("but don't overlook local cuisine"))
 
 	messages = append(messages, userPromptMessage("If there are 3 or more total recipes, make sure one of the saved meals or those in the meal plan is fancy."))
@@ -345,10 +375,16 @@ func (c *client) buildMenuPlanMessages(location *locationtypes.Location, saleIng
 	return messages, nil
 }
 
+// maxPlanCount caps how many plans the user's directions can ask for. Week plans
+// can ask for up to a dinner a day.
+func maxPlanCount(count int) int {
+	return max(6, count)
+}
+
 func buildRegenerateMenuPlanMessages(instructions []string, count int) []PromptMessage {
 	messages := cleanInstructionMessages(instructions)
 	messages = append(messages,
-		userPromptMessage(fmt.Sprintf("Build %d replacement recipe plan(s) by default. If the user's directions clearly ask for a different number of recipes, return that many plans instead. Keep the plan count between 1 and 6. Avoid passed-on recipe titles and close variants. Fit the user's feedback.", count)),
+		userPromptMessage(fmt.Sprintf("Build %d replacement recipe plan(s) by default. If the user's directions clearly ask for a different number of recipes, return that many plans instead. Keep the plan count between 1 and %d. Avoid passed-on recipe titles and close variants. Fit the user's feedback.", count, maxPlanCount(count))),
 	)
 	messages = append(messages, userPromptMessage("If there are 3 or more total recipes, make sure one of the saved meals or those in the meal plan is fancy."))
 	// messages = append(messages, userPromptMessage("Include one less-common cuisine direction."))
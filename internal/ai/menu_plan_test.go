@@ -16,9 +16,8 @@ import (
 )
 
 func TestBuildMenuPlanMessagesIncludesRecipeParentDefaults(t *testing.T) {
-	client := NewClient("test-key", "ignored", nil, nil)
 	location := &locationtypes.Location{State: "WA"}
-	messages, err := client.buildMenuPlanMessages(location, nil, nil, time.Date(2026, time.May, 11, 0, 0, 0, 0, time.UTC), nil, 3)
+	messages, err := buildMenuPlanMessages(location, nil, nil, time.Date(2026, time.May, 11, 0, 0, 0, 0, time.UTC), nil, 3)
 	if err != nil {
 		t.Fatalf("buildMenuPlanMessages returned error: %v", err)
 	}
@@ -34,9 +33,8 @@ func TestBuildMenuPlanMessagesIncludesRecipeParentDefaults(t *testing.T) {
 }
 
 func TestBuildMenuPlanMessagesUsesRequestedCountAsDefault(t *testing.T) {
-	client := NewClient("test-key", "ignored", nil, nil)
 	location := &locationtypes.Location{State: "WA"}
-	messages, err := client.buildMenuPlanMessages(location, nil, nil, time.Date(2026, time.May, 11, 0, 0, 0, 0, time.UTC), nil, 2)
+	messages, err := buildMenuPlanMessages(location, nil, nil, time.Date(2026, time.May, 11, 0, 0, 0, 0, time.UTC), nil, 2)
 	if err != nil {
 		t.Fatalf("buildMenuPlanMessages returned error: %v", err)
 	}
@@ -56,7 +54,6 @@ func TestBuildMenuPlanMessagesUsesRequestedCountAsDefault(t *testing.T) {
 }
 
 func TestBuildMenuPlanMessagesExcludesIngredientAisleNumbers(t *testing.T) {
-	client := NewClient("test-key", "ignored", nil, nil)
 	location := &locationtypes.Location{State: "WA"}
 	ingredients := []InputIngredient{{
 		ProductID:   "asparagus-1",
@@ -64,7 +61,7 @@ func TestBuildMenuPlanMessagesExcludesIngredientAisleNumbers(t *testing.T) {
 		Description: "Asparagus",
 	}}
 
-	messages, err := client.buildMenuPlanMessages(location, ingredients, nil, time.Date(2026, time.May, 11, 0, 0, 0, 0, time.UTC), nil, 1)
+	messages, err := buildMenuPlanMessages(location, ingredients, nil, time.Date(2026, time.May, 11, 0, 0, 0, 0, time.UTC), nil, 1)
 	require.NoError(t, err)
 
 	body := mustJSON(t, messages)
@@ -74,9 +71,8 @@ func TestBuildMenuPlanMessagesExcludesIngredientAisleNumbers(t *testing.T) {
 }
 
 func TestBuildMenuPlanMessagesIncludesCuisineListInspiration(t *testing.T) {
-	client := NewClient("test-key", "ignored", nil, nil)
 	location := &locationtypes.Location{State: "WA"}
-	messages, err := client.buildMenuPlanMessages(location, nil, nil, time.Date(2026, time.May, 11, 0, 0, 0, 0, time.UTC), nil, 3)
+	messages, err := buildMenuPlanMessages(location, nil, nil, time.Date(2026, time.May, 11, 0, 0, 0, 0, time.UTC), nil, 3)
 	if err != nil {
 		t.Fatalf("buildMenuPlanMessages returned error: %v", err)
 	}
@@ -122,6 +118,34 @@ func TestRecipePlanInstructionsIncludesPlanSpecificUserDirections(t *testing.T)
 	}
 }
 
+func TestRecipePlanInstructionsIncludesWeekPlanDayAndLeftovers(t *testing.T) {
+	plan := RecipePlan{
+		Cuisine:          "Mexican",
+		AnchorIngredient: "chicken",
+		Technique:        "shred",
+		SideVegetable:    "cabbage",
+		Day:              "Wednesday",
+		Leftovers:        "use the rest of Monday's roast chicken",
+	}
+
+	got := plan.Instructions()
+	for _, want := range []string{
+		"This recipe is for Wednesday dinner.",
+		"Leftover plan for this recipe: use the rest of Monday's roast chicken",
+	} {
+		if !slices.Contains(got, want) {
+			t.Fatalf("expected %q in plan instructions, got %v", want, got)
+		}
+	}
+}
+
+func TestBuildRegenerateMenuPlanMessagesAllowsAWeekOfPlans(t *testing.T) {
+	body := mustJSON(t, buildRegenerateMenuPlanMessages(nil, 7))
+	if !strings.Contains(body, "Keep the plan count between 1 and 7") {
+		t.Fatalf("expected week plans to allow seven recipes: %s", body)
+	}
+}
+
 func TestAlignMenuPlanIngredientsAcceptsAvailableIngredientDescriptions(t *testing.T) {
 	plan := &MenuPlan{Plans: []RecipePlan{{
 		Cuisine:          "Italian",
@@ -215,10 +239,9 @@ func TestCreateMenuPlanRegeneratesWhenPlanUsesUnavailableIngredient(t *testing.T
 }
 
 func TestBuildMenuPlanMessagesAddsFancyRequirementForThreePlans(t *testing.T) {
-	client := NewClient("test-key", "ignored", nil, nil)
 	location := &locationtypes.Location{State: "WA"}
 	date := time.Date(2026, time.May, 11, 0, 0, 0, 0, time.UTC)
-	messages, err := client.buildMenuPlanMessages(location, nil, nil, date, nil, 3)
+	messages, err := buildMenuPlanMessages(location, nil, nil, date, nil, 3)
 	if err != nil {
 		t.Fatalf("buildMenuPlanMessages returned error: %v", err)
 	}
@@ -0,0 +1,248 @@
+package ai
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"careme/internal/nutrition"
+)
+
+var unicodeFractions = strings.NewReplacer(
+	"¼", " 1/4", "½", " 1/2", "¾", " 3/4",
+	"⅓", " 1/3", "⅔", " 2/3", "⅛", " 1/8",
+	"⁄", "/",
+)
+
+// amountExpr also matches unicode fractions as written, like "1½" or "1⁄2",
+// so scaling can rewrite just the amount in the original text.
+const amountExpr = `\d+\s*[¼½¾⅓⅔⅛]|[¼½¾⅓⅔⅛]|\d+\s+\d+[/⁄]\d+|\d+[/⁄]\d+|\d*\.\d+|\d+`
+
+// amountPattern matches a single amount or a range like "2-3" or "1 to 2".
+var amountPattern = regexp.MustCompile(`(` + amountExpr + `)(?:\s*(?:-|–|to)\s*(` + amountExpr + `))?`)
+
+// ParseQuantity pulls the leading amount and unit out of a free-form quantity such
+// as "1 1/2 cups, diced" or "½ tsp". Ranges and quantities without a leading number
+// ("to taste", "2-3 cloves") are not parsed.
+func ParseQuantity(raw string) (float64, string, bool) {
+	raw = strings.TrimSpace(unicodeFractions.Replace(raw))
+	loc := amountPattern.FindStringSubmatchIndex(raw)
+	if loc == nil || loc[0] != 0 || loc[4] >= 0 {
+		return 0, "", false
+	}
+	amount, ok := parseAmount(raw[loc[2]:loc[3]])
+	if !ok {
+		return 0, "", false
+	}
+	unit := raw[loc[1]:]
+	if i := strings.Index(unit, ","); i >= 0 {
+		unit = unit[:i]
+	}
+	return amount, strings.TrimSpace(unit), true
+}
+
+// ParseQuantities fills the parsed Amount and Unit for each ingredient.
+func (r *Recipe) ParseQuantities() {
+	for i := range r.Ingredients {
+		ing := &r.Ingredients[i]
+		ing.Amount, ing.Unit, _ = ParseQuantity(ing.Quantity)
+	}
+}
+
+// ComputeNutrition estimates per-serving nutrition from the ingredient quantities.
+// Servings is passed in because older recipes don't record it.
+func (r *Recipe) ComputeNutrition(servings int) {
+	ingredients := make([]nutrition.Ingredient, len(r.Ingredients))
+	for i, ing := range r.Ingredients {
+		amount, unit, _ := ParseQuantity(ing.Quantity)
+		ingredients[i] = nutrition.Ingredient{Name: ing.Name, Amount: amount, Unit: unit}
+	}
+	r.Nutrition = nutrition.Estimate(ingredients, servings)
+}
+
+func parseAmount(s string) (float64, bool) {
+	s = strings.TrimSpace(unicodeFractions.Replace(s))
+	whole := 0.0
+	if fields := strings.Fields(s); len(fields) == 2 {
+		w, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return 0, false
+		}
+		whole, s = w, fields[1]
+	}
+	if num, den, ok := strings.Cut(s, "/"); ok {
+		n, err1 := strconv.ParseFloat(num, 64)
+		d, err2 := strconv.ParseFloat(den, 64)
+		if err1 != nil || err2 != nil || d == 0 {
+			return 0, false
+		}
+		return whole + n/d, true
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	return whole + v, true
+}
+
+var kitchenFractions = []struct {
+	value float64
+	text  string
+}{
+	{1.0 / 8, "1/8"}, {1.0 / 4, "1/4"}, {1.0 / 3, "1/3"}, {1.0 / 2, "1/2"},
+	{2.0 / 3, "2/3"}, {3.0 / 4, "3/4"},
+}
+
+// FormatAmount writes an amount the way a recipe would, preferring "1 1/2" to "1.5".
+func FormatAmount(v float64) string {
+	if v <= 0 {
+		return "0"
+	}
+	whole := math.Floor(v)
+	frac := v - whole
+	if frac < 0.04 {
+		return strconv.FormatFloat(whole, 'f', -1, 64)
+	}
+	if frac > 0.96 {
+		return strconv.FormatFloat(whole+1, 'f', -1, 64)
+	}
+	for _, f := range kitchenFractions {
+		if math.Abs(frac-f.value) < 0.02 {
+			if whole == 0 {
+				return f.text
+			}
+			return strconv.FormatFloat(whole, 'f', -1, 64) + " " + f.text
+		}
+	}
+	if v >= 10 {
+		return strconv.FormatFloat(math.Round(v), 'f', -1, 64)
+	}
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+// ScaleQuantity multiplies the leading amount (or range) of a free-form quantity,
+// leaving the rest of the text alone. Quantities without a leading amount are
+// returned unchanged.
+func ScaleQuantity(raw string, factor float64) string {
+	loc := amountPattern.FindStringSubmatchIndex(raw)
+	if loc == nil || strings.TrimSpace(raw[:loc[0]]) != "" {
+		return raw
+	}
+	return raw[:loc[0]] + scaleMatch(raw, loc, factor) + raw[loc[1]:]
+}
+
+// ScaleAmounts multiplies every amount in text that is followed by a word accepted by
+// measured, e.g. "2 tablespoons" or "3 cloves", skipping a size like "large" in between. Amounts followed by anything else,
+// like "400°F" or "10 minutes", are left alone. Only the amounts are rewritten;
+// the rest of the text keeps its spacing and punctuation.
+func ScaleAmounts(text string, factor float64, measured func(word string) bool) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range amountPattern.FindAllStringSubmatchIndex(text, -1) {
+		if prev, _ := utf8.DecodeLastRuneInString(text[:loc[0]]); loc[0] > 0 && isWordRune(prev) {
+			continue
+		}
+		if !measured(measuredWord(text[loc[1]:])) {
+			continue
+		}
+		b.WriteString(text[last:loc[0]])
+		b.WriteString(scaleMatch(text, loc, factor))
+		last = loc[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+func scaleMatch(text string, loc []int, factor float64) string {
+	low, ok := parseAmount(text[loc[2]:loc[3]])
+	if !ok {
+		return text[loc[0]:loc[1]]
+	}
+	if loc[4] < 0 {
+		return FormatAmount(low * factor)
+	}
+	high, ok := parseAmount(text[loc[4]:loc[5]])
+	if !ok {
+		return text[loc[0]:loc[1]]
+	}
+	return FormatAmount(low*factor) + "-" + FormatAmount(high*factor)
+}
+
+func nextWord(s string) string {
+	s = strings.TrimLeft(s, " ")
+	end := strings.IndexFunc(s, func(r rune) bool { return !isWordRune(r) })
+	if end < 0 {
+		end = len(s)
+	}
+	return strings.ToLower(s[:end])
+}
+
+var sizeWords = map[string]bool{"large": true, "medium": true, "small": true}
+
+// measuredWord is the word an amount measures, looking past a size so "2 large
+// eggs" is measured by "eggs" and "2 large bowls" by "bowls".
+func measuredWord(s string) string {
+	word := nextWord(s)
+	for sizeWords[word] {
+		s = strings.TrimLeft(s, " ")[len(word):]
+		word = nextWord(s)
+	}
+	return word
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.'
+}
+
+// massVolumeUnits measure how much of something there is, so an amount in front
+// of one always scales with the servings.
+var massVolumeUnits = map[string]struct{}{}
+
+var measurementUnits = map[string]struct{}{}
+
+func init() {
+	for _, unit := range []string{
+		"cup", "tablespoon", "tbsp", "tbs", "teaspoon", "tsp", "pound", "lb", "ounce", "oz",
+		"gram", "g", "kilogram", "kg", "milliliter", "ml", "liter", "l", "pint", "quart",
+		"gallon",
+	} {
+		massVolumeUnits[unit] = struct{}{}
+		measurementUnits[unit] = struct{}{}
+	}
+	for _, unit := range []string{
+		"clove", "can", "jar", "bunch", "sprig", "slice", "stalk", "stick",
+		"head", "handful", "pinch", "dash", "piece", "fillet", "package", "pkg", "bag",
+		"large", "medium", "small", "whole",
+	} {
+		measurementUnits[unit] = struct{}{}
+	}
+}
+
+// IsMeasurementUnit reports whether word is a unit (or size word) that follows an
+// amount in a recipe, allowing plurals.
+func IsMeasurementUnit(word string) bool {
+	return inUnits(measurementUnits, word)
+}
+
+// IsMassOrVolumeUnit reports whether word is a weight or volume unit like "cups" or
+// "oz". Count and size words such as "pieces" or "large" aren't, since in a step
+// they as often describe the cooking ("cut into 4 pieces") as an amount.
+func IsMassOrVolumeUnit(word string) bool {
+	return inUnits(massVolumeUnits, word)
+}
+
+func inUnits(units map[string]struct{}, word string) bool {
+	word = strings.TrimSuffix(strings.ToLower(word), ".")
+	if _, ok := units[word]; ok {
+		return true
+	}
+	for _, suffix := range []string{"es", "s"} {
+		if _, ok := units[strings.TrimSuffix(word, suffix)]; ok && strings.HasSuffix(word, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package ai
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		raw    string
+		amount float64
+		unit   string
+		ok     bool
+	}{
+		{raw: "2 cups", amount: 2, unit: "cups", ok: true},
+		{raw: "1 1/2 tablespoons, divided", amount: 1.5, unit: "tablespoons", ok: true},
+		{raw: "½ tsp", amount: 0.5, unit: "tsp", ok: true},
+		{raw: "1½ lb", amount: 1.5, unit: "lb", ok: true},
+		{raw: "0.25 cup", amount: 0.25, unit: "cup", ok: true},
+		{raw: "3", amount: 3, unit: "", ok: true},
+		{raw: "2-3 cloves"},
+		{raw: "to taste"},
+		{raw: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			amount, unit, ok := ParseQuantity(tt.raw)
+			assert.Equal(t, tt.ok, ok)
+			assert.InDelta(t, tt.amount, amount, 1e-9)
+			assert.Equal(t, tt.unit, unit)
+		})
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	for v, want := range map[float64]string{
+		3:       "3",
+		1.5:     "1 1/2",
+		0.25:    "1/4",
+		2.0 / 3: "2/3",
+		0.999:   "1",
+		1.1:     "1.1",
+		12.4:    "12",
+	} {
+		assert.Equal(t, want, FormatAmount(v), "FormatAmount(%v)", v)
+	}
+}
+
+func TestScaleQuantity(t *testing.T) {
+	assert.Equal(t, "3 cups, chopped", ScaleQuantity("2 cups, chopped", 1.5))
+	assert.Equal(t, "1 1/2 tsp", ScaleQuantity("½ tsp", 3))
+	assert.Equal(t, "3-4 1/2 cloves", ScaleQuantity("2-3 cloves", 1.5))
+	assert.Equal(t, "to taste", ScaleQuantity("to taste", 3))
+	assert.Equal(t, "3  cups,  chopped ", ScaleQuantity("2  cups,  chopped ", 1.5), "only the amount is rewritten")
+}
+
+func TestScaleAmountsOnlyTouchesMeasuredAmounts(t *testing.T) {
+	measured := func(word string) bool {
+		return IsMeasurementUnit(word) || strings.HasPrefix(word, "egg")
+	}
+	got := ScaleAmounts("Heat oven to 425°F. Whisk 2 eggs with 1/2 cup milk and 1 to 2 tbsp. oil in a 12-inch skillet for 10 minutes.", 2, measured)
+	assert.Equal(t, "Heat oven to 425°F. Whisk 4 eggs with 1 cup milk and 2-4 tbsp. oil in a 12-inch skillet for 10 minutes.", got)
+
+	got = ScaleAmounts("Add 1½ cups stock.\n  Stir in ½ tsp salt,  then 2 cloves garlic.", 2, measured)
+	assert.Equal(t, "Add 3 cups stock.\n  Stir in 1 tsp salt,  then 4 cloves garlic.", got, "spacing and line breaks around the amounts are kept")
+
+	got = ScaleAmounts("Beat 2 large eggs in 2 large bowls, then cut into 4 pieces.", 2, func(word string) bool {
+		return IsMassOrVolumeUnit(word) || strings.HasPrefix(word, "egg")
+	})
+	assert.Equal(t, "Beat 4 large eggs in 2 large bowls, then cut into 4 pieces.", got)
+}
+
+func TestRecipeParseQuantities(t *testing.T) {
+	r := Recipe{Ingredients: []Ingredient{{Name: "rice", Quantity: "1 1/2 cups"}, {Name: "salt", Quantity: "to taste"}}}
+	r.ParseQuantities()
+	assert.Equal(t, 1.5, r.Ingredients[0].Amount)
+	assert.Equal(t, "cups", r.Ingredients[0].Unit)
+	assert.Zero(t, r.Ingredients[1].Amount)
+}
+
+func TestRecipeComputeNutrition(t *testing.T) {
+	r := Recipe{Ingredients: []Ingredient{{Name: "Jasmine rice", Quantity: "2 cups"}, {Name: "Salt", Quantity: "to taste"}}}
+	r.ComputeNutrition(4)
+	require.NotNil(t, r.Nutrition)
+	assert.Equal(t, 338, r.Nutrition.Calories)
+	assert.Equal(t, []string{"Salt"}, r.Nutrition.Uncounted)
+
+	r.ComputeNutrition(0)
+	assert.Nil(t, r.Nutrition)
+}
@@ -8,8 +8,11 @@ import (
 	"hash/fnv"
 	"io"
 	"log/slog"
+	"strconv"
 	"strings"
 
+	"careme/internal/nutrition"
+
 	openai "github.com/openai/openai-go/v3"
 	"github.com/openai/openai-go/v3/responses"
 	"github.com/samber/lo"
@@ -30,12 +33,17 @@ type Ingredient struct {
 	Name        string `json:"name"`
 	Quantity    string `json:"quantity"` // amount used in the recipe, not the catalog package size
 	Price       string `json:"price,omitempty" jsonschema:"-"`
+	LocationID  string `json:"location_id,omitempty" jsonschema:"-"` // store to buy it at on multi-store lists
+	// parsed from Quantity after generation so lists can be scaled and merged
+	Amount float64 `json:"amount,omitempty" jsonschema:"-"`
+	Unit   string  `json:"unit,omitempty" jsonschema:"-"`
 }
 
 type Recipe struct {
 	Title          string       `json:"title"`
 	Description    string       `json:"description"`
 	CookTime       string       `json:"cook_time"`
+	Servings       int          `json:"servings"`
 	CostEstimate   string       `json:"cost_estimate"`
 	Ingredients    []Ingredient `json:"ingredients"`
 	Instructions   []string     `json:"instructions"`
@@ -46,6 +54,14 @@ type Recipe struct {
 	OriginHash     string       `json:"origin_hash,omitempty" jsonschema:"-"`      // not in schema
 	ParentHash     string       `json:"parent_hash,omitempty" jsonschema:"-"`      // regeneration metadata, not in schema
 	PromptCacheKey string       `json:"prompt_cache_key,omitempty" jsonschema:"-"` // server-owned cache routing metadata
+	// SourceURL is the page an imported recipe came from; empty for generated recipes.
+	SourceURL string `json:"source_url,omitempty" jsonschema:"-"`
+	// Cuisine is the direction the menu plan gave this recipe, kept for search.
+	Cuisine string `json:"cuisine,omitempty" jsonschema:"-"`
+	// computed from the ingredient table after generation so Health can be checked against it
+	Nutrition *nutrition.Facts `json:"nutrition,omitempty" jsonschema:"-"`
+	// StoreCost is what the ingredients used cost at the store's prices; CostEstimate is the model's guess.
+	StoreCost float64 `json:"store_cost,omitempty" jsonschema:"-"`
 	// Shove wine selection in here
 }
 
@@ -55,7 +71,7 @@ func (r Recipe) ResponseRef() ResponseRef {
 
 // ComputeHash calculates the fnv128 hash of the recipe content
 func (r *Recipe) ComputeHash() string {
-	// OriginHash, ParentHash, PromptCacheKey, and Saved are intentionally excluded because they describe provenance or UI state,
+	// OriginHash, ParentHash, PromptCacheKey, SourceURL, Cuisine, and Saved are intentionally excluded because they describe provenance or UI state,
 	// not the recipe content itself. If ancestor links ever need to affect identity, that
 	// is a separate model change and should not happen implicitly here.
 	fnv := fnv.New128a()
@@ -63,6 +79,9 @@ func (r *Recipe) ComputeHash() string {
 	lo.Must(io.WriteString(fnv, r.Description))
 	lo.Must(io.WriteString(fnv, r.CookTime))
 	lo.Must(io.WriteString(fnv, r.CostEstimate))
+	if r.Servings > 0 { // older recipes have no servings; keep their hashes
+		lo.Must(io.WriteString(fnv, "servings"+strconv.Itoa(r.Servings)))
+	}
 	for _, ing := range r.Ingredients {
 		lo.Must(io.WriteString(fnv, ing.Name))
 		lo.Must(io.WriteString(fnv, ing.Quantity))
@@ -111,6 +130,7 @@ Create a practical, flavorful recipe using the provided sale ingredients, season
 - title: use a short, appetizing name.
 - description: one appetizing sentence that notes what makes the dish practical, special, or seasonal.
 - cook_time: provide the total elapsed recipe time such as "35 minutes"; include prep, cooking, resting, and any other timed instruction steps.
+- servings: the number of people the recipe serves; every quantity is for this many servings.
 - cost_estimate: align the range with listed priced ingredients.
 - ingredients: for catalog ingredients chosen from the TSV, set id to the exact ProductId. Leave id empty only for pantry items or ingredients not present in the TSV. Set quantity to the total amount needed across the entire recipe, not the catalog package size or sale size. Do not include prices; the app will add known store prices after generation.
 - instructions: 5 to 8 clear steps; start with prep such as preheating, chopping, slicing, dicing, mixing, or make-ahead work before active cooking; do not rely on prep details from the ingredient list alone; end with plating; do not include prices; do not prefix steps with numbers. Every time a step mentions an ingredient, including a pantry ingredient, state the exact amount of that ingredient used in that step. When an ingredient is divided among steps, the step amounts must add up to the total quantity in ingredients. Do not use an unquantified phrase such as "the remaining oil"; write the amount, such as "the remaining 1 tablespoon oil."
@@ -126,16 +146,28 @@ Do not include these checks in the output.`
 
 func responseToRecipe(ctx context.Context, category, model, promptCacheKey string, resp *responses.Response) (*Recipe, error) {
 	slog.InfoContext(ctx, "API usage", "ai_category", category, "model", model, responseUsageLogAttr(model, resp.Usage))
-	var recipe Recipe
-	if err := json.Unmarshal([]byte(resp.OutputText()), &recipe); err != nil {
-		return nil, fmt.Errorf("failed to parse AI response: %w", err)
+	recordUsage(ctx, responseUsage(category, model, resp.Usage))
+	recipe, err := parseRecipe(resp.OutputText())
+	if err != nil {
+		return nil, err
 	}
-	recipe.WineStyles = normalizeRecipeWineStyles(recipe.WineStyles)
 	if strings.TrimSpace(resp.ID) == "" {
 		return nil, fmt.Errorf("failed to get response ID")
 	}
 	recipe.ResponseID = resp.ID
 	recipe.PromptCacheKey = promptCacheKey
+	return recipe, nil
+}
+
+// parseRecipe fills in what the app computes after generation; the caller sets the response ID.
+func parseRecipe(body string) (*Recipe, error) {
+	var recipe Recipe
+	if err := json.Unmarshal([]byte(body), &recipe); err != nil {
+		return nil, fmt.Errorf("failed to parse AI response: %w", err)
+	}
+	recipe.WineStyles = normalizeRecipeWineStyles(recipe.WineStyles)
+	recipe.ParseQuantities()
+	recipe.ComputeNutrition(recipe.Servings)
 	return &recipe, nil
 }
 
@@ -197,7 +229,12 @@ func (c *client) GenerateRecipe(ctx context.Context, instructions []string, menu
 	return responseToRecipe(ctx, aiCategoryRecipe, c.model, menu.PromptCacheKey, resp)
 }
 
+const questionInstructions = "Answer the user's question about the recipe in plain text. Be concise and do not regenerate the full recipe or output JSON."
+
 func (c *client) AskQuestion(ctx context.Context, question string, previous ResponseRef) (*QuestionResponse, error) {
+	if err := allowUsage(ctx, aiCategoryRecipeQuestion); err != nil {
+		return nil, err
+	}
 	question = strings.TrimSpace(question)
 	if question == "" {
 		return nil, fmt.Errorf("question is required")
@@ -205,21 +242,25 @@ func (c *client) AskQuestion(ctx context.Context, question string, previous Resp
 
 	params := responses.ResponseNewParams{
 		Model:        c.model,
-		Instructions: openai.String("Answer the user's question about the recipe in plain text. Be concise and do not regenerate the full recipe or output JSON."),
+		Instructions: openai.String(questionInstructions),
 		Input: responses.ResponseNewParamsInputUnion{
 			OfInputItemList: []responses.ResponseInputItemUnionParam{userWithCacheBreakpoint(question)},
 		},
-		Store:              openai.Bool(true),
-		PreviousResponseID: openai.String(previous.ID),
+		Store: openai.Bool(true),
 
 		PromptCacheKey:     openai.String(previous.PromptCacheKey),
 		PromptCacheOptions: defaultCacheOptions(),
 	}
+	// imported recipes have no response to continue; the question carries the recipe instead.
+	if previous.ID != "" {
+		params.PreviousResponseID = openai.String(previous.ID)
+	}
 	resp, err := c.oai.Responses.New(ctx, params)
 	if err != nil {
 		return nil, fmt.Errorf("failed to answer question: %w", err)
 	}
 	slog.InfoContext(ctx, "API usage", "ai_category", aiCategoryRecipeQuestion, "model", c.model, responseUsageLogAttr(c.model, resp.Usage))
+	recordUsage(ctx, responseUsage(aiCategoryRecipeQuestion, c.model, resp.Usage))
 	answer := strings.TrimSpace(resp.OutputText())
 	if answer == "" {
 		return nil, fmt.Errorf("empty response from model")
@@ -0,0 +1,81 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	openai "github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/responses"
+)
+
+// maxImportChars keeps a pasted page from blowing up the prompt.
+const maxImportChars = 24000
+
+const importInstructions = `You turn a recipe the user found somewhere else into the recipe JSON format.
+
+# Rules
+- Keep the source's dish, ingredients, amounts and steps. Do not substitute, add or drop ingredients, and do not invent prices.
+- Split combined steps only when it makes them easier to follow; keep the author's order.
+- Fill in servings, cook time, health notes, a drink pairing and wine styles when the source leaves them out.
+- Leave every ingredient id empty; the app matches ingredients to the store afterwards.
+- If the text does not contain a recipe, return an empty title and no ingredients.`
+
+func importInput(text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", fmt.Errorf("recipe text is required")
+	}
+	if len(text) > maxImportChars {
+		text = text[:maxImportChars]
+	}
+	return "Here is the recipe:\n\n" + text, nil
+}
+
+// StructureRecipe reads free-form recipe text, like a pasted page, into a Recipe.
+// The response is stored so questions and regeneration can continue from it.
+func (c *client) StructureRecipe(ctx context.Context, text string) (*Recipe, error) {
+	if err := allowUsage(ctx, aiCategoryRecipe); err != nil {
+		return nil, err
+	}
+	input, err := importInput(text)
+	if err != nil {
+		return nil, err
+	}
+	promptMessages := []PromptMessage{userPromptMessage(input)}
+	params := responses.ResponseNewParams{
+		Model:        c.model,
+		Instructions: openai.String(importInstructions),
+		Input: responses.ResponseNewParamsInputUnion{
+			OfInputItemList: messagesToInput(promptMessages),
+		},
+		Store: openai.Bool(true),
+		Text:  scheme(c.recipeSchema),
+	}
+	resp, err := c.oai.Responses.New(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to structure imported recipe: %w", err)
+	}
+	c.recordRecipePrompt(ctx, resp.ID, params, promptMessages)
+	return responseToRecipe(ctx, aiCategoryRecipe, c.model, "", resp)
+}
+
+func (c *chatClient) StructureRecipe(ctx context.Context, text string) (*Recipe, error) {
+	if err := allowUsage(ctx, aiCategoryRecipe); err != nil {
+		return nil, err
+	}
+	input, err := importInput(text)
+	if err != nil {
+		return nil, err
+	}
+	id, output, err := c.converse(ctx, aiCategoryRecipe, importInstructions, "", []PromptMessage{userPromptMessage(input)}, c.recipeSchema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to structure imported recipe: %w", err)
+	}
+	recipe, err := parseRecipe(output)
+	if err != nil {
+		return nil, err
+	}
+	recipe.ResponseID = id
+	return recipe, nil
+}
@@ -93,6 +93,14 @@ func TestRecipeSchemaLeavesServerOwnedIngredientFieldsOut(t *testing.T) {
 	if _, ok := ingredientProperties["aisle_number"]; ok {
 		t.Fatalf("did not expect model schema to include server-owned aisle number")
 	}
+	for _, field := range []string{"amount", "unit"} {
+		if _, ok := ingredientProperties[field]; ok {
+			t.Fatalf("did not expect model schema to include parsed %s", field)
+		}
+	}
+	if _, ok := properties["servings"]; !ok {
+		t.Fatalf("expected recipe schema to include servings")
+	}
 }
 
 func TestSystemMessageRequiresPrepFirstAndTotalTiming(t *testing.T) {
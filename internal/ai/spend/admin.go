@@ -0,0 +1,212 @@
+package spend
+
+import (
+	"cmp"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"careme/internal/ai"
+)
+
+const adminDays = 14
+
+type adminRow struct {
+	Name string
+	URL  string
+	Bucket
+}
+
+type adminTable struct {
+	Label string
+	Rows  []adminRow
+}
+
+type adminPageData struct {
+	Caps       string
+	Days       []Day
+	Date       string
+	Tables     []adminTable
+	List       string
+	ListTotals *Totals
+	ListRows   []adminRow
+}
+
+var adminPageTmpl = template.Must(template.New("admin-spend").Funcs(template.FuncMap{
+	"usd": func(v float64) string { return fmt.Sprintf("$%.4f", v) },
+}).Parse(`<!doctype html>
+<html lang="en">
+<head>
+  <meta charset="utf-8" />
+  <meta name="viewport" content="width=device-width, initial-scale=1" />
+  <title>Admin AI Spend</title>
+</head>
+<body>
+  <nav>
+    <a href="/admin/">Admin</a> |
+    <a href="/admin/users">Users</a> |
+    <a href="/admin/spend">AI Spend</a>
+  </nav>
+  <h1>AI Spend</h1>
+  <p>Estimated from token usage and model prices; days are UTC. Caps: {{.Caps}}</p>
+  <form method="get">
+    <label>Shopping list hash <input name="list" value="{{.List}}" /></label>
+    <button type="submit">Look up</button>
+  </form>
+  {{if .List}}
+  <h2>Shopping list <code>{{.List}}</code></h2>
+  {{if .ListTotals}}
+  <p><a href="/admin/mealplan/{{.List}}">Meal plan</a> | <a href="/admin/params/{{.List}}">Params</a></p>
+  <table border="1" cellpadding="6" cellspacing="0">
+    <thead><tr><th>Category</th><th>Calls</th><th>Unpriced</th><th>Input tokens</th><th>Output tokens</th><th>Cost</th></tr></thead>
+    <tbody>
+      {{range .ListRows}}
+      <tr><td>{{.Name}}</td><td>{{.Calls}}</td><td>{{.UnpricedCalls}}</td><td>{{.InputTokens}}</td><td>{{.OutputTokens}}</td><td>{{usd .CostUSD}}</td></tr>
+      {{end}}
+      <tr><th>Total</th><th>{{.ListTotals.Calls}}</th><th>{{.ListTotals.UnpricedCalls}}</th><th>{{.ListTotals.InputTokens}}</th><th>{{.ListTotals.OutputTokens}}</th><th>{{usd .ListTotals.CostUSD}}</th></tr>
+    </tbody>
+  </table>
+  {{else}}
+  <p>No spend recorded.</p>
+  {{end}}
+  {{end}}
+
+  <h2>Last {{len .Days}} days</h2>
+  <table border="1" cellpadding="6" cellspacing="0">
+    <thead><tr><th>Date</th><th>Calls</th><th>Unpriced</th><th>Input tokens</th><th>Output tokens</th><th>Cost</th></tr></thead>
+    <tbody>
+      {{range .Days}}
+      <tr><td><a href="/admin/spend?date={{.Date}}">{{.Date}}</a></td><td>{{.Calls}}</td><td>{{.UnpricedCalls}}</td><td>{{.InputTokens}}</td><td>{{.OutputTokens}}</td><td>{{usd .CostUSD}}</td></tr>
+      {{end}}
+    </tbody>
+  </table>
+
+  <h2>{{.Date}}</h2>
+  {{range .Tables}}
+  <h3>By {{.Label}}</h3>
+  <table border="1" cellpadding="6" cellspacing="0">
+    <thead><tr><th>{{.Label}}</th><th>Calls</th><th>Unpriced</th><th>Input tokens</th><th>Output tokens</th><th>Cost</th></tr></thead>
+    <tbody>
+      {{range .Rows}}
+      <tr><td>{{if .URL}}<a href="{{.URL}}">{{.Name}}</a>{{else}}{{.Name}}{{end}}</td><td>{{.Calls}}</td><td>{{.UnpricedCalls}}</td><td>{{.InputTokens}}</td><td>{{.OutputTokens}}</td><td>{{usd .CostUSD}}</td></tr>
+      {{else}}
+      <tr><td colspan="6">none</td></tr>
+      {{end}}
+    </tbody>
+  </table>
+  {{end}}
+</body>
+</html>`))
+
+// AdminPage shows recent daily totals, one day split by category, user and
+// shopping list, and a lookup for a single shopping list.
+func AdminPage(l *Ledger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		ctx := r.Context()
+
+		days, err := l.Days(ctx, adminDays)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to load AI spend days", "error", err)
+			http.Error(w, "unable to load spend", http.StatusInternalServerError)
+			return
+		}
+		data := adminPageData{Caps: l.describeCaps(), Days: days, Date: days[0].Date}
+
+		day := days[0]
+		if date := strings.TrimSpace(r.URL.Query().Get("date")); date != "" && date != day.Date {
+			if _, err := time.Parse(dateLayout, date); err != nil {
+				http.Error(w, "date must look like 2026-05-11", http.StatusBadRequest)
+				return
+			}
+			if day, err = l.Day(ctx, date); err != nil {
+				slog.ErrorContext(ctx, "failed to load AI spend day", "date", date, "error", err)
+				http.Error(w, "unable to load spend", http.StatusInternalServerError)
+				return
+			}
+			data.Date = date
+		}
+		data.Tables = []adminTable{
+			{Label: "category", Rows: categoryRows(day.Categories)},
+			{Label: "user", Rows: rows(day.Users, func(string) string { return "" })},
+			{Label: "shopping list", Rows: rows(day.Lists, func(hash string) string { return "/admin/spend?list=" + url.QueryEscape(hash) })},
+		}
+
+		if hash := strings.TrimSpace(r.URL.Query().Get("list")); hash != "" {
+			data.List = hash
+			totals, err := l.ShoppingList(ctx, hash)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to load AI spend for shopping list", "hash", hash, "error", err)
+				http.Error(w, "unable to load spend", http.StatusInternalServerError)
+				return
+			}
+			if totals.Calls > 0 {
+				data.ListTotals = &totals
+				data.ListRows = categoryRows(totals.Categories)
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		if err := adminPageTmpl.Execute(w, data); err != nil {
+			slog.ErrorContext(ctx, "failed to render admin spend page", "error", err)
+		}
+	})
+}
+
+func (l *Ledger) describeCaps() string {
+	var caps []string
+	if l.caps.DailyCapUSD > 0 {
+		caps = append(caps, fmt.Sprintf("$%.2f a day", l.caps.DailyCapUSD))
+	}
+	if l.caps.UserDailyCapUSD > 0 {
+		caps = append(caps, fmt.Sprintf("$%.2f a user a day", l.caps.UserDailyCapUSD))
+	}
+	if len(caps) == 0 {
+		return "none"
+	}
+	mode := "images, wine, critique and farmers market photos stop at a cap"
+	if l.caps.Refuses() {
+		mode = "new menus and questions are refused at a cap too"
+	}
+	return strings.Join(caps, ", ") + "; " + mode
+}
+
+// categoryRows keeps ai.Categories order and puts anything unknown after.
+func categoryRows(buckets map[string]Bucket) []adminRow {
+	out := make([]adminRow, 0, len(buckets))
+	for _, category := range ai.Categories {
+		if b, ok := buckets[category]; ok {
+			out = append(out, adminRow{Name: category, Bucket: b})
+		}
+	}
+	for category, b := range buckets {
+		if !slices.Contains(ai.Categories, category) {
+			out = append(out, adminRow{Name: category, Bucket: b})
+		}
+	}
+	return out
+}
+
+// rows sorts by cost, most expensive first.
+func rows(buckets map[string]Bucket, link func(string) string) []adminRow {
+	out := make([]adminRow, 0, len(buckets))
+	for name, b := range buckets {
+		out = append(out, adminRow{Name: name, URL: link(name), Bucket: b})
+	}
+	slices.SortFunc(out, func(a, b adminRow) int {
+		if c := cmp.Compare(b.CostUSD, a.CostUSD); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return out
+}
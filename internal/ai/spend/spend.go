@@ -0,0 +1,470 @@
+// Package spend adds up estimated model spend per UTC day, user and shopping
+// list in the cache, and holds back model calls once a daily cap is hit.
+// Spend is added up in process and written every FlushInterval, so recording
+// a call never waits on the cache or races other calls for the same key.
+package spend
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"careme/internal/ai"
+	"careme/internal/cache"
+	"careme/internal/config"
+	"careme/internal/logsetup"
+)
+
+const (
+	Prefix     = "ai_spend/"
+	dayPrefix  = Prefix + "days/"
+	userPrefix = Prefix + "users/"
+	listPrefix = Prefix + "lists/"
+
+	dateLayout = "2006-01-02"
+	// FlushInterval is how often spend is written to the cache, and so about
+	// how far behind other replicas can be when checking a cap.
+	FlushInterval = 10 * time.Second
+	flushTimeout  = 30 * time.Second
+	// dayBreakdown is how many of the costliest users and lists a day keeps
+	// for the admin page. Each user's full total is under its own key for
+	// the user cap and each list's under the list's.
+	dayBreakdown = 50
+)
+
+// Bucket adds up calls. Calls to models without a price count as unpriced and
+// add tokens but no cost, so CostUSD is a floor when UnpricedCalls isn't zero.
+type Bucket struct {
+	Calls             int     `json:"calls"`
+	UnpricedCalls     int     `json:"unpriced_calls,omitempty"`
+	InputTokens       int64   `json:"input_tokens"`
+	CachedInputTokens int64   `json:"cached_input_tokens,omitempty"`
+	OutputTokens      int64   `json:"output_tokens"`
+	CostUSD           float64 `json:"cost_usd"`
+}
+
+func (b *Bucket) add(u ai.Usage) {
+	b.Calls++
+	if !u.Priced {
+		b.UnpricedCalls++
+	}
+	b.InputTokens += u.InputTokens
+	b.CachedInputTokens += u.CachedInputTokens
+	b.OutputTokens += u.OutputTokens
+	b.CostUSD += u.CostUSD
+}
+
+func (b *Bucket) merge(o Bucket) {
+	b.Calls += o.Calls
+	b.UnpricedCalls += o.UnpricedCalls
+	b.InputTokens += o.InputTokens
+	b.CachedInputTokens += o.CachedInputTokens
+	b.OutputTokens += o.OutputTokens
+	b.CostUSD += o.CostUSD
+}
+
+// Totals is a Bucket split by ai_category.
+type Totals struct {
+	Bucket
+	Categories map[string]Bucket `json:"categories,omitempty"`
+}
+
+func (t *Totals) add(u ai.Usage) {
+	t.Bucket.add(u)
+	if t.Categories == nil {
+		t.Categories = map[string]Bucket{}
+	}
+	b := t.Categories[u.Category]
+	b.add(u)
+	t.Categories[u.Category] = b
+}
+
+func (t *Totals) merge(o Totals) {
+	t.Bucket.merge(o.Bucket)
+	mergeInto(&t.Categories, o.Categories)
+}
+
+// Day is one UTC day of spend with the per-user split the user cap is checked
+// against and the per-list split for finding what a day went on.
+type Day struct {
+	Date string `json:"date"`
+	Totals
+	Users map[string]Bucket `json:"users,omitempty"`
+	Lists map[string]Bucket `json:"lists,omitempty"`
+}
+
+func (d *Day) merge(o Day) {
+	d.Date = o.Date
+	d.Totals.merge(o.Totals)
+	mergeInto(&d.Users, o.Users)
+	mergeInto(&d.Lists, o.Lists)
+}
+
+// trim keeps the costliest users and lists. One that drops out and comes
+// back later only shows what it spent since.
+func (d *Day) trim() {
+	d.Users = costliest(d.Users, dayBreakdown)
+	d.Lists = costliest(d.Lists, dayBreakdown)
+}
+
+func costliest(m map[string]Bucket, n int) map[string]Bucket {
+	if len(m) <= n {
+		return m
+	}
+	keys := slices.SortedFunc(maps.Keys(m), func(a, b string) int {
+		return cmp.Or(cmp.Compare(m[b].CostUSD, m[a].CostUSD), cmp.Compare(a, b))
+	})
+	for _, key := range keys[n:] {
+		delete(m, key)
+	}
+	return m
+}
+
+func addTo(m *map[string]Bucket, key string, u ai.Usage) {
+	if *m == nil {
+		*m = map[string]Bucket{}
+	}
+	b := (*m)[key]
+	b.add(u)
+	(*m)[key] = b
+}
+
+func mergeInto(m *map[string]Bucket, o map[string]Bucket) {
+	for key, ob := range o {
+		if *m == nil {
+			*m = map[string]Bucket{}
+		}
+		b := (*m)[key]
+		b.merge(ob)
+		(*m)[key] = b
+	}
+}
+
+type shoppingListKey struct{}
+
+// WithShoppingList charges model calls made with ctx to the shopping list hash.
+func WithShoppingList(ctx context.Context, hash string) context.Context {
+	if hash == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, shoppingListKey{}, hash)
+}
+
+func ShoppingListFromContext(ctx context.Context) (string, bool) {
+	hash, ok := ctx.Value(shoppingListKey{}).(string)
+	return hash, ok && hash != ""
+}
+
+// Ledger keeps spend in the cache so every replica adds to and checks the same totals.
+type Ledger struct {
+	cache cache.Cache
+	caps  config.SpendConfig
+	now   func() time.Time
+
+	mu sync.Mutex
+	// spend recorded since the last flush, by the key it's written to.
+	pendingDays  map[string]*Day
+	pendingUsers map[string]*Bucket
+	pendingLists map[string]*Totals
+	// what each day or user had spent when last read or written, for
+	// checking caps between flushes.
+	stored map[string]storedSpend
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+type storedSpend struct {
+	costUSD float64
+	at      time.Time
+}
+
+var _ ai.UsageMeter = (*Ledger)(nil)
+
+func NewLedger(c cache.Cache, caps config.SpendConfig) *Ledger {
+	return &Ledger{
+		cache:        c,
+		caps:         caps,
+		now:          time.Now,
+		pendingDays:  map[string]*Day{},
+		pendingUsers: map[string]*Bucket{},
+		pendingLists: map[string]*Totals{},
+		stored:       map[string]storedSpend{},
+		stop:         make(chan struct{}),
+	}
+}
+
+func dayKey(date string) string {
+	return dayPrefix + date
+}
+
+func userKey(date, userID string) string {
+	return userPrefix + date + "/" + userID
+}
+
+func listKey(hash string) string {
+	return listPrefix + hash
+}
+
+func (l *Ledger) today() string {
+	return l.now().UTC().Format(dateLayout)
+}
+
+func pendingAt[T any](m map[string]*T, key string) *T {
+	v, ok := m[key]
+	if !ok {
+		v = new(T)
+		m[key] = v
+	}
+	return v
+}
+
+// RecordUsage adds the call to what gets written on the next flush.
+func (l *Ledger) RecordUsage(ctx context.Context, u ai.Usage) {
+	date := l.today()
+	userID, _ := logsetup.UserID(ctx)
+	hash, hasList := ShoppingListFromContext(ctx)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	day := pendingAt(l.pendingDays, dayKey(date))
+	day.Date = date
+	day.add(u)
+	if userID != "" {
+		addTo(&day.Users, userID, u)
+		pendingAt(l.pendingUsers, userKey(date, userID)).add(u)
+	}
+	if hasList {
+		addTo(&day.Lists, hash, u)
+		pendingAt(l.pendingLists, listKey(hash)).add(u)
+	}
+}
+
+// Start flushes every FlushInterval until Wait.
+func (l *Ledger) Start() {
+	l.wg.Go(func() {
+		ticker := time.NewTicker(FlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-l.stop:
+				return
+			case <-ticker.C:
+				l.flushInBackground()
+			}
+		}
+	})
+}
+
+// Wait stops flushing on a timer and writes whatever hasn't been yet.
+func (l *Ledger) Wait() {
+	l.stopOnce.Do(func() { close(l.stop) })
+	l.wg.Wait()
+	l.flushInBackground()
+}
+
+func (l *Ledger) flushInBackground() {
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+	if err := l.Flush(ctx); err != nil {
+		slog.ErrorContext(ctx, "failed to flush AI spend", "error", err)
+	}
+}
+
+// Flush writes the spend recorded since the last flush. Whatever fails to
+// write is kept for the next one rather than dropped.
+func (l *Ledger) Flush(ctx context.Context) error {
+	l.mu.Lock()
+	days, users, lists := l.pendingDays, l.pendingUsers, l.pendingLists
+	l.pendingDays, l.pendingUsers, l.pendingLists = map[string]*Day{}, map[string]*Bucket{}, map[string]*Totals{}
+	l.mu.Unlock()
+
+	var errs []error
+	for key, delta := range days {
+		stored, err := cache.UpdateJSON(ctx, l.cache, key, func(d *Day, _ bool) error {
+			d.merge(*delta)
+			d.trim()
+			return nil
+		})
+		l.flushed(key, stored.CostUSD, err, func() { pendingAt(l.pendingDays, key).merge(*delta) })
+		errs = append(errs, err)
+	}
+	for key, delta := range users {
+		stored, err := cache.UpdateJSON(ctx, l.cache, key, func(b *Bucket, _ bool) error {
+			b.merge(*delta)
+			return nil
+		})
+		l.flushed(key, stored.CostUSD, err, func() { pendingAt(l.pendingUsers, key).merge(*delta) })
+		errs = append(errs, err)
+	}
+	for key, delta := range lists {
+		_, err := cache.UpdateJSON(ctx, l.cache, key, func(t *Totals, _ bool) error {
+			t.merge(*delta)
+			return nil
+		})
+		if err != nil {
+			l.mu.Lock()
+			pendingAt(l.pendingLists, key).merge(*delta)
+			l.mu.Unlock()
+		}
+		errs = append(errs, err)
+	}
+
+	l.mu.Lock()
+	for key, s := range l.stored {
+		if l.now().Sub(s.at) > 24*time.Hour {
+			delete(l.stored, key)
+		}
+	}
+	l.mu.Unlock()
+	return errors.Join(errs...)
+}
+
+// flushed remembers what a day or user has spent after writing it, or puts
+// the spend back to try again.
+func (l *Ledger) flushed(key string, costUSD float64, err error, requeue func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err != nil {
+		requeue()
+		return
+	}
+	l.stored[key] = storedSpend{costUSD: costUSD, at: l.now()}
+}
+
+// spent is what a day or user has spent: the stored total, read again once
+// it's a flush old, plus what this process hasn't written yet.
+func (l *Ledger) spent(ctx context.Context, key string) (float64, error) {
+	l.mu.Lock()
+	stored, ok := l.stored[key]
+	l.mu.Unlock()
+	if !ok || l.now().Sub(stored.at) >= FlushInterval {
+		// days and users both keep their cost at the top level.
+		var b Bucket
+		if err := l.load(ctx, key, &b); err != nil {
+			return 0, err
+		}
+		stored = storedSpend{costUSD: b.CostUSD, at: l.now()}
+		l.mu.Lock()
+		l.stored[key] = stored
+		l.mu.Unlock()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	pending := 0.0
+	if d, ok := l.pendingDays[key]; ok {
+		pending = d.CostUSD
+	}
+	if b, ok := l.pendingUsers[key]; ok {
+		pending = b.CostUSD
+	}
+	return stored.costUSD + pending, nil
+}
+
+// Allow holds back optional categories once today's total or the user's share
+// of it reaches a cap, and every category it's asked about in refuse mode.
+func (l *Ledger) Allow(ctx context.Context, category string) error {
+	if l.caps.DailyCapUSD <= 0 && l.caps.UserDailyCapUSD <= 0 {
+		return nil
+	}
+	if !ai.OptionalCategory(category) && !l.caps.Refuses() {
+		return nil
+	}
+	date := l.today()
+	if limit := l.caps.DailyCapUSD; limit > 0 {
+		spent, err := l.spent(ctx, dayKey(date))
+		if err != nil {
+			// a cache hiccup shouldn't stop dinner.
+			slog.ErrorContext(ctx, "failed to check AI spend cap", "ai_category", category, "error", err)
+			return nil
+		}
+		if spent >= limit {
+			slog.WarnContext(ctx, "AI spend cap reached", "ai_category", category, "spent_usd", spent, "cap_usd", limit)
+			return fmt.Errorf("%w: $%.2f of $%.2f spent today", ai.ErrSpendCapReached, spent, limit)
+		}
+	}
+	userID, ok := logsetup.UserID(ctx)
+	if limit := l.caps.UserDailyCapUSD; limit > 0 && ok {
+		spent, err := l.spent(ctx, userKey(date, userID))
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to check AI user spend cap", "ai_category", category, "error", err)
+			return nil
+		}
+		if spent >= limit {
+			slog.WarnContext(ctx, "AI user spend cap reached", "ai_category", category, "spent_usd", spent, "cap_usd", limit)
+			return fmt.Errorf("%w: $%.2f of your $%.2f spent today", ai.ErrSpendCapReached, spent, limit)
+		}
+	}
+	return nil
+}
+
+// Day returns the totals for a UTC date like 2026-05-11, empty when nothing was spent.
+func (l *Ledger) Day(ctx context.Context, date string) (Day, error) {
+	day := Day{Date: date}
+	if err := l.load(ctx, dayKey(date), &day); err != nil {
+		return Day{}, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if pending, ok := l.pendingDays[dayKey(date)]; ok {
+		day.merge(*pending)
+		day.trim()
+	}
+	return day, nil
+}
+
+// Days returns the n days up to and including today, newest first.
+func (l *Ledger) Days(ctx context.Context, n int) ([]Day, error) {
+	today := l.now().UTC()
+	days := make([]Day, 0, n)
+	for i := range n {
+		day, err := l.Day(ctx, today.AddDate(0, 0, -i).Format(dateLayout))
+		if err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+// ShoppingList returns everything spent generating and using one shopping list.
+func (l *Ledger) ShoppingList(ctx context.Context, hash string) (Totals, error) {
+	var totals Totals
+	if err := l.load(ctx, listKey(hash), &totals); err != nil {
+		return Totals{}, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if pending, ok := l.pendingLists[listKey(hash)]; ok {
+		totals.merge(*pending)
+	}
+	return totals, nil
+}
+
+func (l *Ledger) load(ctx context.Context, key string, v any) error {
+	r, err := l.cache.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, cache.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	defer func() {
+		if err := r.Close(); err != nil {
+			slog.ErrorContext(ctx, "failed to close AI spend reader", "key", key, "error", err)
+		}
+	}()
+	if err := json.NewDecoder(r).Decode(v); err != nil {
+		return fmt.Errorf("decode %s: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package spend
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"careme/internal/ai"
+	"careme/internal/cache"
+	"careme/internal/config"
+	"careme/internal/logsetup"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLedger(caps config.SpendConfig) *Ledger {
+	l := NewLedger(cache.NewInMemoryCache(), caps)
+	l.now = func() time.Time { return time.Date(2026, time.May, 11, 23, 30, 0, 0, time.FixedZone("PDT", -7*60*60)) }
+	return l
+}
+
+func TestLedgerAddsUpDayUserAndList(t *testing.T) {
+	l := newTestLedger(config.SpendConfig{})
+	ctx := WithShoppingList(logsetup.WithUserID(t.Context(), "user_1"), "list-1")
+
+	l.RecordUsage(ctx, ai.Usage{Category: "menu", Model: "gpt-5.4", InputTokens: 1000, OutputTokens: 200, CostUSD: 0.01, Priced: true})
+	l.RecordUsage(ctx, ai.Usage{Category: "recipe", Model: "gpt-5.4", InputTokens: 2000, CachedInputTokens: 1500, OutputTokens: 800, CostUSD: 0.02, Priced: true})
+	l.RecordUsage(t.Context(), ai.Usage{Category: "ingredient_grading", Model: "mystery", InputTokens: 50, OutputTokens: 5})
+
+	day, err := l.Day(t.Context(), "2026-05-12")
+	require.NoError(t, err)
+	assert.Equal(t, "2026-05-12", day.Date, "days are UTC")
+	assert.Equal(t, 3, day.Calls)
+	assert.Equal(t, 1, day.UnpricedCalls)
+	assert.Equal(t, int64(3050), day.InputTokens)
+	assert.InDelta(t, 0.03, day.CostUSD, 1e-9)
+	assert.Equal(t, 1, day.Categories["ingredient_grading"].Calls)
+	assert.InDelta(t, 0.03, day.Users["user_1"].CostUSD, 1e-9)
+	assert.Len(t, day.Users, 1, "calls without a user only count toward the day")
+	assert.Equal(t, 2, day.Lists["list-1"].Calls)
+
+	list, err := l.ShoppingList(t.Context(), "list-1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, list.Calls)
+	assert.Equal(t, int64(1500), list.Categories["recipe"].CachedInputTokens)
+
+	days, err := l.Days(t.Context(), 3)
+	require.NoError(t, err)
+	require.Len(t, days, 3)
+	assert.Equal(t, []string{"2026-05-12", "2026-05-11", "2026-05-10"}, []string{days[0].Date, days[1].Date, days[2].Date})
+	assert.Zero(t, days[1].Calls)
+}
+
+func TestLedgerAllowDegradesAtDailyCap(t *testing.T) {
+	l := newTestLedger(config.SpendConfig{DailyCapUSD: 1})
+	ctx := t.Context()
+	require.NoError(t, l.Allow(ctx, "image"))
+
+	l.RecordUsage(ctx, ai.Usage{Category: "recipe", CostUSD: 1.25, Priced: true})
+
+	for _, category := range []string{"image", "wine", "critique", "farmers_market"} {
+		assert.ErrorIs(t, l.Allow(ctx, category), ai.ErrSpendCapReached, category)
+	}
+	for _, category := range []string{"menu", "recipe_question"} {
+		assert.NoError(t, l.Allow(ctx, category), "degrade mode keeps %s going", category)
+	}
+}
+
+func TestLedgerAllowRefusesAtUserCap(t *testing.T) {
+	l := newTestLedger(config.SpendConfig{UserDailyCapUSD: 0.5, CapMode: config.SpendCapRefuse})
+	heavy := logsetup.WithUserID(t.Context(), "user_heavy")
+	light := logsetup.WithUserID(t.Context(), "user_light")
+
+	l.RecordUsage(heavy, ai.Usage{Category: "menu", CostUSD: 0.5, Priced: true})
+
+	err := l.Allow(heavy, "menu")
+	assert.ErrorIs(t, err, ai.ErrSpendCapReached)
+	assert.Contains(t, err.Error(), "your $0.50")
+	assert.NoError(t, l.Allow(light, "menu"))
+	assert.NoError(t, l.Allow(t.Context(), "menu"), "a user cap doesn't apply without a user")
+}
+
+func TestLedgerFlushKeepsEveryCallAndSharesIt(t *testing.T) {
+	c := cache.NewInMemoryCache()
+	now := func() time.Time { return time.Date(2026, time.May, 12, 18, 0, 0, 0, time.UTC) }
+	l := NewLedger(c, config.SpendConfig{})
+	l.now = now
+
+	var wg sync.WaitGroup
+	for i := range 200 {
+		wg.Go(func() {
+			ctx := WithShoppingList(logsetup.WithUserID(t.Context(), "user_"+strconv.Itoa(i)), "list-1")
+			l.RecordUsage(ctx, ai.Usage{Category: "recipe", CostUSD: 0.01, Priced: true})
+		})
+	}
+	wg.Wait()
+	written, err := c.Exists(t.Context(), dayKey("2026-05-12"))
+	require.NoError(t, err)
+	assert.False(t, written, "recording doesn't write")
+	require.NoError(t, l.Flush(t.Context()))
+
+	day, err := l.Day(t.Context(), "2026-05-12")
+	require.NoError(t, err)
+	assert.Equal(t, 200, day.Calls)
+	assert.InDelta(t, 2.0, day.CostUSD, 1e-9)
+	assert.Len(t, day.Users, dayBreakdown, "the day only keeps the costliest users")
+	list, err := l.ShoppingList(t.Context(), "list-1")
+	require.NoError(t, err)
+	assert.Equal(t, 200, list.Calls)
+
+	other := NewLedger(c, config.SpendConfig{DailyCapUSD: 2, UserDailyCapUSD: 0.01})
+	other.now = now
+	err = other.Allow(t.Context(), "image")
+	assert.ErrorIs(t, err, ai.ErrSpendCapReached, "another replica sees the flushed day")
+	other.caps.DailyCapUSD = 0
+	assert.ErrorIs(t, other.Allow(logsetup.WithUserID(t.Context(), "user_199"), "image"), ai.ErrSpendCapReached, "and each user's full total")
+	assert.NoError(t, other.Allow(logsetup.WithUserID(t.Context(), "user_200"), "image"))
+}
+
+func TestAdminPage(t *testing.T) {
+	l := newTestLedger(config.SpendConfig{DailyCapUSD: 20})
+	ctx := WithShoppingList(logsetup.WithUserID(t.Context(), "user_1"), "list-1")
+	l.RecordUsage(ctx, ai.Usage{Category: "critique", CostUSD: 0.004, Priced: true})
+	l.RecordUsage(ctx, ai.Usage{Category: "menu", CostUSD: 0.01, Priced: true})
+	handler := AdminPage(l)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/spend?list=list-1", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	assert.Contains(t, body, "$20.00 a day")
+	assert.Contains(t, body, "user_1")
+	assert.Contains(t, body, `href="/admin/spend?list=list-1"`)
+	assert.Contains(t, body, "$0.0140")
+	assert.Less(t, strings.Index(body, "<td>menu</td>"), strings.Index(body, "<td>critique</td>"), "categories keep their report order")
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/spend?date=yesterday", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
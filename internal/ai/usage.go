@@ -0,0 +1,99 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/responses"
+)
+
+// ErrSpendCapReached is returned instead of calling a model once a daily spend cap is hit.
+var ErrSpendCapReached = errors.New("daily AI spend cap reached")
+
+// Usage is what one model call consumed. CostUSD is an estimate and only
+// meaningful when Priced; models without a configured price record tokens alone.
+type Usage struct {
+	Category          string
+	Model             string
+	InputTokens       int64
+	CachedInputTokens int64
+	OutputTokens      int64
+	CostUSD           float64
+	Priced            bool
+}
+
+// UsageMeter adds up every model call and can hold back new ones. Allow is
+// asked before menus, questions and the optional extras (images, wine,
+// critique, farmers market photos); recipes and grading are never held back
+// so a list that already has its menu plan can finish.
+type UsageMeter interface {
+	RecordUsage(ctx context.Context, usage Usage)
+	Allow(ctx context.Context, category string) error
+}
+
+var usageMeter atomic.Pointer[UsageMeter]
+
+// SetUsageMeter sends the usage of every client in the process to m, the way
+// slog.SetDefault does for logs. nil turns metering off.
+func SetUsageMeter(m UsageMeter) {
+	if m == nil {
+		usageMeter.Store(nil)
+		return
+	}
+	usageMeter.Store(&m)
+}
+
+func recordUsage(ctx context.Context, usage Usage) {
+	if m := usageMeter.Load(); m != nil {
+		(*m).RecordUsage(ctx, usage)
+	}
+}
+
+func allowUsage(ctx context.Context, category string) error {
+	if m := usageMeter.Load(); m != nil {
+		return (*m).Allow(ctx, category)
+	}
+	return nil
+}
+
+func responseUsage(category, model string, usage responses.ResponseUsage) Usage {
+	spend := estimateOpenAIResponseSpend(model, usage.InputTokens, usage.InputTokensDetails.CachedTokens, usage.InputTokensDetails.CacheWriteTokens, usage.OutputTokens)
+	return Usage{
+		Category:          category,
+		Model:             model,
+		InputTokens:       usage.InputTokens,
+		CachedInputTokens: usage.InputTokensDetails.CachedTokens,
+		OutputTokens:      usage.OutputTokens,
+		CostUSD:           roundUSD(spend.totalUSD()),
+		Priced:            spend.reason == "",
+	}
+}
+
+func imageUsage(model string, usage openai.ImagesResponseUsage) Usage {
+	spend := estimateOpenAIImageSpend(model, usage.InputTokensDetails.TextTokens, usage.InputTokensDetails.ImageTokens, usage.OutputTokens)
+	return Usage{
+		Category:     aiCategoryImage,
+		Model:        model,
+		InputTokens:  usage.InputTokens,
+		OutputTokens: usage.OutputTokens,
+		CostUSD:      roundUSD(spend.totalUSD()),
+		Priced:       spend.reason == "",
+	}
+}
+
+// chatUsage prices OpenRouter calls with the cost it reports. Local servers don't bill.
+func chatUsage(category, model string, resp *openai.ChatCompletion, local bool) Usage {
+	usage := Usage{Category: category, Model: model, Priced: local}
+	if resp == nil {
+		return usage
+	}
+	usage.InputTokens = resp.Usage.PromptTokens
+	usage.CachedInputTokens = resp.Usage.PromptTokensDetails.CachedTokens
+	usage.OutputTokens = resp.Usage.CompletionTokens
+	if !local {
+		usage.CostUSD, usage.Priced = openRouterResponseCost(resp.RawJSON())
+	}
+	return usage
+}
@@ -0,0 +1,57 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeMeter struct {
+	mu      sync.Mutex
+	usage   []Usage
+	blocked map[string]bool
+}
+
+func (m *fakeMeter) RecordUsage(_ context.Context, u Usage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.usage = append(m.usage, u)
+}
+
+func (m *fakeMeter) Allow(_ context.Context, category string) error {
+	if m.blocked[category] {
+		return fmt.Errorf("%w: test", ErrSpendCapReached)
+	}
+	return nil
+}
+
+func useMeter(t *testing.T, m UsageMeter) {
+	t.Helper()
+	SetUsageMeter(m)
+	t.Cleanup(func() { SetUsageMeter(nil) })
+}
+
+func TestUsageMeterSeesCallsAndHoldsBackCapped(t *testing.T) {
+	fake, baseURL := newFakeChatServer(t, map[string]string{
+		"recipe_critique": `{"schema_version":"recipe-critique-v1","overall_score":7,"summary":"Needs salt.","strengths":[],"issues":[],"suggested_fixes":[]}`,
+		"":                "Yes, use tofu.",
+	})
+	meter := &fakeMeter{blocked: map[string]bool{aiCategoryCritique: true}}
+	useMeter(t, meter)
+
+	_, err := NewLocalCritiquer(baseURL, "", "local-model", nil).CritiqueRecipe(t.Context(), Recipe{Title: "Roast Chicken"})
+	assert.ErrorIs(t, err, ErrSpendCapReached)
+	assert.Empty(t, fake.requests, "a capped category never reaches the model")
+
+	client := NewChatClient(baseURL, "", "local-model", nil, nil, nil)
+	require.NoError(t, client.conversations.SaveConversation(t.Context(), "chat_1", []PromptMessage{{Role: "user", Content: "Roast chicken please"}}))
+	_, err = client.AskQuestion(t.Context(), "Can I use tofu?", ResponseRef{ID: "chat_1"})
+	require.NoError(t, err)
+
+	require.Len(t, meter.usage, 1)
+	assert.Equal(t, Usage{Category: aiCategoryRecipeQuestion, Model: "local-model", InputTokens: 10, OutputTokens: 5, Priced: true}, meter.usage[0], "local models don't bill")
+}
@@ -28,6 +28,9 @@ Also for fancier/more expensive dishes consider more expensive wines.
 `
 
 func (c *client) PickWine(ctx context.Context, recipe Recipe, wines []InputIngredient) (*WineSelection, error) {
+	if err := allowUsage(ctx, aiCategoryWine); err != nil {
+		return nil, err
+	}
 	prompt, err := buildWineSelectionPrompt(recipe, wines)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build wine selection prompt: %w", err)
@@ -46,6 +49,7 @@ func (c *client) PickWine(ctx context.Context, recipe Recipe, wines []InputIngre
 		return nil, fmt.Errorf("failed to pick wine: %w", err)
 	}
 	slog.InfoContext(ctx, "API usage", "ai_category", aiCategoryWine, "model", c.wineModel, responseUsageLogAttr(c.wineModel, resp.Usage))
+	recordUsage(ctx, responseUsage(aiCategoryWine, c.wineModel, resp.Usage))
 
 	var selection WineSelection
 	if err := json.Unmarshal([]byte(resp.OutputText()), &selection); err != nil {
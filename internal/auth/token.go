@@ -0,0 +1,249 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"careme/internal/cache"
+	"careme/internal/logsetup"
+	"careme/internal/routing"
+)
+
+// API tokens let scripts and the Android app call /api/v1 without a Clerk session.
+// Only a hash of the token is stored so a leaked cache doesn't leak tokens.
+const (
+	TokenPrefix    = "cm_"
+	tokenKeyPrefix = "apitokens/"
+	// ScopeCalendar tokens only read a user's calendar feed, so a feed link
+	// shared with a calendar app can't act for the user anywhere else.
+	ScopeCalendar = "calendar"
+)
+
+type tokenRecord struct {
+	UserID string `json:"user_id"`
+	// Scope limits the token to one use; empty is the full API.
+	Scope     string     `json:"scope,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+type TokenStore struct {
+	cache cache.Cache
+}
+
+func NewTokenStore(c cache.Cache) *TokenStore {
+	return &TokenStore{cache: c}
+}
+
+func tokenKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return tokenKeyPrefix + hex.EncodeToString(sum[:])
+}
+
+// Issue creates a new token for userID. The token is only returned here.
+func (ts *TokenStore) Issue(ctx context.Context, userID string) (string, error) {
+	return ts.IssueScoped(ctx, userID, "")
+}
+
+// IssueScoped creates a new token for userID that only works for scope.
+func (ts *TokenStore) IssueScoped(ctx context.Context, userID, scope string) (string, error) {
+	if strings.TrimSpace(userID) == "" {
+		return "", errors.New("user id is required")
+	}
+	token := TokenPrefix + rand.Text()
+	if err := ts.save(ctx, token, tokenRecord{UserID: userID, Scope: scope, CreatedAt: time.Now()}, cache.IfNoneMatch()); err != nil {
+		return "", fmt.Errorf("failed to save api token: %w", err)
+	}
+	return token, nil
+}
+
+// UserID resolves a token to its user. Unknown, revoked and scoped tokens are ErrNoSession.
+func (ts *TokenStore) UserID(ctx context.Context, token string) (string, error) {
+	return ts.ScopedUserID(ctx, token, "")
+}
+
+// ScopedUserID resolves a token issued for scope to its user.
+func (ts *TokenStore) ScopedUserID(ctx context.Context, token, scope string) (string, error) {
+	record, err := ts.load(ctx, token)
+	if err != nil {
+		return "", err
+	}
+	if record.RevokedAt != nil || record.Scope != scope {
+		return "", ErrNoSession
+	}
+	return record.UserID, nil
+}
+
+// Revoke keeps the record around, marked revoked, so we can tell a revoked
+// token from one we never issued when looking at logs.
+func (ts *TokenStore) Revoke(ctx context.Context, token string) error {
+	record, err := ts.load(ctx, token)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	record.RevokedAt = &now
+	return ts.save(ctx, token, *record, cache.Unconditional())
+}
+
+func (ts *TokenStore) load(ctx context.Context, token string) (*tokenRecord, error) {
+	if !strings.HasPrefix(token, TokenPrefix) {
+		return nil, ErrNoSession
+	}
+	blob, err := ts.cache.Get(ctx, tokenKey(token))
+	if err != nil {
+		if errors.Is(err, cache.ErrNotFound) {
+			return nil, ErrNoSession
+		}
+		return nil, err
+	}
+	defer func() {
+		if err := blob.Close(); err != nil {
+			slog.ErrorContext(ctx, "failed to close api token reader", "error", err)
+		}
+	}()
+	var record tokenRecord
+	if err := json.NewDecoder(blob).Decode(&record); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal api token: %w", err)
+	}
+	return &record, nil
+}
+
+func (ts *TokenStore) save(ctx context.Context, token string, record tokenRecord, opts cache.PutOptions) error {
+	recordBytes, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal api token: %w", err)
+	}
+	return ts.cache.Put(ctx, tokenKey(token), string(recordBytes), opts)
+}
+
+// BearerToken returns the API token from an Authorization header. Clerk session
+// JWTs also arrive as bearer tokens, so anything without our prefix is ignored.
+func BearerToken(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	token = strings.TrimSpace(token)
+	if !ok || !strings.HasPrefix(token, TokenPrefix) {
+		return "", false
+	}
+	return token, true
+}
+
+type tokenUserKey struct{}
+
+// tokenClient accepts API tokens in front of another AuthClient. Requests with an
+// API token never reach the inner middleware since Clerk would treat the token as
+// a bad session and redirect.
+type tokenClient struct {
+	AuthClient
+	tokens *TokenStore
+}
+
+var _ AuthClient = (*tokenClient)(nil)
+
+// WithTokens wraps inner so API tokens work everywhere a session does.
+func WithTokens(inner AuthClient, tokens *TokenStore) AuthClient {
+	return &tokenClient{AuthClient: inner, tokens: tokens}
+}
+
+func (c *tokenClient) GetUserIDFromRequest(r *http.Request) (string, error) {
+	if userID, ok := r.Context().Value(tokenUserKey{}).(string); ok {
+		return userID, nil
+	}
+	if _, ok := BearerToken(r); ok {
+		// a token that got past WithAuthHTTP resolved to nobody.
+		return "", ErrNoSession
+	}
+	return c.AuthClient.GetUserIDFromRequest(r)
+}
+
+func (c *tokenClient) WithAuthHTTP(handler http.Handler) http.Handler {
+	inner := c.AuthClient.WithAuthHTTP(handler)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := BearerToken(r)
+		if !ok {
+			inner.ServeHTTP(w, r)
+			return
+		}
+		userID, err := c.tokens.UserID(r.Context(), token)
+		if err != nil {
+			if !errors.Is(err, ErrNoSession) {
+				slog.ErrorContext(r.Context(), "failed to look up api token", "error", err)
+				http.Error(w, "unable to check token", http.StatusInternalServerError)
+				return
+			}
+			http.Error(w, "invalid api token", http.StatusUnauthorized)
+			return
+		}
+		// logsetup carries the user to logs and spend accounting like a session would.
+		ctx := logsetup.WithUserID(context.WithValue(r.Context(), tokenUserKey{}, userID), userID)
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (c *tokenClient) Register(mux routing.Registrar) {
+	c.AuthClient.Register(mux)
+	mux.HandleFunc("POST /api/v1/tokens", c.handleIssue)
+	mux.HandleFunc("DELETE /api/v1/tokens", c.handleRevoke)
+}
+
+// handleIssue needs a browser session; a token can't mint more tokens.
+func (c *tokenClient) handleIssue(w http.ResponseWriter, r *http.Request) {
+	if _, ok := BearerToken(r); ok {
+		http.Error(w, "sign in to create api tokens", http.StatusForbidden)
+		return
+	}
+	userID, err := c.AuthClient.GetUserIDFromRequest(r)
+	if err != nil {
+		if errors.Is(err, ErrNoSession) {
+			http.Error(w, "no valid session found", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "unable to load account", http.StatusInternalServerError)
+		return
+	}
+	token, err := c.tokens.Issue(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to issue api token", "user_id", userID, "error", err)
+		http.Error(w, "failed to create token", http.StatusInternalServerError)
+		return
+	}
+	slog.InfoContext(r.Context(), "issued api token", "user_id", userID)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(struct {
+		Token string `json:"token"`
+	}{
+		Token: token,
+	}); err != nil {
+		slog.ErrorContext(r.Context(), "api token encode failed", "user_id", userID, "error", err)
+	}
+}
+
+// handleRevoke revokes the token the request was made with.
+func (c *tokenClient) handleRevoke(w http.ResponseWriter, r *http.Request) {
+	token, ok := BearerToken(r)
+	if !ok {
+		http.Error(w, "api token required", http.StatusBadRequest)
+		return
+	}
+	if err := c.tokens.Revoke(r.Context(), token); err != nil {
+		if errors.Is(err, ErrNoSession) {
+			http.Error(w, "invalid api token", http.StatusUnauthorized)
+			return
+		}
+		slog.ErrorContext(r.Context(), "failed to revoke api token", "error", err)
+		http.Error(w, "failed to revoke token", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
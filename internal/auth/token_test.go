@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"careme/internal/cache"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenStoreIssueAndRevoke(t *testing.T) {
+	c := cache.NewInMemoryCache()
+	tokens := NewTokenStore(c)
+
+	token, err := tokens.Issue(t.Context(), "user-1")
+	require.NoError(t, err)
+	assert.Regexp(t, `^cm_\w+$`, token)
+
+	userID, err := tokens.UserID(t.Context(), token)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", userID)
+
+	exists, err := c.Exists(t.Context(), tokenKeyPrefix+token)
+	require.NoError(t, err)
+	assert.False(t, exists, "tokens are stored hashed")
+
+	require.NoError(t, tokens.Revoke(t.Context(), token))
+	_, err = tokens.UserID(t.Context(), token)
+	assert.ErrorIs(t, err, ErrNoSession)
+
+	_, err = tokens.UserID(t.Context(), "cm_never-issued")
+	assert.ErrorIs(t, err, ErrNoSession)
+}
+
+func TestTokenStoreScopedTokensOnlyWorkForTheirScope(t *testing.T) {
+	tokens := NewTokenStore(cache.NewInMemoryCache())
+	token, err := tokens.IssueScoped(t.Context(), "user-1", ScopeCalendar)
+	require.NoError(t, err)
+
+	userID, err := tokens.ScopedUserID(t.Context(), token, ScopeCalendar)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", userID)
+	_, err = tokens.UserID(t.Context(), token)
+	assert.ErrorIs(t, err, ErrNoSession, "a calendar link isn't an API token")
+
+	full, err := tokens.Issue(t.Context(), "user-1")
+	require.NoError(t, err)
+	_, err = tokens.ScopedUserID(t.Context(), full, ScopeCalendar)
+	assert.ErrorIs(t, err, ErrNoSession)
+
+	require.NoError(t, tokens.Revoke(t.Context(), token))
+	_, err = tokens.ScopedUserID(t.Context(), token, ScopeCalendar)
+	assert.ErrorIs(t, err, ErrNoSession)
+}
+
+func TestWithTokensResolvesBearerTokenUser(t *testing.T) {
+	tokens := NewTokenStore(cache.NewInMemoryCache())
+	client := WithTokens(DefaultMock(), tokens)
+	token, err := tokens.Issue(t.Context(), "user-1")
+	require.NoError(t, err)
+
+	var gotUser string
+	handler := client.WithAuthHTTP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUser, err = client.GetUserIDFromRequest(r)
+		require.NoError(t, err)
+	}))
+	serve := func(authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/recipes/abc", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve("Bearer " + token)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "user-1", gotUser)
+
+	// no token falls through to the session client.
+	rr = serve("")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "mock-clerk-user-id", gotUser)
+
+	gotUser = ""
+	rr = serve("Bearer cm_bogus")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Empty(t, gotUser)
+}
+
+func TestTokenRoutes(t *testing.T) {
+	tokens := NewTokenStore(cache.NewInMemoryCache())
+	client := WithTokens(DefaultMock(), tokens)
+	mux := http.NewServeMux()
+	client.Register(mux)
+	handler := client.WithAuthHTTP(mux)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/v1/tokens", nil))
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var issued struct {
+		Token string `json:"token"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &issued))
+	userID, err := tokens.UserID(t.Context(), issued.Token)
+	require.NoError(t, err)
+	assert.Equal(t, "mock-clerk-user-id", userID)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/tokens", nil)
+	req.Header.Set("Authorization", "Bearer "+issued.Token)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusForbidden, rr.Code, "tokens can't mint tokens")
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/tokens", nil)
+	req.Header.Set("Authorization", "Bearer "+issued.Token)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	_, err = tokens.UserID(t.Context(), issued.Token)
+	assert.ErrorIs(t, err, ErrNoSession)
+}
@@ -94,16 +94,25 @@ func (fc *BlobCache) Exists(ctx context.Context, key string) (bool, error) {
 }
 
 func (fc *BlobCache) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	body, _, err := fc.GetWithETag(ctx, key)
+	return body, err
+}
+
+func (fc *BlobCache) GetWithETag(ctx context.Context, key string) (io.ReadCloser, string, error) {
 	stream, err := fc.container.NewBlockBlobClient(key).DownloadStream(ctx, &azblob.DownloadStreamOptions{})
 	if err != nil {
 		if bloberror.HasCode(err, bloberror.BlobNotFound) {
-			return nil, ErrNotFound
+			return nil, "", ErrNotFound
 		}
 		log.Printf("failed to download blob: %v", err)
-		return nil, err
+		return nil, "", err
 	}
 
-	return stream.Body, nil
+	var etag string
+	if stream.ETag != nil {
+		etag = string(*stream.ETag)
+	}
+	return stream.Body, etag, nil
 }
 
 func (fc *BlobCache) Put(ctx context.Context, key, value string, opts PutOptions) error {
@@ -112,18 +121,26 @@ func (fc *BlobCache) Put(ctx context.Context, key, value string, opts PutOptions
 
 func (fc *BlobCache) PutReader(ctx context.Context, key string, reader io.Reader, opts PutOptions) error {
 	var access *blob.AccessConditions
-	if opts.Condition == PutIfNoneMatch {
+	switch opts.Condition {
+	case PutIfNoneMatch:
 		access = &blob.AccessConditions{}
 		access.ModifiedAccessConditions = &blob.ModifiedAccessConditions{}
 		any := azcore.ETag("*")
 		access.ModifiedAccessConditions.IfNoneMatch = &any
-		// TODO: IfMatch support.
+	case PutIfMatch:
+		access = &blob.AccessConditions{}
+		access.ModifiedAccessConditions = &blob.ModifiedAccessConditions{}
+		etag := azcore.ETag(opts.ETag)
+		access.ModifiedAccessConditions.IfMatch = &etag
 	}
 
 	_, err := fc.container.NewBlockBlobClient(key).UploadStream(ctx, reader, &azblob.UploadStreamOptions{
 		AccessConditions: access,
 	})
 	if err != nil {
+		if opts.Condition == PutIfMatch && bloberror.HasCode(err, bloberror.BlobNotFound, bloberror.ConditionNotMet) {
+			return ErrPreconditionFailed
+		}
 		if bloberror.HasCode(err, bloberror.BlobAlreadyExists, bloberror.ResourceAlreadyExists) {
 			return ErrAlreadyExists
 		}
@@ -159,5 +176,10 @@ func EnsureCache(container string) (ListCache, error) {
 		// can pas in  otelhttp.NewTransport(http.DefaultTransport) but it creates a lot of noise
 		return NewBlobCache(container, http.DefaultTransport)
 	}
+	_, ok = os.LookupEnv("S3_ENDPOINT")
+	if ok {
+		slog.Info("Using S3-compatible object storage for cache", "bucket", os.Getenv("S3_BUCKET_PREFIX")+container)
+		return NewS3Cache(container, http.DefaultTransport)
+	}
 	return NewFileCache(container), nil
 }
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCacheContract is what callers rely on from every ListCache backend.
+func testCacheContract(t *testing.T, c ListCache) {
+	t.Helper()
+	ctx := t.Context()
+
+	get := func(key string) string {
+		t.Helper()
+		r, err := c.Get(ctx, key)
+		require.NoError(t, err)
+		defer r.Close()
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	_, err := c.Get(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+	exists, err := c.Exists(ctx, "missing")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	require.NoError(t, c.Put(ctx, "lists/one", "first", Unconditional()))
+	assert.Equal(t, "first", get("lists/one"))
+	exists, err = c.Exists(ctx, "lists/one")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	require.NoError(t, c.Put(ctx, "lists/one", "second", Unconditional()))
+	assert.Equal(t, "second", get("lists/one"))
+
+	err = c.Put(ctx, "lists/one", "third", IfNoneMatch())
+	assert.ErrorIs(t, err, ErrAlreadyExists)
+	assert.Equal(t, "second", get("lists/one"))
+
+	require.NoError(t, c.PutReader(ctx, "lists/two", io.NopCloser(strings.NewReader("from a reader")), IfNoneMatch()))
+	assert.Equal(t, "from a reader", get("lists/two"))
+	require.NoError(t, c.Put(ctx, "lists/nested/three", "3", Unconditional()))
+	require.NoError(t, c.Put(ctx, "other/four", "4", Unconditional()))
+
+	reader, etag, err := c.GetWithETag(ctx, "lists/one")
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	assert.NotEmpty(t, etag)
+	require.NoError(t, c.Put(ctx, "lists/one", "fourth", IfMatch(etag)))
+	assert.Equal(t, "fourth", get("lists/one"))
+	err = c.Put(ctx, "lists/one", "stale", IfMatch(etag))
+	assert.ErrorIs(t, err, ErrPreconditionFailed, "the etag went stale with the last put")
+	assert.Equal(t, "fourth", get("lists/one"))
+	err = c.Put(ctx, "lists/missing", "nope", IfMatch(etag))
+	assert.ErrorIs(t, err, ErrPreconditionFailed)
+	_, _, err = c.GetWithETag(ctx, "lists/missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	keys, err := c.List(ctx, "lists/", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"nested/three", "one", "two"}, keys)
+
+	keys, err = c.List(ctx, "nothing/", "")
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+func TestFileCacheContract(t *testing.T) {
+	testCacheContract(t, NewFileCache(filepath.Join(t.TempDir(), "cache")))
+}
+
+func TestInMemoryCacheContract(t *testing.T) {
+	testCacheContract(t, NewInMemoryCache())
+}
@@ -1,18 +1,24 @@
 package cache
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"io"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 )
 
 var (
 	ErrNotFound      = errors.New("cache entry not found")
 	ErrAlreadyExists = errors.New("cache entry already exists")
+	// ErrPreconditionFailed means an IfMatch put lost to another write since the entry was read.
+	ErrPreconditionFailed = errors.New("cache entry changed since it was read")
 )
 
 type PutCondition uint8
@@ -20,13 +26,13 @@ type PutCondition uint8
 const (
 	PutUnconditional PutCondition = iota
 	PutIfNoneMatch
-	// PutIfMatch
+	PutIfMatch
 )
 
 type PutOptions struct {
 	Condition PutCondition
-	// IfMatch updates the entry only if the current ETag matches this value.
-	// IfMatch string
+	// ETag is the version a PutIfMatch expects to replace, as returned by GetWithETag.
+	ETag string
 }
 
 func Unconditional() PutOptions {
@@ -37,8 +43,14 @@ func IfNoneMatch() PutOptions {
 	return PutOptions{Condition: PutIfNoneMatch}
 }
 
+func IfMatch(etag string) PutOptions {
+	return PutOptions{Condition: PutIfMatch, ETag: etag}
+}
+
 type Cache interface {
 	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// GetWithETag is Get plus the entry's current version for a later IfMatch put.
+	GetWithETag(ctx context.Context, key string) (io.ReadCloser, string, error)
 	Exists(ctx context.Context, key string) (bool, error)
 	Put(ctx context.Context, key, value string, opts PutOptions) error
 	PutReader(ctx context.Context, key string, reader io.Reader, opts PutOptions) error
@@ -113,6 +125,27 @@ func (fc *FileCache) Get(_ context.Context, key string) (io.ReadCloser, error) {
 	return data, nil
 }
 
+func (fc *FileCache) GetWithETag(_ context.Context, key string) (io.ReadCloser, string, error) {
+	data, err := os.ReadFile(filepath.Join(fc.Dir, key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, "", ErrNotFound
+		}
+		return nil, "", err
+	}
+	return io.NopCloser(bytes.NewReader(data)), fileETag(data), nil
+}
+
+// files have no version of their own so the ETag is a hash of the contents.
+func fileETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// fileIfMatchMu makes the compare and rename of IfMatch puts one step. It only
+// covers this process; separate processes sharing a directory can still race.
+var fileIfMatchMu sync.Mutex
+
 func (fc *FileCache) Put(ctx context.Context, key, value string, opts PutOptions) error {
 	return fc.PutReader(ctx, key, strings.NewReader(value), opts)
 }
@@ -124,11 +157,23 @@ func (fc *FileCache) PutReader(_ context.Context, key string, reader io.Reader,
 		return err
 	}
 
-	if opts.Condition == PutIfNoneMatch {
+	switch opts.Condition {
+	case PutIfNoneMatch:
 		return writeIfNoneMatchAtomic(dir, fullPath, reader)
+	case PutIfMatch:
+		fileIfMatchMu.Lock()
+		defer fileIfMatchMu.Unlock()
+		current, err := os.ReadFile(fullPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return ErrPreconditionFailed
+			}
+			return err
+		}
+		if fileETag(current) != opts.ETag {
+			return ErrPreconditionFailed
+		}
 	}
-
-	// TODO: IfMatch support (write only if etag matches).
 	return writeAtomic(dir, fullPath, reader)
 }
 
@@ -5,6 +5,7 @@ import (
 	"context"
 	"io"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 )
@@ -12,7 +13,14 @@ import (
 // InMemoryCache stores cache entries in process memory.
 type InMemoryCache struct {
 	mu   sync.RWMutex
-	data map[string][]byte
+	data map[string]memoryEntry
+	// version is bumped on every put and becomes that entry's ETag.
+	version uint64
+}
+
+type memoryEntry struct {
+	value []byte
+	etag  string
 }
 
 var (
@@ -22,18 +30,23 @@ var (
 
 func NewInMemoryCache() *InMemoryCache {
 	return &InMemoryCache{
-		data: make(map[string][]byte),
+		data: make(map[string]memoryEntry),
 	}
 }
 
-func (c *InMemoryCache) Get(_ context.Context, key string) (io.ReadCloser, error) {
+func (c *InMemoryCache) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	reader, _, err := c.GetWithETag(ctx, key)
+	return reader, err
+}
+
+func (c *InMemoryCache) GetWithETag(_ context.Context, key string) (io.ReadCloser, string, error) {
 	c.mu.RLock()
-	value, ok := c.data[key]
+	entry, ok := c.data[key]
 	c.mu.RUnlock()
 	if !ok {
-		return nil, ErrNotFound
+		return nil, "", ErrNotFound
 	}
-	return io.NopCloser(bytes.NewReader(value)), nil
+	return io.NopCloser(bytes.NewReader(entry.value)), entry.etag, nil
 }
 
 func (c *InMemoryCache) Exists(_ context.Context, key string) (bool, error) {
@@ -56,13 +69,20 @@ func (c *InMemoryCache) PutReader(_ context.Context, key string, reader io.Reade
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if opts.Condition == PutIfNoneMatch {
-		if _, exists := c.data[key]; exists {
+	current, exists := c.data[key]
+	switch opts.Condition {
+	case PutIfNoneMatch:
+		if exists {
 			return ErrAlreadyExists
 		}
+	case PutIfMatch:
+		if !exists || current.etag != opts.ETag {
+			return ErrPreconditionFailed
+		}
 	}
 
-	c.data[key] = buf.Bytes()
+	c.version++
+	c.data[key] = memoryEntry{value: buf.Bytes(), etag: strconv.FormatUint(c.version, 10)}
 	return nil
 }
 
@@ -0,0 +1,197 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3Cache stores entries as objects in an S3-compatible bucket (AWS, MinIO, ...).
+type S3Cache struct {
+	client *s3.Client
+	bucket string
+	// pageSize is MaxKeys for each List request; zero leaves it to the server.
+	pageSize int32
+}
+
+var _ ListCache = (*S3Cache)(nil)
+
+// NewS3Cache uses the bucket named after the container, behind S3_BUCKET_PREFIX
+// if set since bucket names are global on AWS. The bucket must already exist.
+func NewS3Cache(container string, transport http.RoundTripper) (*S3Cache, error) {
+	endpoint, ok := os.LookupEnv("S3_ENDPOINT")
+	if !ok {
+		return nil, fmt.Errorf("S3_ENDPOINT could not be found")
+	}
+
+	accessKey, ok := os.LookupEnv("S3_ACCESS_KEY_ID")
+	if !ok {
+		return nil, fmt.Errorf("S3_ACCESS_KEY_ID could not be found")
+	}
+
+	secretKey, ok := os.LookupEnv("S3_SECRET_ACCESS_KEY")
+	if !ok {
+		return nil, fmt.Errorf("S3_SECRET_ACCESS_KEY could not be found")
+	}
+
+	region := os.Getenv("S3_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	client := s3.New(s3.Options{
+		BaseEndpoint: aws.String(endpoint),
+		Region:       region,
+		Credentials:  credentials.NewStaticCredentialsProvider(accessKey, secretKey, ""),
+		HTTPClient:   &http.Client{Transport: transport},
+		// MinIO and friends serve buckets under the path rather than as subdomains.
+		UsePathStyle: true,
+		// not every S3-compatible store understands the newer default checksums.
+		RequestChecksumCalculation: aws.RequestChecksumCalculationWhenRequired,
+		ResponseChecksumValidation: aws.ResponseChecksumValidationWhenRequired,
+	})
+
+	return &S3Cache{
+		client: client,
+		bucket: os.Getenv("S3_BUCKET_PREFIX") + container,
+	}, nil
+}
+
+// List follows every page for prefix. A non-empty token is the last key the
+// caller already has (relative to prefix) and the listing resumes after it.
+func (sc *S3Cache) List(ctx context.Context, prefix string, token string) ([]string, error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket: &sc.bucket,
+		Prefix: &prefix,
+	}
+	if token != "" {
+		input.StartAfter = aws.String(prefix + token)
+	}
+	if sc.pageSize > 0 {
+		input.MaxKeys = aws.Int32(sc.pageSize)
+	}
+
+	var keys []string
+	pager := s3.NewListObjectsV2Paginator(sc.client, input)
+	for pager.HasMorePages() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get next page of objects: %w", err)
+		}
+		for _, object := range page.Contents {
+			keys = append(keys, strings.TrimPrefix(aws.ToString(object.Key), prefix))
+		}
+	}
+
+	return keys, nil
+}
+
+func (sc *S3Cache) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := sc.client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: &sc.bucket, Key: &key})
+	if err != nil {
+		if s3StatusCode(err) == http.StatusNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (sc *S3Cache) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	body, _, err := sc.GetWithETag(ctx, key)
+	return body, err
+}
+
+func (sc *S3Cache) GetWithETag(ctx context.Context, key string) (io.ReadCloser, string, error) {
+	out, err := sc.client.GetObject(ctx, &s3.GetObjectInput{Bucket: &sc.bucket, Key: &key})
+	if err != nil {
+		if s3StatusCode(err) == http.StatusNotFound {
+			return nil, "", ErrNotFound
+		}
+		return nil, "", err
+	}
+	return out.Body, aws.ToString(out.ETag), nil
+}
+
+func (sc *S3Cache) Put(ctx context.Context, key, value string, opts PutOptions) error {
+	return sc.PutReader(ctx, key, strings.NewReader(value), opts)
+}
+
+func (sc *S3Cache) PutReader(ctx context.Context, key string, reader io.Reader, opts PutOptions) error {
+	// the request is signed over the body so it has to be seekable.
+	body, ok := reader.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket: &sc.bucket,
+		Key:    &key,
+		Body:   body,
+	}
+	var conflict error
+	switch opts.Condition {
+	case PutIfNoneMatch:
+		input.IfNoneMatch = aws.String("*")
+		conflict = ErrAlreadyExists
+	case PutIfMatch:
+		input.IfMatch = aws.String(opts.ETag)
+		conflict = ErrPreconditionFailed
+	}
+
+	_, err := sc.client.PutObject(ctx, input)
+	if err != nil {
+		switch s3StatusCode(err) {
+		case http.StatusPreconditionFailed, http.StatusConflict:
+			// a conflict is a concurrent conditional write to the same key still
+			// in flight; S3 only lets one of them win.
+			if conflict != nil {
+				return conflict
+			}
+		case http.StatusNotFound:
+			if opts.Condition == PutIfMatch {
+				return ErrPreconditionFailed
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete checks for the object first since S3 deletes succeed whether or not it's there.
+func (sc *S3Cache) Delete(ctx context.Context, key string) error {
+	exists, err := sc.Exists(ctx, key)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	_, err = sc.client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: &sc.bucket, Key: &key})
+	return err
+}
+
+func s3StatusCode(err error) int {
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.HTTPStatusCode()
+	}
+	return 0
+}
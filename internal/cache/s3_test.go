@@ -0,0 +1,205 @@
+package cache
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 is a stand-in for MinIO: one path-style bucket with just the calls
+// S3Cache makes, including If-None-Match writes and ListObjectsV2 paging.
+type fakeS3 struct {
+	t       *testing.T
+	bucket  string
+	mu      sync.Mutex
+	objects map[string][]byte
+	// listCalls counts ListObjectsV2 requests so tests can see paging happen.
+	listCalls int
+}
+
+type fakeS3ListResult struct {
+	XMLName               xml.Name `xml:"ListBucketResult"`
+	IsTruncated           bool
+	NextContinuationToken string `xml:",omitempty"`
+	Contents              []struct{ Key string }
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		f.writeError(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	if r.Header.Get("Authorization") == "" {
+		f.writeError(w, http.StatusForbidden, "AccessDenied")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		f.list(w, r)
+	case r.Method == http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			f.writeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("ETag", fakeS3ETag(data))
+		_, _ = w.Write(data)
+	case r.Method == http.MethodHead:
+		if _, ok := f.objects[key]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			f.writeError(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		current, ok := f.objects[key]
+		if ok && r.Header.Get("If-None-Match") == "*" {
+			f.writeError(w, http.StatusPreconditionFailed, "PreconditionFailed")
+			return
+		}
+		if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+			if !ok {
+				f.writeError(w, http.StatusNotFound, "NoSuchKey")
+				return
+			}
+			if ifMatch != fakeS3ETag(current) {
+				f.writeError(w, http.StatusPreconditionFailed, "PreconditionFailed")
+				return
+			}
+		}
+		f.objects[key] = data
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f.writeError(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	f.listCalls++
+	query := r.URL.Query()
+	assert.Equal(f.t, "2", query.Get("list-type"))
+	prefix := query.Get("prefix")
+	after := query.Get("start-after")
+	// the continuation token is just the last key of the previous page.
+	if token := query.Get("continuation-token"); token != "" {
+		after = token
+	}
+	maxKeys := 1000
+	if v := query.Get("max-keys"); v != "" {
+		var err error
+		maxKeys, err = strconv.Atoi(v)
+		if err != nil {
+			f.writeError(w, http.StatusBadRequest, "InvalidArgument")
+			return
+		}
+	}
+
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) && key > after {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	var result fakeS3ListResult
+	if len(keys) > maxKeys {
+		keys = keys[:maxKeys]
+		result.IsTruncated = true
+		result.NextContinuationToken = keys[len(keys)-1]
+	}
+	for _, key := range keys {
+		result.Contents = append(result.Contents, struct{ Key string }{Key: key})
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	assert.NoError(f.t, xml.NewEncoder(w).Encode(result))
+}
+
+// like S3 for single part uploads: the quoted MD5 of the body.
+func fakeS3ETag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func (f *fakeS3) writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func newFakeS3Cache(t *testing.T) (*S3Cache, *fakeS3) {
+	t.Helper()
+	fake := &fakeS3{t: t, bucket: "test-recipes", objects: map[string][]byte{}}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	t.Setenv("S3_ENDPOINT", srv.URL)
+	t.Setenv("S3_ACCESS_KEY_ID", "minioadmin")
+	t.Setenv("S3_SECRET_ACCESS_KEY", "minioadmin")
+	t.Setenv("S3_BUCKET_PREFIX", "test-")
+	c, err := NewS3Cache("recipes", srv.Client().Transport)
+	require.NoError(t, err)
+	return c, fake
+}
+
+func TestS3CacheContract(t *testing.T) {
+	c, _ := newFakeS3Cache(t)
+	testCacheContract(t, c)
+}
+
+func TestS3CacheListPages(t *testing.T) {
+	c, fake := newFakeS3Cache(t)
+	c.pageSize = 2
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		require.NoError(t, c.Put(t.Context(), "shoppinglist/"+key, key, Unconditional()))
+	}
+
+	keys, err := c.List(t.Context(), "shoppinglist/", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, keys)
+	assert.Equal(t, 3, fake.listCalls)
+
+	keys, err = c.List(t.Context(), "shoppinglist/", "c")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"d", "e"}, keys, "token resumes after the key")
+}
+
+func TestS3CacheDelete(t *testing.T) {
+	c, _ := newFakeS3Cache(t)
+	require.NoError(t, c.Put(t.Context(), "k", "v", Unconditional()))
+
+	require.NoError(t, c.Delete(t.Context(), "k"))
+	assert.ErrorIs(t, c.Delete(t.Context(), "k"), ErrNotFound)
+}
+
+func TestEnsureCacheSelectsS3(t *testing.T) {
+	_, _ = newFakeS3Cache(t)
+	c, err := EnsureCache("recipes")
+	require.NoError(t, err)
+	s3c, ok := c.(*S3Cache)
+	require.True(t, ok, "got %T", c)
+	assert.Equal(t, "test-recipes", s3c.bucket)
+}
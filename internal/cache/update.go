@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"time"
+)
+
+// updateAttempts is how many read-modify-write rounds Update tries before it
+// gives up on a key that keeps changing underneath it.
+const updateAttempts = 5
+
+// ErrSkipUpdate is returned by an update func to leave the entry as it is.
+// Update then returns nil.
+var ErrSkipUpdate = errors.New("cache update skipped")
+
+// Update is a read-modify-write of key that doesn't lose concurrent writers. fn
+// gets the current value, or exists false if there isn't one, and returns the
+// new value. That's only written if nobody else wrote key since it was read;
+// otherwise fn runs again on a fresh read, so it shouldn't have side effects.
+func Update(ctx context.Context, c Cache, key string, fn func(current []byte, exists bool) ([]byte, error)) error {
+	var err error
+	for attempt := range updateAttempts {
+		if attempt > 0 {
+			// a little jitter keeps writers that collided from colliding again.
+			backoff := time.Duration(attempt)*10*time.Millisecond + rand.N(10*time.Millisecond)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+		}
+		var conflict bool
+		conflict, err = tryUpdate(ctx, c, key, fn)
+		if !conflict {
+			if errors.Is(err, ErrSkipUpdate) {
+				return nil
+			}
+			return err
+		}
+	}
+	return fmt.Errorf("update %s: gave up after %d attempts: %w", key, updateAttempts, err)
+}
+
+func tryUpdate(ctx context.Context, c Cache, key string, fn func([]byte, bool) ([]byte, error)) (bool, error) {
+	var current []byte
+	reader, etag, err := c.GetWithETag(ctx, key)
+	exists := err == nil
+	switch {
+	case exists:
+		current, err = io.ReadAll(reader)
+		if closeErr := reader.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return false, fmt.Errorf("read %s: %w", key, err)
+		}
+	case !errors.Is(err, ErrNotFound):
+		return false, err
+	}
+
+	updated, err := fn(current, exists)
+	if err != nil {
+		return false, err
+	}
+
+	opts := IfNoneMatch()
+	if exists {
+		opts = IfMatch(etag)
+	}
+	err = c.Put(ctx, key, string(updated), opts)
+	if errors.Is(err, ErrPreconditionFailed) || errors.Is(err, ErrAlreadyExists) {
+		return true, err
+	}
+	return false, err
+}
+
+// UpdateJSON is Update for JSON values. fn changes v in place; it starts as the
+// zero value when exists is false. The value as last handed to fn is returned,
+// which is what got written unless fn skipped or failed.
+func UpdateJSON[T any](ctx context.Context, c Cache, key string, fn func(v *T, exists bool) error) (T, error) {
+	var result T
+	err := Update(ctx, c, key, func(current []byte, exists bool) ([]byte, error) {
+		var v T
+		if exists {
+			if err := json.Unmarshal(current, &v); err != nil {
+				return nil, fmt.Errorf("decode %s: %w", key, err)
+			}
+		}
+		err := fn(&v, exists)
+		result = v
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(v)
+	})
+	return result, err
+}
@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// racingCache lets another writer in between every read and write.
+type racingCache struct {
+	*InMemoryCache
+	races int
+	write func()
+}
+
+func (c *racingCache) Put(ctx context.Context, key, value string, opts PutOptions) error {
+	if c.races > 0 {
+		c.races--
+		c.write()
+	}
+	return c.InMemoryCache.Put(ctx, key, value, opts)
+}
+
+type counter struct {
+	Count int      `json:"count"`
+	Seen  []string `json:"seen"`
+}
+
+func TestUpdateJSONRetriesLostRace(t *testing.T) {
+	c := &racingCache{InMemoryCache: NewInMemoryCache(), races: 2}
+	c.write = func() {
+		_, err := UpdateJSON(t.Context(), c.InMemoryCache, "counter", func(v *counter, _ bool) error {
+			v.Count++
+			v.Seen = append(v.Seen, "other")
+			return nil
+		})
+		require.NoError(t, err)
+	}
+
+	calls := 0
+	got, err := UpdateJSON(t.Context(), c, "counter", func(v *counter, _ bool) error {
+		calls++
+		v.Count++
+		v.Seen = append(v.Seen, "mine")
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, calls, "lost to the other writer twice")
+	assert.Equal(t, counter{Count: 3, Seen: []string{"other", "other", "mine"}}, got)
+	stored, err := UpdateJSON(t.Context(), c.InMemoryCache, "counter", func(*counter, bool) error { return ErrSkipUpdate })
+	require.NoError(t, err)
+	assert.Equal(t, got, stored)
+}
+
+func TestUpdateGivesUpOnBusyKey(t *testing.T) {
+	c := &racingCache{InMemoryCache: NewInMemoryCache(), races: updateAttempts}
+	require.NoError(t, c.InMemoryCache.Put(t.Context(), "busy", "0", Unconditional()))
+	writes := 0
+	c.write = func() {
+		writes++
+		require.NoError(t, c.InMemoryCache.Put(t.Context(), "busy", strconv.Itoa(writes), Unconditional()))
+	}
+
+	err := Update(t.Context(), c, "busy", func(current []byte, exists bool) ([]byte, error) {
+		assert.True(t, exists)
+		return []byte("mine"), nil
+	})
+
+	assert.ErrorIs(t, err, ErrPreconditionFailed)
+	assert.Equal(t, updateAttempts, writes)
+}
+
+func TestUpdateSkip(t *testing.T) {
+	c := NewInMemoryCache()
+	err := Update(t.Context(), c, "key", func([]byte, bool) ([]byte, error) {
+		return nil, ErrSkipUpdate
+	})
+	require.NoError(t, err)
+	exists, err := c.Exists(t.Context(), "key")
+	require.NoError(t, err)
+	assert.False(t, exists, "skipping doesn't create the entry")
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 
 	"careme/internal/brightdata"
@@ -19,8 +20,11 @@ const (
 
 type Config struct {
 	AI                AIConfig                `json:"ai"`
+	LocalAI           LocalAIConfig           `json:"local_ai"`
 	OpenRouter        OpenRouterConfig        `json:"openrouter"`
 	IngredientGrading IngredientGradingConfig `json:"ingredient_grading"`
+	Embedding         EmbeddingConfig         `json:"embedding"`
+	Spend             SpendConfig             `json:"spend"`
 	Kroger            KrogerConfig            `json:"kroger"`
 	Walmart           WalmartConfig           `json:"walmart"`
 	Aldi              AldiConfig              `json:"aldi"`
@@ -40,11 +44,38 @@ type AIConfig struct {
 	APIKey string `json:"api_key"`
 }
 
+// LocalAIConfig points generation, grading and critique at an OpenAI-compatible
+// chat-completions server (llama.cpp, Ollama, vLLM) instead of OpenAI and OpenRouter.
+type LocalAIConfig struct {
+	BaseURL string `json:"base_url"`
+	APIKey  string `json:"api_key"` // most local servers don't check it
+	Model   string `json:"model"`
+	// CritiqueModel defaults to Model; a second model keeps critiques from grading their own homework.
+	CritiqueModel string `json:"critique_model"`
+}
+
+func (c *LocalAIConfig) IsEnabled() bool {
+	return strings.TrimSpace(c.BaseURL) != ""
+}
+
+func (c *LocalAIConfig) ResolvedCritiqueModel() string {
+	if model := strings.TrimSpace(c.CritiqueModel); model != "" {
+		return model
+	}
+	return c.Model
+}
+
 type IngredientGradingConfig struct {
 	Enable bool   `json:"enable"`
 	Model  string `json:"model"`
 }
 
+// EmbeddingConfig picks the model behind "more like this". Without a model
+// recipes are compared by a local bag of words instead.
+type EmbeddingConfig struct {
+	Model string `json:"model"`
+}
+
 type OpenRouterConfig struct {
 	APIKey        string `json:"api_key"`
 	CritiqueModel string `json:"critique_model"`
@@ -54,6 +85,24 @@ func (c *OpenRouterConfig) IsEnabled() bool {
 	return strings.TrimSpace(c.APIKey) != ""
 }
 
+const (
+	SpendCapDegrade = "degrade"
+	SpendCapRefuse  = "refuse"
+)
+
+// SpendConfig caps estimated model spend per UTC day; zero means no cap. At a
+// cap images, wine, critique and farmers market photos stop, and in refuse mode
+// new menus and questions are turned away too.
+type SpendConfig struct {
+	DailyCapUSD     float64 `json:"daily_cap_usd"`
+	UserDailyCapUSD float64 `json:"user_daily_cap_usd"`
+	CapMode         string  `json:"cap_mode"`
+}
+
+func (c *SpendConfig) Refuses() bool {
+	return strings.TrimSpace(c.CapMode) == SpendCapRefuse
+}
+
 type KrogerConfig struct {
 	ClientID     string
 	ClientSecret string
@@ -168,14 +217,37 @@ func Load() (*Config, error) {
 		return nil, err
 	}
 
+	dailyCap, err := envUSD("AI_DAILY_SPEND_CAP_USD")
+	if err != nil {
+		return nil, err
+	}
+	userDailyCap, err := envUSD("AI_USER_DAILY_SPEND_CAP_USD")
+	if err != nil {
+		return nil, err
+	}
+
 	config := &Config{
 		AI: AIConfig{
 			APIKey: os.Getenv("AI_API_KEY"),
 		},
+		LocalAI: LocalAIConfig{
+			BaseURL:       os.Getenv("LOCAL_AI_BASE_URL"),
+			APIKey:        os.Getenv("LOCAL_AI_API_KEY"),
+			Model:         os.Getenv("LOCAL_AI_MODEL"),
+			CritiqueModel: os.Getenv("LOCAL_AI_CRITIQUE_MODEL"),
+		},
 		IngredientGrading: IngredientGradingConfig{
 			Enable: envEnabled("INGREDIENT_GRADING_ENABLE"),
 			Model:  os.Getenv("INGREDIENT_GRADING_MODEL"),
 		},
+		Embedding: EmbeddingConfig{
+			Model: os.Getenv("EMBEDDING_MODEL"),
+		},
+		Spend: SpendConfig{
+			DailyCapUSD:     dailyCap,
+			UserDailyCapUSD: userDailyCap,
+			CapMode:         os.Getenv("AI_SPEND_CAP_MODE"),
+		},
 		OpenRouter: OpenRouterConfig{
 			APIKey:        os.Getenv("OPENROUTER_API_KEY"),
 			CritiqueModel: os.Getenv("OPENROUTER_CRITIQUE_MODEL"),
@@ -234,6 +306,18 @@ func envEnabled(name string) bool {
 	return os.Getenv(name) != "false"
 }
 
+func envUSD(name string) (float64, error) {
+	raw := strings.TrimPrefix(strings.TrimSpace(os.Getenv(name)), "$")
+	if raw == "" {
+		return 0, nil
+	}
+	usd, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a dollar amount: %w", name, err)
+	}
+	return usd, nil
+}
+
 func validate(cfg *Config) error {
 	if err := validateAbsoluteURL("public origin", cfg.ResolvedPublicOrigin()); err != nil {
 		return err
@@ -248,6 +332,24 @@ func validate(cfg *Config) error {
 		}
 	}
 
+	if cfg.LocalAI.IsEnabled() {
+		if err := validateAbsoluteURL("local AI base URL", cfg.LocalAI.BaseURL); err != nil {
+			return err
+		}
+		if strings.TrimSpace(cfg.LocalAI.Model) == "" {
+			return fmt.Errorf("local AI model must be set with the local AI base URL")
+		}
+	}
+
+	if cfg.Spend.DailyCapUSD < 0 || cfg.Spend.UserDailyCapUSD < 0 {
+		return fmt.Errorf("AI spend caps can't be negative")
+	}
+	switch strings.TrimSpace(cfg.Spend.CapMode) {
+	case "", SpendCapDegrade, SpendCapRefuse:
+	default:
+		return fmt.Errorf("AI spend cap mode must be %q or %q, got %q", SpendCapDegrade, SpendCapRefuse, cfg.Spend.CapMode)
+	}
+
 	if cfg.Mocks.Enable {
 		return nil
 	}
@@ -258,8 +360,8 @@ func validate(cfg *Config) error {
 	if cfg.Kroger.ClientID == "" || cfg.Kroger.ClientSecret == "" {
 		return fmt.Errorf("kroger client ID and secret must be set")
 	}
-	if cfg.AI.APIKey == "" {
-		return fmt.Errorf("AI API  key must be set")
+	if cfg.AI.APIKey == "" && !cfg.LocalAI.IsEnabled() {
+		return fmt.Errorf("AI API  key or local AI base URL must be set")
 	}
 	return nil
 }
@@ -139,6 +139,89 @@ func TestLoadReadsOpenRouterCritiqueConfig(t *testing.T) {
 	}
 }
 
+func TestLoadReadsLocalAIConfig(t *testing.T) {
+	resetStoreEnvs(t)
+	t.Setenv("ENABLE_MOCKS", "1")
+	t.Setenv("LOCAL_AI_BASE_URL", "http://localhost:11434/v1")
+	t.Setenv("LOCAL_AI_MODEL", "qwen3:32b")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if !cfg.LocalAI.IsEnabled() {
+		t.Fatal("expected local AI config to be enabled")
+	}
+	if got, want := cfg.LocalAI.Model, "qwen3:32b"; got != want {
+		t.Fatalf("expected local AI model %q, got %q", want, got)
+	}
+}
+
+func TestValidate_LocalAINeedsModel(t *testing.T) {
+	cfg := &Config{
+		Mocks:   MockConfig{Enable: true},
+		LocalAI: LocalAIConfig{BaseURL: "http://localhost:8000/v1"},
+	}
+
+	err := validate(cfg)
+	if err == nil || !contains(err.Error(), "local AI model") {
+		t.Fatalf("expected local AI model validation error, got %v", err)
+	}
+}
+
+func TestValidate_LocalAIReplacesAIKey(t *testing.T) {
+	cfg := &Config{
+		Clerk:   ClerkConfig{SecretKey: "sk_test", PublishableKey: "pk_test", Domain: "clerk.careme.test"},
+		Kroger:  KrogerConfig{ClientID: "id", ClientSecret: "secret"},
+		LocalAI: LocalAIConfig{BaseURL: "http://localhost:8000/v1", Model: "llama"},
+	}
+
+	if err := validate(cfg); err != nil {
+		t.Fatalf("expected local AI to stand in for the AI key, got %v", err)
+	}
+}
+
+func TestLoadReadsSpendCaps(t *testing.T) {
+	resetStoreEnvs(t)
+	t.Setenv("ENABLE_MOCKS", "1")
+	t.Setenv("AI_DAILY_SPEND_CAP_USD", "$25")
+	t.Setenv("AI_USER_DAILY_SPEND_CAP_USD", "0.75")
+	t.Setenv("AI_SPEND_CAP_MODE", "refuse")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if got, want := cfg.Spend.DailyCapUSD, 25.0; got != want {
+		t.Fatalf("expected daily cap %v, got %v", want, got)
+	}
+	if got, want := cfg.Spend.UserDailyCapUSD, 0.75; got != want {
+		t.Fatalf("expected user daily cap %v, got %v", want, got)
+	}
+	if !cfg.Spend.Refuses() {
+		t.Fatal("expected refuse cap mode")
+	}
+
+	t.Setenv("AI_DAILY_SPEND_CAP_USD", "lots")
+	if _, err := Load(); err == nil || !contains(err.Error(), "AI_DAILY_SPEND_CAP_USD") {
+		t.Fatalf("expected daily cap parse error, got %v", err)
+	}
+}
+
+func TestValidate_RejectsUnknownSpendCapMode(t *testing.T) {
+	cfg := &Config{
+		Mocks: MockConfig{Enable: true},
+		Spend: SpendConfig{DailyCapUSD: 10, CapMode: "panic"},
+	}
+
+	err := validate(cfg)
+	if err == nil || !contains(err.Error(), "spend cap mode") {
+		t.Fatalf("expected spend cap mode validation error, got %v", err)
+	}
+}
+
 func TestResolvedPublicOriginDefaultsToLocalhostOutsideProd(t *testing.T) {
 	cfg := &Config{}
 	if got, want := cfg.ResolvedPublicOrigin(), "http://localhost:8080"; got != want {
@@ -192,6 +275,13 @@ func resetStoreEnvs(t *testing.T) {
 		"BRIGHTDATA_PROXY_PASSWORD",
 		"OPENROUTER_API_KEY",
 		"OPENROUTER_CRITIQUE_MODEL",
+		"LOCAL_AI_BASE_URL",
+		"LOCAL_AI_API_KEY",
+		"LOCAL_AI_MODEL",
+		"LOCAL_AI_CRITIQUE_MODEL",
+		"AI_DAILY_SPEND_CAP_USD",
+		"AI_USER_DAILY_SPEND_CAP_USD",
+		"AI_SPEND_CAP_MODE",
 		"PUBLIX_ENABLE",
 		"PUBLIX_ABCK",
 		"HEB_ENABLE",
@@ -0,0 +1,341 @@
+// Package dietary knows which grocery and recipe words conflict with a
+// household's allergens, diets, and disliked ingredients.
+package dietary
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Allergens offered on the profile form. Anything else typed by the user is
+// matched literally.
+var Allergens = []string{
+	"peanuts",
+	"tree nuts",
+	"shellfish",
+	"fish",
+	"dairy",
+	"eggs",
+	"gluten",
+	"soy",
+	"sesame",
+}
+
+// Diets offered on the profile form.
+var Diets = []string{
+	"vegetarian",
+	"vegan",
+	"pescatarian",
+	"halal",
+	"kosher",
+	"gluten-free",
+	"dairy-free",
+}
+
+// termGroup is a set of words that all mean "contains X". Qualifiers are
+// words that make a following term safe, e.g. "dairy free cheese", and ignore
+// lists whole phrases that only look like a term, e.g. "butter lettuce".
+type termGroup struct {
+	terms      []string
+	qualifiers []string
+	ignore     []string
+}
+
+var (
+	peanutTerms  = termGroup{terms: []string{"peanut"}}
+	treeNutTerms = termGroup{terms: []string{
+		"almond", "walnut", "pecan", "cashew", "pistachio", "hazelnut", "macadamia",
+		"brazil nut", "pine nut", "chestnut", "praline", "marzipan", "frangipane", "nut",
+	}}
+	shellfishTerms = termGroup{terms: []string{
+		"shrimp", "prawn", "crab", "lobster", "crawfish", "crayfish", "langoustine",
+		"scallop", "clam", "mussel", "oyster", "squid", "calamari", "octopus", "shellfish",
+	}, ignore: []string{"oyster mushroom", "crab apple"}}
+	fishTerms = termGroup{terms: []string{
+		"fish", "salmon", "tuna", "cod", "halibut", "tilapia", "trout", "anchovy", "sardine",
+		"mackerel", "snapper", "sea bass", "swordfish", "catfish", "haddock", "pollock",
+		"mahi", "branzino", "flounder", "sole", "bonito", "worcestershire",
+	}}
+	dairyTerms = termGroup{
+		terms: []string{
+			"milk", "cheese", "butter", "cream", "yogurt", "ghee", "parmesan", "parmigiano",
+			"mozzarella", "ricotta", "feta", "cheddar", "gruyere", "pecorino", "mascarpone",
+			"buttermilk", "whey", "creme fraiche", "crème fraîche", "paneer", "queso", "burrata", "halloumi",
+		},
+		qualifiers: []string{"dairy free", "non dairy", "vegan", "plant based", "oat", "almond", "soy", "coconut", "peanut", "cocoa", "cashew"},
+		ignore:     []string{"butter lettuce", "butter bean", "cream of tartar"},
+	}
+	eggTerms = termGroup{
+		terms:      []string{"egg", "mayonnaise", "mayo", "aioli", "meringue"},
+		qualifiers: []string{"egg free", "vegan", "eggless"},
+	}
+	glutenTerms = termGroup{
+		terms: []string{
+			"wheat", "flour", "bread", "breadcrumb", "panko", "pasta", "spaghetti", "linguine",
+			"fettuccine", "penne", "rigatoni", "orzo", "couscous", "farro", "barley", "rye",
+			"bulgur", "semolina", "seitan", "noodle", "tortilla", "pita", "naan", "baguette",
+			"soy sauce", "udon", "ramen", "crouton",
+		},
+		qualifiers: []string{"gluten free", "corn", "rice", "almond", "chickpea", "buckwheat", "cassava", "coconut"},
+	}
+	soyTerms    = termGroup{terms: []string{"soy", "soybean", "tofu", "edamame", "tempeh", "miso", "tamari"}}
+	sesameTerms = termGroup{terms: []string{"sesame", "tahini", "halva"}}
+
+	porkTerms = termGroup{
+		terms: []string{
+			"pork", "bacon", "ham", "prosciutto", "pancetta", "guanciale", "lard", "chorizo",
+			"salami", "pepperoni", "sausage", "speck", "mortadella",
+		},
+		qualifiers: []string{"chicken", "turkey", "beef", "lamb", "vegan", "plant based", "veggie"},
+	}
+	meatTerms = termGroup{terms: []string{
+		"beef", "steak", "veal", "lamb", "mutton", "goat", "venison", "bison", "chicken",
+		"turkey", "duck", "goose", "quail", "brisket", "chuck", "sirloin", "ribeye",
+		"meatball", "gelatin", "bone broth", "oxtail", "liver",
+	}}
+	alcoholTerms = termGroup{terms: []string{
+		"wine", "beer", "ale", "lager", "stout", "sake", "mirin", "rum", "brandy", "bourbon",
+		"whiskey", "vodka", "tequila", "sherry", "vermouth", "marsala", "cognac", "liqueur",
+	}, ignore: []string{"ginger ale", "ginger beer", "root beer", "wine vinegar", "sherry vinegar"}}
+	animalProductTerms = termGroup{terms: []string{"honey"}}
+)
+
+var allergenGroups = map[string][]termGroup{
+	"peanuts":   {peanutTerms},
+	"tree nuts": {treeNutTerms},
+	"shellfish": {shellfishTerms},
+	"fish":      {fishTerms},
+	"dairy":     {dairyTerms},
+	"eggs":      {eggTerms},
+	"gluten":    {glutenTerms},
+	"soy":       {soyTerms},
+	"sesame":    {sesameTerms},
+}
+
+var allergenAliases = map[string]string{
+	"peanut":   "peanuts",
+	"nuts":     "tree nuts",
+	"tree nut": "tree nuts",
+	"milk":     "dairy",
+	"lactose":  "dairy",
+	"egg":      "eggs",
+	"wheat":    "gluten",
+	"celiac":   "gluten",
+	"soybeans": "soy",
+}
+
+var dietGroups = map[string][]termGroup{
+	"vegetarian":  {meatTerms, porkTerms, fishTerms, shellfishTerms},
+	"vegan":       {meatTerms, porkTerms, fishTerms, shellfishTerms, dairyTerms, eggTerms, animalProductTerms},
+	"pescatarian": {meatTerms, porkTerms},
+	"halal":       {porkTerms, alcoholTerms},
+	"kosher":      {porkTerms, shellfishTerms},
+	"gluten-free": {glutenTerms},
+	"dairy-free":  {dairyTerms},
+}
+
+var dietAliases = map[string]string{
+	"veggie":      "vegetarian",
+	"pescetarian": "pescatarian",
+	"gluten free": "gluten-free",
+	"dairy free":  "dairy-free",
+	"celiac":      "gluten-free",
+}
+
+// NormalizeAllergen folds common spellings onto the names in Allergens.
+// Unknown allergens are returned lowercased so they can still be matched literally.
+func NormalizeAllergen(allergen string) string {
+	allergen = strings.ToLower(strings.Join(strings.Fields(allergen), " "))
+	if canonical, ok := allergenAliases[allergen]; ok {
+		return canonical
+	}
+	return allergen
+}
+
+// NormalizeDiet folds common spellings onto the names in Diets and reports
+// whether the diet is one we know how to enforce.
+func NormalizeDiet(diet string) (string, bool) {
+	diet = strings.ToLower(strings.Join(strings.Fields(diet), " "))
+	if canonical, ok := dietAliases[diet]; ok {
+		diet = canonical
+	}
+	_, ok := dietGroups[diet]
+	return diet, ok
+}
+
+// Conflict names the restriction a piece of text ran into and the word that
+// triggered it.
+type Conflict struct {
+	Restriction string
+	Term        string
+}
+
+func (c Conflict) String() string {
+	return c.Term + " (" + c.Restriction + ")"
+}
+
+type rule struct {
+	restriction string
+	group       termGroup
+}
+
+// Matcher checks text against a household's restrictions. The zero value
+// matches nothing.
+type Matcher struct {
+	hard []rule
+	soft []rule
+}
+
+// NewMatcher builds a matcher. Allergens and diets are hard restrictions that
+// generated recipes must respect; dislikes are soft and only steer planning.
+func NewMatcher(allergens, diets, dislikes []string) Matcher {
+	var m Matcher
+	for _, allergen := range allergens {
+		allergen = NormalizeAllergen(allergen)
+		if allergen == "" {
+			continue
+		}
+		groups, ok := allergenGroups[allergen]
+		if !ok {
+			groups = []termGroup{{terms: []string{allergen}}}
+		}
+		for _, group := range groups {
+			m.hard = append(m.hard, rule{restriction: allergen + " allergy", group: group})
+		}
+	}
+	for _, diet := range diets {
+		diet, ok := NormalizeDiet(diet)
+		if !ok {
+			continue
+		}
+		for _, group := range dietGroups[diet] {
+			m.hard = append(m.hard, rule{restriction: diet + " diet", group: group})
+		}
+	}
+	for _, dislike := range dislikes {
+		dislike = strings.ToLower(strings.Join(strings.Fields(dislike), " "))
+		if dislike == "" {
+			continue
+		}
+		m.soft = append(m.soft, rule{restriction: "dislikes " + dislike, group: termGroup{terms: []string{dislike}}})
+	}
+	return m
+}
+
+// Empty reports whether the matcher has no restrictions at all.
+func (m Matcher) Empty() bool {
+	return len(m.hard) == 0 && len(m.soft) == 0
+}
+
+// HardConflicts returns allergen and diet conflicts found in text.
+func (m Matcher) HardConflicts(text string) []Conflict {
+	return conflicts(m.hard, words(text))
+}
+
+// Conflicts returns hard conflicts plus disliked ingredients found in text.
+func (m Matcher) Conflicts(text string) []Conflict {
+	w := words(text)
+	return append(conflicts(m.hard, w), conflicts(m.soft, w)...)
+}
+
+func conflicts(rules []rule, text []string) []Conflict {
+	var found []Conflict
+	for _, r := range rules {
+		for _, term := range r.group.terms {
+			if containsTerm(text, words(term), r.group) {
+				c := Conflict{Restriction: r.restriction, Term: term}
+				if !slices.Contains(found, c) {
+					found = append(found, c)
+				}
+				break
+			}
+		}
+	}
+	return found
+}
+
+// containsTerm looks for term as whole words in text, allowing a plural on the
+// last word. Occurrences directly preceded by a safe qualifier, or that are part
+// of an ignored phrase, do not count.
+func containsTerm(text, term []string, group termGroup) bool {
+	if len(term) == 0 {
+		return false
+	}
+	for i := 0; i+len(term) <= len(text); i++ {
+		if !phraseAt(text, i, term) {
+			continue
+		}
+		if qualified(text[:i], group.qualifiers) || ignored(text, i, group.ignore) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func ignored(text []string, start int, phrases []string) bool {
+	for _, phrase := range phrases {
+		pw := words(phrase)
+		for offset := 0; offset < len(pw); offset++ {
+			begin := start - offset
+			if begin < 0 || begin+len(pw) > len(text) {
+				continue
+			}
+			if phraseAt(text, begin, pw) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func phraseAt(text []string, start int, term []string) bool {
+	last := len(term) - 1
+	for j, word := range term {
+		got := text[start+j]
+		if j == last {
+			if !sameWord(got, word) {
+				return false
+			}
+			continue
+		}
+		if got != word {
+			return false
+		}
+	}
+	return true
+}
+
+func qualified(before []string, qualifiers []string) bool {
+	for _, q := range qualifiers {
+		qw := words(q)
+		if len(qw) > len(before) {
+			continue
+		}
+		if slices.Equal(before[len(before)-len(qw):], qw) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameWord(got, want string) bool {
+	switch {
+	case got == want, got == want+"s", got == want+"es":
+		return true
+	case strings.HasSuffix(want, "y") && got == strings.TrimSuffix(want, "y")+"ies":
+		return true
+	}
+	return false
+}
+
+func words(text string) []string {
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return ' '
+	}, text)
+	return strings.Fields(text)
+}
@@ -0,0 +1,82 @@
+package dietary
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHardConflicts(t *testing.T) {
+	tests := []struct {
+		name      string
+		allergens []string
+		diets     []string
+		text      string
+		want      []Conflict
+	}{
+		{
+			name:      "tree nut plural",
+			allergens: []string{"tree nuts"},
+			text:      "1/4 cup toasted walnuts",
+			want:      []Conflict{{Restriction: "tree nuts allergy", Term: "walnut"}},
+		},
+		{
+			name:      "whole words only",
+			allergens: []string{"tree nuts", "eggs"},
+			text:      "nutmeg, coconut flakes and roasted eggplant",
+		},
+		{
+			name:      "alias and anchovies",
+			allergens: []string{"Fish"},
+			text:      "2 anchovies, minced",
+			want:      []Conflict{{Restriction: "fish allergy", Term: "anchovy"}},
+		},
+		{
+			name:      "qualifier makes dairy term safe",
+			allergens: []string{"milk"},
+			text:      "1 can coconut milk and 2 tbsp peanut butter",
+		},
+		{
+			name:      "ignored phrase",
+			allergens: []string{"dairy"},
+			text:      "1 head butter lettuce",
+		},
+		{
+			name:      "unknown allergen matched literally",
+			allergens: []string{"cilantro"},
+			text:      "Fresh Cilantro Bunch",
+			want:      []Conflict{{Restriction: "cilantro allergy", Term: "cilantro"}},
+		},
+		{
+			name:  "vegetarian rejects chicken stock",
+			diets: []string{"vegetarian"},
+			text:  "2 cups chicken stock",
+			want:  []Conflict{{Restriction: "vegetarian diet", Term: "chicken"}},
+		},
+		{
+			name:  "halal allows chicken sausage but not wine",
+			diets: []string{"halal"},
+			text:  "chicken sausage deglazed with white wine",
+			want:  []Conflict{{Restriction: "halal diet", Term: "wine"}},
+		},
+		{
+			name:  "unknown diet ignored",
+			diets: []string{"keto"},
+			text:  "bread",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMatcher(tt.allergens, tt.diets, nil)
+			assert.Equal(t, tt.want, m.HardConflicts(tt.text))
+		})
+	}
+}
+
+func TestDislikesAreSoft(t *testing.T) {
+	m := NewMatcher(nil, nil, []string{"Mushrooms"})
+	assert.Empty(t, m.HardConflicts("cremini mushrooms"))
+	assert.Equal(t, []Conflict{{Restriction: "dislikes mushrooms", Term: "mushrooms"}}, m.Conflicts("cremini mushrooms"))
+	assert.False(t, m.Empty())
+	assert.True(t, Matcher{}.Empty())
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"hash/fnv"
 	"io"
@@ -182,24 +181,29 @@ func (s *store) saveMarket(ctx context.Context, market Market) error {
 	return nil
 }
 
-func (s *store) mergeInventory(ctx context.Context, locationID string, date time.Time, ingredients []ai.InputIngredient) error {
-	existing, err := s.loadInventoryByDate(ctx, locationID, date)
-	if err != nil && !errors.Is(err, cache.ErrNotFound) {
-		return fmt.Errorf("load farmers market inventory: %w", err)
-	}
-
-	all := append(existing, ingredients...)
-	merged := lo.UniqBy(all, func(i ai.InputIngredient) string {
-		return i.ProductID
+// updateMarket applies fn to the stored market, or a new one with exists false,
+// and re-runs it if another upload changed the market in between.
+func (s *store) updateMarket(ctx context.Context, locationID string, fn func(market *Market, exists bool)) (Market, error) {
+	market, err := cache.UpdateJSON(ctx, s.cache, locationKey(locationID), func(market *Market, exists bool) error {
+		fn(market, exists)
+		return nil
 	})
+	if err != nil {
+		return Market{}, fmt.Errorf("save farmers market: %w", err)
+	}
+	return market, nil
+}
 
-	raw, err := json.Marshal(inventoryRecord{
-		Ingredients: merged,
+// mergeInventory adds ingredients to what other uploads for the day already found.
+func (s *store) mergeInventory(ctx context.Context, locationID string, date time.Time, ingredients []ai.InputIngredient) error {
+	_, err := cache.UpdateJSON(ctx, s.cache, inventoryKey(locationID, date), func(record *inventoryRecord, _ bool) error {
+		all := append(record.Ingredients, ingredients...)
+		record.Ingredients = lo.UniqBy(all, func(i ai.InputIngredient) string {
+			return i.ProductID
+		})
+		return nil
 	})
 	if err != nil {
-		return fmt.Errorf("marshal farmers market inventory: %w", err)
-	}
-	if err := s.cache.Put(ctx, inventoryKey(locationID, date), string(raw), cache.Unconditional()); err != nil {
 		return fmt.Errorf("save farmers market inventory: %w", err)
 	}
 	return nil
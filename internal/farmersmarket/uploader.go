@@ -39,31 +39,36 @@ func (u *uploader) saveUpload(ctx context.Context, name string, coor geo.Coordin
 	if err := coor.Valid(); err != nil {
 		return nil, fmt.Errorf("invalid market coordinates: %w", err)
 	}
-	market, err := u.store.findNearbyMarket(ctx, coor)
+	nearby, err := u.store.findNearbyMarket(ctx, coor)
 	if err != nil {
 		return nil, err
 	}
 
 	now := time.Now().UTC()
-	if market == nil {
-		market = &Market{
-			Coordinate: coor,
-			ID:         marketID(coor),
-			Names:      []string{name},
-			PhotoCount: photoCount,
-			CreatedAt:  now,
-			UpdatedAt:  now,
+	id := marketID(coor)
+	if nearby != nil {
+		id = nearby.ID
+	}
+	market, err := u.store.updateMarket(ctx, id, func(market *Market, exists bool) {
+		if !exists {
+			*market = Market{
+				Coordinate: coor,
+				ID:         id,
+				Names:      []string{name},
+				PhotoCount: photoCount,
+				CreatedAt:  now,
+				UpdatedAt:  now,
+			}
+			return
 		}
-	} else {
 		market.merge(name, coor, photoCount, now)
-	}
-
-	if err := u.store.saveMarket(ctx, *market); err != nil {
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	if err := u.store.mergeInventory(ctx, market.ID, date, ingredients); err != nil {
 		return nil, err
 	}
-	return market, nil
+	return &market, nil
 }
@@ -1,12 +1,16 @@
 package httpx
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/url"
 	"strings"
 )
 
-const htmlContentType = "text/html; charset=utf-8"
+const (
+	htmlContentType = "text/html; charset=utf-8"
+	jsonContentType = "application/json; charset=utf-8"
+)
 
 // SetHTMLContentType marks a response as UTF-8 HTML.
 func SetHTMLContentType(w http.ResponseWriter) {
@@ -50,3 +54,18 @@ func LocalReferrerPath(r *http.Request) string {
 	}
 	return referrer.RequestURI()
 }
+
+// WriteJSON writes v as a JSON response with the given status code.
+func WriteJSON(w http.ResponseWriter, status int, v any) error {
+	w.Header().Set("Content-Type", jsonContentType)
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(v)
+}
+
+// JSONError is http.Error for API clients: {"error": msg}.
+func JSONError(w http.ResponseWriter, msg string, status int) {
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	_ = WriteJSON(w, status, struct {
+		Error string `json:"error"`
+	}{Error: msg})
+}
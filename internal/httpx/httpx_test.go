@@ -17,6 +17,16 @@ func TestSetHTMLContentType(t *testing.T) {
 	assert.Equal(t, "text/html; charset=utf-8", response.Header().Get("Content-Type"))
 }
 
+func TestJSONError(t *testing.T) {
+	response := httptest.NewRecorder()
+
+	JSONError(response, "recipe not found", http.StatusNotFound)
+
+	assert.Equal(t, http.StatusNotFound, response.Code)
+	assert.Equal(t, "application/json; charset=utf-8", response.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"error":"recipe not found"}`, response.Body.String())
+}
+
 func TestIsHTMX(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -0,0 +1,133 @@
+// Package ical writes the small slice of iCalendar (RFC 5545) that calendar
+// apps need to subscribe to a feed of events.
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ContentType is what a feed is served as.
+const ContentType = "text/calendar; charset=utf-8"
+
+// Calendar is a feed of events. Name is what calendar apps show for the subscription.
+type Calendar struct {
+	Name string
+	// RefreshInterval hints how often subscribers should poll; zero leaves it to them.
+	RefreshInterval time.Duration
+	Events          []Event
+}
+
+// Event is one VEVENT. Timed events are written in UTC; an AllDay event covers
+// Start's date.
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	URL         string
+	Start       time.Time
+	Duration    time.Duration
+	AllDay      bool
+	// Alarm pops a reminder when the event starts.
+	Alarm bool
+}
+
+// Write encodes c. stamp is DTSTAMP on every event, when the feed was made.
+func (c Calendar) Write(w io.Writer, stamp time.Time) error {
+	bw := bufio.NewWriter(w)
+	line := func(name, value string) {
+		writeFolded(bw, name+":"+value)
+	}
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//Careme//Careme Calendar//EN")
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	if c.Name != "" {
+		line("X-WR-CALNAME", escape(c.Name))
+	}
+	if c.RefreshInterval > 0 {
+		writeFolded(bw, "REFRESH-INTERVAL;VALUE=DURATION:"+duration(c.RefreshInterval))
+		line("X-PUBLISHED-TTL", duration(c.RefreshInterval))
+	}
+	for _, e := range c.Events {
+		line("BEGIN", "VEVENT")
+		line("UID", escape(e.UID))
+		line("DTSTAMP", utc(stamp))
+		if e.AllDay {
+			writeFolded(bw, "DTSTART;VALUE=DATE:"+e.Start.Format("20060102"))
+			writeFolded(bw, "DTEND;VALUE=DATE:"+e.Start.AddDate(0, 0, 1).Format("20060102"))
+		} else {
+			line("DTSTART", utc(e.Start))
+			if e.Duration > 0 {
+				line("DURATION", duration(e.Duration))
+			}
+		}
+		line("SUMMARY", escape(e.Summary))
+		if e.Description != "" {
+			line("DESCRIPTION", escape(e.Description))
+		}
+		if e.URL != "" {
+			writeFolded(bw, "URL;VALUE=URI:"+e.URL)
+		}
+		if e.Alarm {
+			line("BEGIN", "VALARM")
+			line("ACTION", "DISPLAY")
+			line("DESCRIPTION", escape(e.Summary))
+			line("TRIGGER", "PT0M")
+			line("END", "VALARM")
+		}
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+	return bw.Flush()
+}
+
+func utc(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// duration writes d as an RFC 5545 duration, like PT1H30M or P1D.
+func duration(d time.Duration) string {
+	if d%(24*time.Hour) == 0 && d > 0 {
+		return "P" + strconv.Itoa(int(d/(24*time.Hour))) + "D"
+	}
+	hours := int(d / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+	s := "PT"
+	if hours > 0 {
+		s += strconv.Itoa(hours) + "H"
+	}
+	if minutes > 0 || hours == 0 {
+		s += strconv.Itoa(minutes) + "M"
+	}
+	return s
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// writeFolded ends a content line with CRLF, folding it so no line is longer
+// than 75 octets without splitting a UTF-8 character.
+func writeFolded(w *bufio.Writer, s string) {
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		_, _ = w.WriteString(s[:cut])
+		_, _ = w.WriteString("\r\n ")
+		s = s[cut:]
+		limit = 74 // continuation lines start with a space
+	}
+	_, _ = w.WriteString(s)
+	_, _ = w.WriteString("\r\n")
+}
@@ -0,0 +1,66 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	la, err := time.LoadLocation("America/Los_Angeles")
+	require.NoError(t, err)
+	cal := Calendar{
+		Name:            "Dinners",
+		RefreshInterval: 6 * time.Hour,
+		Events: []Event{
+			{UID: "trip@example.com", Summary: "Shopping", Start: time.Date(2026, time.January, 12, 0, 0, 0, 0, la), AllDay: true},
+			{
+				UID:         "dinner@example.com",
+				Summary:     "Chicken, rice; and greens",
+				Description: "Line one\nLine two",
+				URL:         "https://example.com/recipe/abc",
+				Start:       time.Date(2026, time.January, 12, 17, 45, 0, 0, la),
+				Duration:    45 * time.Minute,
+				Alarm:       true,
+			},
+		},
+	}
+	var b strings.Builder
+	require.NoError(t, cal.Write(&b, time.Date(2026, time.January, 10, 8, 0, 0, 0, time.UTC)))
+	out := b.String()
+
+	assert.True(t, strings.HasPrefix(out, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+	assert.True(t, strings.HasSuffix(out, "END:VCALENDAR\r\n"))
+	assert.Contains(t, out, "X-WR-CALNAME:Dinners\r\n")
+	assert.Contains(t, out, "REFRESH-INTERVAL;VALUE=DURATION:PT6H\r\n")
+	assert.Contains(t, out, "DTSTART;VALUE=DATE:20260112\r\nDTEND;VALUE=DATE:20260113\r\n")
+	assert.Contains(t, out, "DTSTAMP:20260110T080000Z\r\n")
+	assert.Contains(t, out, "DTSTART:20260113T014500Z\r\nDURATION:PT45M\r\n", "timed events are written in UTC")
+	assert.Contains(t, out, `SUMMARY:Chicken\, rice\; and greens`+"\r\n")
+	assert.Contains(t, out, `DESCRIPTION:Line one\nLine two`+"\r\n")
+	assert.Contains(t, out, "URL;VALUE=URI:https://example.com/recipe/abc\r\n")
+	assert.Contains(t, out, "BEGIN:VALARM\r\nACTION:DISPLAY\r\n")
+	assert.Equal(t, 1, strings.Count(out, "BEGIN:VALARM"))
+}
+
+func TestWriteFoldsLongLines(t *testing.T) {
+	summary := strings.Repeat("é", 100)
+	var b strings.Builder
+	require.NoError(t, Calendar{Events: []Event{{UID: "x", Summary: summary}}}.Write(&b, time.Now()))
+
+	for line := range strings.SplitSeq(strings.TrimSuffix(b.String(), "\r\n"), "\r\n") {
+		assert.LessOrEqual(t, len(line), 75)
+		assert.True(t, strings.ToValidUTF8(line, "?") == line, "folding split a character: %q", line)
+	}
+	assert.Contains(t, strings.ReplaceAll(b.String(), "\r\n ", ""), "SUMMARY:"+summary+"\r\n")
+}
+
+func TestDuration(t *testing.T) {
+	assert.Equal(t, "PT1H30M", duration(90*time.Minute))
+	assert.Equal(t, "PT2H", duration(2*time.Hour))
+	assert.Equal(t, "PT0M", duration(0))
+	assert.Equal(t, "P1D", duration(24*time.Hour))
+}
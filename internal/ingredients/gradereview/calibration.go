@@ -0,0 +1,382 @@
+package gradereview
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"careme/internal/ai"
+	"careme/internal/cache"
+	"careme/internal/parallelism"
+
+	"github.com/samber/lo"
+)
+
+const (
+	uncategorized = "Uncategorized"
+	// minCalibrationReviews is how many reviews a category needs before its
+	// bias turns into a rubric line rather than noise.
+	minCalibrationReviews = 5
+	// minCalibrationBias is how lopsided a category's misses have to be, as a
+	// share of its reviews, before the prompt is told to lean the other way.
+	minCalibrationBias = 0.3
+	compareBatchSize   = 30
+)
+
+// Grader is what Compare regrades reviewed ingredients with.
+type Grader interface {
+	GradeIngredients(ctx context.Context, ingredients []ai.InputIngredient) ([]ai.InputIngredient, error)
+}
+
+// LabeledReview is a review turned into a label: the range of scores the
+// reviewer would have accepted for the ingredient.
+type LabeledReview struct {
+	Review
+	// Version is the grader cache version that produced the reviewed grade.
+	Version  string `json:"version"`
+	Category string `json:"category"`
+	Grade    int    `json:"grade"`
+	Low      int    `json:"low"`
+	High     int    `json:"high"`
+}
+
+// Label turns a review into a score range. A grade judged too high should have
+// been at least a point lower, one judged too low at least a point higher, and
+// a correct one is allowed a point either way since graders aren't that exact.
+func Label(review Review) (LabeledReview, bool) {
+	if review.Ingredient.Grade == nil || !review.Verdict.Valid() {
+		return LabeledReview{}, false
+	}
+	grade := review.Ingredient.Grade.Score
+	labeled := LabeledReview{
+		Review:   review,
+		Version:  gradeKeyVersion(review.GradeKey),
+		Category: ingredientCategory(review.Ingredient),
+		Grade:    grade,
+	}
+	switch review.Verdict {
+	case VerdictTooHigh:
+		labeled.Low, labeled.High = 0, grade-1
+	case VerdictTooLow:
+		labeled.Low, labeled.High = grade+1, 10
+	default:
+		labeled.Low, labeled.High = grade-1, grade+1
+	}
+	// a reviewer can still call a 10 too low or a 0 too high.
+	labeled.Low = min(max(labeled.Low, 0), 10)
+	labeled.High = min(max(labeled.High, 0), 10)
+	return labeled, true
+}
+
+// Judge is the verdict the reviewer would have given score.
+func (l LabeledReview) Judge(score int) Verdict {
+	switch {
+	case score > l.High:
+		return VerdictTooHigh
+	case score < l.Low:
+		return VerdictTooLow
+	default:
+		return VerdictCorrect
+	}
+}
+
+// LoadReviews reads every stored review, labeled, newest first. Reviews of
+// ingredients without a grade are skipped.
+func LoadReviews(ctx context.Context, c cache.ListCache) ([]LabeledReview, error) {
+	keys, err := c.List(ctx, reviewCachePrefix, "")
+	if err != nil {
+		return nil, fmt.Errorf("list ingredient grade reviews: %w", err)
+	}
+	labeled := make([]LabeledReview, 0, len(keys))
+	for _, key := range keys {
+		review, err := loadReview(ctx, c, key)
+		if err != nil {
+			return nil, err
+		}
+		if l, ok := Label(*review); ok {
+			labeled = append(labeled, l)
+		}
+	}
+	slices.SortStableFunc(labeled, func(a, b LabeledReview) int {
+		return b.ReviewedAt.Compare(a.ReviewedAt)
+	})
+	return labeled, nil
+}
+
+func loadReview(ctx context.Context, c cache.ListCache, key string) (*Review, error) {
+	reader, err := c.Get(ctx, reviewCachePrefix+key)
+	if err != nil {
+		return nil, fmt.Errorf("load ingredient grade review %q: %w", key, err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	var review Review
+	if err := json.NewDecoder(reader).Decode(&review); err != nil {
+		return nil, fmt.Errorf("decode ingredient grade review %q: %w", key, err)
+	}
+	if review.GradeKey == "" {
+		review.GradeKey = key
+	}
+	return &review, nil
+}
+
+func gradeKeyVersion(gradeKey string) string {
+	version, _, _ := strings.Cut(gradeKey, "/")
+	return version
+}
+
+func ingredientCategory(ingredient ai.InputIngredient) string {
+	for _, category := range ingredient.Categories {
+		if category = strings.TrimSpace(category); category != "" {
+			return category
+		}
+	}
+	return uncategorized
+}
+
+// Accuracy tallies verdicts, from reviewers or from Judge.
+type Accuracy struct {
+	Reviewed int `json:"reviewed"`
+	Correct  int `json:"correct"`
+	TooHigh  int `json:"too_high"`
+	TooLow   int `json:"too_low"`
+}
+
+func (a *Accuracy) add(v Verdict) {
+	a.Reviewed++
+	switch v {
+	case VerdictTooHigh:
+		a.TooHigh++
+	case VerdictTooLow:
+		a.TooLow++
+	default:
+		a.Correct++
+	}
+}
+
+// Rate is the share of grades judged correct.
+func (a Accuracy) Rate() float64 {
+	if a.Reviewed == 0 {
+		return 0
+	}
+	return float64(a.Correct) / float64(a.Reviewed)
+}
+
+// Bias is positive when grades run high and negative when they run low, as a
+// share of everything reviewed.
+func (a Accuracy) Bias() float64 {
+	if a.Reviewed == 0 {
+		return 0
+	}
+	return float64(a.TooHigh-a.TooLow) / float64(a.Reviewed)
+}
+
+// Report is reviewer accuracy overall, per grader cache version and per
+// ingredient category.
+type Report struct {
+	Overall    Accuracy
+	ByVersion  map[string]*Accuracy
+	ByCategory map[string]*Accuracy
+}
+
+func Measure(labeled []LabeledReview) Report {
+	report := Report{ByVersion: map[string]*Accuracy{}, ByCategory: map[string]*Accuracy{}}
+	for _, l := range labeled {
+		report.Overall.add(l.Verdict)
+		tally(report.ByVersion, l.Version).add(l.Verdict)
+		tally(report.ByCategory, l.Category).add(l.Verdict)
+	}
+	return report
+}
+
+func tally(m map[string]*Accuracy, key string) *Accuracy {
+	a, ok := m[key]
+	if !ok {
+		a = &Accuracy{}
+		m[key] = a
+	}
+	return a
+}
+
+// Calibrate writes the calibration section of the grading prompt: a rubric
+// line for each category reviewers found consistently graded one way, and up
+// to maxExamples reviewed grades as examples, misses first since they're what
+// the grader has to learn. labeled should be newest first, as LoadReviews
+// returns it.
+func Calibrate(labeled []LabeledReview, maxExamples int) string {
+	var b strings.Builder
+	report := Measure(labeled)
+	var rubric []string
+	for _, category := range lo.Keys(report.ByCategory) {
+		a := report.ByCategory[category]
+		if a.Reviewed < minCalibrationReviews {
+			continue
+		}
+		switch bias := a.Bias(); {
+		case bias >= minCalibrationBias:
+			rubric = append(rubric, fmt.Sprintf("- %s: reviewers found %d of %d grades too high. Grade these lower.", category, a.TooHigh, a.Reviewed))
+		case bias <= -minCalibrationBias:
+			rubric = append(rubric, fmt.Sprintf("- %s: reviewers found %d of %d grades too low. Grade these higher.", category, a.TooLow, a.Reviewed))
+		}
+	}
+	slices.Sort(rubric)
+	if len(rubric) > 0 {
+		b.WriteString("Calibration from reviewed grades:\n")
+		b.WriteString(strings.Join(rubric, "\n"))
+		b.WriteString("\n")
+	}
+
+	examples := calibrationExamples(labeled, maxExamples)
+	if len(examples) > 0 {
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("Reviewed examples:\n")
+		for _, l := range examples {
+			b.WriteString("- ")
+			b.WriteString(exampleLine(l))
+			b.WriteString("\n")
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// calibrationExamples alternates misses and correct grades, newest first, so
+// the examples show where the line is from both sides. An ingredient reviewed
+// under several grader versions is only used once.
+func calibrationExamples(labeled []LabeledReview, maxExamples int) []LabeledReview {
+	if maxExamples <= 0 {
+		return nil
+	}
+	unique := lo.UniqBy(labeled, func(l LabeledReview) string {
+		return strings.ToLower(strings.TrimSpace(l.Ingredient.Brand + " " + l.Ingredient.Description + " " + l.Ingredient.Size))
+	})
+	misses, correct := lo.FilterReject(unique, func(l LabeledReview, _ int) bool {
+		return l.Verdict != VerdictCorrect
+	})
+	examples := make([]LabeledReview, 0, maxExamples)
+	for i := 0; len(examples) < maxExamples && (i < len(misses) || i < len(correct)); i++ {
+		if i < len(misses) {
+			examples = append(examples, misses[i])
+		}
+		if i < len(correct) && len(examples) < maxExamples {
+			examples = append(examples, correct[i])
+		}
+	}
+	return examples
+}
+
+func exampleLine(l LabeledReview) string {
+	name := strings.TrimSpace(strings.Join(lo.Compact([]string{l.Ingredient.Brand, l.Ingredient.Description}), " "))
+	if size := strings.TrimSpace(l.Ingredient.Size); size != "" {
+		name += " (" + size + ")"
+	}
+	line := fmt.Sprintf("%q in %s was graded %d", name, l.Category, l.Grade)
+	switch l.Verdict {
+	case VerdictTooHigh:
+		return fmt.Sprintf("%s; it should be %d or lower.", line, l.High)
+	case VerdictTooLow:
+		return fmt.Sprintf("%s; it should be %d or higher.", line, l.Low)
+	default:
+		return line + ", which is right."
+	}
+}
+
+// Regrade is a reviewed ingredient graded again by a candidate grader.
+type Regrade struct {
+	LabeledReview
+	Score   int
+	Reason  string
+	Verdict Verdict
+}
+
+// CategoryComparison is one category's accuracy before and after.
+type CategoryComparison struct {
+	Category  string
+	Baseline  Accuracy
+	Candidate Accuracy
+}
+
+// Comparison is a candidate grader against the grades reviewers judged. The
+// baseline is the reviewers' verdicts on the grades they saw; the candidate is
+// its own grades judged against the same labels.
+type Comparison struct {
+	Baseline   Accuracy
+	Candidate  Accuracy
+	Categories []CategoryComparison
+	Regrades   []Regrade
+}
+
+// Changed are the regrades whose verdict differs from the reviewer's.
+func (c Comparison) Changed() []Regrade {
+	return lo.Filter(c.Regrades, func(r Regrade, _ int) bool {
+		return r.Verdict != r.LabeledReview.Verdict
+	})
+}
+
+// Compare regrades every reviewed ingredient with grader and judges the new
+// grades against the reviews. An ingredient reviewed more than once is graded
+// once, against its newest review.
+func Compare(ctx context.Context, grader Grader, labeled []LabeledReview) (*Comparison, error) {
+	latest := map[string]LabeledReview{}
+	var ingredients []ai.InputIngredient
+	for _, l := range labeled {
+		id := strings.TrimSpace(l.Ingredient.ProductID)
+		if id == "" {
+			continue
+		}
+		if existing, ok := latest[id]; ok && !l.ReviewedAt.After(existing.ReviewedAt) {
+			continue
+		}
+		if _, ok := latest[id]; !ok {
+			ingredient := l.Ingredient
+			ingredient.Grade = nil
+			ingredients = append(ingredients, ingredient)
+		}
+		latest[id] = l
+	}
+
+	graded, err := parallelism.Flatten(lo.Chunk(ingredients, compareBatchSize), func(batch []ai.InputIngredient) ([]ai.InputIngredient, error) {
+		return grader.GradeIngredients(ctx, batch)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("regrade reviewed ingredients: %w", err)
+	}
+
+	comparison := &Comparison{}
+	categories := map[string]*CategoryComparison{}
+	for _, ingredient := range graded {
+		l, ok := latest[ingredient.ProductID]
+		if !ok || ingredient.Grade == nil {
+			continue
+		}
+		regrade := Regrade{LabeledReview: l, Score: ingredient.Grade.Score, Reason: ingredient.Grade.Reason}
+		regrade.Verdict = l.Judge(regrade.Score)
+		comparison.Regrades = append(comparison.Regrades, regrade)
+
+		comparison.Baseline.add(l.Verdict)
+		comparison.Candidate.add(regrade.Verdict)
+		category, ok := categories[l.Category]
+		if !ok {
+			category = &CategoryComparison{Category: l.Category}
+			categories[l.Category] = category
+		}
+		category.Baseline.add(l.Verdict)
+		category.Candidate.add(regrade.Verdict)
+	}
+	for _, category := range categories {
+		comparison.Categories = append(comparison.Categories, *category)
+	}
+	slices.SortFunc(comparison.Categories, func(a, b CategoryComparison) int {
+		return cmp.Compare(a.Category, b.Category)
+	})
+	slices.SortFunc(comparison.Regrades, func(a, b Regrade) int {
+		return cmp.Or(cmp.Compare(a.Category, b.Category), cmp.Compare(a.Ingredient.ProductID, b.Ingredient.ProductID))
+	})
+	return comparison, nil
+}
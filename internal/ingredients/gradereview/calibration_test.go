@@ -0,0 +1,136 @@
+package gradereview
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"careme/internal/ai"
+	"careme/internal/cache"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func saveReview(t *testing.T, cacheStore cache.Cache, review Review) {
+	t.Helper()
+	body, err := json.Marshal(review)
+	require.NoError(t, err)
+	require.NoError(t, cacheStore.Put(t.Context(), reviewCachePrefix+review.GradeKey, string(body), cache.Unconditional()))
+}
+
+func reviewed(gradeKey, id, description, category string, score int, verdict Verdict, at time.Time) Review {
+	return Review{
+		GradeKey: gradeKey,
+		Ingredient: ai.InputIngredient{
+			ProductID:   id,
+			Description: description,
+			Categories:  []string{category},
+			Grade:       &ai.IngredientGrade{Score: score},
+		},
+		Verdict:    verdict,
+		ReviewedAt: at,
+	}
+}
+
+func TestLabelJudge(t *testing.T) {
+	tooHigh, ok := Label(reviewed("v/a", "a", "Dip", "Deli", 7, VerdictTooHigh, time.Time{}))
+	require.True(t, ok)
+	assert.Equal(t, VerdictTooHigh, tooHigh.Judge(7))
+	assert.Equal(t, VerdictCorrect, tooHigh.Judge(4))
+	assert.Equal(t, "v", tooHigh.Version)
+
+	correct, ok := Label(reviewed("v/b", "b", "Leeks", "Produce", 8, VerdictCorrect, time.Time{}))
+	require.True(t, ok)
+	assert.Equal(t, VerdictCorrect, correct.Judge(9))
+	assert.Equal(t, VerdictTooLow, correct.Judge(6))
+
+	tooLow, ok := Label(reviewed("v/c", "c", "Salmon", "Seafood", 10, VerdictTooLow, time.Time{}))
+	require.True(t, ok)
+	assert.Equal(t, 10, tooLow.Low, "nothing scores above 10")
+
+	_, ok = Label(Review{GradeKey: "v/d", Verdict: VerdictCorrect})
+	assert.False(t, ok, "a review without a grade can't be labeled")
+}
+
+func TestLoadReviewsMeasureAndCalibrate(t *testing.T) {
+	cacheStore := cache.NewInMemoryCache()
+	start := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+	for i := range 6 {
+		verdict := VerdictTooHigh
+		if i == 5 {
+			verdict = VerdictCorrect
+		}
+		saveReview(t, cacheStore, reviewed(fmt.Sprintf("old/deli%d", i), fmt.Sprintf("deli%d", i), fmt.Sprintf("Dip %d", i), "Deli", 8, verdict, start.Add(time.Duration(i)*time.Minute)))
+	}
+	saveReview(t, cacheStore, reviewed("new/leeks", "leeks", "Leeks", "Produce", 5, VerdictTooLow, start.Add(time.Hour)))
+	saveReview(t, cacheStore, reviewed("new/kale", "kale", "Kale", "Produce", 8, VerdictCorrect, start.Add(2*time.Hour)))
+
+	labeled, err := LoadReviews(t.Context(), cacheStore)
+	require.NoError(t, err)
+	require.Len(t, labeled, 8)
+	assert.Equal(t, "Kale", labeled[0].Ingredient.Description, "newest first")
+
+	report := Measure(labeled)
+	assert.Equal(t, Accuracy{Reviewed: 8, Correct: 2, TooHigh: 5, TooLow: 1}, report.Overall)
+	assert.Equal(t, Accuracy{Reviewed: 6, Correct: 1, TooHigh: 5}, *report.ByVersion["old"])
+	assert.Equal(t, Accuracy{Reviewed: 2, Correct: 1, TooLow: 1}, *report.ByCategory["Produce"])
+
+	calibration := Calibrate(labeled, 3)
+	assert.Contains(t, calibration, "- Deli: reviewers found 5 of 6 grades too high. Grade these lower.")
+	assert.NotContains(t, calibration, "- Produce:", "too few produce reviews to call a bias")
+	assert.Contains(t, calibration, `- "Leeks" in Produce was graded 5; it should be 6 or higher.`)
+	assert.Contains(t, calibration, `- "Kale" in Produce was graded 8, which is right.`)
+	assert.Equal(t, 3, strings.Count(calibration, " was graded "))
+
+	instructions := ai.IngredientGradeInstructions(calibration)
+	assert.True(t, strings.HasSuffix(instructions, calibration))
+	assert.Equal(t, ai.IngredientGradeInstructions(""), ai.IngredientGradeInstructions("  \n"))
+}
+
+type scoreGrader map[string]int
+
+func (g scoreGrader) GradeIngredients(_ context.Context, ingredients []ai.InputIngredient) ([]ai.InputIngredient, error) {
+	graded := make([]ai.InputIngredient, 0, len(ingredients))
+	for _, ingredient := range ingredients {
+		if ingredient.Grade != nil {
+			return nil, fmt.Errorf("already graded ingredient %s", ingredient.ProductID)
+		}
+		ingredient.Grade = &ai.IngredientGrade{Score: g[ingredient.ProductID]}
+		graded = append(graded, ingredient)
+	}
+	return graded, nil
+}
+
+func TestCompare(t *testing.T) {
+	start := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+	labeled := make([]LabeledReview, 0, 4)
+	for _, review := range []Review{
+		reviewed("new/dip", "dip", "Dip", "Deli", 8, VerdictTooHigh, start.Add(time.Hour)),
+		reviewed("old/dip", "dip", "Dip", "Deli", 9, VerdictTooHigh, start),
+		reviewed("new/kale", "kale", "Kale", "Produce", 8, VerdictCorrect, start),
+		reviewed("new/none", "", "Loose onions", "Produce", 8, VerdictCorrect, start),
+	} {
+		l, ok := Label(review)
+		require.True(t, ok)
+		labeled = append(labeled, l)
+	}
+
+	comparison, err := Compare(t.Context(), scoreGrader{"dip": 5, "kale": 4}, labeled)
+	require.NoError(t, err)
+	assert.Equal(t, Accuracy{Reviewed: 2, Correct: 1, TooHigh: 1}, comparison.Baseline, "dip is graded once and unidentified items are skipped")
+	assert.Equal(t, Accuracy{Reviewed: 2, Correct: 1, TooLow: 1}, comparison.Candidate)
+	require.Len(t, comparison.Categories, 2)
+	assert.Equal(t, "Deli", comparison.Categories[0].Category)
+	assert.Equal(t, Accuracy{Reviewed: 1, Correct: 1}, comparison.Categories[0].Candidate)
+
+	changed := comparison.Changed()
+	require.Len(t, changed, 2)
+	assert.Equal(t, "dip", changed[0].Ingredient.ProductID)
+	assert.Equal(t, "new/dip", changed[0].GradeKey)
+	assert.Equal(t, VerdictCorrect, changed[0].Verdict)
+	assert.Equal(t, VerdictTooLow, changed[1].Verdict)
+}
@@ -44,10 +44,18 @@ func (m *multiGrader) CacheVersion() string {
 }
 
 func NewManager(cfg *config.Config, c cache.ListCache, httpClient *http.Client) grader {
-	if cfg == nil || !cfg.IngredientGrading.Enable || strings.TrimSpace(cfg.AI.APIKey) == "" {
+	if cfg == nil || !cfg.IngredientGrading.Enable {
+		return rubberstamp{}
+	}
+	var base baseGrader
+	switch {
+	case cfg.LocalAI.IsEnabled():
+		base = ai.NewLocalIngredientGrader(cfg.LocalAI.BaseURL, cfg.LocalAI.APIKey, cfg.LocalAI.Model, httpClient)
+	case strings.TrimSpace(cfg.AI.APIKey) != "":
+		base = ai.NewIngredientGrader(cfg.AI.APIKey, cfg.IngredientGrading.Model, httpClient)
+	default:
 		return rubberstamp{}
 	}
-	base := ai.NewIngredientGrader(cfg.AI.APIKey, cfg.IngredientGrading.Model, httpClient)
 	return newCachingGrader(&multiGrader{grader: base}, NewStore(c))
 }
 
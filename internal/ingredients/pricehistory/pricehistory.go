@@ -0,0 +1,211 @@
+// Package pricehistory remembers what each store charged for its staples on
+// every fetch, so a sale price can be compared with what an item usually costs.
+package pricehistory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"careme/internal/ai"
+	"careme/internal/cache"
+)
+
+const (
+	Prefix = "price_history/"
+
+	dateLayout = "2006-01-02"
+	// keepDays is how far back observations are kept.
+	keepDays = 60
+	// TrailingDays is the window lows and averages are taken over.
+	TrailingDays = 28
+	// minDays of earlier prices are needed before anything is called a deal.
+	minDays = 3
+	// dealPercent is how far under its trailing average a price has to be to count as a deal.
+	dealPercent = 10
+)
+
+// Observation is one day's prices for a product. A later fetch the same day replaces it.
+type Observation struct {
+	Date    string   `json:"date"`
+	Regular *float32 `json:"regular,omitempty"`
+	Sale    *float32 `json:"sale,omitempty"`
+}
+
+func (o Observation) price() (float32, bool) {
+	if o.Sale != nil {
+		return *o.Sale, true
+	}
+	if o.Regular != nil {
+		return *o.Regular, true
+	}
+	return 0, false
+}
+
+// Product is every price seen for one product, oldest first.
+type Product struct {
+	Observations []Observation `json:"observations"`
+}
+
+// Stats are the trailing low and average of a product's price, sale price when
+// there was one. Days counts the days that had a price.
+type Stats struct {
+	Days    int
+	Low     float32
+	Average float32
+}
+
+// Trailing returns stats over the days days before date, not counting date itself.
+func (p Product) Trailing(date time.Time, days int) Stats {
+	until := date.UTC().Format(dateLayout)
+	since := date.UTC().AddDate(0, 0, -days).Format(dateLayout)
+	var stats Stats
+	var sum float32
+	for _, o := range p.Observations {
+		if o.Date < since || o.Date >= until {
+			continue
+		}
+		price, ok := o.price()
+		if !ok {
+			continue
+		}
+		if stats.Days == 0 || price < stats.Low {
+			stats.Low = price
+		}
+		sum += price
+		stats.Days++
+	}
+	if stats.Days > 0 {
+		stats.Average = sum / float32(stats.Days)
+	}
+	return stats
+}
+
+func (p *Product) observe(o Observation) {
+	if n := len(p.Observations); n > 0 && p.Observations[n-1].Date == o.Date {
+		p.Observations[n-1] = o
+		return
+	}
+	p.Observations = append(p.Observations, o)
+}
+
+func (p *Product) prune(before string) {
+	p.Observations = slices.DeleteFunc(p.Observations, func(o Observation) bool {
+		return o.Date < before
+	})
+}
+
+// Location is the price history of every product a location has listed, by ProductID.
+type Location struct {
+	Products map[string]*Product `json:"products"`
+}
+
+// Store keeps one Location per location ID in the cache.
+type Store struct {
+	cache cache.Cache
+	now   func() time.Time
+}
+
+func NewStore(c cache.Cache) *Store {
+	if c == nil {
+		panic("cache must not be nil")
+	}
+	return &Store{cache: c, now: time.Now}
+}
+
+func cacheKey(locationID string) string {
+	return Prefix + locationID
+}
+
+// Record adds today's prices for ingredients to the location's history and
+// returns copies of them with PriceHistory set from the days before today.
+// Products that stopped showing up age out after keepDays.
+func (s *Store) Record(ctx context.Context, locationID string, ingredients []ai.InputIngredient) ([]ai.InputIngredient, error) {
+	now := s.now().UTC()
+	today := now.Format(dateLayout)
+	cutoff := now.AddDate(0, 0, -keepDays).Format(dateLayout)
+	history, err := cache.UpdateJSON(ctx, s.cache, cacheKey(locationID), func(l *Location, _ bool) error {
+		if l.Products == nil {
+			l.Products = map[string]*Product{}
+		}
+		for _, ingredient := range ingredients {
+			if ingredient.ProductID == "" || (ingredient.PriceRegular == nil && ingredient.PriceSale == nil) {
+				continue
+			}
+			p := l.Products[ingredient.ProductID]
+			if p == nil {
+				p = &Product{}
+				l.Products[ingredient.ProductID] = p
+			}
+			p.observe(Observation{Date: today, Regular: ingredient.PriceRegular, Sale: ingredient.PriceSale})
+		}
+		for id, p := range l.Products {
+			p.prune(cutoff)
+			if len(p.Observations) == 0 {
+				delete(l.Products, id)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("record price history for %s: %w", locationID, err)
+	}
+	return annotate(history, ingredients, now), nil
+}
+
+func annotate(history Location, ingredients []ai.InputIngredient, now time.Time) []ai.InputIngredient {
+	annotated := slices.Clone(ingredients)
+	for i, ingredient := range annotated {
+		p, ok := history.Products[ingredient.ProductID]
+		if !ok {
+			continue
+		}
+		stats := p.Trailing(now, TrailingDays)
+		if stats.Days == 0 {
+			continue
+		}
+		ph := &ai.PriceHistory{Days: stats.Days, Low: stats.Low, Average: stats.Average}
+		annotated[i].PriceHistory = ph
+		ph.Deal = stats.Days >= minDays && annotated[i].PercentBelowUsual() >= dealPercent
+	}
+	return annotated
+}
+
+// Location returns everything recorded for a location, empty when nothing was.
+func (s *Store) Location(ctx context.Context, locationID string) (Location, error) {
+	key := cacheKey(locationID)
+	r, err := s.cache.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, cache.ErrNotFound) {
+			return Location{}, nil
+		}
+		return Location{}, err
+	}
+	defer func() {
+		if err := r.Close(); err != nil {
+			slog.ErrorContext(ctx, "failed to close price history reader", "key", key, "error", err)
+		}
+	}()
+	var l Location
+	if err := json.NewDecoder(r).Decode(&l); err != nil {
+		return Location{}, fmt.Errorf("decode %s: %w", key, err)
+	}
+	return l, nil
+}
+
+// Product returns the trailing low and average for one product at a location, today included.
+func (s *Store) Product(ctx context.Context, locationID, productID string) (Stats, error) {
+	l, err := s.Location(ctx, locationID)
+	if err != nil {
+		return Stats{}, err
+	}
+	p, ok := l.Products[productID]
+	if !ok {
+		return Stats{}, nil
+	}
+	return p.Trailing(s.now().AddDate(0, 0, 1), TrailingDays), nil
+}
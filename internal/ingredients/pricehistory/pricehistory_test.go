@@ -0,0 +1,72 @@
+package pricehistory
+
+import (
+	"testing"
+	"time"
+
+	"careme/internal/ai"
+	"careme/internal/cache"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordFlagsOnlyPricesUnderTheUsual(t *testing.T) {
+	s := NewStore(cache.NewInMemoryCache())
+	day := time.Date(2026, time.May, 1, 15, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return day }
+
+	fetch := func(salmon, leeks *float32) []ai.InputIngredient {
+		t.Helper()
+		got, err := s.Record(t.Context(), "70100023", []ai.InputIngredient{
+			{ProductID: "salmon-1", Description: "Salmon", PriceRegular: new(float32(14)), PriceSale: salmon},
+			{ProductID: "leek-1", Description: "Leeks", PriceRegular: leeks},
+		})
+		require.NoError(t, err)
+		return got
+	}
+
+	// the store always has salmon "on sale" for 10.
+	for range 3 {
+		got := fetch(new(float32(10)), new(float32(3)))
+		assert.False(t, got[0].Deal())
+		assert.False(t, got[1].Deal(), "no deals without %d days of history", minDays)
+		day = day.AddDate(0, 0, 1)
+	}
+	fetch(new(float32(10)), new(float32(3.5))) // a second fetch the same day replaces the first
+	fetch(new(float32(10)), new(float32(3)))
+
+	day = day.AddDate(0, 0, 1)
+	got := fetch(new(float32(10)), new(float32(2)))
+	require.NotNil(t, got[0].PriceHistory)
+	assert.Equal(t, 4, got[0].PriceHistory.Days)
+	assert.False(t, got[0].Deal(), "a standing sale isn't a deal")
+	assert.True(t, got[1].Deal())
+	assert.Equal(t, ai.PriceHistory{Days: 4, Low: 3, Average: 3, Deal: true}, *got[1].PriceHistory)
+
+	stats, err := s.Product(t.Context(), "70100023", "leek-1")
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Days: 5, Low: 2, Average: 2.8}, stats)
+}
+
+func TestRecordForgetsOldPrices(t *testing.T) {
+	s := NewStore(cache.NewInMemoryCache())
+	day := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return day }
+
+	_, err := s.Record(t.Context(), "70100023", []ai.InputIngredient{
+		{ProductID: "ramps-1", PriceRegular: new(float32(9))},
+		{ProductID: "leek-1", PriceRegular: new(float32(3))},
+	})
+	require.NoError(t, err)
+
+	day = day.AddDate(0, 0, keepDays+1)
+	got, err := s.Record(t.Context(), "70100023", []ai.InputIngredient{{ProductID: "leek-1", PriceRegular: new(float32(3))}})
+	require.NoError(t, err)
+	assert.Nil(t, got[0].PriceHistory)
+
+	l, err := s.Location(t.Context(), "70100023")
+	require.NoError(t, err)
+	assert.NotContains(t, l.Products, "ramps-1")
+	assert.Len(t, l.Products["leek-1"].Observations, 1)
+}
@@ -83,6 +83,16 @@ func (p StaplesProvider) FetchWines(ctx context.Context, locationID string, styl
 	})
 }
 
+// SearchStaples looks up term at the store right now, for finding something to
+// swap in when a planned ingredient isn't on the shelf.
+func (p StaplesProvider) SearchStaples(ctx context.Context, locationID, term string) ([]ai.InputIngredient, error) {
+	ingredients, err := searchIngredients(ctx, p.client, locationID, term, []string{"*"}, false, 0)
+	if err != nil {
+		return nil, err
+	}
+	return lo.Map(ingredients, inputIngredientFromKrogerIngredient), nil
+}
+
 var availableInStore = products.Ais
 
 func searchIngredients(ctx context.Context, client *products.ClientWithResponses, locationID, term string, brands []string, frozen bool, skip int) ([]Ingredient, error) {
@@ -0,0 +1,66 @@
+package locations
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"careme/internal/auth"
+	"careme/internal/httpx"
+	"careme/internal/routing"
+)
+
+type apiLocation struct {
+	Location
+	// SupportsStaples means we have inventory and can generate recipes for the store.
+	SupportsStaples bool `json:"supports_staples"`
+	Favorite        bool `json:"favorite,omitempty"`
+}
+
+// RegisterAPI serves the JSON version of the locations page. Like the page it
+// works without an account; signing in only marks the favorite store.
+func (l *locationServer) RegisterAPI(mux routing.Registrar, authClient auth.AuthClient) {
+	mux.HandleFunc("GET /api/v1/locations", func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		var favoriteStore string
+		currentUser, err := l.userStorage.FromRequest(ctx, r, authClient)
+		if err != nil {
+			if !errors.Is(err, auth.ErrNoSession) {
+				slog.ErrorContext(ctx, "failed to get user from request", "error", err)
+				httpx.JSONError(w, "unable to load account", http.StatusInternalServerError)
+				return
+			}
+		} else {
+			favoriteStore = currentUser.FavoriteStore
+		}
+
+		coordinates, err := l.searchCoordinates(r)
+		if err != nil {
+			httpx.JSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// zero locations is valid here.
+		locs, err := l.storage.GetLocationsByCoordinates(ctx, coordinates)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to get locations for api", "lat", coordinates.Lat, "lon", coordinates.Lon, "error", err)
+			httpx.JSONError(w, "failed to search locations", http.StatusInternalServerError)
+			return
+		}
+
+		result := struct {
+			Locations []apiLocation `json:"locations"`
+		}{
+			Locations: make([]apiLocation, 0, len(locs)),
+		}
+		for _, loc := range locs {
+			result.Locations = append(result.Locations, apiLocation{
+				Location:        loc,
+				SupportsStaples: l.storage.HasInventory(loc.ID),
+				Favorite:        favoriteStore != "" && loc.ID == favoriteStore,
+			})
+		}
+		if err := httpx.WriteJSON(w, http.StatusOK, result); err != nil {
+			slog.ErrorContext(ctx, "failed to encode locations", "error", err)
+		}
+	})
+}
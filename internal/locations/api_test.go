@@ -0,0 +1,52 @@
+package locations
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"careme/internal/auth"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocationsAPIReturnsJSON(t *testing.T) {
+	client := newFakeLocationClient()
+	client.setListResponse("98005", []Location{
+		{ID: "70001001", Name: "Supported Store", ZipCode: "98005"},
+		{ID: "70001002", Name: "Other Store", ZipCode: "98005"},
+	})
+	client.setHasInventory("70001001", true)
+	server := NewServer(newTestLocationServer(client), LoadCentroids(), fakeUserLookup{}, fakeProduceScoreLookup{})
+	mux := http.NewServeMux()
+	server.RegisterAPI(mux, auth.DefaultMock())
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/locations?zip=98005", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "application/json; charset=utf-8", rr.Header().Get("Content-Type"))
+	var got struct {
+		Locations []apiLocation `json:"locations"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	require.Len(t, got.Locations, 2)
+	assert.Equal(t, "70001001", got.Locations[0].ID)
+	assert.True(t, got.Locations[0].SupportsStaples)
+	assert.Equal(t, "70001002", got.Locations[1].ID)
+	assert.False(t, got.Locations[1].SupportsStaples)
+}
+
+func TestLocationsAPIRejectsMixedLocationInputs(t *testing.T) {
+	server := NewServer(newTestLocationServer(newFakeLocationClient()), LoadCentroids(), fakeUserLookup{}, fakeProduceScoreLookup{})
+	mux := http.NewServeMux()
+	server.RegisterAPI(mux, auth.DefaultMock())
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/locations?zip=98005&lat=47.6&lon=-122.3", nil))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.JSONEq(t, `{"error":"provide either a ZIP code or coordinates, not both"}`, rr.Body.String())
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"html/template"
 	"net/http"
+	"slices"
+	"strings"
 
 	"careme/internal/auth"
 	"careme/internal/locations/geo"
@@ -38,6 +40,14 @@ var fakes = map[string]Location{
 	},
 }
 
+// fakeLocations lists the fakes by ID so pages and recorded AI fixtures see
+// them in the same order every run.
+func fakeLocations() []Location {
+	locs := lo.Values(fakes)
+	slices.SortFunc(locs, func(a, b Location) int { return strings.Compare(a.ID, b.ID) })
+	return locs
+}
+
 func float64Pointer(value float64) *float64 {
 	return &value
 }
@@ -51,7 +61,7 @@ func (m mock) GetLocationByID(ctx context.Context, locationID string) (*Location
 }
 
 func (m mock) GetLocationsByCoordinates(ctx context.Context, coordinates geo.Coordinate) ([]Location, error) {
-	return lo.Values(fakes), nil
+	return fakeLocations(), nil
 }
 
 func (mock) HasInventory(locationID string) bool {
@@ -77,7 +87,7 @@ func (m mock) Register(mux routing.Registrar, _ auth.AuthClient) {
 			Style           seasons.Style
 			ServerSignedIn  bool
 		}{
-			Locations:       lo.Values(fakes),
+			Locations:       fakeLocations(),
 			Zip:             r.URL.Query().Get("zip"),
 			FavoriteStore:   "",
 			ClarityScript:   templates.ClarityScript(r.Context()),
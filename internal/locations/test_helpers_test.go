@@ -106,6 +106,10 @@ func (f failingListCache) Get(context.Context, string) (io.ReadCloser, error) {
 	return nil, cachepkg.ErrNotFound
 }
 
+func (f failingListCache) GetWithETag(context.Context, string) (io.ReadCloser, string, error) {
+	return nil, "", cachepkg.ErrNotFound
+}
+
 func (f failingListCache) Exists(context.Context, string) (bool, error) {
 	return false, nil
 }
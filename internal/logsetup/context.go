@@ -51,6 +51,23 @@ func UserIDFromContext(ctx context.Context) (string, bool) {
 	return userID, true
 }
 
+// UserID is the user a request or job acts for: one set with WithUserID, or
+// else the signed in clerk session.
+func UserID(ctx context.Context) (string, bool) {
+	if userID, ok := UserIDFromContext(ctx); ok {
+		return userID, true
+	}
+	if ctx == nil {
+		return "", false
+	}
+	// hard dependency on clerk is bad. but plumbg an auth
+	sessionClaims, ok := clerk.SessionClaimsFromContext(ctx)
+	if !ok || sessionClaims == nil || sessionClaims.Subject == "" {
+		return "", false
+	}
+	return sessionClaims.Subject, true
+}
+
 // Cosider https://github.com/PumpkinSeed/slog-context instead
 type contextHandler struct {
 	handler slog.Handler
@@ -75,16 +92,9 @@ func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
 			slog.String("span_id", spanContext.SpanID().String()),
 		)
 	}
-	if userID, ok := UserIDFromContext(ctx); ok {
+	if userID, ok := UserID(ctx); ok {
 		record.AddAttrs(slog.String("user_id", userID))
-		return h.handler.Handle(ctx, record)
 	}
-	// hard dependency on clerk is bad. but plumbg an auth
-	sessionClaims, ok := clerk.SessionClaimsFromContext(ctx)
-	if ok && sessionClaims != nil {
-		record.AddAttrs(slog.String("user_id", sessionClaims.Subject))
-	}
-
 	return h.handler.Handle(ctx, record)
 }
 
@@ -16,6 +16,7 @@ import (
 	"time"
 
 	"careme/internal/ai"
+	"careme/internal/ai/spend"
 	"careme/internal/cache"
 	"careme/internal/config"
 	ingredientgrading "careme/internal/ingredients/grading"
@@ -76,6 +77,9 @@ func NewMailer(cfg *config.Config) (*mailer, error) {
 	}
 
 	userStorage := users.NewStorage(cache)
+	spendLedger := spend.NewLedger(cache, cfg.Spend)
+	spendLedger.Start()
+	ai.SetUsageMeter(spendLedger)
 	aiHTTPClient := &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)}
 	mc := critique.NewManager(cfg, cache, aiHTTPClient)
 	ig := ingredientgrading.NewManager(cfg, cache, aiHTTPClient)
@@ -84,7 +88,7 @@ func NewMailer(cfg *config.Config) (*mailer, error) {
 		return nil, fmt.Errorf("failed to create staples service: %w", err)
 	}
 	ss := recipes.StatusStore(cache)
-	aiClient := ai.NewClient(cfg.AI.APIKey, "TODOMODEL", aiHTTPClient, prompts.NewCacheRecorder(cache))
+	aiClient := ai.NewFromConfig(cfg, aiHTTPClient, prompts.NewCacheConversationStore(cache), prompts.NewCacheRecorder(cache))
 	generator, err := recipes.NewGenerator(aiClient, mc, staples, ss, recipes.IO(cache))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create recipe generator: %w", err)
@@ -104,13 +108,16 @@ func NewMailer(cfg *config.Config) (*mailer, error) {
 	}
 
 	return &mailer{
-		cache:              cache,
-		userStorage:        userStorage,
-		generator:          generator,
-		locServer:          locationserver,
-		client:             sendgrid.NewSendClient(sendgridkey),
-		publicOrigin:       cfg.ResolvedPublicOrigin(),
-		wait:               mc.Wait,
+		cache:        cache,
+		userStorage:  userStorage,
+		generator:    generator,
+		locServer:    locationserver,
+		client:       sendgrid.NewSendClient(sendgridkey),
+		publicOrigin: cfg.ResolvedPublicOrigin(),
+		wait: func() {
+			mc.Wait()
+			spendLedger.Wait()
+		},
 		unsubscribeFactory: users.NewUnsubscribeTokenFactory(*cfg),
 	}, nil
 }
@@ -174,8 +181,19 @@ func (m *mailer) sendEmail(ctx context.Context, user utypes.User) {
 		return
 	}
 
+	pantry, err := m.userStorage.Pantry(ctx, user.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to load pantry", "user", user.ID, "error", err)
+		return
+	}
+
 	p := recipes.DefaultParams(l, date)
 	// p.UserID = user.ID
+	p.ApplyUser(&user, pantry)
+	if err := p.SetWeekPlanDays(user.WeekPlanDays); err != nil {
+		slog.ErrorContext(ctx, "invalid week plan days", "user", user.ID, "error", err)
+		return
+	}
 
 	paramsHash := p.Hash()
 	sentKey := mailSentPrefix + paramsHash + "/" + user.ID
@@ -227,6 +245,11 @@ func (m *mailer) sendEmail(ctx context.Context, user utypes.User) {
 			slog.ErrorContext(ctx, "failed to save shopping list", "user", user.ID, "params_hash", paramsHash, "error", err)
 			return
 		}
+		if len(p.Days) > 0 {
+			if err := rio.SaveWeekPlan(ctx, recipes.NewWeekPlan(p, shoppingList, nil), paramsHash); err != nil {
+				slog.ErrorContext(ctx, "failed to save week plan", "user", user.ID, "params_hash", paramsHash, "error", err)
+			}
+		}
 	}
 
 	var buf bytes.Buffer
@@ -242,8 +265,11 @@ func (m *mailer) sendEmail(ctx context.Context, user utypes.User) {
 	from := mail.NewEmail("Chef", "chef@careme.cooking")
 	subject := "Your new recipes are ready!"
 
-	plainTextContent := "Check out your new recipes at " + m.publicOrigin + "/recipes?h=" + paramsHash +
-		"\n\n Unsubscribe from these emails: " + unsubscribeURL
+	plainTextContent := "Check out your new recipes at " + m.publicOrigin + "/recipes?h=" + paramsHash
+	if len(p.Days) > 0 {
+		plainTextContent += "\n\nSee the week plan at " + m.publicOrigin + "/recipes/" + paramsHash + "/week"
+	}
+	plainTextContent += "\n\n Unsubscribe from these emails: " + unsubscribeURL
 
 	to := mail.NewEmail(user.Email[0], user.Email[0])
 	message := mail.NewSingleEmail(from, subject, to, plainTextContent, buf.String())
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"io"
 	"os"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -66,6 +67,11 @@ func (c *fakeMailCache) Get(_ context.Context, key string) (io.ReadCloser, error
 	return io.NopCloser(strings.NewReader(value)), nil
 }
 
+func (c *fakeMailCache) GetWithETag(ctx context.Context, key string) (io.ReadCloser, string, error) {
+	reader, err := c.Get(ctx, key)
+	return reader, "", err
+}
+
 func (c *fakeMailCache) Exists(_ context.Context, key string) (bool, error) {
 	_, ok := c.data[key]
 	return ok, nil
@@ -81,6 +87,16 @@ func (c *fakeMailCache) Put(_ context.Context, key, value string, opts cache.Put
 	return nil
 }
 
+func (c *fakeMailCache) List(_ context.Context, prefix, _ string) ([]string, error) {
+	var keys []string
+	for key := range c.data {
+		if rest, ok := strings.CutPrefix(key, prefix); ok {
+			keys = append(keys, rest)
+		}
+	}
+	return keys, nil
+}
+
 func (c *fakeMailCache) PutReader(_ context.Context, key string, reader io.Reader, opts cache.PutOptions) error {
 	body, err := io.ReadAll(reader)
 	if err != nil {
@@ -109,11 +125,13 @@ func (f *fakeMailClient) Send(msg *sgmail.SGMailV3) (*rest.Response, error) {
 }
 
 type capturingMailGenerator struct {
-	ctx context.Context
+	ctx    context.Context
+	params *recipes.GeneratorParams
 }
 
-func (g *capturingMailGenerator) GenerateRecipes(ctx context.Context, _ *recipes.GeneratorParams) (*ai.ShoppingList, error) {
+func (g *capturingMailGenerator) GenerateRecipes(ctx context.Context, p *recipes.GeneratorParams) (*ai.ShoppingList, error) {
 	g.ctx = ctx
+	g.params = p
 	return &ai.ShoppingList{
 		Recipes: []ai.Recipe{
 			{Title: "Generated Test Recipe"},
@@ -147,7 +165,8 @@ func TestSendEmail_DoesNotRecordSentClaimOnNonSuccessSendGridStatus(t *testing.T
 	fc := newFakeMailCache(t)
 	location := testMailLocation()
 	m := &mailer{
-		cache: fc,
+		cache:       fc,
+		userStorage: users.NewStorage(fc),
 		locServer: &fakeMailLocServer{
 			location: location,
 		},
@@ -179,7 +198,8 @@ func TestSendEmail_RecordsSentClaimOnSuccessSendGridStatus(t *testing.T) {
 		response: &rest.Response{StatusCode: 202, Body: "accepted"},
 	}
 	m := &mailer{
-		cache: fc,
+		cache:       fc,
+		userStorage: users.NewStorage(fc),
 		locServer: &fakeMailLocServer{
 			location: location,
 		},
@@ -235,7 +255,8 @@ func TestSendEmail_GenerationContextIncludesMailSessionAndUserID(t *testing.T) {
 	location := testMailLocation()
 	generator := &capturingMailGenerator{}
 	m := &mailer{
-		cache: fc,
+		cache:       fc,
+		userStorage: users.NewStorage(fc),
 		locServer: &fakeMailLocServer{
 			location: location,
 		},
@@ -273,3 +294,58 @@ func TestSendEmail_GenerationContextIncludesMailSessionAndUserID(t *testing.T) {
 		t.Fatalf("expected user id user-1, got %q", userID)
 	}
 }
+
+func TestSendEmail_GeneratesWithUserPreferences(t *testing.T) {
+	fc := newFakeMailCache(t)
+	fc.missShoppingList = true
+	location := testMailLocation()
+	generator := &capturingMailGenerator{}
+	storage := users.NewStorage(fc)
+	m := &mailer{
+		cache:       fc,
+		userStorage: storage,
+		locServer: &fakeMailLocServer{
+			location: location,
+		},
+		generator: generator,
+		client: &fakeMailClient{
+			response: &rest.Response{StatusCode: 202, Body: "accepted"},
+		},
+		publicOrigin:       "https://careme.cooking",
+		unsubscribeFactory: users.FakeUnsubscribeTokenFactory(),
+	}
+	pantry := utypes.Pantry{Items: []utypes.PantryItem{{Name: "rice"}}}
+	if err := storage.SavePantry(context.Background(), "user-1", pantry); err != nil {
+		t.Fatalf("failed to save pantry: %v", err)
+	}
+
+	user := utypes.User{
+		ID:            "user-1",
+		MailOptIn:     true,
+		Email:         []string{"u1@example.com"},
+		FavoriteStore: "123",
+		ShoppingDay:   shoppingDayForStore(t, location),
+		Household:     utypes.Household{Members: 2, Allergens: []string{"peanuts"}},
+		Budget:        utypes.Budget{PerWeek: 80},
+	}
+	m.sendEmail(context.Background(), user)
+
+	if generator.params == nil {
+		t.Fatal("expected generator to be called")
+	}
+	if !slices.Equal(generator.params.Household.Allergens, []string{"peanuts"}) {
+		t.Fatalf("expected mailed list to avoid the household's allergens, got %v", generator.params.Household.Allergens)
+	}
+	if generator.params.Budget != user.Budget {
+		t.Fatalf("expected mailed list to keep the user's budget, got %+v", generator.params.Budget)
+	}
+	if len(generator.params.Pantry) != 1 || generator.params.Pantry[0].Name != "rice" {
+		t.Fatalf("expected mailed list to plan around the pantry, got %v", generator.params.Pantry)
+	}
+
+	web := recipes.DefaultParams(location, generator.params.Date)
+	web.ApplyUser(&user, pantry)
+	if generator.params.Hash() != web.Hash() {
+		t.Fatal("expected the mailed list to hash the same as the user's list on the site")
+	}
+}
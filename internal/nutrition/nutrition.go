@@ -0,0 +1,242 @@
+// Package nutrition estimates per-serving nutrition for a recipe from its
+// ingredient quantities instead of trusting the model's health sentence.
+package nutrition
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	// foods.csv is a small subset of USDA FoodData Central (SR Legacy) covering
+	// common weeknight ingredients. Nutrients are per 100 g of the food as bought
+	// (raw meat, dry grains, drained canned beans). grams_per_cup and grams_each
+	// convert volume and count quantities to weight; 0 means we don't know.
+	// Names are singular and "|" separates aliases. Add rows by hand from
+	// https://fdc.nal.usda.gov when an ingredient keeps showing up as uncounted.
+	//
+	//go:embed foods.csv
+	foodsCSV []byte
+
+	loadFoods = sync.OnceValue(func() []food {
+		foods, err := parseFoods(foodsCSV)
+		if err != nil {
+			panic("failed to parse embedded nutrition table: " + err.Error())
+		}
+		return foods
+	})
+)
+
+// Facts is per-serving nutrition. Ingredients we couldn't match or measure are
+// listed in Uncounted and contribute nothing, so the numbers are a floor.
+type Facts struct {
+	Calories         int      `json:"calories"`
+	ProteinGrams     int      `json:"protein_g"`
+	CarbsGrams       int      `json:"carbs_g"`
+	FatGrams         int      `json:"fat_g"`
+	FiberGrams       int      `json:"fiber_g"`
+	SodiumMilligrams int      `json:"sodium_mg"`
+	Counted          int      `json:"counted"`
+	Uncounted        []string `json:"uncounted,omitempty"`
+}
+
+// Ingredient is one recipe line. Amount and Unit come from ai.ParseQuantity.
+type Ingredient struct {
+	Name   string
+	Amount float64
+	Unit   string
+}
+
+type food struct {
+	// names holds the primary name and aliases, normalized.
+	names        []string
+	per100g      nutrients
+	gramsPerCup  float64
+	gramsPerEach float64
+}
+
+type nutrients struct {
+	calories, protein, carbs, fat, fiber, sodium float64
+}
+
+func (n *nutrients) add(o nutrients, grams float64) {
+	f := grams / 100
+	n.calories += o.calories * f
+	n.protein += o.protein * f
+	n.carbs += o.carbs * f
+	n.fat += o.fat * f
+	n.fiber += o.fiber * f
+	n.sodium += o.sodium * f
+}
+
+// Estimate totals the ingredients and divides by servings. It returns nil when
+// servings is unknown or nothing could be counted.
+func Estimate(ingredients []Ingredient, servings int) *Facts {
+	if servings <= 0 {
+		return nil
+	}
+	var total nutrients
+	facts := &Facts{}
+	for _, ing := range ingredients {
+		f, ok := match(ing.Name)
+		if !ok {
+			facts.Uncounted = append(facts.Uncounted, ing.Name)
+			continue
+		}
+		grams, ok := f.grams(ing.Amount, ing.Unit)
+		if !ok {
+			facts.Uncounted = append(facts.Uncounted, ing.Name)
+			continue
+		}
+		total.add(f.per100g, grams)
+		facts.Counted++
+	}
+	if facts.Counted == 0 {
+		return nil
+	}
+	s := float64(servings)
+	facts.Calories = round(total.calories / s)
+	facts.ProteinGrams = round(total.protein / s)
+	facts.CarbsGrams = round(total.carbs / s)
+	facts.FatGrams = round(total.fat / s)
+	facts.FiberGrams = round(total.fiber / s)
+	facts.SodiumMilligrams = round(total.sodium / s)
+	return facts
+}
+
+func round(v float64) int {
+	return int(math.Round(v))
+}
+
+var (
+	weightGrams = map[string]float64{
+		"g": 1, "gram": 1, "kg": 1000, "kilogram": 1000,
+		"oz": 28.35, "ounce": 28.35, "lb": 453.6, "pound": 453.6,
+	}
+	volumeCups = map[string]float64{
+		"cup": 1, "tablespoon": 1.0 / 16, "tbsp": 1.0 / 16, "tbs": 1.0 / 16,
+		"teaspoon": 1.0 / 48, "tsp": 1.0 / 48, "ml": 1 / 236.6, "milliliter": 1 / 236.6,
+		"l": 1000 / 236.6, "liter": 1000 / 236.6, "pint": 2, "quart": 4,
+	}
+	// anything that isn't a weight, a volume or one of these is a count like
+	// "3 cloves" or "2 large", and grams_each says what one weighs.
+	unknownSizeUnits = map[string]bool{
+		"bunch": true, "package": true, "pkg": true, "bag": true, "jar": true, "box": true,
+		"handful": true, "pinch": true, "dash": true, "sprig": true, "splash": true, "drizzle": true,
+	}
+	// "1 (15 oz) can" says the weight right in the quantity.
+	packageWeight = regexp.MustCompile(`^\(\s*([\d.]+)\s*-?\s*([a-z]+)\.?\s*\)`)
+)
+
+func (f food) grams(amount float64, unit string) (float64, bool) {
+	if amount <= 0 {
+		return 0, false
+	}
+	unit = strings.ToLower(strings.TrimSpace(unit))
+	if m := packageWeight.FindStringSubmatch(unit); m != nil {
+		size, err := strconv.ParseFloat(m[1], 64)
+		if g, ok := weightGrams[singular(m[2])]; ok && err == nil {
+			return amount * size * g, true
+		}
+	}
+	word, _, _ := strings.Cut(unit, " ")
+	word = singular(strings.TrimSuffix(word, "."))
+	if g, ok := weightGrams[word]; ok {
+		return amount * g, true
+	}
+	if cups, ok := volumeCups[word]; ok && f.gramsPerCup > 0 {
+		return amount * cups * f.gramsPerCup, true
+	}
+	if !unknownSizeUnits[word] && f.gramsPerEach > 0 {
+		return amount * f.gramsPerEach, true
+	}
+	return 0, false
+}
+
+// match finds the food whose name appears as whole words in the ingredient name,
+// preferring the longest so "chicken broth" beats "chicken".
+func match(name string) (food, bool) {
+	padded := " " + normalize(name) + " "
+	var best food
+	bestLen := 0
+	for _, f := range loadFoods() {
+		for _, n := range f.names {
+			if len(n) > bestLen && strings.Contains(padded, " "+n+" ") {
+				best, bestLen = f, len(n)
+			}
+		}
+	}
+	return best, bestLen > 0
+}
+
+var nonWord = regexp.MustCompile(`[^a-z\s-]+`)
+
+func normalize(name string) string {
+	name = strings.ToLower(name)
+	if i := strings.Index(name, "("); i >= 0 {
+		if j := strings.Index(name[i:], ")"); j >= 0 {
+			name = name[:i] + " " + name[i+j+1:]
+		}
+	}
+	words := strings.Fields(nonWord.ReplaceAllString(name, " "))
+	for i, w := range words {
+		words[i] = singular(w)
+	}
+	return strings.Join(words, " ")
+}
+
+func singular(w string) string {
+	switch {
+	case len(w) <= 2 || strings.HasSuffix(w, "ss"):
+		return w
+	case strings.HasSuffix(w, "oes"), strings.HasSuffix(w, "ches"):
+		return strings.TrimSuffix(w, "es")
+	case strings.HasSuffix(w, "ies"):
+		return strings.TrimSuffix(w, "ies") + "y"
+	}
+	return strings.TrimSuffix(w, "s")
+}
+
+func parseFoods(raw []byte) ([]food, error) {
+	rows, err := csv.NewReader(bytes.NewReader(raw)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) < 2 {
+		return nil, fmt.Errorf("empty nutrition table")
+	}
+	foods := make([]food, 0, len(rows)-1)
+	for i, row := range rows[1:] {
+		if len(row) != 9 {
+			return nil, fmt.Errorf("row %d: expected 9 columns, got %d", i+2, len(row))
+		}
+		values := make([]float64, 8)
+		for j, cell := range row[1:] {
+			if values[j], err = strconv.ParseFloat(cell, 64); err != nil {
+				return nil, fmt.Errorf("row %d: %w", i+2, err)
+			}
+		}
+		var names []string
+		for n := range strings.SplitSeq(row[0], "|") {
+			names = append(names, normalize(n))
+		}
+		foods = append(foods, food{
+			names: slices.Compact(names),
+			per100g: nutrients{
+				calories: values[0], protein: values[1], carbs: values[2],
+				fat: values[3], fiber: values[4], sodium: values[5],
+			},
+			gramsPerCup:  values[6],
+			gramsPerEach: values[7],
+		})
+	}
+	return foods, nil
+}
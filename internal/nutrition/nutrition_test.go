@@ -0,0 +1,95 @@
+package nutrition
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedTableParses(t *testing.T) {
+	foods, err := parseFoods(foodsCSV)
+	require.NoError(t, err)
+	assert.Greater(t, len(foods), 100)
+}
+
+func TestMatchPrefersLongestName(t *testing.T) {
+	for name, want := range map[string]string{
+		"Low-sodium chicken broth":         "chicken broth",
+		"Boneless skinless chicken thighs": "chicken thigh",
+		"Extra-virgin olive oil":           "olive oil",
+		"Kosher salt":                      "kosher salt",
+		"Roma tomatoes (about 3)":          "tomato",
+		"Unsalted butter":                  "butter",
+	} {
+		f, ok := match(name)
+		require.True(t, ok, name)
+		assert.Contains(t, f.names, want, name)
+	}
+	eggplant, ok := match("Eggplant")
+	require.True(t, ok)
+	assert.NotContains(t, eggplant.names, "egg")
+	for _, name := range []string{"Gochujang", ""} {
+		_, ok := match(name)
+		assert.False(t, ok, name)
+	}
+}
+
+func TestGramsConvertsUnits(t *testing.T) {
+	rice, _ := match("rice")
+	garlic, _ := match("garlic")
+	beans, _ := match("black beans")
+	cilantro, _ := match("cilantro")
+
+	for _, tc := range []struct {
+		name   string
+		food   food
+		amount float64
+		unit   string
+		want   float64
+	}{
+		{"weight", rice, 1, "lb", 453.6},
+		{"volume", rice, 2, "cups", 370},
+		{"spoon", rice, 4, "tbsp.", 46.25},
+		{"count", garlic, 3, "cloves, minced", 9},
+		{"package weight", beans, 1, "(15 oz) can", 425.25},
+	} {
+		got, ok := tc.food.grams(tc.amount, tc.unit)
+		require.True(t, ok, tc.name)
+		assert.InDelta(t, tc.want, got, 0.01, tc.name)
+	}
+
+	_, ok := cilantro.grams(1, "bunch")
+	assert.False(t, ok)
+	_, ok = rice.grams(0, "cups")
+	assert.False(t, ok)
+}
+
+func TestEstimatePerServing(t *testing.T) {
+	facts := Estimate([]Ingredient{
+		{Name: "Chicken breast", Amount: 1, Unit: "lb"},
+		{Name: "Jasmine rice", Amount: 1, Unit: "cup"},
+		{Name: "Olive oil", Amount: 2, Unit: "tbsp"},
+		{Name: "Kosher salt", Amount: 1, Unit: "tsp"},
+		{Name: "Gochujang", Amount: 2, Unit: "tbsp"},
+		{Name: "Black pepper"},
+	}, 2)
+	require.NotNil(t, facts)
+
+	// 453.6 g chicken + 185 g rice + 27 g oil + 5 g salt, split two ways.
+	assert.Equal(t, 729, facts.Calories)
+	assert.Equal(t, 58, facts.ProteinGrams)
+	assert.Equal(t, 74, facts.CarbsGrams)
+	assert.Equal(t, 20, facts.FatGrams)
+	assert.Equal(t, 1, facts.FiberGrams)
+	assert.Equal(t, 1076, facts.SodiumMilligrams)
+	assert.Equal(t, 4, facts.Counted)
+	assert.Equal(t, []string{"Gochujang", "Black pepper"}, facts.Uncounted)
+}
+
+func TestEstimateNeedsServingsAndSomethingCounted(t *testing.T) {
+	ingredients := []Ingredient{{Name: "Rice", Amount: 1, Unit: "cup"}}
+	assert.Nil(t, Estimate(ingredients, 0))
+	assert.Nil(t, Estimate([]Ingredient{{Name: "Gochujang", Amount: 1, Unit: "cup"}}, 2))
+	assert.NotNil(t, Estimate(ingredients, 2))
+}
@@ -0,0 +1,293 @@
+package recipes
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"careme/internal/ai"
+	"careme/internal/auth"
+	"careme/internal/cache"
+	"careme/internal/httpx"
+	"careme/internal/routing"
+	utypes "careme/internal/users/types"
+)
+
+// RegisterAPI serves the JSON versions of the shopping list and recipe pages for
+// the Android app and scripts. Hashes are the same ones the HTML pages use.
+func (s *server) RegisterAPI(mux routing.Registrar) {
+	mux.HandleFunc("POST /api/v1/shoppinglists", s.handleAPIGenerate)
+	mux.HandleFunc("GET /api/v1/shoppinglists/{hash}", s.handleAPIShoppingList)
+	mux.HandleFunc("GET /api/v1/shoppinglists/{hash}/status", s.handleAPIStatus)
+	mux.HandleFunc("GET /api/v1/recipes/search", s.handleAPISearch)
+	mux.HandleFunc("GET /api/v1/recipes/{hash}", s.handleAPIRecipe)
+	mux.HandleFunc("GET /api/v1/recipes/{hash}/thread", s.handleAPIThread)
+	mux.HandleFunc("GET /api/v1/recipes/{hash}/critique", s.handleAPICritique)
+	mux.HandleFunc("POST /api/v1/recipes/{hash}/save", s.handleAPISaveRecipe)
+	mux.HandleFunc("POST /api/v1/recipes/{hash}/dismiss", s.handleAPIDismissRecipe)
+}
+
+type apiRecipe struct {
+	Hash string `json:"hash"`
+	ai.Recipe
+}
+
+type apiShoppingList struct {
+	Hash      string        `json:"hash"`
+	Recipes   []apiRecipe   `json:"recipes"`
+	Plan      *ai.MenuPlan  `json:"plan,omitempty"`
+	Saved     []string      `json:"saved,omitempty"`
+	Dismissed []string      `json:"dismissed,omitempty"`
+	Status    *apiGenStatus `json:"status,omitempty"`
+}
+
+type apiGenStatus struct {
+	Hash  string `json:"hash"`
+	Ready bool   `json:"ready"`
+	// Message is the latest progress line shown on the spinner page.
+	Message string `json:"message,omitempty"`
+}
+
+type apiSelectionRequest struct {
+	ShoppingList string `json:"shopping_list"`
+}
+
+func newAPIRecipe(recipe ai.Recipe) apiRecipe {
+	return apiRecipe{Hash: recipe.ComputeHash(), Recipe: recipe}
+}
+
+func writeAPIJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	if err := httpx.WriteJSON(w, status, v); err != nil {
+		slog.ErrorContext(r.Context(), "failed to encode api response", "path", r.URL.Path, "error", err)
+	}
+}
+
+// apiUser is storage.FromRequest for API handlers. There are no guest allowances
+// here; the caller needs a session or an API token.
+func (s *server) apiUser(w http.ResponseWriter, r *http.Request) (*utypes.User, bool) {
+	currentUser, err := s.storage.FromRequest(r.Context(), r, s.clerk)
+	if err != nil {
+		if errors.Is(err, auth.ErrNoSession) {
+			httpx.JSONError(w, "must be logged in", http.StatusUnauthorized)
+			return nil, false
+		}
+		slog.ErrorContext(r.Context(), "failed to load user for api", "error", err)
+		httpx.JSONError(w, "unable to load account", http.StatusInternalServerError)
+		return nil, false
+	}
+	return currentUser, true
+}
+
+func (s *server) handleAPIGenerate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	currentUser, ok := s.apiUser(w, r)
+	if !ok {
+		return
+	}
+	var req generationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.JSONError(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	p, err := req.params(ctx, s.locServer)
+	if err != nil {
+		httpx.JSONError(w, "invalid parameters: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	status := http.StatusAccepted
+	if err := s.startGeneration(ctx, currentUser, p); err != nil {
+		if !errors.Is(err, ErrAlreadyExists) {
+			slog.ErrorContext(ctx, "failed to save params", "error", err)
+			httpx.JSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		status = http.StatusOK
+	}
+	hash := p.Hash()
+	w.Header().Set("Location", "/api/v1/shoppinglists/"+hash)
+	writeAPIJSON(w, r, status, s.generationStatus(r, hash))
+}
+
+// generationStatus is best effort; a missing status just means nothing has been reported yet.
+func (s *server) generationStatus(r *http.Request, hash string) apiGenStatus {
+	ctx := r.Context()
+	status := apiGenStatus{Hash: hash}
+	if _, err := s.FromCache(ctx, hash); err == nil {
+		status.Ready = true
+		return status
+	}
+	message, err := s.statusReader.GenerationStatusFromCache(ctx, hash)
+	if err != nil && !errors.Is(err, cache.ErrNotFound) {
+		slog.ErrorContext(ctx, "failed to load generation status", "hash", hash, "error", err)
+	}
+	status.Message = strings.TrimSpace(message)
+	return status
+}
+
+func (s *server) handleAPIStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	hash := r.PathValue("hash")
+	if _, err := s.ParamsFromCache(r.Context(), hash); err != nil {
+		if errors.Is(err, cache.ErrNotFound) {
+			httpx.JSONError(w, "shoppinglist not found or expired", http.StatusNotFound)
+			return
+		}
+		slog.ErrorContext(r.Context(), "failed to load params for api status", "hash", hash, "error", err)
+		httpx.JSONError(w, "failed to load shopping list", http.StatusInternalServerError)
+		return
+	}
+	writeAPIJSON(w, r, http.StatusOK, s.generationStatus(r, hash))
+}
+
+// handleAPIShoppingList answers 202 with the generation status while the list is
+// still being generated.
+func (s *server) handleAPIShoppingList(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	w.Header().Set("Cache-Control", "no-store")
+	hash := r.PathValue("hash")
+	p, err := s.ParamsFromCache(ctx, hash)
+	if err != nil {
+		if errors.Is(err, cache.ErrNotFound) {
+			httpx.JSONError(w, "shoppinglist not found or expired", http.StatusNotFound)
+			return
+		}
+		slog.ErrorContext(ctx, "failed to load params for api shopping list", "hash", hash, "error", err)
+		httpx.JSONError(w, "failed to load shopping list", http.StatusInternalServerError)
+		return
+	}
+	slist, err := s.FromCache(ctx, hash)
+	if err != nil {
+		if errors.Is(err, cache.ErrNotFound) {
+			status := s.generationStatus(r, hash)
+			writeAPIJSON(w, r, http.StatusAccepted, apiShoppingList{Hash: hash, Recipes: []apiRecipe{}, Status: &status})
+			return
+		}
+		slog.ErrorContext(ctx, "failed to load recipe list for api", "hash", hash, "error", err)
+		httpx.JSONError(w, "failed to load shopping list", http.StatusInternalServerError)
+		return
+	}
+
+	selection := selectionFromSaved(p.Saved)
+	if userID, err := s.clerk.GetUserIDFromRequest(r); err == nil {
+		userSelection, err := s.loadRecipeSelection(ctx, userID, hash)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to load recipe selection for api", "hash", hash, "error", err)
+			httpx.JSONError(w, "failed to load recipe selection", http.StatusInternalServerError)
+			return
+		}
+		selection = selection.override(userSelection)
+	}
+
+	list := apiShoppingList{
+		Hash:      hash,
+		Recipes:   make([]apiRecipe, 0, len(slist.Recipes)),
+		Plan:      slist.Plan,
+		Saved:     selection.SavedHashes,
+		Dismissed: selection.DismissedHashes,
+	}
+	for _, recipe := range slist.Recipes {
+		list.Recipes = append(list.Recipes, newAPIRecipe(recipe))
+	}
+	writeAPIJSON(w, r, http.StatusOK, list)
+}
+
+func (s *server) handleAPIRecipe(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	hash := r.PathValue("hash")
+	recipe, err := s.SingleFromCache(ctx, hash)
+	if err != nil {
+		if errors.Is(err, cache.ErrNotFound) {
+			httpx.JSONError(w, "recipe not found", http.StatusNotFound)
+			return
+		}
+		slog.ErrorContext(ctx, "failed to load recipe for api", "hash", hash, "error", err)
+		httpx.JSONError(w, "failed to load recipe", http.StatusInternalServerError)
+		return
+	}
+	if recipe.Nutrition == nil {
+		// recipes from before nutrition was computed at generation
+		recipe.ComputeNutrition(recipe.Servings)
+	}
+	writeAPIJSON(w, r, http.StatusOK, newAPIRecipe(*recipe))
+}
+
+func (s *server) handleAPIThread(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	hash := r.PathValue("hash")
+	thread, err := s.ThreadFromCache(ctx, hash)
+	if err != nil && !errors.Is(err, cache.ErrNotFound) {
+		slog.ErrorContext(ctx, "failed to load thread for api", "hash", hash, "error", err)
+		httpx.JSONError(w, "failed to load thread", http.StatusInternalServerError)
+		return
+	}
+	if thread == nil {
+		thread = []RecipeThreadEntry{}
+	}
+	writeAPIJSON(w, r, http.StatusOK, thread)
+}
+
+func (s *server) handleAPICritique(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	hash := r.PathValue("hash")
+	result, err := s.critiques.Load(ctx, hash)
+	if err != nil {
+		if errors.Is(err, cache.ErrNotFound) {
+			httpx.JSONError(w, "critique not found", http.StatusNotFound)
+			return
+		}
+		slog.ErrorContext(ctx, "failed to load critique for api", "hash", hash, "error", err)
+		httpx.JSONError(w, "failed to load critique", http.StatusInternalServerError)
+		return
+	}
+	writeAPIJSON(w, r, http.StatusOK, result)
+}
+
+func (s *server) handleAPISaveRecipe(w http.ResponseWriter, r *http.Request) {
+	s.handleAPISelection(w, r, true)
+}
+
+func (s *server) handleAPIDismissRecipe(w http.ResponseWriter, r *http.Request) {
+	s.handleAPISelection(w, r, false)
+}
+
+// handleAPISelection saves or dismisses a recipe from a shopping list and answers
+// with the recipe, the same as the htmx card does.
+func (s *server) handleAPISelection(w http.ResponseWriter, r *http.Request, save bool) {
+	ctx := r.Context()
+	recipeHash := strings.TrimSpace(r.PathValue("hash"))
+	currentUser, ok := s.apiUser(w, r)
+	if !ok {
+		return
+	}
+	var req apiSelectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.JSONError(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	shoppingListHash := strings.TrimSpace(req.ShoppingList)
+	if shoppingListHash == "" {
+		httpx.JSONError(w, "shopping_list is required", http.StatusBadRequest)
+		return
+	}
+
+	var recipe *ai.Recipe
+	var err error
+	if save {
+		recipe, err = s.saveRecipeForUser(ctx, currentUser, shoppingListHash, recipeHash)
+	} else if err = s.dismissRecipeForUser(ctx, currentUser, shoppingListHash, recipeHash); err == nil {
+		recipe, err = s.SingleFromCache(ctx, recipeHash)
+	}
+	if err != nil {
+		if errors.Is(err, cache.ErrNotFound) {
+			httpx.JSONError(w, "recipe not found", http.StatusNotFound)
+			return
+		}
+		slog.ErrorContext(ctx, "failed to update recipe selection", "shopping_list", shoppingListHash, "recipe_hash", recipeHash, "save", save, "error", err)
+		httpx.JSONError(w, "failed to update recipe selection", http.StatusInternalServerError)
+		return
+	}
+	writeAPIJSON(w, r, http.StatusOK, newAPIRecipe(*recipe))
+}
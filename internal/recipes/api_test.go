@@ -0,0 +1,157 @@
+package recipes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"careme/internal/ai"
+	"careme/internal/cache"
+	"careme/internal/locations"
+	"careme/internal/users"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveAPI(t *testing.T, s *server, method, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	mux := http.NewServeMux()
+	s.RegisterAPI(mux)
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestAPIGenerate_PollsUntilShoppingListIsReady(t *testing.T) {
+	cacheStore := cache.NewFileCache(filepath.Join(t.TempDir(), "cache"))
+	location := &locations.Location{ID: "70001001", Name: "Test Store", ZipCode: "94105"}
+	s := newTestServer(t, withTestCache(cacheStore), withTestLocationServer(staticLocationLookup{location: location}))
+
+	rr := serveAPI(t, s, http.MethodPost, "/api/v1/shoppinglists", `{"location":"70001001","date":"2026-03-06"}`)
+	require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+	var started apiGenStatus
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &started))
+	require.NotEmpty(t, started.Hash)
+	assert.Equal(t, "/api/v1/shoppinglists/"+started.Hash, rr.Header().Get("Location"))
+	s.Wait()
+
+	rr = serveAPI(t, s, http.MethodGet, "/api/v1/shoppinglists/"+started.Hash+"/status", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var status apiGenStatus
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &status))
+	assert.True(t, status.Ready)
+
+	rr = serveAPI(t, s, http.MethodGet, "/api/v1/shoppinglists/"+started.Hash, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var list apiShoppingList
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &list))
+	require.NotEmpty(t, list.Recipes)
+	assert.Equal(t, list.Recipes[0].ComputeHash(), list.Recipes[0].Hash)
+
+	// asking again for the same list doesn't start another generation.
+	rr = serveAPI(t, s, http.MethodPost, "/api/v1/shoppinglists", `{"location":"70001001","date":"2026-03-06"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestAPIShoppingList_PendingAndMissing(t *testing.T) {
+	s := newTestServer(t)
+	p := DefaultParams(&locations.Location{ID: "70004001", Name: "Store"}, time.Now())
+	require.NoError(t, s.SaveParams(t.Context(), p))
+	require.NoError(t, s.statusWriter.SaveGenerationStatus(t.Context(), p.Hash(), "Considering 10 out of 20 ingredients\n"))
+
+	rr := serveAPI(t, s, http.MethodGet, "/api/v1/shoppinglists/"+p.Hash(), "")
+	require.Equal(t, http.StatusAccepted, rr.Code)
+	assert.JSONEq(t, `{"hash":"`+p.Hash()+`","recipes":[],"status":{"hash":"`+p.Hash()+`","ready":false,"message":"Considering 10 out of 20 ingredients"}}`, rr.Body.String())
+
+	rr = serveAPI(t, s, http.MethodGet, "/api/v1/shoppinglists/nope/status", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.JSONEq(t, `{"error":"shoppinglist not found or expired"}`, rr.Body.String())
+}
+
+func TestAPIGenerate_RequiresSession(t *testing.T) {
+	s := newTestServer(t, withTestClerk(noSessionAuth{}))
+
+	rr := serveAPI(t, s, http.MethodPost, "/api/v1/shoppinglists", `{"location":"70001001"}`)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.JSONEq(t, `{"error":"must be logged in"}`, rr.Body.String())
+}
+
+func TestAPIRecipe_ThreadAndCritique(t *testing.T) {
+	s := newTestServer(t)
+	recipe := ai.Recipe{Title: "Rice Bowl", Servings: 2, Ingredients: []ai.Ingredient{{Name: "Rice", Quantity: "1 cup"}}}
+	require.NoError(t, s.SaveRecipe(t.Context(), recipe))
+	hash := recipe.ComputeHash()
+
+	rr := serveAPI(t, s, http.MethodGet, "/api/v1/recipes/"+hash, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var got apiRecipe
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, hash, got.Hash)
+	assert.Equal(t, "Rice Bowl", got.Title)
+	assert.NotNil(t, got.Nutrition, "older recipes get nutrition computed on the way out")
+
+	rr = serveAPI(t, s, http.MethodGet, "/api/v1/recipes/"+hash+"/thread", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `[]`, rr.Body.String())
+
+	require.NoError(t, s.SaveThread(t.Context(), hash, []RecipeThreadEntry{{Question: "Brown rice?", Answer: "Yes, cook it longer."}}))
+	rr = serveAPI(t, s, http.MethodGet, "/api/v1/recipes/"+hash+"/thread", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"question":"Brown rice?"`)
+
+	rr = serveAPI(t, s, http.MethodGet, "/api/v1/recipes/"+hash+"/critique", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = serveAPI(t, s, http.MethodGet, "/api/v1/recipes/missing", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.JSONEq(t, `{"error":"recipe not found"}`, rr.Body.String())
+}
+
+func TestAPISaveAndDismissRecipe(t *testing.T) {
+	cacheStore := cache.NewFileCache(filepath.Join(t.TempDir(), "cache"))
+	storage := users.NewStorage(cacheStore)
+	s := newTestServer(t, withTestCache(cacheStore), withTestStorage(storage))
+	t.Cleanup(s.Wait)
+
+	recipe := ai.Recipe{Title: "Save Me", ResponseID: "resp-123"}
+	p := DefaultParams(&locations.Location{ID: "70004001", Name: "Store"}, time.Now())
+	originHash := p.Hash()
+	require.NoError(t, s.SaveParams(t.Context(), p))
+	saveRecipesForOrigin(t, s, originHash, recipe)
+	require.NoError(t, s.SaveShoppingList(t.Context(), &ai.ShoppingList{Recipes: []ai.Recipe{recipe}}, originHash))
+	recipeHash := recipe.ComputeHash()
+	body := `{"shopping_list":"` + originHash + `"}`
+
+	rr := serveAPI(t, s, http.MethodPost, "/api/v1/recipes/"+recipeHash+"/save", body)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	user, err := storage.GetByID("mock-clerk-user-id")
+	require.NoError(t, err)
+	require.Len(t, user.LastRecipes, 1)
+	assert.Equal(t, recipeHash, user.LastRecipes[0].Hash)
+
+	rr = serveAPI(t, s, http.MethodGet, "/api/v1/shoppinglists/"+originHash, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var list apiShoppingList
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &list))
+	assert.Equal(t, []string{recipeHash}, list.Saved)
+
+	rr = serveAPI(t, s, http.MethodPost, "/api/v1/recipes/"+recipeHash+"/dismiss", body)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	user, err = storage.GetByID("mock-clerk-user-id")
+	require.NoError(t, err)
+	assert.Empty(t, user.LastRecipes)
+	selection, err := s.loadRecipeSelection(t.Context(), "mock-clerk-user-id", originHash)
+	require.NoError(t, err)
+	assert.Equal(t, []string{recipeHash}, selection.DismissedHashes)
+
+	rr = serveAPI(t, s, http.MethodPost, "/api/v1/recipes/"+recipeHash+"/save", `{}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
@@ -0,0 +1,183 @@
+package recipes
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"careme/internal/ai"
+	utypes "careme/internal/users/types"
+)
+
+// how many recipes a normal (not week plan) list has.
+const defaultRecipeCount = 3
+
+func (p *generatorParams) recipeCount() int {
+	if p.isWeekPlan() {
+		return len(p.Days)
+	}
+	return defaultRecipeCount
+}
+
+func budgetSignature(b utypes.Budget) string {
+	return fmt.Sprintf("budget%g|%g", b.PerMeal, b.PerWeek)
+}
+
+// mealBudget is the most one recipe may cost, or zero for no limit. A weekly
+// budget is split evenly across meals and the tighter of the two limits wins.
+func mealBudget(b utypes.Budget, meals int) float64 {
+	limit := b.PerMeal
+	if b.PerWeek > 0 && meals > 0 {
+		perMeal := b.PerWeek / float64(meals)
+		if limit == 0 || perMeal < limit {
+			limit = perMeal
+		}
+	}
+	return limit
+}
+
+func budgetInstructions(limit float64) []string {
+	if limit <= 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("Each recipe must cost under $%.2f at the listed store prices for the amounts it uses.", limit)}
+}
+
+// units we can compare between a recipe quantity and a package size, scaled to grams,
+// milliliters or a count of items.
+var costUnits = map[string]struct {
+	dimension string
+	scale     float64
+}{
+	"g": {"weight", 1}, "gram": {"weight", 1}, "kg": {"weight", 1000}, "kilogram": {"weight", 1000},
+	"oz": {"weight", 28.35}, "ounce": {"weight", 28.35}, "lb": {"weight", 453.6}, "pound": {"weight", 453.6},
+	"ml": {"volume", 1}, "milliliter": {"volume", 1}, "l": {"volume", 1000}, "liter": {"volume", 1000},
+	"cup": {"volume", 236.6}, "tbsp": {"volume", 14.79}, "tablespoon": {"volume", 14.79},
+	"tsp": {"volume", 4.93}, "teaspoon": {"volume", 4.93}, "fl oz": {"volume", 29.57},
+	"pint": {"volume", 473.2}, "pt": {"volume", 473.2}, "quart": {"volume", 946.4}, "qt": {"volume", 946.4},
+	"gallon": {"volume", 3785}, "gal": {"volume", 3785},
+	"": {"count", 1}, "ct": {"count", 1}, "count": {"count", 1}, "each": {"count", 1}, "ea": {"count", 1},
+	"large": {"count", 1}, "medium": {"count", 1}, "small": {"count", 1}, "whole": {"count", 1},
+}
+
+// measure converts a free-form quantity like "1 1/2 lbs" or "750mL" to its dimension
+// and base amount.
+func measure(quantity string) (string, float64, bool) {
+	quantity = strings.ToLower(strings.TrimSpace(quantity))
+	// weighed produce is priced "per lb"
+	if rest, ok := strings.CutPrefix(quantity, "per "); ok {
+		quantity = "1 " + rest
+	}
+	amount, unit, ok := ai.ParseQuantity(quantity)
+	if !ok || amount <= 0 {
+		return "", 0, false
+	}
+	unit = strings.TrimSuffix(unit, ".")
+	if !strings.HasPrefix(unit, "fl") {
+		unit, _, _ = strings.Cut(unit, " ")
+	}
+	for _, candidate := range []string{unit, strings.TrimSuffix(unit, "s"), strings.TrimSuffix(unit, "es")} {
+		if u, ok := costUnits[candidate]; ok {
+			return u.dimension, amount * u.scale, true
+		}
+	}
+	return "", 0, false
+}
+
+// ingredientCost is the share of the package price the recipe uses. When the
+// quantity and package size can't be compared we charge the whole package since
+// that's what leaves the store.
+func ingredientCost(ing ai.Ingredient, input ai.InputIngredient) (float64, bool) {
+	price := input.PriceSale
+	if price == nil {
+		price = input.PriceRegular
+	}
+	if price == nil {
+		return 0, false
+	}
+	needDim, need, ok := measure(ing.Quantity)
+	sizeDim, size, sizeOK := measure(input.Size)
+	if !ok || !sizeOK || needDim != sizeDim {
+		return float64(*price), true
+	}
+	return float64(*price) * need / size, true
+}
+
+type ingredientCostLine struct {
+	name string
+	cost float64
+}
+
+func recipeCostLines(recipe ai.Recipe, ingMap map[string]ai.InputIngredient) []ingredientCostLine {
+	var lines []ingredientCostLine
+	for _, ing := range recipe.Ingredients {
+		input, ok := ingMap[strings.TrimSpace(ing.ProductID)]
+		if !ok {
+			continue
+		}
+		if cost, ok := ingredientCost(ing, input); ok {
+			lines = append(lines, ingredientCostLine{name: ing.Name, cost: cost})
+		}
+	}
+	return lines
+}
+
+// recipeCost only counts ingredients picked from the store's list. Pantry staples
+// without a product aren't priced.
+func recipeCost(recipe ai.Recipe, ingMap map[string]ai.InputIngredient) float64 {
+	total := 0.0
+	for _, line := range recipeCostLines(recipe, ingMap) {
+		total += line.cost
+	}
+	return total
+}
+
+// enrichRecipe fills store metadata on a freshly generated recipe and prices it.
+func enrichRecipe(recipe *ai.Recipe, ingMap map[string]ai.InputIngredient) {
+	enrichIngredientsMetadata(recipe.Ingredients, ingMap)
+	recipe.StoreCost = recipeCost(*recipe, ingMap)
+}
+
+func budgetRetryInstructions(recipe ai.Recipe, ingMap map[string]ai.InputIngredient, limit float64) []string {
+	lines := recipeCostLines(recipe, ingMap)
+	slices.SortFunc(lines, func(a, b ingredientCostLine) int { return cmp.Compare(b.cost, a.cost) })
+	priciest := make([]string, 0, 3)
+	for _, line := range lines[:min(3, len(lines))] {
+		priciest = append(priciest, fmt.Sprintf("%s ($%.2f)", line.name, line.cost))
+	}
+	instructions := []string{fmt.Sprintf("This recipe costs $%.2f at store prices, over the $%.2f budget per meal.", recipe.StoreCost, limit)}
+	if len(priciest) > 0 {
+		instructions = append(instructions, "The most expensive ingredients are "+strings.Join(priciest, ", ")+".")
+	}
+	return append(instructions, "Rewrite the recipe to fit the budget with cheaper cuts, sale items, or smaller amounts of the priciest ingredients, keeping the dish as close as possible otherwise.")
+}
+
+// retryOverBudget asks for a cheaper version of a recipe that costs more than limit,
+// once, the same way a low critique score gets one retry. The regenerated recipe is
+// not saved; callers save what comes back, so it keeps the parent of the recipe it
+// replaces.
+func (g *generatorService) retryOverBudget(ctx context.Context, hash string, recipe *ai.Recipe, ingMap map[string]ai.InputIngredient, limit float64) (*ai.Recipe, error) {
+	if limit <= 0 || recipe.StoreCost <= limit {
+		return recipe, nil
+	}
+	slog.InfoContext(ctx, "recipe over budget", "hash", hash, "title", recipe.Title, "cost", recipe.StoreCost, "limit", limit)
+	if strings.TrimSpace(recipe.ResponseID) == "" {
+		return nil, fmt.Errorf("recipe %q is missing response ID for budget retry", recipe.Title)
+	}
+	g.writeStatus(ctx, hash, "Trimming the cost of "+recipe.Title+"\n")
+	retry, err := g.aiClient.Regenerate(ctx, budgetRetryInstructions(*recipe, ingMap, limit), recipe.ResponseRef())
+	if err != nil {
+		return nil, fmt.Errorf("failed to regenerate recipe %q for budget: %w", recipe.Title, err)
+	}
+	enrichRecipe(retry, ingMap)
+	retry.OriginHash = hash
+	retry.ParentHash = recipe.ParentHash
+	retry.Cuisine = recipe.Cuisine
+	if retry.StoreCost > limit {
+		slog.InfoContext(ctx, "recipe still over budget after retry", "hash", hash, "title", retry.Title, "cost", retry.StoreCost, "limit", limit)
+	}
+	return retry, nil
+}
@@ -0,0 +1,120 @@
+package recipes
+
+import (
+	"testing"
+	"time"
+
+	"careme/internal/ai"
+	"careme/internal/locations"
+	utypes "careme/internal/users/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngredientCost(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		quantity string
+		size     string
+		price    float32
+		want     float64
+	}{
+		{"share of package", "1 lb", "2 lb", 10, 5},
+		{"mixed weights", "8 oz", "1 lb", 9, 4.5},
+		{"volume", "2 tbsp", "750mL", 15, 0.59},
+		{"weighed produce", "2 lbs", "per lb", 4, 8},
+		{"counts", "2 large", "12 ct", 6, 1},
+		{"incomparable charges the package", "3 cloves", "1 bunch", 2.5, 2.5},
+		{"unparsed quantity charges the package", "to taste", "1 lb", 3, 3},
+	} {
+		got, ok := ingredientCost(ai.Ingredient{Quantity: tc.quantity}, ai.InputIngredient{Size: tc.size, PriceRegular: new(tc.price)})
+		require.True(t, ok, tc.name)
+		assert.InDelta(t, tc.want, got, 0.01, tc.name)
+	}
+
+	sale, ok := ingredientCost(ai.Ingredient{Quantity: "1 lb"}, ai.InputIngredient{Size: "1 lb", PriceRegular: new(float32(8)), PriceSale: new(float32(6))})
+	require.True(t, ok)
+	assert.InDelta(t, 6, sale, 0.001, "sale price wins")
+
+	_, ok = ingredientCost(ai.Ingredient{Quantity: "1 lb"}, ai.InputIngredient{Size: "1 lb"})
+	assert.False(t, ok)
+}
+
+func TestMealBudget(t *testing.T) {
+	assert.Zero(t, mealBudget(utypes.Budget{}, 3))
+	assert.Equal(t, 20.0, mealBudget(utypes.Budget{PerMeal: 20}, 3))
+	assert.Equal(t, 20.0, mealBudget(utypes.Budget{PerWeek: 100}, 5))
+	assert.Equal(t, 20.0, mealBudget(utypes.Budget{PerMeal: 25, PerWeek: 60}, 3), "tighter limit wins")
+	assert.Equal(t, 15.0, mealBudget(utypes.Budget{PerMeal: 15, PerWeek: 60}, 3), "tighter limit wins")
+}
+
+func TestHash_BudgetOnlyHashedWhenSet(t *testing.T) {
+	p := DefaultParams(&locations.Location{ID: "70004001"}, time.Now())
+	before := p.Hash()
+	p.Budget = utypes.Budget{}
+	assert.Equal(t, before, p.Hash())
+	p.Budget = utypes.Budget{PerMeal: 20}
+	assert.NotEqual(t, before, p.Hash())
+}
+
+func budgetStaples() fixedStaplesService {
+	grade := &ai.IngredientGrade{Score: 8}
+	return fixedStaplesService{ingredients: []ai.InputIngredient{
+		{ProductID: "steak", Description: "Ribeye Steak", Size: "1 lb", PriceRegular: new(float32(15)), Grade: grade},
+		{ProductID: "thighs", Description: "Chicken Thighs", Size: "1 lb", PriceRegular: new(float32(3)), Grade: grade},
+	}}
+}
+
+func TestGenerateRecipes_RegeneratesRecipesOverBudget(t *testing.T) {
+	initial := ai.Recipe{
+		Title:       "Ribeye Dinner",
+		ResponseID:  "resp-initial",
+		Ingredients: []ai.Ingredient{{ProductID: "steak", Name: "Ribeye steak", Quantity: "2 lb"}, {Name: "Salt", Quantity: "1 tsp"}},
+	}
+	cheaper := ai.Recipe{
+		Title:       "Chicken Thigh Dinner",
+		ResponseID:  "resp-cheaper",
+		Ingredients: []ai.Ingredient{{ProductID: "thighs", Name: "Chicken thighs", Quantity: "2 lb"}},
+	}
+	aiStub := &sequenceAIClient{
+		generateResponses:   []*ai.ShoppingList{{Recipes: []ai.Recipe{initial}}},
+		regenerateResponses: []*ai.Recipe{&cheaper},
+	}
+	params := DefaultParams(&locations.Location{ID: "70004001", Name: "Store"}, time.Now())
+	params.Budget = utypes.Budget{PerMeal: 20}
+	saver := &captureRecipeSaver{}
+	g := newTestGenerator(t, aiStub, nil, budgetStaples(), noopstatuswriter{}, saver)
+
+	got, err := g.GenerateRecipes(t.Context(), params)
+	require.NoError(t, err)
+	require.Len(t, got.Recipes, 1)
+	assert.Equal(t, "Chicken Thigh Dinner", got.Recipes[0].Title)
+	assert.InDelta(t, 6, got.Recipes[0].StoreCost, 0.001)
+	assert.Empty(t, got.Recipes[0].ParentHash, "the over-budget draft was never saved, so it can't be the parent")
+	assert.Equal(t, []string{"resp-initial"}, aiStub.regenerateResponseIDs)
+	require.Len(t, aiStub.regenerateInstructions, 1)
+	assert.Contains(t, aiStub.regenerateInstructions[0], "This recipe costs $30.00 at store prices, over the $20.00 budget per meal.")
+	assert.Contains(t, aiStub.regenerateInstructions[0], "The most expensive ingredients are Ribeye steak ($30.00).")
+	require.Len(t, aiStub.menuPlanInstructions, 1)
+	assert.Contains(t, aiStub.menuPlanInstructions[0], "Each recipe must cost under $20.00 at the listed store prices for the amounts it uses.")
+	assert.Equal(t, []string{"Chicken Thigh Dinner"}, saver.titles(), "over budget recipes should never be saved")
+}
+
+func TestGenerateRecipes_KeepsRecipesWithinBudget(t *testing.T) {
+	recipe := ai.Recipe{
+		Title:       "Chicken Thigh Dinner",
+		ResponseID:  "resp-1",
+		Ingredients: []ai.Ingredient{{ProductID: "thighs", Name: "Chicken thighs", Quantity: "1 1/2 lb"}},
+	}
+	aiStub := &sequenceAIClient{generateResponses: []*ai.ShoppingList{{Recipes: []ai.Recipe{recipe}}}}
+	params := DefaultParams(&locations.Location{ID: "70004001", Name: "Store"}, time.Now())
+	params.Budget = utypes.Budget{PerWeek: 30}
+	g := newTestGenerator(t, aiStub, nil, budgetStaples(), noopstatuswriter{}, nil)
+
+	got, err := g.GenerateRecipes(t.Context(), params)
+	require.NoError(t, err)
+	require.Len(t, got.Recipes, 1)
+	assert.InDelta(t, 4.5, got.Recipes[0].StoreCost, 0.001)
+	assert.Zero(t, aiStub.regenerateCalls)
+}
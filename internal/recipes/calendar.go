@@ -0,0 +1,315 @@
+package recipes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"careme/internal/ai"
+	"careme/internal/auth"
+	"careme/internal/cache"
+	"careme/internal/ical"
+	"careme/internal/locations"
+	"careme/internal/recipes/export"
+	"careme/internal/users"
+	utypes "careme/internal/users/types"
+
+	"github.com/samber/lo"
+)
+
+const (
+	// planned dinners are on the table at 6:30 in the store's time zone; the
+	// event starts early enough to cook them.
+	dinnerHour   = 18
+	dinnerMinute = 30
+	// defaultCookTime is how long a dinner's event runs when its cook time can't be read.
+	defaultCookTime = time.Hour
+	// calendarWeeks is how many weekly shopping lists back the feed goes.
+	calendarWeeks = 2
+	// minPrepLead is how far ahead a step has to start before it gets its own reminder.
+	minPrepLead = time.Hour
+	// overnightLead puts an overnight step's reminder the evening before.
+	overnightLead = 20 * time.Hour
+)
+
+// handleCalendar serves a user's shopping trips and planned dinners as an
+// iCalendar feed. Calendar apps poll it without a session, so it's
+// authenticated by the calendar token in the link on the user page, which the
+// user can reset to revoke.
+func (s *server) handleCalendar(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	token := strings.TrimSpace(r.URL.Query().Get("token"))
+	if token == "" {
+		http.Error(w, "invalid calendar link", http.StatusBadRequest)
+		return
+	}
+	userID, err := s.tokens.ScopedUserID(ctx, token, auth.ScopeCalendar)
+	if err != nil {
+		if errors.Is(err, auth.ErrNoSession) {
+			http.Error(w, "invalid calendar link", http.StatusBadRequest)
+			return
+		}
+		slog.ErrorContext(ctx, "failed to look up calendar token", "error", err)
+		http.Error(w, "unable to load calendar", http.StatusInternalServerError)
+		return
+	}
+	currentUser, err := s.storage.GetByID(userID)
+	if err != nil {
+		if errors.Is(err, users.ErrNotFound) {
+			http.Error(w, "invalid calendar link", http.StatusBadRequest)
+			return
+		}
+		slog.ErrorContext(ctx, "failed to load user for calendar", "user_id", userID, "error", err)
+		http.Error(w, "unable to load calendar", http.StatusInternalServerError)
+		return
+	}
+
+	cal, err := s.userCalendar(ctx, currentUser)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to build calendar", "user_id", userID, "error", err)
+		http.Error(w, "unable to load calendar", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", ical.ContentType)
+	w.Header().Set("Content-Disposition", `inline; filename="careme.ics"`)
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	if err := cal.Write(w, nowFn()); err != nil {
+		slog.ErrorContext(ctx, "failed to write calendar", "user_id", userID, "error", err)
+	}
+}
+
+// userCalendar has the next shopping trip, and the last few weeks' trips with
+// the dinners planned from the lists the user saved recipes from. Without a
+// favorite store and shopping day it's empty but still a calendar, so a
+// subscription doesn't break.
+func (s *server) userCalendar(ctx context.Context, currentUser *utypes.User) (ical.Calendar, error) {
+	cal := ical.Calendar{Name: "Careme dinners", RefreshInterval: 6 * time.Hour}
+	if strings.TrimSpace(currentUser.FavoriteStore) == "" || strings.TrimSpace(currentUser.ShoppingDay) == "" {
+		return cal, nil
+	}
+	shoppingDay, err := utypes.ParseWeekday(currentUser.ShoppingDay)
+	if err != nil {
+		return cal, err
+	}
+	loc, err := s.locServer.GetLocationByID(ctx, currentUser.FavoriteStore)
+	if err != nil {
+		return cal, fmt.Errorf("load favorite store: %w", err)
+	}
+	today, err := StoreToDate(ctx, nowFn(), loc)
+	if err != nil {
+		return cal, err
+	}
+	lastTrip := today.AddDate(0, 0, -((int(today.Weekday()) - int(shoppingDay) + 7) % 7))
+	firstTrip := lastTrip.AddDate(0, 0, -7*(calendarWeeks-1))
+	origin := s.cfg.ResolvedPublicOrigin()
+
+	cal.Events = append(cal.Events, shoppingEvent(currentUser.ID, loc, lastTrip.AddDate(0, 0, 7), "", origin))
+	// one trip a day, from the newest list the user saved from that day.
+	trips := map[string]bool{}
+	for _, list := range s.savedLists(ctx, currentUser, firstTrip) {
+		// cached dates come back with a fixed offset; dinner time should follow the store's daylight saving.
+		list.params.Date = list.params.Date.In(today.Location())
+		if day := list.params.Date.Format(time.DateOnly); !trips[day] {
+			trips[day] = true
+			cal.Events = append(cal.Events, shoppingEvent(currentUser.ID, list.params.Location, list.params.Date, list.hash, origin))
+		}
+		events, err := s.listDinnerEvents(ctx, currentUser, list.params, list.hash, origin)
+		if err != nil {
+			return cal, err
+		}
+		cal.Events = append(cal.Events, events...)
+	}
+	for week := range calendarWeeks {
+		trip := lastTrip.AddDate(0, 0, -7*week)
+		if !trips[trip.Format(time.DateOnly)] {
+			cal.Events = append(cal.Events, shoppingEvent(currentUser.ID, loc, trip, "", origin))
+		}
+	}
+	return cal, nil
+}
+
+// savedList is a shopping list the user saved recipes from.
+type savedList struct {
+	hash   string
+	params *generatorParams
+}
+
+// savedLists are the lists the user saved recipes from for trips since
+// firstTrip, newest saved first. The list hashes come from the saved recipes,
+// so they match however the list was planned.
+func (s *server) savedLists(ctx context.Context, currentUser *utypes.User, firstTrip time.Time) []savedList {
+	var lists []savedList
+	seen := map[string]bool{}
+	// saved recipes are kept newest first, and a list isn't planned more than
+	// a week ahead of its trip.
+	for _, saved := range currentUser.LastRecipes {
+		if saved.CreatedAt.Before(firstTrip.AddDate(0, 0, -7)) {
+			break
+		}
+		recipe, err := s.SingleFromCache(ctx, saved.Hash)
+		if err != nil {
+			slog.WarnContext(ctx, "saved recipe missing from cache, leaving it off the calendar", "hash", saved.Hash, "error", err)
+			continue
+		}
+		if recipe.OriginHash == "" || seen[recipe.OriginHash] {
+			continue
+		}
+		seen[recipe.OriginHash] = true
+		p, err := s.ParamsFromCache(ctx, recipe.OriginHash)
+		if err != nil {
+			slog.WarnContext(ctx, "saved recipe's shopping list missing from cache, leaving it off the calendar", "origin_hash", recipe.OriginHash, "error", err)
+			continue
+		}
+		if p.Location == nil || p.Date.Before(firstTrip) {
+			continue
+		}
+		lists = append(lists, savedList{hash: recipe.OriginHash, params: p})
+	}
+	return lists
+}
+
+// listDinnerEvents are the dinners planned from one shopping list.
+func (s *server) listDinnerEvents(ctx context.Context, currentUser *utypes.User, p *generatorParams, hash, origin string) ([]ical.Event, error) {
+	list, err := s.FromCache(ctx, hash)
+	if errors.Is(err, cache.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load shopping list %s: %w", hash, err)
+	}
+	dinners, err := s.plannedDinners(ctx, currentUser.ID, p, hash, list.Recipes)
+	if err != nil {
+		return nil, err
+	}
+	var events []ical.Event
+	for _, dinner := range dinners {
+		recipe, err := s.SingleFromCache(ctx, dinner.RecipeHash)
+		if errors.Is(err, cache.ErrNotFound) {
+			slog.WarnContext(ctx, "planned recipe missing from cache, leaving it off the calendar", "hash", dinner.RecipeHash, "list", hash)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("load recipe %s: %w", dinner.RecipeHash, err)
+		}
+		events = append(events, dinnerEvents(*recipe, dinner.RecipeHash, dinner.Date, origin)...)
+	}
+	return events, nil
+}
+
+// plannedDinners are a week plan's dinners. A regular list has no days, so the
+// recipes the user saved from it go one a night starting on shopping day.
+func (s *server) plannedDinners(ctx context.Context, userID string, p *generatorParams, hash string, recipes []ai.Recipe) ([]WeekPlanDay, error) {
+	plan, err := s.WeekPlanFromCache(ctx, hash)
+	if err == nil {
+		days := lo.Filter(plan.Days, func(day WeekPlanDay, _ int) bool {
+			return !day.Date.IsZero() && day.RecipeHash != ""
+		})
+		// cached dates come back with a fixed offset; dinner time should follow the store's daylight saving.
+		for i := range days {
+			days[i].Date = days[i].Date.In(p.Date.Location())
+		}
+		return days, nil
+	}
+	if !errors.Is(err, cache.ErrNotFound) {
+		return nil, err
+	}
+	selection, err := s.loadRecipeSelection(ctx, userID, hash)
+	if err != nil {
+		return nil, fmt.Errorf("load recipe selection for %s: %w", hash, err)
+	}
+	var days []WeekPlanDay
+	for _, recipe := range recipes {
+		recipeHash := recipe.ComputeHash()
+		if !selection.IsSaved(recipeHash) {
+			continue
+		}
+		days = append(days, WeekPlanDay{Date: p.Date.AddDate(0, 0, len(days)), RecipeHash: recipeHash, Title: recipe.Title})
+	}
+	return days, nil
+}
+
+func shoppingEvent(userID string, loc *locations.Location, date time.Time, hash, origin string) ical.Event {
+	e := ical.Event{
+		UID:     "shopping-" + date.Format("20060102") + "-" + userID + "@careme.cooking",
+		Summary: "Grocery shopping at " + loc.Name,
+		Start:   date,
+		AllDay:  true,
+	}
+	if hash != "" {
+		e.URL = origin + "/recipes?h=" + hash
+		e.Description = "Shopping list: " + e.URL
+	}
+	return e
+}
+
+// dinnerEvents is a dinner that starts cooking in time to eat at dinner time,
+// and a reminder for each step that has to start well ahead of that.
+func dinnerEvents(recipe ai.Recipe, recipeHash string, date time.Time, origin string) []ical.Event {
+	cook := export.CookDuration(recipe.CookTime)
+	if cook <= 0 {
+		cook = defaultCookTime
+	}
+	start := time.Date(date.Year(), date.Month(), date.Day(), dinnerHour, dinnerMinute, 0, 0, date.Location()).Add(-cook)
+	recipeURL := origin + "/recipe/" + recipeHash
+	uid := recipeHash + "-" + date.Format("20060102")
+	events := []ical.Event{{
+		UID:         uid + "@careme.cooking",
+		Summary:     recipe.Title,
+		Description: strings.TrimSpace(recipe.Description + "\n\n" + recipeURL),
+		URL:         recipeURL,
+		Start:       start,
+		Duration:    cook,
+	}}
+	for i, step := range recipe.Instructions {
+		lead, ok := prepLeadTime(step)
+		if !ok {
+			continue
+		}
+		events = append(events, ical.Event{
+			UID:         uid + "-prep" + strconv.Itoa(i) + "@careme.cooking",
+			Summary:     "Start prep for " + recipe.Title,
+			Description: step + "\n\n" + recipeURL,
+			URL:         recipeURL,
+			Start:       start.Add(-lead),
+			Duration:    15 * time.Minute,
+			Alarm:       true,
+		})
+	}
+	return events
+}
+
+var (
+	prepStep     = regexp.MustCompile(`(?i)\b(marinat\w*|brin(?:e|ed|es|ing)|soak\w*|chill\w*|refrigerat\w*|rest(?:s|ing)?|rise|rising|proof\w*|thaw\w*|defrost\w*|cure|curing|pickl\w*)\b`)
+	stepDuration = regexp.MustCompile(`(?i)((?:\d+\s+)?\d+/\d+|\d*\.\d+|\d+)\s*(?:(?:to|-|–)\s*[\d./]+\s*)?(hours?|hrs?|minutes?|mins?)\b`)
+)
+
+// prepLeadTime is how long before cooking a step like marinating or brining
+// has to start, when that's at least minPrepLead. Ranges count from their low
+// end since that's all the recipe needs.
+func prepLeadTime(step string) (time.Duration, bool) {
+	if !prepStep.MatchString(step) {
+		return 0, false
+	}
+	var lead time.Duration
+	if strings.Contains(strings.ToLower(step), "overnight") {
+		lead = overnightLead
+	}
+	for _, m := range stepDuration.FindAllStringSubmatch(step, -1) {
+		n, _, ok := ai.ParseQuantity(m[1])
+		if !ok {
+			continue
+		}
+		unit := time.Minute
+		if strings.HasPrefix(strings.ToLower(m[2]), "h") {
+			unit = time.Hour
+		}
+		lead = max(lead, time.Duration(n*float64(unit)).Round(time.Minute))
+	}
+	return lead, lead >= minPrepLead
+}
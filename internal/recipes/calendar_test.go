@@ -0,0 +1,163 @@
+package recipes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"careme/internal/ai"
+	"careme/internal/auth"
+	"careme/internal/cache"
+	"careme/internal/config"
+	"careme/internal/locations"
+	"careme/internal/users"
+	utypes "careme/internal/users/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrepLeadTime(t *testing.T) {
+	for _, tc := range []struct {
+		step string
+		want time.Duration
+		ok   bool
+	}{
+		{step: "Marinate the chicken for 2 to 4 hours.", want: 2 * time.Hour, ok: true},
+		{step: "Brine the pork chops overnight, or at least 6 hours.", want: overnightLead, ok: true},
+		{step: "Soak the beans 8 hrs.", want: 8 * time.Hour, ok: true},
+		{step: "Let the steak rest 10 minutes before slicing.", want: 10 * time.Minute},
+		{step: "Bring to a boil and simmer for 2 hours.", want: 0},
+		{step: "Chill the dough for 90 minutes.", want: 90 * time.Minute, ok: true},
+		{step: "Marinate for 1.5 hours.", want: 90 * time.Minute, ok: true},
+		{step: "Let the dough rise 1 1/2 to 2 hours.", want: 90 * time.Minute, ok: true},
+	} {
+		got, ok := prepLeadTime(tc.step)
+		assert.Equal(t, tc.want, got, tc.step)
+		assert.Equal(t, tc.ok, ok, tc.step)
+	}
+}
+
+func calendarTestLocation() *locations.Location {
+	lat := 47.61
+	lon := -122.33
+	return &locations.Location{ID: "70000001", Name: "Store", Lat: &lat, Lon: &lon}
+}
+
+func TestHandleCalendar_SavedRecipesAndPrep(t *testing.T) {
+	withNow(t, time.Date(2026, time.January, 15, 20, 0, 0, 0, time.UTC)) // Thursday noon in Seattle
+	cacheStore := cache.NewFileCache(filepath.Join(t.TempDir(), "cache"))
+	storage := users.NewStorage(cacheStore)
+	cfg := &config.Config{PublicOrigin: "https://careme.cooking"}
+	loc := calendarTestLocation()
+	s := newTestServer(t,
+		withTestCache(cacheStore),
+		withTestStorage(storage),
+		withTestConfig(cfg),
+		withTestLocationServer(staticLocationLookup{location: loc}),
+	)
+	seattle, err := time.LoadLocation("America/Los_Angeles")
+	require.NoError(t, err)
+	p := DefaultParams(loc, time.Date(2026, time.January, 12, 0, 0, 0, 0, seattle))
+	// a list planned for the user's household doesn't hash like the default one.
+	p.Household = utypes.Household{Members: 4, Allergens: []string{"peanuts"}}
+	chicken := ai.Recipe{
+		Title:        "Lemon Chicken",
+		CookTime:     "45 minutes",
+		Instructions: []string{"Marinate the chicken for 2 to 4 hours.", "Roast until golden."},
+		OriginHash:   p.Hash(),
+	}
+	skipped := ai.Recipe{Title: "Tomato Soup", CookTime: "30 minutes"}
+	chickenHash := chicken.ComputeHash()
+	require.NoError(t, s.SaveParams(t.Context(), p))
+	require.NoError(t, s.SaveRecipe(t.Context(), chicken))
+	require.NoError(t, s.SaveShoppingList(t.Context(), &ai.ShoppingList{Recipes: []ai.Recipe{skipped, chicken}}, p.Hash()))
+	require.NoError(t, s.saveRecipeSelection(t.Context(), "u-1", p.Hash(), recipeSelection{SavedHashes: []string{chickenHash}}))
+	require.NoError(t, storage.Update(&utypes.User{
+		ID:            "u-1",
+		Email:         []string{"you@careme.cooking"},
+		CreatedAt:     time.Now(),
+		ShoppingDay:   time.Monday.String(),
+		FavoriteStore: loc.ID,
+		LastRecipes:   []utypes.Recipe{{Title: chicken.Title, Hash: chickenHash, CreatedAt: time.Date(2026, time.January, 12, 18, 0, 0, 0, time.UTC)}},
+	}))
+
+	calendar := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/user/calendar.ics?"+url.Values{"token": {token}}.Encode(), nil)
+		rr := httptest.NewRecorder()
+		s.handleCalendar(rr, req)
+		return rr
+	}
+	require.Equal(t, http.StatusBadRequest, calendar("nope").Code)
+	apiToken, err := s.tokens.Issue(t.Context(), "u-1")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, calendar(apiToken).Code, "only calendar tokens read the feed")
+
+	token, err := s.tokens.IssueScoped(t.Context(), "u-1", auth.ScopeCalendar)
+	require.NoError(t, err)
+	rr := calendar(token)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/calendar; charset=utf-8", rr.Header().Get("Content-Type"))
+
+	body := strings.ReplaceAll(rr.Body.String(), "\r\n ", "")
+	assert.Contains(t, body, "DTSTART;VALUE=DATE:20260112\r\n", "this week's trip")
+	assert.Contains(t, body, "DTSTART;VALUE=DATE:20260119\r\n", "next week's trip")
+	assert.Contains(t, body, "DTSTART;VALUE=DATE:20260105\r\n", "last week's trip")
+	assert.Contains(t, body, "URL;VALUE=URI:https://careme.cooking/recipes?h="+p.Hash()+"\r\n")
+	assert.Contains(t, body, "SUMMARY:Lemon Chicken\r\n")
+	assert.Contains(t, body, "DTSTART:20260113T014500Z\r\nDURATION:PT45M\r\n", "cooking starts in time to eat at 6:30")
+	assert.Contains(t, body, "URL;VALUE=URI:https://careme.cooking/recipe/"+chickenHash+"\r\n")
+	assert.Contains(t, body, "SUMMARY:Start prep for Lemon Chicken\r\n")
+	assert.Contains(t, body, "DTSTART:20260112T234500Z\r\n", "the marinade starts two hours ahead")
+	assert.NotContains(t, body, "Tomato Soup", "only saved recipes are planned")
+	assert.Equal(t, 1, strings.Count(body, "DTSTART;VALUE=DATE:20260112\r\n"), "one trip a day")
+
+	require.NoError(t, s.tokens.Revoke(t.Context(), token))
+	require.Equal(t, http.StatusBadRequest, calendar(token).Code, "a reset link stops working")
+}
+
+func TestUserCalendar_WeekPlanDays(t *testing.T) {
+	withNow(t, time.Date(2026, time.January, 15, 20, 0, 0, 0, time.UTC))
+	cacheStore := cache.NewFileCache(filepath.Join(t.TempDir(), "cache"))
+	loc := calendarTestLocation()
+	s := newTestServer(t,
+		withTestCache(cacheStore),
+		withTestConfig(&config.Config{PublicOrigin: "https://careme.cooking"}),
+		withTestLocationServer(staticLocationLookup{location: loc}),
+	)
+	seattle, err := time.LoadLocation("America/Los_Angeles")
+	require.NoError(t, err)
+	p := DefaultParams(loc, time.Date(2026, time.January, 12, 0, 0, 0, 0, seattle))
+	require.NoError(t, p.SetWeekPlanDays([]string{"Tuesday", "Thursday"}))
+	stew := ai.Recipe{Title: "Beef Stew", CookTime: "2 hours", OriginHash: p.Hash()}
+	user := &utypes.User{
+		ID:            "u-1",
+		ShoppingDay:   time.Monday.String(),
+		FavoriteStore: loc.ID,
+		WeekPlanDays:  []string{"Tuesday", "Thursday"},
+		LastRecipes:   []utypes.Recipe{{Title: stew.Title, Hash: stew.ComputeHash(), CreatedAt: time.Date(2026, time.January, 11, 18, 0, 0, 0, time.UTC)}},
+	}
+	require.NoError(t, s.SaveParams(t.Context(), p))
+	require.NoError(t, s.SaveRecipe(t.Context(), stew))
+	require.NoError(t, s.SaveShoppingList(t.Context(), &ai.ShoppingList{Recipes: []ai.Recipe{stew}}, p.Hash()))
+	require.NoError(t, s.SaveWeekPlan(t.Context(), &WeekPlan{Days: []WeekPlanDay{
+		{Date: time.Date(2026, time.January, 13, 0, 0, 0, 0, seattle), RecipeHash: stew.ComputeHash(), Title: stew.Title},
+		{Date: time.Date(2026, time.January, 15, 0, 0, 0, 0, seattle), Leftovers: "Beef Stew"},
+	}}, p.Hash()))
+
+	cal, err := s.userCalendar(t.Context(), user)
+	require.NoError(t, err)
+	dinners := 0
+	for _, e := range cal.Events {
+		if e.Summary == "Beef Stew" {
+			dinners++
+			assert.Equal(t, time.Date(2026, time.January, 13, 16, 30, 0, 0, seattle), e.Start)
+			assert.Equal(t, 2*time.Hour, e.Duration)
+		}
+	}
+	assert.Equal(t, 1, dinners, "leftover nights have no recipe to cook")
+}
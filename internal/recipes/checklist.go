@@ -0,0 +1,289 @@
+package recipes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"careme/internal/ai"
+	"careme/internal/auth"
+	"careme/internal/cache"
+	"careme/internal/httpx"
+)
+
+const (
+	checklistCachePrefix = "checklist/"
+	// maxChecklistItemLen bounds item keys, which are normalized ingredient names.
+	maxChecklistItemLen = 200
+	// maxChecklistToggles bounds one sync; a phone that was offline for a whole
+	// trip has at most a toggle or two per item.
+	maxChecklistToggles = 500
+)
+
+// shoppingChecklist is what a user has checked off one shopping list. Lists
+// are shared by hash, so each user keeps their own. Each item keeps when it
+// last changed so toggles from several phones, some replayed after being
+// offline, settle on the latest one.
+type shoppingChecklist struct {
+	Items map[string]checklistItem `json:"items,omitempty"`
+}
+
+type checklistItem struct {
+	Checked   bool      `json:"checked"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// checklistToggle is one tap on a shopping list item. At is the phone's clock
+// in unix milliseconds when it was tapped, not when it reached us.
+type checklistToggle struct {
+	Item    string `json:"item"`
+	Checked bool   `json:"checked"`
+	At      int64  `json:"at"`
+}
+
+type checklistSyncRequest struct {
+	Toggles []checklistToggle `json:"toggles"`
+}
+
+type checklistSyncResponse struct {
+	Checked []string `json:"checked"`
+}
+
+func checklistKey(userID, listHash string) string {
+	return fmt.Sprintf("%s%s/%s", checklistCachePrefix, strings.TrimSpace(listHash), strings.TrimSpace(userID))
+}
+
+// checklistItemKey identifies a shopping list row. Lists that span stores can
+// have the same ingredient at each, so those are keyed by store too.
+func checklistItemKey(store, name string) string {
+	key := normalizeShoppingListName(name)
+	if store = strings.TrimSpace(store); store != "" && key != "" {
+		key = strings.ToLower(store) + "/" + key
+	}
+	return key
+}
+
+// apply records t unless a later toggle of the same item is already in. A
+// missing or future timestamp counts as now so a phone with a bad clock can't
+// pin an item.
+func (c *shoppingChecklist) apply(t checklistToggle, now time.Time) bool {
+	at := now
+	if t.At > 0 && t.At < now.UnixMilli() {
+		at = time.UnixMilli(t.At)
+	}
+	if existing, ok := c.Items[t.Item]; ok && existing.UpdatedAt.After(at) {
+		return false
+	}
+	if c.Items == nil {
+		c.Items = make(map[string]checklistItem)
+	}
+	c.Items[t.Item] = checklistItem{Checked: t.Checked, UpdatedAt: at}
+	return true
+}
+
+func (c shoppingChecklist) IsChecked(item string) bool {
+	return c.Items[item].Checked
+}
+
+func (c shoppingChecklist) checked() []string {
+	checked := []string{}
+	for item, state := range c.Items {
+		if state.Checked {
+			checked = append(checked, item)
+		}
+	}
+	slices.Sort(checked)
+	return checked
+}
+
+func (rio recipeio) loadChecklist(ctx context.Context, userID, listHash string) (shoppingChecklist, error) {
+	reader, err := rio.Cache.Get(ctx, checklistKey(userID, listHash))
+	if err != nil {
+		if errors.Is(err, cache.ErrNotFound) {
+			return shoppingChecklist{}, nil
+		}
+		return shoppingChecklist{}, err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	var checklist shoppingChecklist
+	if err := json.NewDecoder(reader).Decode(&checklist); err != nil {
+		return shoppingChecklist{}, fmt.Errorf("failed to decode checklist: %w", err)
+	}
+	return checklist, nil
+}
+
+// applyChecklistToggles merges toggles into the stored checklist, re-reading
+// if the other phone wrote in between, and returns the merged checklist.
+func (rio recipeio) applyChecklistToggles(ctx context.Context, userID, listHash string, toggles []checklistToggle) (shoppingChecklist, error) {
+	checklist, err := cache.UpdateJSON(ctx, rio.Cache, checklistKey(userID, listHash), func(checklist *shoppingChecklist, _ bool) error {
+		now := time.Now()
+		changed := false
+		for _, t := range toggles {
+			changed = checklist.apply(t, now) || changed
+		}
+		if !changed {
+			return cache.ErrSkipUpdate
+		}
+		return nil
+	})
+	if err != nil {
+		return shoppingChecklist{}, fmt.Errorf("failed to save checklist: %w", err)
+	}
+	return checklist, nil
+}
+
+func validChecklistItem(item string) bool {
+	return item != "" && len(item) <= maxChecklistItemLen
+}
+
+// handleChecklistToggle records one item checked or unchecked from the
+// shopping list's htmx form. The checkbox already shows the new state, so
+// there's nothing to swap back.
+func (s *server) handleChecklistToggle(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	listHash := strings.TrimSpace(r.PathValue("hash"))
+	if listHash == "" {
+		http.Error(w, "missing shopping list hash", http.StatusBadRequest)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	toggle := checklistToggle{
+		Item:    strings.TrimSpace(r.FormValue("item")),
+		Checked: r.FormValue("checked") != "",
+	}
+	if !validChecklistItem(toggle.Item) {
+		http.Error(w, "invalid item", http.StatusBadRequest)
+		return
+	}
+	if at := r.FormValue("at"); at != "" {
+		ms, err := strconv.ParseInt(at, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid timestamp", http.StatusBadRequest)
+			return
+		}
+		toggle.At = ms
+	}
+	currentUser, err := s.storage.FromRequest(ctx, r, s.clerk)
+	if err != nil {
+		if errors.Is(err, auth.ErrNoSession) {
+			redirectToAccountRequired(w, r, auth.AccountRequiredAddRecipe, shoppingListArgs(map[string]string{queryArgHash: listHash}))
+			return
+		}
+		slog.ErrorContext(ctx, "failed to load user for checklist", "error", err)
+		http.Error(w, "unable to load account", http.StatusInternalServerError)
+		return
+	}
+	exists, err := s.ShoppingListExists(ctx, listHash)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to check shopping list for checklist", "hash", listHash, "error", err)
+		http.Error(w, "failed to save checklist", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "shopping list not found", http.StatusNotFound)
+		return
+	}
+	if _, err := s.applyChecklistToggles(ctx, currentUser.ID, listHash, []checklistToggle{toggle}); err != nil {
+		slog.ErrorContext(ctx, "failed to toggle checklist item", "hash", listHash, "error", err)
+		http.Error(w, "failed to save checklist", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleChecklistSync replays toggles a phone made while it was offline and
+// answers with everything checked, including what another phone checked off
+// in the meantime. An empty sync just reads the list.
+func (s *server) handleChecklistSync(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	listHash := strings.TrimSpace(r.PathValue("hash"))
+	if listHash == "" {
+		httpx.JSONError(w, "missing shopping list hash", http.StatusBadRequest)
+		return
+	}
+	currentUser, ok := s.apiUser(w, r)
+	if !ok {
+		return
+	}
+	var req checklistSyncRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		httpx.JSONError(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Toggles) > maxChecklistToggles {
+		httpx.JSONError(w, "too many toggles", http.StatusBadRequest)
+		return
+	}
+	for i := range req.Toggles {
+		req.Toggles[i].Item = strings.TrimSpace(req.Toggles[i].Item)
+		if !validChecklistItem(req.Toggles[i].Item) {
+			httpx.JSONError(w, "invalid item", http.StatusBadRequest)
+			return
+		}
+	}
+
+	exists, err := s.ShoppingListExists(ctx, listHash)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to check shopping list for checklist", "hash", listHash, "error", err)
+		httpx.JSONError(w, "failed to sync checklist", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		httpx.JSONError(w, "shopping list not found", http.StatusNotFound)
+		return
+	}
+	var checklist shoppingChecklist
+	if len(req.Toggles) == 0 {
+		checklist, err = s.loadChecklist(ctx, currentUser.ID, listHash)
+	} else {
+		checklist, err = s.applyChecklistToggles(ctx, currentUser.ID, listHash, req.Toggles)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to sync checklist", "hash", listHash, "error", err)
+		httpx.JSONError(w, "failed to sync checklist", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeAPIJSON(w, r, http.StatusOK, checklistSyncResponse{Checked: checklist.checked()})
+}
+
+// shoppingListRow is a shopping list item with its place on the checklist.
+type shoppingListRow struct {
+	*ai.Ingredient
+	Key     string
+	Checked bool
+}
+
+// shoppingListSection is a shoppingListGroup as the page renders it.
+type shoppingListSection struct {
+	Store        string
+	StoreHeading bool
+	Aisle        string
+	Items        []shoppingListRow
+}
+
+func withChecklist(groups []shoppingListGroup, checklist shoppingChecklist) []shoppingListSection {
+	sections := make([]shoppingListSection, 0, len(groups))
+	for _, group := range groups {
+		section := shoppingListSection{Store: group.Store, StoreHeading: group.StoreHeading, Aisle: group.Aisle}
+		for _, item := range group.Items {
+			key := checklistItemKey(group.Store, item.Name)
+			section.Items = append(section.Items, shoppingListRow{Ingredient: item, Key: key, Checked: checklist.IsChecked(key)})
+		}
+		sections = append(sections, section)
+	}
+	return sections
+}
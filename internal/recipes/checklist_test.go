@@ -0,0 +1,119 @@
+package recipes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"careme/internal/ai"
+	"careme/internal/cache"
+	"careme/internal/locations"
+	utypes "careme/internal/users/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShoppingChecklistApply(t *testing.T) {
+	now := time.Date(2026, time.January, 12, 18, 0, 0, 0, time.UTC)
+	var c shoppingChecklist
+
+	assert.True(t, c.apply(checklistToggle{Item: "leeks", Checked: true, At: now.Add(-time.Minute).UnixMilli()}, now))
+	assert.False(t, c.apply(checklistToggle{Item: "leeks", Checked: false, At: now.Add(-10 * time.Minute).UnixMilli()}, now),
+		"a tap replayed from offline loses to a later one from the other phone")
+	assert.True(t, c.IsChecked("leeks"))
+
+	assert.True(t, c.apply(checklistToggle{Item: "butter", Checked: true, At: now.Add(time.Hour).UnixMilli()}, now))
+	assert.Equal(t, now, c.Items["butter"].UpdatedAt, "a clock running ahead counts as now")
+	assert.True(t, c.apply(checklistToggle{Item: "butter", Checked: false}, now))
+	assert.Equal(t, []string{"leeks"}, c.checked())
+}
+
+func TestHandleChecklistSync_ReconcilesOfflineToggles(t *testing.T) {
+	cacheStore := cache.NewFileCache(filepath.Join(t.TempDir(), "cache"))
+	s := newTestServer(t, withTestCache(cacheStore))
+	now := time.Now()
+	require.NoError(t, s.SaveShoppingList(t.Context(), &ai.ShoppingList{}, "list-hash"))
+
+	// the other phone, online, checks off leeks and then butter.
+	form := url.Values{"item": {"leeks"}, "checked": {"1"}, "at": {strconv.FormatInt(now.Add(-2*time.Minute).UnixMilli(), 10)}}
+	req := httptest.NewRequest(http.MethodPost, "/recipes/list-hash/checklist", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+	req.SetPathValue("hash", "list-hash")
+	rr := httptest.NewRecorder()
+	s.handleChecklistToggle(rr, req)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+
+	// this phone was offline: it unchecked leeks before the other phone checked
+	// them, and checked garlic.
+	body := `{"toggles":[
+		{"item":"leeks","checked":false,"at":` + strconv.FormatInt(now.Add(-5*time.Minute).UnixMilli(), 10) + `},
+		{"item":"garlic","checked":true,"at":` + strconv.FormatInt(now.Add(-4*time.Minute).UnixMilli(), 10) + `}
+	]}`
+	req = httptest.NewRequest(http.MethodPost, "/recipes/list-hash/checklist/sync", strings.NewReader(body))
+	req.SetPathValue("hash", "list-hash")
+	rr = httptest.NewRecorder()
+	s.handleChecklistSync(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"checked":["garlic","leeks"]}`, rr.Body.String())
+
+	checklist, err := s.loadChecklist(t.Context(), "mock-clerk-user-id", "list-hash")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"garlic", "leeks"}, checklist.checked())
+	checklist, err = s.loadChecklist(t.Context(), "someone-else", "list-hash")
+	require.NoError(t, err)
+	assert.Empty(t, checklist.checked(), "another user opening the same list has their own checklist")
+
+	req = httptest.NewRequest(http.MethodPost, "/recipes/list-hash/checklist/sync", strings.NewReader(`{"toggles":[{"item":"","checked":true}]}`))
+	req.SetPathValue("hash", "list-hash")
+	rr = httptest.NewRecorder()
+	s.handleChecklistSync(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/recipes/made-up/checklist/sync", strings.NewReader(`{"toggles":[{"item":"leeks","checked":true}]}`))
+	req.SetPathValue("hash", "made-up")
+	rr = httptest.NewRecorder()
+	s.handleChecklistSync(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	form = url.Values{"item": {"leeks"}, "checked": {"1"}}
+	req = httptest.NewRequest(http.MethodPost, "/recipes/made-up/checklist", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetPathValue("hash", "made-up")
+	rr = httptest.NewRecorder()
+	s.handleChecklistToggle(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code, "unknown lists don't get checklists")
+	_, err = cacheStore.Get(t.Context(), checklistKey("mock-clerk-user-id", "made-up"))
+	assert.ErrorIs(t, err, cache.ErrNotFound)
+}
+
+func TestFormatShoppingListHTML_ShowsChecklist(t *testing.T) {
+	p := DefaultParams(&locations.Location{ID: "70004001", Name: "Store"}, time.Now())
+	list := ai.ShoppingList{Recipes: []ai.Recipe{{
+		Title:       "Leek Soup",
+		Ingredients: []ai.Ingredient{{Name: "Leeks", Quantity: "2"}, {Name: "Butter", Quantity: "2 tbsp"}},
+	}}}
+	selection := recipeSelection{SavedHashes: []string{list.Recipes[0].ComputeHash()}}
+	checklist := shoppingChecklist{Items: map[string]checklistItem{"leeks": {Checked: true}}}
+
+	w := httptest.NewRecorder()
+	FormatShoppingListHTMLForHashWithHelp(t.Context(), p, list, nil, nil, renderTestUser(true), utypes.Pantry{}, p.Hash(), selection, checklist, "", "", 0, w)
+	html := assertHTTPSuccess(t, w)
+	isValidHTML(t, html)
+	assert.Contains(t, html, `data-checklist="`+p.Hash()+`"`)
+	assert.Contains(t, html, `hx-post="/recipes/`+p.Hash()+`/checklist"`)
+	assert.Regexp(t, `data-checklist-item="leeks"\s+checked`, html)
+	assert.NotRegexp(t, `data-checklist-item="butter"\s+checked`, html)
+	assert.Contains(t, html, `/static/checklist.js`)
+
+	w = httptest.NewRecorder()
+	FormatShoppingListHTMLForHashWithHelp(t.Context(), p, list, nil, nil, renderTestUser(false), utypes.Pantry{}, p.Hash(), selection, shoppingChecklist{}, "", "", 0, w)
+	html = assertHTTPSuccess(t, w)
+	assert.NotContains(t, html, "data-checklist", "guests have no checklist to sync")
+}
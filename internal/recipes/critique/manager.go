@@ -47,10 +47,15 @@ type waitingCritiquer struct {
 var _ recipeCritiquer = &waitingCritiquer{}
 
 func NewManager(cfg *config.Config, c cache.ListCache, httpClient *http.Client) *waitingCritiquer {
-	if !cfg.OpenRouter.IsEnabled() {
-		panic("OpenRouter must be enabled")
+	var crit recipeCritiquer
+	switch {
+	case cfg.LocalAI.IsEnabled():
+		crit = ai.NewLocalCritiquer(cfg.LocalAI.BaseURL, cfg.LocalAI.APIKey, cfg.LocalAI.ResolvedCritiqueModel(), httpClient)
+	case cfg.OpenRouter.IsEnabled():
+		crit = ai.NewCritiquer(cfg.OpenRouter.APIKey, cfg.OpenRouter.CritiqueModel, httpClient)
+	default:
+		panic("OpenRouter or local AI must be enabled")
 	}
-	crit := ai.NewCritiquer(cfg.OpenRouter.APIKey, cfg.OpenRouter.CritiqueModel, httpClient)
 	return &waitingCritiquer{
 		critiquer: newCachingCritiquer(crit, NewStore(c)),
 	}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 
 	"careme/internal/ai"
 	"careme/internal/cache"
+	"careme/internal/recipes/search"
 )
 
 const cachePrefix = "recipe_critiques/"
@@ -48,7 +50,14 @@ func (s store) Save(ctx context.Context, hash string, critique *ai.RecipeCritiqu
 	if err != nil {
 		return err
 	}
-	return s.cache.Put(ctx, cacheKey(hash), string(body), cache.Unconditional())
+	if err := s.cache.Put(ctx, cacheKey(hash), string(body), cache.Unconditional()); err != nil {
+		return err
+	}
+	// search ranks by the score; a stale one isn't worth failing the save over.
+	if err := search.NewStore(s.cache).SetScore(ctx, hash, critique.OverallScore); err != nil {
+		slog.ErrorContext(ctx, "failed to index critique score for search", "hash", hash, "error", err)
+	}
+	return nil
 }
 
 func (s store) ListHashes(ctx context.Context) ([]string, error) {
@@ -0,0 +1,226 @@
+package recipes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"careme/internal/ai"
+	"careme/internal/cache"
+)
+
+// Generation events are pushed in process as they happen. Generation runs on the
+// replica that kicked it off, so a stream connected to another replica only sees
+// what it picks up polling the cache.
+var generationEvents = newEventHub()
+
+const (
+	eventStatus  = "status"
+	eventRecipe  = "recipe"
+	eventDone    = "done"
+	eventError   = "failed" // "error" is taken by EventSource for connection errors
+	eventTimeout = "timeout"
+)
+
+type eventHub struct {
+	mu   sync.Mutex
+	subs map[string]map[chan any]struct{}
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subs: make(map[string]map[chan any]struct{})}
+}
+
+// subscribe returns a channel of events for hash and a func to stop listening.
+// Events are recipes and the event*Data types.
+func (h *eventHub) subscribe(hash string) (<-chan any, func()) {
+	ch := make(chan any, 16)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs[hash] == nil {
+		h.subs[hash] = make(map[chan any]struct{})
+	}
+	h.subs[hash][ch] = struct{}{}
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.subs[hash], ch)
+		if len(h.subs[hash]) == 0 {
+			delete(h.subs, hash)
+		}
+	}
+}
+
+// publish never blocks generation; a subscriber that falls behind misses events
+// and catches up from the cache poll.
+func (h *eventHub) publish(hash string, event any) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs[hash] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+type eventStatusData struct {
+	Message string `json:"message"`
+}
+
+type eventErrorData struct {
+	Message string `json:"message"`
+}
+
+type eventDoneData struct {
+	Hash string `json:"hash"`
+	URL  string `json:"url"`
+}
+
+func doneEvent(hash string) eventDoneData {
+	return eventDoneData{Hash: hash, URL: "/recipes?" + queryArgHash + "=" + hash}
+}
+
+// how often a stream checks the cache for progress made on another replica.
+const eventPollInterval = 5 * time.Second
+
+// handleEvents streams generation progress as server-sent events: status lines as
+// they're written, each recipe as soon as it's done, then done, error or timeout.
+// It gives up on the same ten minutes as the spinner page.
+func (s *server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	hash := strings.TrimSpace(r.PathValue("hash"))
+	if hash == "" {
+		http.Error(w, "missing shopping list hash", http.StatusBadRequest)
+		return
+	}
+	// subscribe before looking at the cache so nothing lands in between.
+	events, unsubscribe := generationEvents.subscribe(hash)
+	defer unsubscribe()
+
+	if _, err := s.ParamsFromCache(ctx, hash); err != nil {
+		if errors.Is(err, cache.ErrNotFound) {
+			http.Error(w, "shoppinglist not found or expired", http.StatusNotFound)
+			return
+		}
+		slog.ErrorContext(ctx, "failed to load params for events", "hash", hash, "error", err)
+		http.Error(w, "failed to load shopping list", http.StatusInternalServerError)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	// keep proxies from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(name string, data any) bool {
+		if err := writeEvent(w, name, data); err != nil {
+			slog.InfoContext(ctx, "events stream closed", "hash", hash, "error", err)
+			return false
+		}
+		if err := rc.Flush(); err != nil {
+			slog.ErrorContext(ctx, "failed to flush events stream", "hash", hash, "error", err)
+			return false
+		}
+		return true
+	}
+
+	sent := map[string]bool{}
+	lastStatus := ""
+	// catchUp sends whatever the cache has that this stream hasn't and reports
+	// whether the list is finished.
+	catchUp := func() (bool, bool) {
+		if slist, err := s.FromCache(ctx, hash); err == nil {
+			for _, recipe := range slist.Recipes {
+				if !sendRecipe(send, sent, recipe) {
+					return false, false
+				}
+			}
+			return send(eventDone, doneEvent(hash)), true
+		}
+		status, err := s.statusReader.GenerationStatusFromCache(ctx, hash)
+		if err != nil && !errors.Is(err, cache.ErrNotFound) {
+			slog.ErrorContext(ctx, "failed to load generation status", "hash", hash, "error", err)
+		}
+		if status != "" && status != lastStatus {
+			lastStatus = status
+			return send(eventStatus, eventStatusData{Message: status}), false
+		}
+		return true, false
+	}
+
+	if ok, done := catchUp(); !ok || done {
+		return
+	}
+
+	poll := time.NewTicker(eventPollInterval)
+	defer poll.Stop()
+	timeout := time.NewTimer(10 * time.Minute)
+	defer timeout.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timeout.C:
+			send(eventTimeout, doneEvent(hash))
+			return
+		case <-poll.C:
+			if ok, done := catchUp(); !ok || done {
+				return
+			}
+		case ev := <-events:
+			switch data := ev.(type) {
+			case ai.Recipe:
+				if !sendRecipe(send, sent, data) {
+					return
+				}
+			case eventStatusData:
+				lastStatus = data.Message
+				if !send(eventStatus, data) {
+					return
+				}
+			case eventDoneData:
+				// regenerated lists carry saved recipes nobody published, so send from the cache.
+				if ok, done := catchUp(); !ok || done {
+					return
+				}
+			case eventErrorData:
+				send(eventError, data)
+				return
+			}
+		}
+	}
+}
+
+func sendRecipe(send func(string, any) bool, sent map[string]bool, recipe ai.Recipe) bool {
+	recipeHash := recipe.ComputeHash()
+	if sent[recipeHash] {
+		return true
+	}
+	sent[recipeHash] = true
+	return send(eventRecipe, newAPIRecipe(recipe))
+}
+
+func writeEvent(w http.ResponseWriter, name string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, payload)
+	return err
+}
+
+// publishRecipe is for a recipe the generator has finished, after any retries.
+func publishRecipe(hash string, recipe *ai.Recipe) {
+	if recipe == nil {
+		return
+	}
+	generationEvents.publish(hash, *recipe)
+}
@@ -0,0 +1,129 @@
+package recipes
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"careme/internal/ai"
+	"careme/internal/locations"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sseEvent struct {
+	name string
+	data string
+}
+
+func readEvent(t *testing.T, r *bufio.Reader) sseEvent {
+	t.Helper()
+	var ev sseEvent
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			return ev
+		case strings.HasPrefix(line, "event: "):
+			ev.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			ev.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestEventHubPublishAndUnsubscribe(t *testing.T) {
+	hub := newEventHub()
+	events, unsubscribe := hub.subscribe("abc")
+	hub.publish("abc", eventStatusData{Message: "working"})
+	hub.publish("other", eventStatusData{Message: "not for us"})
+
+	require.Len(t, events, 1)
+	assert.Equal(t, eventStatusData{Message: "working"}, <-events)
+
+	unsubscribe()
+	assert.Empty(t, hub.subs)
+	hub.publish("abc", eventStatusData{Message: "nobody listening"})
+	assert.Empty(t, events)
+}
+
+func TestHandleEvents_FinishedListSendsRecipesThenDone(t *testing.T) {
+	s := newTestServer(t)
+	p := DefaultParams(&locations.Location{ID: "70004001", Name: "Store"}, time.Now())
+	require.NoError(t, s.SaveParams(t.Context(), p))
+	recipes := []ai.Recipe{{Title: "Rice Bowl"}, {Title: "Tacos"}}
+	require.NoError(t, s.SaveShoppingList(t.Context(), &ai.ShoppingList{Recipes: recipes}, p.Hash()))
+
+	mux := http.NewServeMux()
+	s.Register(mux)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/recipes/"+p.Hash()+"/events", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/event-stream", rr.Header().Get("Content-Type"))
+	body := bufio.NewReader(rr.Body)
+	for _, recipe := range recipes {
+		ev := readEvent(t, body)
+		assert.Equal(t, eventRecipe, ev.name)
+		var got apiRecipe
+		require.NoError(t, json.Unmarshal([]byte(ev.data), &got))
+		assert.Equal(t, recipe.Title, got.Title)
+		assert.Equal(t, recipe.ComputeHash(), got.Hash)
+	}
+	ev := readEvent(t, body)
+	assert.Equal(t, eventDone, ev.name)
+	assert.JSONEq(t, `{"hash":"`+p.Hash()+`","url":"/recipes?h=`+p.Hash()+`"}`, ev.data)
+}
+
+func TestHandleEvents_StreamsPublishedProgress(t *testing.T) {
+	s := newTestServer(t)
+	p := DefaultParams(&locations.Location{ID: "70004001", Name: "Store"}, time.Now())
+	hash := p.Hash()
+	require.NoError(t, s.SaveParams(t.Context(), p))
+	require.NoError(t, s.statusWriter.SaveGenerationStatus(t.Context(), hash, "Picking ingredients"))
+
+	mux := http.NewServeMux()
+	s.Register(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, srv.URL+"/recipes/"+hash+"/events", nil)
+	require.NoError(t, err)
+	resp, err := srv.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body := bufio.NewReader(resp.Body)
+
+	// the first status comes from the cache, which also means the stream is subscribed.
+	ev := readEvent(t, body)
+	assert.Equal(t, sseEvent{name: eventStatus, data: `{"message":"Picking ingredients"}`}, ev)
+
+	recipe := ai.Recipe{Title: "Rice Bowl"}
+	generationEvents.publish(hash, eventStatusData{Message: "Writing recipes"})
+	publishRecipe(hash, &recipe)
+	publishRecipe(hash, &recipe)
+	generationEvents.publish(hash, eventErrorData{Message: "out of ideas"})
+
+	assert.Equal(t, sseEvent{name: eventStatus, data: `{"message":"Writing recipes"}`}, readEvent(t, body))
+	ev = readEvent(t, body)
+	assert.Equal(t, eventRecipe, ev.name)
+	assert.Contains(t, ev.data, `"hash":"`+recipe.ComputeHash()+`"`)
+	assert.Equal(t, sseEvent{name: eventError, data: `{"message":"out of ideas"}`}, readEvent(t, body), "repeat recipes are skipped")
+}
+
+func TestHandleEvents_UnknownList(t *testing.T) {
+	s := newTestServer(t)
+	mux := http.NewServeMux()
+	s.Register(mux)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/recipes/nope/events", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
@@ -0,0 +1,281 @@
+// Package export writes saved recipes in formats other recipe apps import:
+// schema.org JSON-LD, Paprika, Mealie (which Tandoor also reads) and Markdown.
+package export
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"careme/internal/ai"
+)
+
+type Format string
+
+const (
+	JSONLD   Format = "jsonld"
+	Paprika  Format = "paprika"
+	Mealie   Format = "mealie"
+	Markdown Format = "markdown"
+)
+
+var Formats = []Format{JSONLD, Paprika, Mealie, Markdown}
+
+// ParseFormat accepts a format name as it appears in ?format=.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(s))); f {
+	case JSONLD, Paprika, Mealie, Markdown:
+		return f, nil
+	case "md":
+		return Markdown, nil
+	case "tandoor":
+		return Mealie, nil
+	}
+	return "", fmt.Errorf("unknown export format %q, want one of %v", s, Formats)
+}
+
+func (f Format) ContentType() string {
+	switch f {
+	case JSONLD:
+		return "application/ld+json"
+	case Paprika:
+		return "application/octet-stream"
+	case Markdown:
+		return "text/markdown; charset=utf-8"
+	default:
+		return "application/json"
+	}
+}
+
+func (f Format) extension() string {
+	switch f {
+	case JSONLD:
+		return ".jsonld"
+	case Paprika:
+		return ".paprikarecipe"
+	case Markdown:
+		return ".md"
+	default:
+		return ".json"
+	}
+}
+
+// ArchiveName is what a zip of recipes in this format is called. Paprika
+// imports a zip of .paprikarecipe files as long as it's named .paprikarecipes.
+func (f Format) ArchiveName() string {
+	if f == Paprika {
+		return "careme-recipes.paprikarecipes"
+	}
+	return "careme-recipes-" + string(f) + ".zip"
+}
+
+// Recipe is a saved recipe and what's needed to link back to it.
+type Recipe struct {
+	ai.Recipe
+	Hash     string
+	HasImage bool
+	// SavedAt is when the user saved it; zero when exporting a recipe nobody saved.
+	SavedAt time.Time
+}
+
+// URL is the recipe's page under origin.
+func (r Recipe) URL(origin string) string {
+	return strings.TrimRight(origin, "/") + "/recipe/" + r.Hash
+}
+
+func (r Recipe) imageURL(origin string) string {
+	if !r.HasImage {
+		return ""
+	}
+	return r.URL(origin) + "/image"
+}
+
+// Filename is the recipe's title as a file name with the format's extension.
+func (r Recipe) Filename(f Format) string {
+	return slug(r.Title) + f.extension()
+}
+
+// Write encodes r in format f. origin is the site's public origin, for links back.
+func (r Recipe) Write(w io.Writer, f Format, origin string) error {
+	switch f {
+	case JSONLD:
+		return writeJSON(w, r.JSONLD(origin))
+	case Paprika:
+		return r.writePaprika(w, origin)
+	case Mealie:
+		return writeJSON(w, r.mealie(origin))
+	case Markdown:
+		_, err := io.WriteString(w, r.markdown(origin))
+		return err
+	}
+	return fmt.Errorf("unknown export format %q", f)
+}
+
+// WriteZip writes every recipe in format f to one zip archive.
+func WriteZip(w io.Writer, f Format, origin string, recipes []Recipe) error {
+	zw := zip.NewWriter(w)
+	used := map[string]int{}
+	for _, r := range recipes {
+		base := slug(r.Title)
+		name := base + f.extension()
+		if n := used[base]; n > 0 {
+			name = base + "-" + strconv.Itoa(n+1) + f.extension()
+		}
+		used[base]++
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: r.SavedAt})
+		if err != nil {
+			return fmt.Errorf("add %s to archive: %w", name, err)
+		}
+		if err := r.Write(fw, f, origin); err != nil {
+			return fmt.Errorf("write %s: %w", name, err)
+		}
+	}
+	return zw.Close()
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// ingredientLine is how an ingredient reads in a recipe: "1 lb skirt steak".
+func ingredientLine(ing ai.Ingredient) string {
+	return strings.TrimSpace(strings.TrimSpace(ing.Quantity) + " " + strings.TrimSpace(ing.Name))
+}
+
+func (r Recipe) ingredientLines() []string {
+	lines := make([]string, 0, len(r.Ingredients))
+	for _, ing := range r.Ingredients {
+		lines = append(lines, ingredientLine(ing))
+	}
+	return lines
+}
+
+func (r Recipe) yield() string {
+	if r.Servings <= 0 {
+		return ""
+	}
+	return strconv.Itoa(r.Servings) + " servings"
+}
+
+// durationPart matches an amount, whole, decimal or fraction, and its unit.
+var durationPart = regexp.MustCompile(`((?:\d+\s+)?\d+/\d+|\d*\.\d+|\d+\s*[¼½¾⅓⅔⅛]?|[¼½¾⅓⅔⅛])\s*(hours?|hrs?|h|minutes?|mins?|m)\b`)
+
+// CookDuration reads a cook time like "1 hour 15 minutes" or "1 1/2 hours",
+// to the nearest minute. It's zero when there's no time in it.
+func CookDuration(cookTime string) time.Duration {
+	var d time.Duration
+	for _, m := range durationPart.FindAllStringSubmatch(strings.ToLower(cookTime), -1) {
+		n, _, ok := ai.ParseQuantity(m[1])
+		if !ok {
+			continue
+		}
+		unit := time.Minute
+		if strings.HasPrefix(m[2], "h") {
+			unit = time.Hour
+		}
+		d += time.Duration(n * float64(unit))
+	}
+	return d.Round(time.Minute)
+}
+
+// ISODuration writes d as an ISO 8601 duration, PT1H15M, the way schema.org
+// and iCalendar want it. It's empty for anything under a minute.
+func ISODuration(d time.Duration) string {
+	hours := int(d / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+	if hours == 0 && minutes == 0 {
+		return ""
+	}
+	s := "PT"
+	if hours > 0 {
+		s += strconv.Itoa(hours) + "H"
+	}
+	if minutes > 0 {
+		s += strconv.Itoa(minutes) + "M"
+	}
+	return s
+}
+
+// isoDuration reads a cook time like "1 hour 15 minutes" as an ISO 8601
+// duration, PT1H15M. It's empty when there's no time in it.
+func isoDuration(cookTime string) string {
+	return ISODuration(CookDuration(cookTime))
+}
+
+func slug(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	s := strings.TrimSuffix(b.String(), "-")
+	if s == "" {
+		return "recipe"
+	}
+	return s
+}
+
+func (r Recipe) markdown(origin string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", r.Title)
+	if r.Description != "" {
+		fmt.Fprintf(&b, "%s\n\n", r.Description)
+	}
+	if r.Servings > 0 {
+		fmt.Fprintf(&b, "- Serves: %d\n", r.Servings)
+	}
+	if r.CookTime != "" {
+		fmt.Fprintf(&b, "- Total time: %s\n", r.CookTime)
+	}
+	if r.CostEstimate != "" {
+		fmt.Fprintf(&b, "- Estimated cost: %s\n", r.CostEstimate)
+	}
+	b.WriteString("\n## Ingredients\n\n")
+	for _, line := range r.ingredientLines() {
+		fmt.Fprintf(&b, "- %s\n", line)
+	}
+	b.WriteString("\n## Instructions\n\n")
+	for i, step := range r.Instructions {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, step)
+	}
+	if notes := r.notes(); len(notes) > 0 {
+		b.WriteString("\n## Notes\n\n")
+		for _, n := range notes {
+			fmt.Fprintf(&b, "- **%s:** %s\n", n.Title, n.Text)
+		}
+	}
+	fmt.Fprintf(&b, "\nFrom Careme: %s\n", r.URL(origin))
+	return b.String()
+}
+
+type note struct {
+	Title string `json:"title"`
+	Text  string `json:"text"`
+}
+
+func (r Recipe) notes() []note {
+	var notes []note
+	if r.Health != "" {
+		notes = append(notes, note{Title: "Health", Text: r.Health})
+	}
+	if r.DrinkPairing != "" {
+		notes = append(notes, note{Title: "Drink pairing", Text: r.DrinkPairing})
+	}
+	return notes
+}
@@ -0,0 +1,175 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"careme/internal/ai"
+	"careme/internal/nutrition"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const origin = "https://careme.cooking"
+
+func testRecipe() Recipe {
+	return Recipe{
+		Recipe: ai.Recipe{
+			Title:        "Skirt Steak & Charred Leeks",
+			Description:  "Weeknight steak.",
+			CookTime:     "1 hour 15 minutes",
+			Servings:     4,
+			CostEstimate: "$18",
+			Ingredients: []ai.Ingredient{
+				{Name: "Skirt steak", Quantity: "1 lb"},
+				{Name: "Flaky salt"},
+			},
+			Instructions: []string{"Sear the steak.", "Char the leeks."},
+			Health:       "High protein.",
+			DrinkPairing: "Malbec",
+			Nutrition:    &nutrition.Facts{Calories: 520, ProteinGrams: 38, CarbsGrams: 12, FatGrams: 30, FiberGrams: 3, SodiumMilligrams: 640},
+		},
+		Hash:     "abc123==",
+		HasImage: true,
+		SavedAt:  time.Date(2026, time.May, 11, 18, 30, 0, 0, time.UTC),
+	}
+}
+
+func TestJSONLD(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, testRecipe().Write(&buf, JSONLD, origin+"/"))
+
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	assert.Equal(t, "https://schema.org", got["@context"])
+	assert.Equal(t, "Recipe", got["@type"])
+	assert.Equal(t, "https://careme.cooking/recipe/abc123==", got["url"])
+	assert.Equal(t, "https://careme.cooking/recipe/abc123==/image", got["image"])
+	assert.Equal(t, "4 servings", got["recipeYield"])
+	assert.Equal(t, "PT1H15M", got["totalTime"])
+	assert.Equal(t, []any{"1 lb Skirt steak", "Flaky salt"}, got["recipeIngredient"])
+	assert.Equal(t, map[string]any{"@type": "HowToStep", "text": "Sear the steak."}, got["recipeInstructions"].([]any)[0])
+	assert.Equal(t, "38 g", got["nutrition"].(map[string]any)["proteinContent"])
+}
+
+func TestIsoDuration(t *testing.T) {
+	for in, want := range map[string]string{
+		"45 minutes":        "PT45M",
+		"1 hr":              "PT1H",
+		"90 mins":           "PT1H30M",
+		"2 hours 5 min":     "PT2H5M",
+		"About 30-40 min":   "PT40M",
+		"overnight":         "",
+		"":                  "",
+		"1 h 10 m (active)": "PT1H10M",
+		"1.5 hours":         "PT1H30M",
+		"1 1/2 hours":       "PT1H30M",
+		"1½ hrs":            "PT1H30M",
+		"1/3 hour":          "PT20M",
+		"2.5 min":           "PT3M",
+	} {
+		assert.Equal(t, want, isoDuration(in), in)
+	}
+}
+
+func TestPaprika(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, testRecipe().Write(&buf, Paprika, origin))
+
+	zr, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+	var got paprikaRecipe
+	require.NoError(t, json.NewDecoder(zr).Decode(&got))
+	assert.Equal(t, "Skirt Steak & Charred Leeks", got.Name)
+	assert.Equal(t, "1 lb Skirt steak\nFlaky salt", got.Ingredients)
+	assert.Equal(t, "Sear the steak.\n\nChar the leeks.", got.Directions)
+	assert.Equal(t, "4", got.Servings)
+	assert.Equal(t, "2026-05-11 18:30:00", got.Created)
+	assert.Equal(t, "Health: High protein.\n\nDrink pairing: Malbec", got.Notes)
+	assert.Equal(t, testRecipe().paprika(origin).UID, got.UID, "the same recipe always gets the same UID")
+}
+
+func TestMealie(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, testRecipe().Write(&buf, Mealie, origin))
+
+	var got mealieRecipe
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	assert.Equal(t, "skirt-steak-charred-leeks", got.Slug)
+	assert.Equal(t, "https://careme.cooking/recipe/abc123==", got.OrgURL)
+	assert.Equal(t, "1 lb Skirt steak", got.RecipeIngredient[0].Note)
+	assert.Equal(t, "Char the leeks.", got.RecipeInstructions[1].Text)
+	require.NotNil(t, got.Nutrition)
+	assert.Equal(t, "520", got.Nutrition.Calories)
+}
+
+func TestMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, testRecipe().Write(&buf, Markdown, origin))
+	assert.Equal(t, `# Skirt Steak & Charred Leeks
+
+Weeknight steak.
+
+- Serves: 4
+- Total time: 1 hour 15 minutes
+- Estimated cost: $18
+
+## Ingredients
+
+- 1 lb Skirt steak
+- Flaky salt
+
+## Instructions
+
+1. Sear the steak.
+2. Char the leeks.
+
+## Notes
+
+- **Health:** High protein.
+- **Drink pairing:** Malbec
+
+From Careme: https://careme.cooking/recipe/abc123==
+`, buf.String())
+}
+
+func TestWriteZipNamesEachRecipe(t *testing.T) {
+	again := testRecipe()
+	again.Hash = "def456=="
+	untitled := testRecipe()
+	untitled.Title = "!!!"
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteZip(&buf, Markdown, origin, []Recipe{testRecipe(), again, untitled}))
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	assert.Equal(t, []string{"skirt-steak-charred-leeks.md", "skirt-steak-charred-leeks-2.md", "recipe.md"}, names)
+
+	rc, err := zr.File[1].Open()
+	require.NoError(t, err)
+	defer func() { _ = rc.Close() }()
+	body, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "/recipe/def456==")
+}
+
+func TestParseFormat(t *testing.T) {
+	f, err := ParseFormat(" Tandoor ")
+	require.NoError(t, err)
+	assert.Equal(t, Mealie, f)
+	assert.Equal(t, "careme-recipes.paprikarecipes", Paprika.ArchiveName())
+
+	_, err = ParseFormat("pdf")
+	assert.ErrorContains(t, err, "unknown export format")
+}
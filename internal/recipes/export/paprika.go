@@ -0,0 +1,83 @@
+package export
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// paprikaRecipe is one recipe as Paprika 3 exports it. A .paprikarecipe file is
+// this JSON gzipped.
+type paprikaRecipe struct {
+	UID             string   `json:"uid"`
+	Name            string   `json:"name"`
+	Description     string   `json:"description"`
+	Ingredients     string   `json:"ingredients"`
+	Directions      string   `json:"directions"`
+	Notes           string   `json:"notes"`
+	NutritionalInfo string   `json:"nutritional_info"`
+	Servings        string   `json:"servings"`
+	TotalTime       string   `json:"total_time"`
+	CookTime        string   `json:"cook_time"`
+	PrepTime        string   `json:"prep_time"`
+	Source          string   `json:"source"`
+	SourceURL       string   `json:"source_url"`
+	ImageURL        string   `json:"image_url"`
+	Categories      []string `json:"categories"`
+	Rating          int      `json:"rating"`
+	Difficulty      string   `json:"difficulty"`
+	Created         string   `json:"created"`
+	Hash            string   `json:"hash"`
+	Photo           string   `json:"photo"`
+	PhotoHash       string   `json:"photo_hash"`
+}
+
+func (r Recipe) paprika(origin string) paprikaRecipe {
+	sourceURL := r.URL(origin)
+	var notes []string
+	for _, n := range r.notes() {
+		notes = append(notes, n.Title+": "+n.Text)
+	}
+	p := paprikaRecipe{
+		// the same recipe keeps its UID so re-importing updates it instead of duplicating it.
+		UID:         strings.ToUpper(uuid.NewSHA1(uuid.NameSpaceURL, []byte(sourceURL)).String()),
+		Name:        r.Title,
+		Description: r.Description,
+		Ingredients: strings.Join(r.ingredientLines(), "\n"),
+		Directions:  strings.Join(r.Instructions, "\n\n"),
+		Notes:       strings.Join(notes, "\n\n"),
+		TotalTime:   strings.TrimSpace(r.CookTime),
+		Source:      "Careme",
+		SourceURL:   sourceURL,
+		ImageURL:    r.imageURL(origin),
+		Categories:  []string{},
+	}
+	if r.Servings > 0 {
+		p.Servings = strconv.Itoa(r.Servings)
+	}
+	if n := r.Nutrition; n != nil {
+		p.NutritionalInfo = fmt.Sprintf("Per serving: %d calories, %d g protein, %d g carbs, %d g fat, %d g fiber, %d mg sodium",
+			n.Calories, n.ProteinGrams, n.CarbsGrams, n.FatGrams, n.FiberGrams, n.SodiumMilligrams)
+	}
+	if !r.SavedAt.IsZero() {
+		p.Created = r.SavedAt.UTC().Format("2006-01-02 15:04:05")
+	}
+	sum := sha256.Sum256([]byte(r.Hash))
+	p.Hash = strings.ToUpper(hex.EncodeToString(sum[:]))
+	return p
+}
+
+func (r Recipe) writePaprika(w io.Writer, origin string) error {
+	zw := gzip.NewWriter(w)
+	if err := json.NewEncoder(zw).Encode(r.paprika(origin)); err != nil {
+		return err
+	}
+	return zw.Close()
+}
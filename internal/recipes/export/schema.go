@@ -0,0 +1,171 @@
+package export
+
+import (
+	"strconv"
+	"strings"
+)
+
+// SchemaRecipe is a schema.org Recipe. The recipe page embeds it as JSON-LD
+// for search engines and the JSON-LD export is the same document.
+type SchemaRecipe struct {
+	Context            string             `json:"@context"`
+	Type               string             `json:"@type"`
+	Name               string             `json:"name"`
+	Description        string             `json:"description,omitempty"`
+	URL                string             `json:"url"`
+	Image              string             `json:"image,omitempty"`
+	Author             schemaOrganization `json:"author"`
+	RecipeYield        string             `json:"recipeYield,omitempty"`
+	TotalTime          string             `json:"totalTime,omitempty"`
+	RecipeIngredient   []string           `json:"recipeIngredient"`
+	RecipeInstructions []schemaStep       `json:"recipeInstructions"`
+	Nutrition          *schemaNutrition   `json:"nutrition,omitempty"`
+	EstimatedCost      string             `json:"estimatedCost,omitempty"`
+}
+
+type schemaOrganization struct {
+	Type string `json:"@type"`
+	Name string `json:"name"`
+}
+
+type schemaStep struct {
+	Type string `json:"@type"`
+	Text string `json:"text"`
+}
+
+type schemaNutrition struct {
+	Type                string `json:"@type"`
+	ServingSize         string `json:"servingSize,omitempty"`
+	Calories            string `json:"calories"`
+	ProteinContent      string `json:"proteinContent"`
+	CarbohydrateContent string `json:"carbohydrateContent"`
+	FatContent          string `json:"fatContent"`
+	FiberContent        string `json:"fiberContent"`
+	SodiumContent       string `json:"sodiumContent"`
+}
+
+// JSONLD describes r as a schema.org Recipe.
+func (r Recipe) JSONLD(origin string) SchemaRecipe {
+	steps := make([]schemaStep, 0, len(r.Instructions))
+	for _, step := range r.Instructions {
+		steps = append(steps, schemaStep{Type: "HowToStep", Text: step})
+	}
+	s := SchemaRecipe{
+		Context:            "https://schema.org",
+		Type:               "Recipe",
+		Name:               r.Title,
+		Description:        r.Description,
+		URL:                r.URL(origin),
+		Image:              r.imageURL(origin),
+		Author:             schemaOrganization{Type: "Organization", Name: "Careme"},
+		RecipeYield:        r.yield(),
+		TotalTime:          isoDuration(r.CookTime),
+		RecipeIngredient:   r.ingredientLines(),
+		RecipeInstructions: steps,
+		EstimatedCost:      r.CostEstimate,
+	}
+	if n := r.Nutrition; n != nil {
+		s.Nutrition = &schemaNutrition{
+			Type:                "NutritionInformation",
+			ServingSize:         servingSize(r.Servings),
+			Calories:            strconv.Itoa(n.Calories) + " calories",
+			ProteinContent:      grams(n.ProteinGrams),
+			CarbohydrateContent: grams(n.CarbsGrams),
+			FatContent:          grams(n.FatGrams),
+			FiberContent:        grams(n.FiberGrams),
+			SodiumContent:       strconv.Itoa(n.SodiumMilligrams) + " mg",
+		}
+	}
+	return s
+}
+
+func grams(n int) string {
+	return strconv.Itoa(n) + " g"
+}
+
+func servingSize(servings int) string {
+	if servings <= 0 {
+		return ""
+	}
+	return "1 of " + strconv.Itoa(servings) + " servings"
+}
+
+// mealieRecipe is Mealie's recipe JSON, trimmed to what it needs to import.
+// Tandoor imports it too.
+type mealieRecipe struct {
+	Name               string              `json:"name"`
+	Slug               string              `json:"slug"`
+	Description        string              `json:"description"`
+	Image              string              `json:"image,omitempty"`
+	RecipeYield        string              `json:"recipeYield,omitempty"`
+	RecipeServings     int                 `json:"recipeServings,omitempty"`
+	TotalTime          string              `json:"totalTime,omitempty"`
+	OrgURL             string              `json:"orgURL"`
+	RecipeIngredient   []mealieIngredient  `json:"recipeIngredient"`
+	RecipeInstructions []mealieInstruction `json:"recipeInstructions"`
+	Nutrition          *mealieNutrition    `json:"nutrition,omitempty"`
+	Notes              []note              `json:"notes,omitempty"`
+	Tags               []mealieTag         `json:"tags"`
+}
+
+// mealieIngredient leaves amounts in the note; Mealie only parses them when asked.
+type mealieIngredient struct {
+	Note          string `json:"note"`
+	Display       string `json:"display"`
+	OriginalText  string `json:"originalText"`
+	DisableAmount bool   `json:"disableAmount"`
+}
+
+type mealieInstruction struct {
+	Text string `json:"text"`
+}
+
+type mealieNutrition struct {
+	Calories            string `json:"calories"`
+	ProteinContent      string `json:"proteinContent"`
+	CarbohydrateContent string `json:"carbohydrateContent"`
+	FatContent          string `json:"fatContent"`
+	FiberContent        string `json:"fiberContent"`
+	SodiumContent       string `json:"sodiumContent"`
+}
+
+type mealieTag struct {
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+func (r Recipe) mealie(origin string) mealieRecipe {
+	ingredients := make([]mealieIngredient, 0, len(r.Ingredients))
+	for _, line := range r.ingredientLines() {
+		ingredients = append(ingredients, mealieIngredient{Note: line, Display: line, OriginalText: line, DisableAmount: true})
+	}
+	instructions := make([]mealieInstruction, 0, len(r.Instructions))
+	for _, step := range r.Instructions {
+		instructions = append(instructions, mealieInstruction{Text: step})
+	}
+	m := mealieRecipe{
+		Name:               r.Title,
+		Slug:               slug(r.Title),
+		Description:        r.Description,
+		Image:              r.imageURL(origin),
+		RecipeYield:        r.yield(),
+		RecipeServings:     r.Servings,
+		TotalTime:          strings.TrimSpace(r.CookTime),
+		OrgURL:             r.URL(origin),
+		RecipeIngredient:   ingredients,
+		RecipeInstructions: instructions,
+		Notes:              r.notes(),
+		Tags:               []mealieTag{{Name: "Careme", Slug: "careme"}},
+	}
+	if n := r.Nutrition; n != nil {
+		m.Nutrition = &mealieNutrition{
+			Calories:            strconv.Itoa(n.Calories),
+			ProteinContent:      strconv.Itoa(n.ProteinGrams),
+			CarbohydrateContent: strconv.Itoa(n.CarbsGrams),
+			FatContent:          strconv.Itoa(n.FatGrams),
+			FiberContent:        strconv.Itoa(n.FiberGrams),
+			SodiumContent:       strconv.Itoa(n.SodiumMilligrams),
+		}
+	}
+	return m
+}
@@ -79,5 +79,10 @@ func (ss *statusStore) SaveGenerationStatus(ctx context.Context, hash, status st
 	if err := scanner.Err(); err != nil {
 		return err
 	}
-	return ss.cache.Put(ctx, key, strings.TrimRight(sb.String(), "\n"), cache.Unconditional())
+	saved := strings.TrimRight(sb.String(), "\n")
+	if err := ss.cache.Put(ctx, key, saved, cache.Unconditional()); err != nil {
+		return err
+	}
+	generationEvents.publish(hash, eventStatusData{Message: saved})
+	return nil
 }
@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"careme/internal/ai"
+	"careme/internal/ai/spend"
+	"careme/internal/dietary"
 	"careme/internal/locations"
 	"careme/internal/parallelism"
 	"careme/internal/recipes/critique"
@@ -28,6 +30,7 @@ type aiClient interface {
 	Regenerate(ctx context.Context, newinstructions []string, previous ai.ResponseRef) (*ai.Recipe, error)
 	AskQuestion(ctx context.Context, question string, previous ai.ResponseRef) (*ai.QuestionResponse, error)
 	PickWine(ctx context.Context, recipe ai.Recipe, wines []ai.InputIngredient) (*ai.WineSelection, error)
+	StructureRecipe(ctx context.Context, text string) (*ai.Recipe, error)
 }
 
 type staplesService interface {
@@ -77,7 +80,7 @@ func NewGenerator(aiClient aiClient, critiquer recipeCritiquer, staples staplesS
 }
 
 func (g *generatorService) PickAWine(ctx context.Context, location string, recipe ai.Recipe, date time.Time) (*ai.WineSelection, error) {
-	ctx, span := tracer.Start(ctx, "recipes.pickawine")
+	ctx, span := tracer.Start(spend.WithShoppingList(ctx, recipe.OriginHash), "recipes.pickawine")
 	defer span.End()
 	var styles []string
 	for _, style := range recipe.WineStyles {
@@ -114,6 +117,7 @@ func (g *generatorService) PickAWine(ctx context.Context, location string, recip
 func (g *generatorService) GenerateRecipes(ctx context.Context, p *generatorParams) (*ai.ShoppingList, error) {
 	hash := p.Hash()
 	start := time.Now()
+	ctx = spend.WithShoppingList(ctx, hash)
 
 	if p.isRegeneration() {
 		slog.InfoContext(ctx, "Regenerating recipes for location", "location", p.String(), "dismissed_count", len(p.Dismissed))
@@ -129,11 +133,11 @@ func (g *generatorService) GenerateRecipes(ctx context.Context, p *generatorPara
 		if err != nil {
 			return nil, fmt.Errorf("failed to get staples: %w", err)
 		}
-		ingredients = lo.Filter(ingredients, func(ing ai.InputIngredient, _ int) bool {
-			// TODO make configurable?
-			return ing.Grade == nil || ing.Grade.Score > IngredientGradeCutoff
-		})
+		ingredients, _ = newStapleFilter(p.IngredientPreferences).apply(ingredients, true)
+		restrictions := householdMatcher(p.Household)
+		ingredients = filterRestrictedStaples(ingredients, restrictions)
 		ingMap := inputIngredientMap(ingredients)
+		budget := mealBudget(p.Budget, p.recipeCount())
 		replacmentCount := max(len(p.Dismissed), 1) // if no dismissed then just regenerate one and hope for better, if dismissed then regenerate all dismissed
 		plan, err := g.replacementMenuPlan(ctx, p, regenInstructions, replacmentCount)
 		if err != nil {
@@ -146,22 +150,21 @@ func (g *generatorService) GenerateRecipes(ctx context.Context, p *generatorPara
 			ctx, span := tracer.Start(ctx, "recipes.regenerate.single")
 			defer span.End()
 
-			recipe, err := g.aiClient.GenerateRecipe(ctx, plan.Instructions(), menuResponse)
+			recipe, err := g.generateRecipe(ctx, hash, plan.Cuisine, plan.Instructions(), menuResponse, ingMap, restrictions, budget)
 			if err != nil {
-				return nil, err
-			}
-			recipe.OriginHash = hash
-			enrichIngredientsMetadata(recipe.Ingredients, ingMap)
-			if err := g.saver.SaveRecipe(ctx, *recipe); err != nil {
-				return nil, err
+				return nil, g.leaveOutUnmetRestrictions(ctx, hash, err)
 			}
-			return g.critiqueAndMaybeRetryRecipe(ctx, hash, recipe, ingMap)
+			publishRecipe(hash, recipe)
+			return recipe, nil
 		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to generate replacement recipes with AI: %w", err)
 		}
 
-		recipes := append(lo.FromSlicePtr(results), p.Saved...)
+		recipes := append(lo.FromSlicePtr(lo.Compact(results)), p.Saved...)
+		if len(recipes) == 0 && len(results) > 0 {
+			return nil, fmt.Errorf("no replacement recipes fit the household's restrictions")
+		}
 
 		slog.InfoContext(ctx, "regenerated chat", "location", p.String(), "duration", time.Since(start), "hash", hash)
 		return &ai.ShoppingList{
@@ -179,30 +182,31 @@ func (g *generatorService) GenerateRecipes(ctx context.Context, p *generatorPara
 		return nil, fmt.Errorf("failed to get staples: %w", err)
 	}
 	ogCount := len(ingredients)
-	ingredients = lo.Filter(ingredients, func(ing ai.InputIngredient, _ int) bool {
-		// TODO make configurable?
-		return ing.Grade.GetScore() > IngredientGradeCutoff
-	})
+	staples := newStapleFilter(p.IngredientPreferences)
+	ingredients, filtered := staples.apply(ingredients, false)
+	restrictions := householdMatcher(p.Household)
+	ingredients = filterRestrictedStaples(ingredients, restrictions)
 	ingMap := inputIngredientMap(ingredients)
 
-	g.writeStatus(ctx, hash, status.Ingredients(ingredients, ogCount))
+	g.writeStatus(ctx, hash, status.Ingredients(ingredients, ogCount, filtered))
 	// Prompt caching requires byte-for-byte identical prefixes. Keep the ingredient
 	// TSV deterministic while the menu planner supplies variety itself.
-	slices.SortStableFunc(ingredients, func(a, b ai.InputIngredient) int {
-		gradeDiff := a.Grade.GetScore() - b.Grade.Score
-		if gradeDiff != 0 {
-			return gradeDiff
-		}
-
-		if result := strings.Compare(a.ProductID, b.ProductID); result != 0 {
-			return result
-		}
-		return strings.Compare(a.Description, b.Description)
-	})
-
-	menuPlanInstructions := []string{p.Directive, p.Instructions}
-
-	menuPlan, err := g.aiClient.CreateMenuPlan(ctx, p.Location, ingredients, menuPlanInstructions, p.Date, p.LastRecipes, 3)
+	slices.SortStableFunc(ingredients, staples.compareStaples)
+
+	budget := mealBudget(p.Budget, p.recipeCount())
+	menuPlanInstructions := append([]string{p.Directive}, tasteInstructions(p.Taste)...)
+	menuPlanInstructions = append(menuPlanInstructions, householdInstructions(p.Household)...)
+	menuPlanInstructions = append(menuPlanInstructions, pantryInstructions(p.Pantry)...)
+	menuPlanInstructions = append(menuPlanInstructions, ingredientPreferenceInstructions(p.IngredientPreferences)...)
+	menuPlanInstructions = append(menuPlanInstructions, neighborInstructions(p.Neighbors)...)
+	menuPlanInstructions = append(menuPlanInstructions, budgetInstructions(budget)...)
+	menuPlanInstructions = append(menuPlanInstructions, p.Instructions)
+	planCount := p.recipeCount()
+	if p.isWeekPlan() {
+		menuPlanInstructions = append(menuPlanInstructions, weekPlanInstructions(p)...)
+	}
+
+	menuPlan, err := g.aiClient.CreateMenuPlan(ctx, p.Location, ingredients, menuPlanInstructions, p.Date, p.LastRecipes, planCount)
 	if err != nil {
 		return nil, fmt.Errorf("failed to plan recipe variety: %w", err)
 	}
@@ -213,26 +217,27 @@ func (g *generatorService) GenerateRecipes(ctx context.Context, p *generatorPara
 	results, err := parallelism.MapWithErrors(menuPlan.Plans, func(plan ai.RecipePlan) (*ai.Recipe, error) {
 		ctx, span := tracer.Start(ctx, "recipes.generate.single")
 		defer span.End()
-		recipeInstructions := append([]string{p.Directive}, plan.Instructions()...)
-		recipe, err := g.aiClient.GenerateRecipe(ctx, recipeInstructions, menuResponse)
+		recipeInstructions := append([]string{p.Directive}, householdInstructions(p.Household)...)
+		recipeInstructions = append(recipeInstructions, budgetInstructions(budget)...)
+		recipeInstructions = append(recipeInstructions, plan.Instructions()...)
+		recipe, err := g.generateRecipe(ctx, hash, plan.Cuisine, recipeInstructions, menuResponse, ingMap, restrictions, budget)
 		if err != nil {
-			return nil, err
+			return nil, g.leaveOutUnmetRestrictions(ctx, hash, err)
 		}
-		// would prefer to do this deeper down in client like response id but have to pass in the hash
-		recipe.OriginHash = hash
-
-		enrichIngredientsMetadata(recipe.Ingredients, ingMap)
-		if err := g.saver.SaveRecipe(ctx, *recipe); err != nil {
-			return nil, err
-		}
-		return g.critiqueAndMaybeRetryRecipe(ctx, hash, recipe, ingMap)
+		// the events stream shows each recipe without waiting on the slowest one.
+		publishRecipe(hash, recipe)
+		return recipe, nil
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate recipes with AI: %w", err)
 	}
+	recipes := lo.FromSlicePtr(lo.Compact(results))
+	if len(recipes) == 0 && len(results) > 0 {
+		return nil, fmt.Errorf("no recipes fit the household's restrictions")
+	}
 	slog.InfoContext(ctx, "generated chat", "location", p.String(), "duration", time.Since(start), "hash", hash)
 	return &ai.ShoppingList{
-		Recipes: lo.FromSlicePtr(results),
+		Recipes: recipes,
 		Plan:    menuPlan,
 	}, nil
 }
@@ -304,6 +309,53 @@ func regenerateInstructions(p *generatorParams) []string {
 	return instructions
 }
 
+// generateRecipe turns one plan into a saved recipe, fixing household restriction
+// violations before and after the critique pass and asking once for a cheaper
+// version when it costs more than budget.
+func (g *generatorService) generateRecipe(ctx context.Context, hash, cuisine string, instructions []string, menuResponse ai.ResponseRef, ingMap map[string]ai.InputIngredient, restrictions dietary.Matcher, budget float64) (*ai.Recipe, error) {
+	recipe, err := g.aiClient.GenerateRecipe(ctx, instructions, menuResponse)
+	if err != nil {
+		return nil, err
+	}
+	// would prefer to do this deeper down in client like response id but have to pass in the hash
+	recipe.OriginHash = hash
+	recipe.Cuisine = cuisine
+
+	enrichRecipe(recipe, ingMap)
+	recipe, err = g.enforceRestrictions(ctx, hash, recipe, ingMap, restrictions)
+	if err != nil {
+		return nil, err
+	}
+	cheaper, err := g.retryOverBudget(ctx, hash, recipe, ingMap, budget)
+	if err != nil {
+		return nil, err
+	}
+	if cheaper != recipe {
+		// the cheaper rewrite still has to clear the household's restrictions.
+		if recipe, err = g.enforceRestrictions(ctx, hash, cheaper, ingMap, restrictions); err != nil {
+			return nil, err
+		}
+	}
+	if err := g.saver.SaveRecipe(ctx, *recipe); err != nil {
+		return nil, err
+	}
+	critiqued, err := g.critiqueAndMaybeRetryRecipe(ctx, hash, recipe, ingMap)
+	if err != nil || critiqued == recipe {
+		return critiqued, err
+	}
+	// the critique retry can reintroduce something we just took out.
+	fixed, err := g.enforceRestrictions(ctx, hash, critiqued, ingMap, restrictions)
+	if err != nil {
+		return nil, err
+	}
+	if fixed != critiqued {
+		if err := g.saver.SaveRecipe(ctx, *fixed); err != nil {
+			return nil, err
+		}
+	}
+	return fixed, nil
+}
+
 func (g *generatorService) critiqueAndMaybeRetryRecipe(ctx context.Context, hash string, recipe *ai.Recipe, ingMap map[string]ai.InputIngredient) (*ai.Recipe, error) {
 	ctx, span := tracer.Start(ctx, "recipes.critique.recipe")
 	defer span.End()
@@ -332,9 +384,10 @@ func (g *generatorService) critiqueAndMaybeRetryRecipe(ctx context.Context, hash
 	if err != nil {
 		return nil, fmt.Errorf("failed to regenerate recipe %q from critique feedback: %w", recipe.Title, err)
 	}
-	enrichIngredientsMetadata(retry.Ingredients, ingMap)
+	enrichRecipe(retry, ingMap)
 	retry.OriginHash = hash
 	retry.ParentHash = recipe.ComputeHash()
+	retry.Cuisine = recipe.Cuisine
 	if err := g.saver.SaveRecipe(ctx, *retry); err != nil {
 		return nil, err
 	}
@@ -353,6 +406,7 @@ func enrichIngredientsMetadata(ingredients []ai.Ingredient, byProductID map[stri
 		}
 		ingredient.ProductID = strings.TrimSpace(input.ProductID)
 		ingredient.AisleNumber = strings.TrimSpace(input.AisleNumber)
+		ingredient.LocationID = input.LocationID
 		ingredient.Price = inputIngredientDisplayPrice(input)
 	}
 }
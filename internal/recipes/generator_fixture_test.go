@@ -0,0 +1,131 @@
+package recipes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"careme/internal/ai"
+	"careme/internal/ai/fixture"
+	"careme/internal/cache"
+	"careme/internal/config"
+	ingredientgrading "careme/internal/ingredients/grading"
+	"careme/internal/locations"
+	"careme/internal/recipes/critique"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// goldenFixtures are real OpenAI and OpenRouter exchanges replayed without the
+// network. Record them again after changing what the generator sends with
+// AI_FIXTURES=record and real AI_API_KEY and OPENROUTER_API_KEY.
+const goldenFixtures = "testdata/ai-fixtures"
+
+func goldenTransport(t *testing.T) http.RoundTripper {
+	t.Helper()
+	if fixture.Mode(os.Getenv("AI_FIXTURES")) != fixture.Record {
+		if _, err := os.Stat(goldenFixtures); errors.Is(err, fs.ErrNotExist) {
+			t.Skip("no golden AI fixtures recorded yet")
+		}
+		return fixture.NewTransport(fixture.Replay, goldenFixtures, offlineTransport(t), ai.VolatilePrompts...)
+	}
+	require.NoError(t, os.RemoveAll(goldenFixtures))
+	return fixture.NewTransport(fixture.Record, goldenFixtures, http.DefaultTransport, ai.VolatilePrompts...)
+}
+
+// recordingKey is the real key from env when recording. Keys aren't part of a
+// recording, so replays get by with a placeholder.
+func recordingKey(t *testing.T, env string) string {
+	t.Helper()
+	if fixture.Mode(os.Getenv("AI_FIXTURES")) != fixture.Record {
+		return "test-key"
+	}
+	key := os.Getenv(env)
+	require.NotEmpty(t, key, "%s is needed to record golden fixtures", env)
+	return key
+}
+
+// offlineTransport fails any request that would leave the process.
+func offlineTransport(t *testing.T) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		t.Errorf("unexpected network request to %s", req.URL)
+		return nil, fmt.Errorf("network disabled in tests")
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// gradingStaplesService grades its ingredients like the cached staples service does.
+type gradingStaplesService struct {
+	grader interface {
+		GradeIngredients(ctx context.Context, ingredients []ai.InputIngredient) ([]ai.InputIngredient, error)
+	}
+}
+
+func (s gradingStaplesService) FetchStaples(ctx context.Context, _ *GeneratorParams) ([]ai.InputIngredient, error) {
+	chicken, beans := float32(6.99), float32(2.49)
+	return s.grader.GradeIngredients(ctx, []ai.InputIngredient{
+		{ProductID: "chicken-1", Description: "Chicken Thighs", PriceRegular: &chicken},
+		{ProductID: "beans-1", Description: "Green Beans", PriceRegular: &beans},
+	})
+}
+
+func (gradingStaplesService) FetchWines(context.Context, string, []string, time.Time) ([]ai.InputIngredient, error) {
+	price := float32(14.99)
+	return []ai.InputIngredient{{ProductID: "wine-1", Description: "Dry Riesling", PriceRegular: &price}}, nil
+}
+
+type fixtureRun struct {
+	list *ai.ShoppingList
+	wine *ai.WineSelection
+}
+
+// generateWithFixtures runs the real generator, critiquer and grader through a fixture transport.
+func generateWithFixtures(t *testing.T, transport http.RoundTripper) fixtureRun {
+	t.Helper()
+	cacheStore := cache.NewInMemoryCache()
+	httpClient := &http.Client{Transport: transport}
+	cfg := &config.Config{
+		AI:                config.AIConfig{APIKey: recordingKey(t, "AI_API_KEY")},
+		OpenRouter:        config.OpenRouterConfig{APIKey: recordingKey(t, "OPENROUTER_API_KEY")},
+		IngredientGrading: config.IngredientGradingConfig{Enable: true},
+	}
+	critiquer := critique.NewManager(cfg, cacheStore, httpClient)
+	staples := gradingStaplesService{grader: ingredientgrading.NewManager(cfg, cacheStore, httpClient)}
+	g, err := NewGenerator(ai.NewClient(cfg.AI.APIKey, "", httpClient, nil), critiquer, staples, StatusStore(cacheStore), IO(cacheStore))
+	require.NoError(t, err)
+
+	date := time.Date(2026, time.May, 11, 0, 0, 0, 0, time.UTC)
+	location := &locations.Location{ID: "70001001", Name: "Test Store", State: "WA"}
+	p := DefaultParams(location, date)
+	p.Instructions = "weeknight dinners"
+	list, err := g.GenerateRecipes(t.Context(), p)
+	require.NoError(t, err)
+	require.NotEmpty(t, list.Recipes)
+	wine, err := g.PickAWine(t.Context(), location.ID, list.Recipes[0], date)
+	require.NoError(t, err)
+	critiquer.Wait()
+	return fixtureRun{list: list, wine: wine}
+}
+
+func TestGeneratorReplaysGoldenAIFixtures(t *testing.T) {
+	run := generateWithFixtures(t, goldenTransport(t))
+
+	for _, recipe := range run.list.Recipes {
+		assert.NotEmpty(t, recipe.Title)
+		assert.NotEmpty(t, recipe.Ingredients)
+		assert.NotEmpty(t, recipe.Instructions)
+	}
+	require.NotEmpty(t, run.wine.Wines)
+	assert.Equal(t, "Dry Riesling", run.wine.Wines[0].Name, "the only wine on offer")
+}
@@ -162,6 +162,10 @@ func (c *captureWineQuestionAIClient) PickWine(ctx context.Context, recipe ai.Re
 	}, nil
 }
 
+func (c *captureWineQuestionAIClient) StructureRecipe(ctx context.Context, text string) (*ai.Recipe, error) {
+	panic("unexpected call to StructureRecipe")
+}
+
 func (c *captureWineQuestionAIClient) Ready(ctx context.Context) error {
 	return nil
 }
@@ -235,6 +239,10 @@ func (c *captureRegenerateAIClient) PickWine(ctx context.Context, recipe ai.Reci
 	panic("unexpected call to PickWine")
 }
 
+func (c *captureRegenerateAIClient) StructureRecipe(ctx context.Context, text string) (*ai.Recipe, error) {
+	panic("unexpected call to StructureRecipe")
+}
+
 func (c *captureRegenerateAIClient) Ready(ctx context.Context) error {
 	return nil
 }
@@ -292,6 +300,10 @@ func (c *captureGenerateAIClient) PickWine(ctx context.Context, recipe ai.Recipe
 	panic("unexpected call to PickWine")
 }
 
+func (c *captureGenerateAIClient) StructureRecipe(ctx context.Context, text string) (*ai.Recipe, error) {
+	panic("unexpected call to StructureRecipe")
+}
+
 func (c *captureGenerateAIClient) Ready(ctx context.Context) error {
 	return nil
 }
@@ -417,6 +429,10 @@ func (c *sequenceAIClient) PickWine(ctx context.Context, recipe ai.Recipe, wines
 	panic("unexpected call to PickWine")
 }
 
+func (c *sequenceAIClient) StructureRecipe(ctx context.Context, text string) (*ai.Recipe, error) {
+	panic("unexpected call to StructureRecipe")
+}
+
 func (c *sequenceAIClient) Ready(ctx context.Context) error {
 	return nil
 }
@@ -0,0 +1,136 @@
+package recipes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"careme/internal/ai"
+	"careme/internal/dietary"
+	utypes "careme/internal/users/types"
+
+	"github.com/samber/lo"
+)
+
+// how many times we ask the model to fix a recipe that breaks an allergen or diet
+// before giving up on it.
+const maxRestrictionRetries = 2
+
+// unmetRestrictionsError is a recipe that still broke the household's restrictions
+// after maxRestrictionRetries. Generation leaves it out rather than failing the list.
+type unmetRestrictionsError struct {
+	Title     string
+	Conflicts []dietary.Conflict
+}
+
+func (e *unmetRestrictionsError) Error() string {
+	return fmt.Sprintf("recipe %q still conflicts with household restrictions: %v", e.Title, e.Conflicts)
+}
+
+func householdSignature(h utypes.Household) string {
+	h = h.Normalize()
+	return "household" + strconv.Itoa(h.Members) +
+		"|" + strings.Join(h.Allergens, ",") +
+		"|" + strings.Join(h.Diets, ",") +
+		"|" + strings.Join(h.Dislikes, ",")
+}
+
+func householdMatcher(h utypes.Household) dietary.Matcher {
+	return dietary.NewMatcher(h.Allergens, h.Diets, h.Dislikes)
+}
+
+// householdInstructions turns the structured profile into prompt text. The matcher
+// still checks the output because the model doesn't always listen.
+func householdInstructions(h utypes.Household) []string {
+	var instructions []string
+	if h.Members > 0 {
+		instructions = append(instructions, fmt.Sprintf("Each recipe should serve %d people.", h.Members))
+	}
+	if len(h.Allergens) > 0 {
+		instructions = append(instructions, "Household allergies, never use these or anything containing them: "+strings.Join(h.Allergens, ", ")+".")
+	}
+	if len(h.Diets) > 0 {
+		instructions = append(instructions, "Every recipe must be: "+strings.Join(h.Diets, ", ")+".")
+	}
+	if len(h.Dislikes) > 0 {
+		instructions = append(instructions, "Avoid these disliked ingredients: "+strings.Join(h.Dislikes, ", ")+".")
+	}
+	return instructions
+}
+
+// filterRestrictedStaples drops staples the household can't or won't eat so the
+// menu planner never sees them.
+func filterRestrictedStaples(ingredients []ai.InputIngredient, m dietary.Matcher) []ai.InputIngredient {
+	if m.Empty() {
+		return ingredients
+	}
+	return lo.Filter(ingredients, func(ing ai.InputIngredient, _ int) bool {
+		return len(m.Conflicts(ing.Description)) == 0
+	})
+}
+
+// recipeConflicts only looks at ingredient names. Instructions often say things
+// like "skip the nuts" and would trip the matcher for no reason.
+func recipeConflicts(recipe ai.Recipe, m dietary.Matcher) []dietary.Conflict {
+	var found []dietary.Conflict
+	for _, ing := range recipe.Ingredients {
+		for _, c := range m.HardConflicts(ing.Name) {
+			if !lo.Contains(found, c) {
+				found = append(found, c)
+			}
+		}
+	}
+	return found
+}
+
+func restrictionRetryInstructions(conflicts []dietary.Conflict) []string {
+	names := lo.Map(conflicts, func(c dietary.Conflict, _ int) string { return c.String() })
+	return []string{
+		"This recipe uses ingredients the household cannot eat: " + strings.Join(names, ", ") + ".",
+		"Rewrite the recipe without them or anything derived from them, keeping the dish as close as possible otherwise.",
+	}
+}
+
+// enforceRestrictions regenerates recipe until its ingredients clear the household's
+// allergens and diets. Regenerated recipes are not saved; callers save what comes back,
+// so a retry keeps the parent of the recipe it replaces rather than pointing at a draft.
+func (g *generatorService) enforceRestrictions(ctx context.Context, hash string, recipe *ai.Recipe, ingMap map[string]ai.InputIngredient, m dietary.Matcher) (*ai.Recipe, error) {
+	for attempt := 0; ; attempt++ {
+		conflicts := recipeConflicts(*recipe, m)
+		if len(conflicts) == 0 {
+			return recipe, nil
+		}
+		if attempt == maxRestrictionRetries {
+			return nil, &unmetRestrictionsError{Title: recipe.Title, Conflicts: conflicts}
+		}
+		if strings.TrimSpace(recipe.ResponseID) == "" {
+			return nil, fmt.Errorf("recipe %q is missing response ID for restriction retry", recipe.Title)
+		}
+		g.writeStatus(ctx, hash, "Reworking "+recipe.Title+" for household restrictions\n")
+		retry, err := g.aiClient.Regenerate(ctx, restrictionRetryInstructions(conflicts), recipe.ResponseRef())
+		if err != nil {
+			return nil, fmt.Errorf("failed to regenerate recipe %q for household restrictions: %w", recipe.Title, err)
+		}
+		enrichRecipe(retry, ingMap)
+		retry.OriginHash = hash
+		retry.ParentHash = recipe.ParentHash
+		retry.Cuisine = recipe.Cuisine
+		recipe = retry
+	}
+}
+
+// leaveOutUnmetRestrictions reports a recipe that couldn't be made to fit the
+// household in the status and returns nil so the rest of the list still
+// generates. Any other error comes back as is.
+func (g *generatorService) leaveOutUnmetRestrictions(ctx context.Context, hash string, err error) error {
+	var unmet *unmetRestrictionsError
+	if !errors.As(err, &unmet) {
+		return err
+	}
+	slog.WarnContext(ctx, "leaving out recipe that breaks household restrictions", "hash", hash, "title", unmet.Title, "conflicts", unmet.Conflicts)
+	g.writeStatus(ctx, hash, "Left out "+unmet.Title+", which kept calling for something the household can't eat\n")
+	return nil
+}
@@ -0,0 +1,136 @@
+package recipes
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"careme/internal/ai"
+	"careme/internal/locations"
+	utypes "careme/internal/users/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHash_HouseholdOnlyChangesHashWhenSet(t *testing.T) {
+	date := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	p := DefaultParams(&locations.Location{ID: "70004001"}, date)
+	before := p.Hash()
+
+	p.Household = utypes.Household{}
+	assert.Equal(t, before, p.Hash(), "empty household must keep existing hashes")
+
+	p.Household = utypes.Household{Members: 4, Allergens: []string{"Tree Nuts"}}
+	withHousehold := p.Hash()
+	assert.NotEqual(t, before, withHousehold)
+
+	p.Household = utypes.Household{Members: 4, Allergens: []string{"tree nut"}}
+	assert.Equal(t, withHousehold, p.Hash(), "equivalent households should hash the same")
+}
+
+func TestGenerateRecipes_HouseholdFiltersStaplesAndInstructsPlanner(t *testing.T) {
+	grade := &ai.IngredientGrade{Score: 8}
+	staples := fixedStaplesService{ingredients: []ai.InputIngredient{
+		{ProductID: "1", Description: "Organic Walnut Halves", Grade: grade},
+		{ProductID: "2", Description: "Boneless Chicken Thighs", Grade: grade},
+		{ProductID: "3", Description: "Broccoli Crowns", Grade: grade},
+		{ProductID: "4", Description: "Cremini Mushrooms", Grade: grade},
+	}}
+	aiStub := &captureGenerateAIClient{}
+	params := DefaultParams(&locations.Location{ID: "70004001", Name: "Store"}, time.Now())
+	params.Household = utypes.Household{
+		Members:   4,
+		Allergens: []string{"tree nuts"},
+		Diets:     []string{"vegetarian"},
+		Dislikes:  []string{"mushrooms"},
+	}
+	g := newTestGenerator(t, aiStub, nil, staples, noopstatuswriter{}, nil)
+
+	_, err := g.GenerateRecipes(t.Context(), params)
+	require.NoError(t, err)
+
+	require.Len(t, aiStub.ingredients, 1)
+	assert.Equal(t, "Broccoli Crowns", aiStub.ingredients[0].Description)
+	require.Len(t, aiStub.instructions, 1)
+	assert.Contains(t, aiStub.instructions[0], "Each recipe should serve 4 people.")
+	assert.Contains(t, aiStub.instructions[0], "Household allergies, never use these or anything containing them: tree nuts.")
+	assert.Contains(t, aiStub.instructions[0], "Every recipe must be: vegetarian.")
+	assert.Contains(t, aiStub.instructions[0], "Avoid these disliked ingredients: mushrooms.")
+}
+
+func TestGenerateRecipes_RegeneratesRecipesThatBreakAllergens(t *testing.T) {
+	initial := ai.Recipe{
+		Title:       "Pesto Pasta",
+		ResponseID:  "resp-initial",
+		Ingredients: []ai.Ingredient{{Name: "pine nuts"}, {Name: "basil"}},
+	}
+	fixed := ai.Recipe{
+		Title:       "Sunflower Pesto Pasta",
+		ResponseID:  "resp-fixed",
+		Ingredients: []ai.Ingredient{{Name: "sunflower seeds"}, {Name: "basil"}},
+	}
+	aiStub := &sequenceAIClient{
+		generateResponses:   []*ai.ShoppingList{{Recipes: []ai.Recipe{initial}}},
+		regenerateResponses: []*ai.Recipe{&fixed},
+	}
+	params := DefaultParams(&locations.Location{ID: "70004001", Name: "Store"}, time.Now())
+	params.Household = utypes.Household{Allergens: []string{"tree nuts"}}
+	saver := &captureRecipeSaver{}
+	g := newTestGenerator(t, aiStub, nil, fixedStaplesService{}, noopstatuswriter{}, saver)
+
+	got, err := g.GenerateRecipes(t.Context(), params)
+	require.NoError(t, err)
+	require.Len(t, got.Recipes, 1)
+	assert.Equal(t, "Sunflower Pesto Pasta", got.Recipes[0].Title)
+	assert.Empty(t, got.Recipes[0].ParentHash, "the rejected draft was never saved, so it can't be the parent")
+	assert.Equal(t, params.Hash(), got.Recipes[0].OriginHash)
+	assert.Equal(t, []string{"resp-initial"}, aiStub.regenerateResponseIDs)
+	assert.True(t, strings.Contains(aiStub.regenerateInstructions[0][0], "pine nut (tree nuts allergy)"), aiStub.regenerateInstructions[0])
+	assert.Equal(t, []string{"Sunflower Pesto Pasta"}, saver.titles(), "rejected recipes should never be saved")
+}
+
+func TestGenerateRecipes_LeavesOutRecipesThatKeepBreakingAllergens(t *testing.T) {
+	withShrimp := func(id string) ai.Recipe {
+		return ai.Recipe{Title: "Shrimp Tacos", ResponseID: id, Ingredients: []ai.Ingredient{{Name: "shrimp"}}}
+	}
+	initial := withShrimp("resp-1")
+	again := withShrimp("resp-2")
+	still := withShrimp("resp-3")
+	safe := ai.Recipe{Title: "Chicken Tacos", ResponseID: "resp-chicken", Ingredients: []ai.Ingredient{{Name: "chicken thighs"}}}
+	aiStub := &sequenceAIClient{
+		generateResponses:   []*ai.ShoppingList{{Recipes: []ai.Recipe{initial, safe}}},
+		regenerateResponses: []*ai.Recipe{&again, &still},
+	}
+	params := DefaultParams(&locations.Location{ID: "70004001", Name: "Store"}, time.Now())
+	params.Household = utypes.Household{Allergens: []string{"shellfish"}}
+	statuses := &statusCounter{}
+	g := newTestGenerator(t, aiStub, nil, fixedStaplesService{}, statuses, nil)
+
+	got, err := g.GenerateRecipes(t.Context(), params)
+	require.NoError(t, err)
+	require.Len(t, got.Recipes, 1)
+	assert.Equal(t, "Chicken Tacos", got.Recipes[0].Title)
+	assert.Equal(t, maxRestrictionRetries, aiStub.regenerateCalls)
+	assert.Contains(t, statuses.status, "Left out Shrimp Tacos, which kept calling for something the household can't eat\n")
+}
+
+func TestGenerateRecipes_FailsWhenNoRecipeFitsRestrictions(t *testing.T) {
+	withShrimp := func(id string) ai.Recipe {
+		return ai.Recipe{Title: "Shrimp Tacos", ResponseID: id, Ingredients: []ai.Ingredient{{Name: "shrimp"}}}
+	}
+	initial := withShrimp("resp-1")
+	again := withShrimp("resp-2")
+	still := withShrimp("resp-3")
+	aiStub := &sequenceAIClient{
+		generateResponses:   []*ai.ShoppingList{{Recipes: []ai.Recipe{initial}}},
+		regenerateResponses: []*ai.Recipe{&again, &still},
+	}
+	params := DefaultParams(&locations.Location{ID: "70004001", Name: "Store"}, time.Now())
+	params.Household = utypes.Household{Allergens: []string{"shellfish"}}
+	g := newTestGenerator(t, aiStub, nil, fixedStaplesService{}, noopstatuswriter{}, nil)
+
+	_, err := g.GenerateRecipes(t.Context(), params)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no recipes fit the household's restrictions")
+}
@@ -6,9 +6,8 @@ import (
 	"fmt"
 	"html/template"
 	"io"
-	"math"
 	"net/http"
-	"regexp"
+	"net/url"
 	"slices"
 	"strconv"
 	"strings"
@@ -19,10 +18,14 @@ import (
 	"careme/internal/httpx"
 	"careme/internal/locations"
 	"careme/internal/recipes/critique"
+	"careme/internal/recipes/export"
 	"careme/internal/recipes/feedback"
+	"careme/internal/recipes/similar"
 	"careme/internal/seasons"
 	"careme/internal/templates"
 	utypes "careme/internal/users/types"
+
+	"github.com/samber/lo"
 )
 
 type recipeImageView struct {
@@ -56,14 +59,17 @@ type shoppingRecipeView struct {
 }
 
 type shoppingListGroup struct {
-	Aisle string
-	Items []*ai.Ingredient
+	// Store is only set on lists that draw on more than one store.
+	Store        string
+	StoreHeading bool
+	Aisle        string
+	Items        []*ai.Ingredient
 }
 
 // FormatShoppingListHTMLForHashWithHelp renders the multi-recipe shopping list view for a specific hash.
 // should shove wine recs into recipe instead of having them seperate.
 func FormatShoppingListHTMLForHashWithHelp(ctx context.Context, p *generatorParams, l ai.ShoppingList,
-	wineRecommendations map[string]*ai.WineSelection, recipeImages map[string]bool, currentUser *utypes.User, hash string, selection recipeSelection, helpMessage, pendingInstructions string, writer http.ResponseWriter,
+	wineRecommendations map[string]*ai.WineSelection, recipeImages map[string]bool, currentUser *utypes.User, pantry utypes.Pantry, hash string, selection recipeSelection, checklist shoppingChecklist, helpMessage, pendingInstructions string, servings int, writer http.ResponseWriter,
 ) {
 	serverSignedIn := currentUser != nil
 	instructions := strings.TrimSpace(p.Instructions)
@@ -75,6 +81,7 @@ func FormatShoppingListHTMLForHashWithHelp(ctx context.Context, p *generatorPara
 	hasSavedRecipes := false
 	for _, recipe := range l.Recipes {
 		recipeHash := recipe.ComputeHash()
+		recipe = scaleRecipe(recipe, servings) // after hashing so links still point at the stored recipe
 		wineRecommendation := wineRecommendations[recipeHash]
 		displayIngredients := ingredientsForDisplay(recipe.Ingredients, wineRecommendation)
 		saved := selection.IsSaved(recipeHash)
@@ -96,6 +103,7 @@ func FormatShoppingListHTMLForHashWithHelp(ctx context.Context, p *generatorPara
 	}
 	data := struct {
 		Location             locations.Location
+		ExtraLocations       []*locations.Location
 		Date                 string
 		DateDisplay          string
 		MetaDescription      string
@@ -106,7 +114,8 @@ func FormatShoppingListHTMLForHashWithHelp(ctx context.Context, p *generatorPara
 		HelpMessage          string
 		Hash                 string
 		Recipes              []shoppingRecipeView
-		ShoppingList         []shoppingListGroup
+		ShoppingList         []shoppingListSection
+		OnHand               []*ai.Ingredient
 		HasSavedRecipes      bool
 		Style                seasons.Style
 		ServerSignedIn       bool
@@ -114,8 +123,11 @@ func FormatShoppingListHTMLForHashWithHelp(ctx context.Context, p *generatorPara
 		AuthReturnTo         string
 		UseTodaysIngredients bool
 		AdminURL             string
+		Servings             int
+		WeekPlanURL          string
 	}{
 		Location:             *p.Location,
+		ExtraLocations:       p.ExtraLocations,
 		Date:                 p.Date.Format("2006-01-02"),
 		DateDisplay:          p.Date.Format("January 2, 2006"),
 		MetaDescription:      shoppingListMetaDescription(l.Recipes, p.Location.Name, p.Date.Format("2006-01-02")),
@@ -126,7 +138,6 @@ func FormatShoppingListHTMLForHashWithHelp(ctx context.Context, p *generatorPara
 		HelpMessage:          strings.TrimSpace(helpMessage),
 		Hash:                 hash,
 		Recipes:              recipeViews,
-		ShoppingList:         shoppingListForDisplay(combinedIngredients),
 		HasSavedRecipes:      hasSavedRecipes,
 		Style:                seasons.GetCurrentStyle(),
 		ServerSignedIn:       serverSignedIn,
@@ -134,7 +145,13 @@ func FormatShoppingListHTMLForHashWithHelp(ctx context.Context, p *generatorPara
 		AuthReturnTo:         "/recipes?h=" + hash,
 		UseTodaysIngredients: shoppingListIsOlderThanFreshIngredientsWindow(ctx, p),
 		AdminURL:             "/admin/mealplan/" + hash,
+		Servings:             servings,
+	}
+	if p.isWeekPlan() {
+		data.WeekPlanURL = weekPlanURL(hash)
 	}
+	toBuy, onHand := applyPantry(shoppingListForStores(p, combinedIngredients), pantry)
+	data.ShoppingList, data.OnHand = withChecklist(markStoreHeadings(toBuy), checklist), onHand
 
 	httpx.SetHTMLContentType(writer)
 	if err := templates.ShoppingList.Execute(writer, data); err != nil {
@@ -167,12 +184,19 @@ func shoppingListMetaDescription(recipes []ai.Recipe, locationName, date string)
 // FormatRecipeHTML renders a single recipe view with a browser session id for analytics.
 func FormatRecipeHTML(ctx context.Context, p *generatorParams, recipe ai.Recipe, saved bool,
 	currentUser *utypes.User, critiqueScore *int, hasRecipeImage bool, thread []RecipeThreadEntry,
-	fb feedback.Feedback, wineRecommendation *ai.WineSelection, writer http.ResponseWriter,
+	fb feedback.Feedback, wineRecommendation *ai.WineSelection, moreLikeThis []similar.Match, servings int, writer http.ResponseWriter,
 ) {
 	slices.SortFunc(thread, func(i, j RecipeThreadEntry) int {
 		return j.CreatedAt.Compare(i.CreatedAt)
 	})
 	recipeHash := recipe.ComputeHash()
+	if recipe.Nutrition == nil {
+		// saved before nutrition was computed at generation
+		recipe.ComputeNutrition(recipeServings(recipe))
+	}
+	// search engines and exports get the recipe as written, not scaled.
+	exported := export.Recipe{Recipe: recipe, Hash: recipeHash, HasImage: hasRecipeImage}
+	recipe = scaleRecipe(recipe, servings)
 	activeResponseID := recipe.ResponseID
 	if threadResponseID := latestThreadResponseID(thread); threadResponseID != "" {
 		activeResponseID = threadResponseID
@@ -203,6 +227,11 @@ func FormatRecipeHTML(ctx context.Context, p *generatorParams, recipe ai.Recipe,
 		RecipeCritiqueNeedsCare bool
 		MinimumRecipeScore      int
 		AdminURL                string
+		Servings                int
+		Scaled                  bool
+		Export                  export.Recipe
+		SourceHost              string
+		MoreLikeThis            []similar.Match
 	}{
 		Location:                *p.Location,
 		Date:                    p.Date.Format("2006-01-02"),
@@ -228,6 +257,11 @@ func FormatRecipeHTML(ctx context.Context, p *generatorParams, recipe ai.Recipe,
 		RecipeCritiqueNeedsCare: critiqueScore != nil && *critiqueScore < critique.MinimumRecipeScore,
 		MinimumRecipeScore:      critique.MinimumRecipeScore,
 		AdminURL:                "/admin/prompt/recipe/" + recipeHash,
+		Servings:                recipeServings(recipe),
+		Scaled:                  servings > 0,
+		Export:                  exported,
+		SourceHost:              sourceHost(recipe.SourceURL),
+		MoreLikeThis:            moreLikeThis,
 	}
 
 	httpx.SetHTMLContentType(writer)
@@ -236,6 +270,15 @@ func FormatRecipeHTML(ctx context.Context, p *generatorParams, recipe ai.Recipe,
 	}
 }
 
+// sourceHost is where an imported recipe came from, like "seriouseats.com".
+func sourceHost(sourceURL string) string {
+	u, err := url.Parse(sourceURL)
+	if err != nil || u.Hostname() == "" {
+		return sourceURL
+	}
+	return strings.TrimPrefix(u.Hostname(), "www.")
+}
+
 func recipeImageData(recipeHash string, hasImage bool, outOfBand bool) recipeImageView {
 	return recipeImageView{
 		HasImage:  hasImage,
@@ -270,6 +313,42 @@ func FormatRecipeThreadHTML(thread []RecipeThreadEntry, signedIn bool, response
 	}
 }
 
+type substituteView struct {
+	ProductID string
+	Name      string
+	Size      string
+	Price     string
+	Deal      bool
+}
+
+// FormatSubstitutesHTML renders the substitutes on offer for one ingredient for HTMX swaps.
+func FormatSubstitutesHTML(recipeHash string, index int, missing ai.Ingredient, substitutes []ai.InputIngredient, writer http.ResponseWriter) {
+	data := struct {
+		RecipeHash  string
+		Index       int
+		Missing     string
+		Substitutes []substituteView
+	}{
+		RecipeHash: recipeHash,
+		Index:      index,
+		Missing:    missing.Name,
+		Substitutes: lo.Map(substitutes, func(ing ai.InputIngredient, _ int) substituteView {
+			return substituteView{
+				ProductID: ing.ProductID,
+				Name:      strings.TrimSpace(ing.Brand + " " + ing.Description),
+				Size:      ing.Size,
+				Price:     inputIngredientDisplayPrice(ing),
+				Deal:      ing.Deal(),
+			}
+		}),
+	}
+
+	httpx.SetHTMLContentType(writer)
+	if err := templates.Recipe.ExecuteTemplate(writer, "recipe_substitutes", data); err != nil {
+		http.Error(writer, "recipe substitutes template error: "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
 func RenderShoppingFinalizeControlsHTML(hash string, writer io.Writer) error {
 	data := struct {
 		Hash            string
@@ -340,6 +419,7 @@ func FormatMail(p *generatorParams, l ai.ShoppingList, publicOrigin string, unsu
 		Recipes        []ai.Recipe
 		Domain         string
 		UnsubscribeURL string
+		WeekPlanURL    string
 		Style          seasons.Style
 	}{
 		Location:       *p.Location,
@@ -350,6 +430,9 @@ func FormatMail(p *generatorParams, l ai.ShoppingList, publicOrigin string, unsu
 		UnsubscribeURL: unsubscribeURL,
 		Style:          seasons.GetCurrentStyle(),
 	}
+	if p.isWeekPlan() {
+		data.WeekPlanURL = publicOrigin + weekPlanURL(data.Hash)
+	}
 
 	return templates.Mail.Execute(writer, data)
 }
@@ -368,6 +451,7 @@ func shoppingListForDisplay(ingredients []ai.Ingredient) []shoppingListGroup {
 			item := &ai.Ingredient{
 				ProductID:   strings.TrimSpace(ingredient.ProductID),
 				AisleNumber: strings.TrimSpace(ingredient.AisleNumber),
+				LocationID:  ingredient.LocationID,
 				Name:        ingredient.Name, // show non normalized
 				Quantity:    strings.TrimSpace(ingredient.Quantity),
 				Price:       strings.TrimSpace(ingredient.Price),
@@ -397,8 +481,6 @@ func shoppingListForDisplay(ingredients []ai.Ingredient) []shoppingListGroup {
 	return groups
 }
 
-var shoppingQtyWithSuffixPattern = regexp.MustCompile(`^\s*(\d+(?:\.\d+)?)\s+(.+?)\s*$`)
-
 func mergeShoppingQuantities(existing string, incoming string) string {
 	existing = strings.TrimSpace(existing)
 	incoming = strings.TrimSpace(incoming)
@@ -412,45 +494,25 @@ func mergeShoppingQuantities(existing string, incoming string) string {
 	existingNumber, existingSuffix, okExisting := parseShoppingQuantity(existing)
 	incomingNumber, incomingSuffix, okIncoming := parseShoppingQuantity(incoming)
 	if okExisting && okIncoming && normalizeShoppingQuantitySuffix(existingSuffix) == normalizeShoppingQuantitySuffix(incomingSuffix) {
-		return formatShoppingQuantity(existingNumber+incomingNumber, existingSuffix)
+		return ai.FormatAmount(existingNumber+incomingNumber) + " " + existingSuffix
 	}
 	return existing + ", " + incoming
 }
 
+// parseShoppingQuantity only merges quantities that carry a unit; bare counts like
+// "1" and "2" are too ambiguous to add up.
 func parseShoppingQuantity(raw string) (float64, string, bool) {
-	match := shoppingQtyWithSuffixPattern.FindStringSubmatch(beforeFirstComma(raw))
-	if len(match) != 3 {
-		return 0, "", false
-	}
-	value, err := strconv.ParseFloat(match[1], 64)
-	if err != nil {
-		return 0, "", false
-	}
-	suffix := strings.TrimSpace(match[2])
-	if suffix == "" {
+	value, suffix, ok := ai.ParseQuantity(raw)
+	if !ok || suffix == "" {
 		return 0, "", false
 	}
 	return value, suffix, true
 }
 
-func beforeFirstComma(value string) string {
-	if index := strings.Index(value, ","); index >= 0 {
-		return strings.TrimSpace(value[:index])
-	}
-	return strings.TrimSpace(value)
-}
-
 func normalizeShoppingQuantitySuffix(suffix string) string {
 	return strings.ToLower(strings.Join(strings.Fields(suffix), " "))
 }
 
-func formatShoppingQuantity(value float64, suffix string) string {
-	if math.Abs(value-math.Round(value)) < 1e-9 {
-		return fmt.Sprintf("%d %s", int64(math.Round(value)), suffix)
-	}
-	return fmt.Sprintf("%s %s", strconv.FormatFloat(value, 'f', -1, 64), suffix)
-}
-
 func shoppingAisleHeading(aisle string) string {
 	aisle = strings.TrimSpace(aisle)
 	if aisle == "" {
@@ -3,6 +3,7 @@ package recipes
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -19,6 +20,7 @@ import (
 	utypes "careme/internal/users/types"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"golang.org/x/net/html"
 )
 
@@ -41,7 +43,7 @@ func assertHTTPSuccess(t *testing.T, w *httptest.ResponseRecorder) string {
 }
 
 func formatShoppingListHTMLForTest(ctx context.Context, p *generatorParams, l ai.ShoppingList, signedIn bool, selection recipeSelection, w *httptest.ResponseRecorder) {
-	FormatShoppingListHTMLForHashWithHelp(ctx, p, l, nil, nil, renderTestUser(signedIn), p.Hash(), selection, "", "", w)
+	FormatShoppingListHTMLForHashWithHelp(ctx, p, l, nil, nil, renderTestUser(signedIn), utypes.Pantry{}, p.Hash(), selection, shoppingChecklist{}, "", "", 0, w)
 }
 
 func renderTestUser(signedIn bool) *utypes.User {
@@ -244,7 +246,7 @@ func TestFormatShoppingListHTML_ShowsCampaignHelpMessage(t *testing.T) {
 	p := DefaultParams(&loc, time.Now())
 	w := httptest.NewRecorder()
 
-	FormatShoppingListHTMLForHashWithHelp(t.Context(), p, list, nil, nil, renderTestUser(true), p.Hash(), recipeSelection{}, "Save two dinners before building your shopping list.", "", w)
+	FormatShoppingListHTMLForHashWithHelp(t.Context(), p, list, nil, nil, renderTestUser(true), utypes.Pantry{}, p.Hash(), recipeSelection{}, shoppingChecklist{}, "Save two dinners before building your shopping list.", "", 0, w)
 
 	html := assertHTTPSuccess(t, w)
 	assert.Contains(t, html, "Welcome to Careme")
@@ -462,7 +464,7 @@ func TestFormatRecipeHTML_NoFinalizeOrRegenerate(t *testing.T) {
 	recipe.ResponseID = "resp-123"
 	recipe.OriginHash = p.Hash()
 	w := httptest.NewRecorder()
-	FormatRecipeHTML(t.Context(), p, recipe, false, renderTestUser(true), nil, false, []RecipeThreadEntry{}, feedback.Feedback{}, nil, w)
+	FormatRecipeHTML(t.Context(), p, recipe, false, renderTestUser(true), nil, false, []RecipeThreadEntry{}, feedback.Feedback{}, nil, nil, 0, w)
 	html := assertHTTPSuccess(t, w)
 
 	isValidHTML(t, html)
@@ -560,13 +562,35 @@ func TestFormatRecipeHTML_NoFinalizeOrRegenerate(t *testing.T) {
 	}
 }
 
+func TestFormatRecipeHTML_EmbedsSchemaRecipe(t *testing.T) {
+	loc := locations.Location{ID: "70000001", Name: "Store", Address: "1 Main St"}
+	p := DefaultParams(&loc, time.Now())
+	recipe := list.Recipes[0]
+	recipe.Title = "Quail </script><b>"
+	w := httptest.NewRecorder()
+	FormatRecipeHTML(t.Context(), p, recipe, false, nil, nil, true, nil, feedback.Feedback{}, nil, nil, 2, w)
+	page := assertHTTPSuccess(t, w)
+
+	_, rest, ok := strings.Cut(page, `<script type="application/ld+json">`)
+	require.True(t, ok, "recipe page should embed JSON-LD")
+	body, _, ok := strings.Cut(rest, "</script>")
+	require.True(t, ok)
+	var got map[string]any
+	require.NoError(t, json.Unmarshal([]byte(body), &got))
+	assert.Equal(t, "Recipe", got["@type"])
+	assert.Equal(t, recipe.Title, got["name"], "the title is escaped inside the script, not cut short")
+	assert.Equal(t, "http://localhost:8080/recipe/"+recipe.ComputeHash(), got["url"])
+	assert.Equal(t, got["url"].(string)+"/image", got["image"])
+	assert.Len(t, got["recipeIngredient"], len(recipe.Ingredients))
+}
+
 func TestFormatRecipeHTML_HidesQuestionInputWhenSignedOut(t *testing.T) {
 	loc := locations.Location{ID: "70000001", Name: "Store", Address: "1 Main St"}
 	p := DefaultParams(&loc, time.Now())
 	recipe := list.Recipes[0]
 	recipe.ResponseID = "resp-123"
 	w := httptest.NewRecorder()
-	FormatRecipeHTML(t.Context(), p, recipe, false, nil, nil, false, []RecipeThreadEntry{}, feedback.Feedback{}, nil, w)
+	FormatRecipeHTML(t.Context(), p, recipe, false, nil, nil, false, []RecipeThreadEntry{}, feedback.Feedback{}, nil, nil, 0, w)
 	html := assertHTTPSuccess(t, w)
 
 	isValidHTML(t, html)
@@ -599,7 +623,7 @@ func TestFormatRecipeHTML_ShowsRecipeCritiqueScore(t *testing.T) {
 	w := httptest.NewRecorder()
 	score := 8
 
-	FormatRecipeHTML(t.Context(), p, recipe, false, renderTestUser(true), &score, false, []RecipeThreadEntry{}, feedback.Feedback{}, nil, w)
+	FormatRecipeHTML(t.Context(), p, recipe, false, renderTestUser(true), &score, false, []RecipeThreadEntry{}, feedback.Feedback{}, nil, nil, 0, w)
 	html := assertHTTPSuccess(t, w)
 
 	isValidHTML(t, html)
@@ -617,6 +641,27 @@ func TestFormatRecipeHTML_ShowsRecipeCritiqueScore(t *testing.T) {
 	}
 }
 
+func TestFormatRecipeHTML_ShowsComputedNutrition(t *testing.T) {
+	loc := locations.Location{ID: "70000001", Name: "Store", Address: "1 Main St"}
+	p := DefaultParams(&loc, time.Now())
+	recipe := ai.Recipe{
+		Title:       "Rice Bowl",
+		Servings:    2,
+		Ingredients: []ai.Ingredient{{Name: "Jasmine rice", Quantity: "1 cup"}, {Name: "Gochujang", Quantity: "2 tbsp"}},
+		Health:      "Light and balanced.",
+	}
+	w := httptest.NewRecorder()
+
+	// recipes saved before nutrition existed get it computed on the way out.
+	FormatRecipeHTML(t.Context(), p, recipe, false, renderTestUser(true), nil, false, []RecipeThreadEntry{}, feedback.Feedback{}, nil, nil, 4, w)
+	html := assertHTTPSuccess(t, w)
+
+	isValidHTML(t, html)
+	assert.Contains(t, html, "Nutrition per serving:")
+	assert.Contains(t, html, "338 calories")
+	assert.Contains(t, html, "not counted: Gochujang")
+}
+
 func TestFormatRecipeHTML_ShowsProminentWarningForLowCritiqueScore(t *testing.T) {
 	loc := locations.Location{ID: "70000001", Name: "Store", Address: "1 Main St"}
 	p := DefaultParams(&loc, time.Now())
@@ -625,7 +670,7 @@ func TestFormatRecipeHTML_ShowsProminentWarningForLowCritiqueScore(t *testing.T)
 	w := httptest.NewRecorder()
 	score := 6
 
-	FormatRecipeHTML(t.Context(), p, recipe, false, renderTestUser(true), &score, false, []RecipeThreadEntry{}, feedback.Feedback{}, nil, w)
+	FormatRecipeHTML(t.Context(), p, recipe, false, renderTestUser(true), &score, false, []RecipeThreadEntry{}, feedback.Feedback{}, nil, nil, 0, w)
 	html := assertHTTPSuccess(t, w)
 
 	isValidHTML(t, html)
@@ -704,7 +749,7 @@ func TestFormatRecipeHTML_RendersCachedWineRecommendation(t *testing.T) {
 			{Name: "Backup Chardonnay", Price: "$11.99"},
 		},
 		Commentary: "Great with the savory notes.",
-	}, w)
+	}, nil, 0, w)
 	html := assertHTTPSuccess(t, w)
 
 	isValidHTML(t, html)
@@ -744,7 +789,7 @@ func TestFormatRecipeHTML_AllowsIngredientWithoutPrice(t *testing.T) {
 		ResponseID:   "resp-123",
 	}
 
-	FormatRecipeHTML(t.Context(), p, recipe, false, renderTestUser(true), nil, false, []RecipeThreadEntry{}, feedback.Feedback{}, nil, w)
+	FormatRecipeHTML(t.Context(), p, recipe, false, renderTestUser(true), nil, false, []RecipeThreadEntry{}, feedback.Feedback{}, nil, nil, 0, w)
 	html := assertHTTPSuccess(t, w)
 
 	isValidHTML(t, html)
@@ -803,7 +848,7 @@ func TestFormatRecipeHTML_RendersRecipeImage(t *testing.T) {
 	recipe.ResponseID = "resp-123"
 	recipeHash := recipe.ComputeHash()
 
-	FormatRecipeHTML(t.Context(), p, recipe, false, renderTestUser(true), nil, true, []RecipeThreadEntry{}, feedback.Feedback{}, nil, w)
+	FormatRecipeHTML(t.Context(), p, recipe, false, renderTestUser(true), nil, true, []RecipeThreadEntry{}, feedback.Feedback{}, nil, nil, 0, w)
 	html := assertHTTPSuccess(t, w)
 
 	isValidHTML(t, html)
@@ -825,7 +870,7 @@ func TestFormatShoppingListHTML_RendersRecipeImageInResponsiveQuarterWidthColumn
 	w := httptest.NewRecorder()
 	recipeHash := list.Recipes[0].ComputeHash()
 
-	FormatShoppingListHTMLForHashWithHelp(t.Context(), p, list, nil, map[string]bool{recipeHash: true}, renderTestUser(true), p.Hash(), recipeSelection{}, "", "", w)
+	FormatShoppingListHTMLForHashWithHelp(t.Context(), p, list, nil, map[string]bool{recipeHash: true}, renderTestUser(true), utypes.Pantry{}, p.Hash(), recipeSelection{}, shoppingChecklist{}, "", "", 0, w)
 	html := assertHTTPSuccess(t, w)
 
 	assert.Contains(t, html, `src="/recipe/`+recipeHash+`/image"`)
@@ -868,7 +913,7 @@ func TestFormatShoppingListHTMLForHash_RendersWineOnlyInDetails(t *testing.T) {
 			},
 			Commentary: "Good with roasted flavors.",
 		},
-	}, nil, renderTestUser(true), p.Hash(), selection, "", "", w)
+	}, nil, renderTestUser(true), utypes.Pantry{}, p.Hash(), selection, shoppingChecklist{}, "", "", 0, w)
 	html := assertHTTPSuccess(t, w)
 
 	isValidHTML(t, html)
@@ -0,0 +1,154 @@
+package recipes
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"careme/internal/ai"
+	"careme/internal/recipes/status"
+	utypes "careme/internal/users/types"
+)
+
+func ingredientPreferencesSignature(prefs utypes.IngredientPreferences) string {
+	prefs = prefs.Normalize()
+	return "ingredients" + strconv.Itoa(prefs.MinGrade) +
+		"|" + strings.Join(prefs.Boosts, ",") +
+		"|" + strings.Join(prefs.Bans, ",")
+}
+
+// minIngredientGrade is the lowest grade planned with. Without a user
+// preference it's just above IngredientGradeCutoff.
+func minIngredientGrade(prefs utypes.IngredientPreferences) int {
+	if prefs.MinGrade > 0 {
+		return prefs.MinGrade
+	}
+	return IngredientGradeCutoff + 1
+}
+
+type preferenceTerm struct {
+	term string
+	re   *regexp.Regexp
+}
+
+// stapleFilter applies a user's ingredient preferences to store staples.
+type stapleFilter struct {
+	minGrade int
+	boosts   []preferenceTerm
+	bans     []preferenceTerm
+}
+
+func newStapleFilter(prefs utypes.IngredientPreferences) stapleFilter {
+	prefs = prefs.Normalize()
+	return stapleFilter{
+		minGrade: minIngredientGrade(prefs),
+		boosts:   preferenceTerms(prefs.Boosts),
+		bans:     preferenceTerms(prefs.Bans),
+	}
+}
+
+// preferenceTerms match whole words with an optional plural, so "pork" finds
+// "Pork Chops" but not "Porkchop Seasoning", and "egg" finds "Eggs".
+func preferenceTerms(terms []string) []preferenceTerm {
+	out := make([]preferenceTerm, 0, len(terms))
+	for _, term := range terms {
+		out = append(out, preferenceTerm{term: term, re: regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(term) + `(?:e?s)?\b`)})
+	}
+	return out
+}
+
+func (t preferenceTerm) matches(ing ai.InputIngredient) bool {
+	if t.re.MatchString(ing.Description) {
+		return true
+	}
+	for _, category := range ing.Categories {
+		if t.re.MatchString(category) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f stapleFilter) banned(ing ai.InputIngredient) (string, bool) {
+	for _, t := range f.bans {
+		if t.matches(ing) {
+			return t.term, true
+		}
+	}
+	return "", false
+}
+
+func (f stapleFilter) boosted(ing ai.InputIngredient) bool {
+	for _, t := range f.boosts {
+		if t.matches(ing) {
+			return true
+		}
+	}
+	return false
+}
+
+// allows reports whether a staple is graded well enough and not banned.
+// Ungraded staples are allowed, as they are on regeneration.
+func (f stapleFilter) allows(ing ai.InputIngredient) bool {
+	if ing.Grade != nil && ing.Grade.Score < f.minGrade {
+		return false
+	}
+	_, banned := f.banned(ing)
+	return !banned
+}
+
+// apply drops staples graded below the threshold or banned, counting what the
+// user's own preferences removed beyond the default cutoff. Regeneration keeps
+// ungraded staples since they were already planned with once.
+func (f stapleFilter) apply(ingredients []ai.InputIngredient, keepUngraded bool) ([]ai.InputIngredient, status.Filtered) {
+	removed := status.Filtered{MinGrade: f.minGrade}
+	kept := make([]ai.InputIngredient, 0, len(ingredients))
+	for _, ing := range ingredients {
+		score := ing.Grade.GetScore()
+		if !(keepUngraded && ing.Grade == nil) && score < f.minGrade {
+			if score > IngredientGradeCutoff {
+				removed.BelowGrade++
+			}
+			continue
+		}
+		if term, ok := f.banned(ing); ok {
+			removed.Ban(term)
+			continue
+		}
+		kept = append(kept, ing)
+	}
+	return kept, removed
+}
+
+// compareStaples orders the ingredient TSV: boosted staples last, next to the
+// best graded ones, so the planner reads them as the picks of the store.
+func (f stapleFilter) compareStaples(a, b ai.InputIngredient) int {
+	if ab, bb := f.boosted(a), f.boosted(b); ab != bb {
+		if ab {
+			return 1
+		}
+		return -1
+	}
+	if gradeDiff := a.Grade.GetScore() - b.Grade.GetScore(); gradeDiff != 0 {
+		return gradeDiff
+	}
+	if result := strings.Compare(a.ProductID, b.ProductID); result != 0 {
+		return result
+	}
+	return strings.Compare(a.Description, b.Description)
+}
+
+// ingredientPreferenceInstructions tells the planner what the user wants more
+// of. Bans are already gone from the staples but the planner can still reach
+// for them from memory.
+func ingredientPreferenceInstructions(prefs utypes.IngredientPreferences) []string {
+	prefs = prefs.Normalize()
+	var instructions []string
+	if len(prefs.Boosts) > 0 {
+		instructions = append(instructions, "Favor recipes built around: "+strings.Join(prefs.Boosts, ", ")+".")
+	}
+	if len(prefs.Bans) > 0 {
+		instructions = append(instructions, "Never use: "+strings.Join(prefs.Bans, ", ")+".")
+	}
+	return instructions
+}
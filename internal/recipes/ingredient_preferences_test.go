@@ -0,0 +1,83 @@
+package recipes
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"careme/internal/ai"
+	"careme/internal/locations"
+	utypes "careme/internal/users/types"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHash_IngredientPreferencesOnlyHashedWhenSet(t *testing.T) {
+	p := DefaultParams(&locations.Location{ID: "70004001"}, time.Now())
+	before := p.Hash()
+	p.IngredientPreferences = utypes.IngredientPreferences{}
+	assert.Equal(t, before, p.Hash())
+	p.IngredientPreferences = utypes.IngredientPreferences{Bans: []string{"Pork"}}
+	banned := p.Hash()
+	assert.NotEqual(t, before, banned)
+	p.IngredientPreferences = utypes.IngredientPreferences{Bans: []string{"pork", "pork "}}
+	assert.Equal(t, banned, p.Hash(), "equivalent preferences share a list")
+	p.IngredientPreferences = utypes.IngredientPreferences{MinGrade: 8, Bans: []string{"pork"}}
+	assert.NotEqual(t, banned, p.Hash())
+}
+
+func TestStapleFilter(t *testing.T) {
+	grade := func(score int) *ai.IngredientGrade { return &ai.IngredientGrade{Score: score} }
+	staples := []ai.InputIngredient{
+		{ProductID: "chops", Description: "Pork Chops", Grade: grade(9)},
+		{ProductID: "seasoning", Description: "Porkchop Seasoning", Grade: grade(9)},
+		{ProductID: "salmon", Description: "Atlantic Salmon", Categories: []string{"Meat & Seafood"}, Grade: grade(7)},
+		{ProductID: "kale", Description: "Kale", Categories: []string{"Produce"}, Grade: grade(9)},
+		{ProductID: "leeks", Description: "Leeks", Categories: []string{"Produce"}, Grade: grade(7)},
+		{ProductID: "dip", Description: "Onion Dip", Grade: grade(3)},
+		{ProductID: "loose", Description: "Loose Onions"},
+	}
+
+	f := newStapleFilter(utypes.IngredientPreferences{MinGrade: 8, Boosts: []string{"seafood"}, Bans: []string{"pork"}})
+	kept, filtered := f.apply(staples, false)
+	assert.Equal(t, []string{"seasoning", "kale"}, lo.Map(kept, func(ing ai.InputIngredient, _ int) string { return ing.ProductID }))
+	assert.Equal(t, 2, filtered.BelowGrade, "the onion dip was below the default cutoff anyway")
+	assert.Equal(t, map[string]int{"pork": 1}, filtered.Banned)
+
+	kept, _ = f.apply(staples, true)
+	assert.Contains(t, kept, staples[6], "regeneration keeps ungraded staples")
+
+	f = newStapleFilter(utypes.IngredientPreferences{Boosts: []string{"seafood"}})
+	kept, filtered = f.apply(staples, false)
+	assert.Zero(t, filtered.BelowGrade)
+	require.Len(t, kept, 5, "no grade preference keeps the default cutoff")
+	slices.SortStableFunc(kept, f.compareStaples)
+	assert.Equal(t, []string{"leeks", "chops", "kale", "seasoning", "salmon"}, lo.Map(kept, func(ing ai.InputIngredient, _ int) string { return ing.ProductID }),
+		"boosted staples go last, with the best")
+}
+
+func TestGenerateRecipes_HonorsIngredientPreferences(t *testing.T) {
+	grade := func(score int) *ai.IngredientGrade { return &ai.IngredientGrade{Score: score} }
+	staples := fixedStaplesService{ingredients: []ai.InputIngredient{
+		{ProductID: "chops", Description: "Pork Chops", Grade: grade(9)},
+		{ProductID: "salmon", Description: "Atlantic Salmon", Categories: []string{"Seafood"}, Grade: grade(8)},
+		{ProductID: "kale", Description: "Kale", Grade: grade(9)},
+		{ProductID: "leeks", Description: "Leeks", Grade: grade(7)},
+	}}
+	aiStub := &captureGenerateAIClient{}
+	statuses := &statusCounter{}
+	params := DefaultParams(&locations.Location{ID: "70004001", Name: "Store"}, time.Now())
+	params.IngredientPreferences = utypes.IngredientPreferences{MinGrade: 8, Boosts: []string{"seafood"}, Bans: []string{"pork"}}
+	g := newTestGenerator(t, aiStub, nil, staples, statuses, nil)
+
+	_, err := g.GenerateRecipes(t.Context(), params)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"kale", "salmon"}, lo.Map(aiStub.ingredients, func(ing ai.InputIngredient, _ int) string { return ing.ProductID }))
+	require.Len(t, aiStub.instructions, 1)
+	assert.Contains(t, aiStub.instructions[0], "Favor recipes built around: seafood.")
+	assert.Contains(t, aiStub.instructions[0], "Never use: pork.")
+	require.NotEmpty(t, statuses.status)
+	assert.Contains(t, statuses.status[0], "Considering 2 out of 4 ingredients\nLeft out 1 ingredient graded below your 8\nLeft out 1 ingredient matching \"pork\"\n")
+}
@@ -10,6 +10,7 @@ import (
 	"careme/internal/ai"
 	"careme/internal/cache"
 	"careme/internal/recipes/feedback"
+	"careme/internal/recipes/search"
 
 	"github.com/samber/lo"
 )
@@ -19,17 +20,20 @@ const (
 	ShoppingListCachePrefix = "shoppinglist/"
 	ingredientsCachePrefix  = "ingredients/"
 	paramsCachePrefix       = "params/"
+	weekPlanCachePrefix     = "weekplan/"
 )
 
 type recipeio struct {
 	Cache               cache.Cache
 	feedback.FeedbackIO // should this be pulled out?
+	search              search.Store
 }
 
 func IO(c cache.Cache) recipeio {
 	return recipeio{
 		Cache:      c,
 		FeedbackIO: feedback.NewIO(c),
+		search:     search.NewStore(c),
 	}
 }
 
@@ -75,6 +79,10 @@ func (rio recipeio) FromCache(ctx context.Context, hash string) (*ai.ShoppingLis
 	return &list, nil
 }
 
+func (rio recipeio) ShoppingListExists(ctx context.Context, hash string) (bool, error) {
+	return rio.Cache.Exists(ctx, ShoppingListCachePrefix+hash)
+}
+
 func (rio recipeio) ParamsFromCache(ctx context.Context, hash string) (*generatorParams, error) {
 	primaryKey := paramsCachePrefix + hash
 	// have to convert legacy hashes because each recipe stored an origin hash and we didn't rewrite them
@@ -134,6 +142,10 @@ func (rio recipeio) SaveRecipe(ctx context.Context, r ai.Recipe) error {
 		return err
 	}
 	slog.InfoContext(ctx, "stored recipe", "title", r.Title, "hash", hash)
+	// search is a nice to have; the recipe is saved either way.
+	if err := rio.search.Put(ctx, search.DocFor(r)); err != nil {
+		slog.ErrorContext(ctx, "failed to index recipe for search", "hash", hash, "error", err)
+	}
 	return nil
 }
 
@@ -165,3 +177,30 @@ func (rio recipeio) SaveShoppingList(ctx context.Context, shoppingList *ai.Shopp
 
 	return nil
 }
+
+func (rio recipeio) WeekPlanFromCache(ctx context.Context, hash string) (*WeekPlan, error) {
+	blob, err := rio.Cache.Get(ctx, weekPlanCachePrefix+hash)
+	if err != nil {
+		return nil, fmt.Errorf("error getting week plan for hash %s: %w", hash, err)
+	}
+	defer func() {
+		if err := blob.Close(); err != nil {
+			slog.ErrorContext(ctx, "failed to close cached week plan", "hash", hash, "error", err)
+		}
+	}()
+
+	var plan WeekPlan
+	if err := json.NewDecoder(blob).Decode(&plan); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+func (rio recipeio) SaveWeekPlan(ctx context.Context, plan *WeekPlan, hash string) error {
+	planJSON := lo.Must(json.Marshal(plan))
+	if err := rio.Cache.Put(ctx, weekPlanCachePrefix+hash, string(planJSON), cache.Unconditional()); err != nil {
+		slog.ErrorContext(ctx, "failed to cache week plan", "hash", hash, "error", err)
+		return err
+	}
+	return nil
+}
@@ -235,6 +235,6 @@ func TestSaveWine_UsesNonConflictingPrefixWhenRecipeKeyAlreadyExists(t *testing.
 		t.Fatalf("WineFromCache failed: %v", err)
 	}
 	if got.Commentary != "Try a tempranillo." {
-		t.Fatalf("unexpected cached wine recommendation: got %q", got)
+		t.Fatalf("unexpected cached wine recommendation: got %q", got.Commentary)
 	}
 }
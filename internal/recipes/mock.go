@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"log/slog"
 	"math/rand"
+	"slices"
+	"strings"
 	"time"
 
 	"careme/internal/ai"
@@ -464,3 +466,48 @@ func (m mock) PickAWine(ctx context.Context, location string, recipe ai.Recipe,
 		Commentary: fmt.Sprintf("Mock wine pick for %s: try a medium-bodied red.", recipe.Title),
 	}, nil
 }
+
+func (m mock) FindSubstitutes(ctx context.Context, p *generatorParams, missing ai.Ingredient) ([]ai.InputIngredient, error) {
+	_ = ctx
+	_ = p
+	return []ai.InputIngredient{{
+		ProductID:    "mock-substitute",
+		Description:  "Mock substitute for " + missing.Name,
+		Size:         "1 each",
+		PriceRegular: new(float32(3.99)),
+	}}, nil
+}
+
+func (m mock) SubstituteIngredient(ctx context.Context, p *generatorParams, recipe ai.Recipe, missing ai.Ingredient, substitute ai.InputIngredient) (*ai.Recipe, error) {
+	_ = ctx
+	_ = p
+	recipe.Ingredients = slices.Clone(recipe.Ingredients)
+	for i, ing := range recipe.Ingredients {
+		if ing.Name == missing.Name {
+			recipe.Ingredients[i].Name = substitute.Description
+		}
+	}
+	recipe.ResponseID = uuid.NewString()
+	return &recipe, nil
+}
+
+func (m mock) ImportRecipe(ctx context.Context, p *generatorParams, sourceURL, text string) (*ai.Recipe, error) {
+	_ = ctx
+	_ = p
+	title := "Mock Imported Recipe"
+	if line, _, _ := strings.Cut(strings.TrimSpace(text), "\n"); line != "" {
+		title = line
+	}
+	recipe := ai.Recipe{
+		Title:        title,
+		Description:  "Mock import of a recipe from elsewhere.",
+		CookTime:     "30 minutes",
+		Servings:     2,
+		Ingredients:  []ai.Ingredient{{Name: "Mock ingredient", Quantity: "1 cup"}},
+		Instructions: []string{"Follow the original recipe."},
+		WineStyles:   []string{"Pinot Noir"},
+		ResponseID:   uuid.NewString(),
+		SourceURL:    sourceURL,
+	}
+	return &recipe, nil
+}
@@ -0,0 +1,116 @@
+package recipes
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"careme/internal/ai"
+	"careme/internal/locations"
+	"careme/internal/locations/geo"
+	"careme/internal/parallelism"
+
+	"github.com/samber/lo"
+)
+
+const (
+	// maxExtraLocations keeps a trip to three stores.
+	maxExtraLocations = 2
+	// maxExtraLocationMiles is how far another store can be from the first one.
+	maxExtraLocationMiles = 30
+)
+
+// extraLocations looks up the other stores asked for alongside primary, dropping
+// blanks and repeats.
+func extraLocations(ctx context.Context, ls locServer, primary *locations.Location, ids []string) ([]*locations.Location, error) {
+	seen := []string{primary.ID}
+	var extras []*locations.Location
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" || slices.Contains(seen, id) {
+			continue
+		}
+		seen = append(seen, id)
+		if len(extras) == maxExtraLocations {
+			return nil, fmt.Errorf("can shop at most %d stores at once", maxExtraLocations+1)
+		}
+		l, err := ls.GetLocationByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if primary.Lat != nil && primary.Lon != nil && l.Lat != nil && l.Lon != nil {
+			miles := geo.HaversineMiles(geo.Coordinate{Lat: *primary.Lat, Lon: *primary.Lon}, geo.Coordinate{Lat: *l.Lat, Lon: *l.Lon})
+			if miles > maxExtraLocationMiles {
+				return nil, fmt.Errorf("%s is %.0f miles from %s; stores must be within %d miles", l.Name, miles, primary.Name, maxExtraLocationMiles)
+			}
+		}
+		extras = append(extras, l)
+	}
+	return extras, nil
+}
+
+// allLocations is the first store followed by any extra ones.
+func (g *generatorParams) allLocations() []*locations.Location {
+	return append([]*locations.Location{g.Location}, g.ExtraLocations...)
+}
+
+// fetchStaplesAcrossStores fetches and caches each store's staples on its own,
+// the same as a single-store list would, then merges them. Every item keeps the
+// store it came from; an ID already seen at an earlier store is dropped.
+func (s *cachedStaplesService) fetchStaplesAcrossStores(ctx context.Context, p *generatorParams) ([]ai.InputIngredient, error) {
+	ctx, span := tracer.Start(ctx, "staples.fetchacrossstores")
+	defer span.End()
+
+	perStore, err := parallelism.MapWithErrors(p.allLocations(), func(l *locations.Location) ([]ai.InputIngredient, error) {
+		ingredients, err := s.FetchStaples(ctx, DefaultParams(l, p.Date))
+		if err != nil {
+			return nil, err
+		}
+		tagged := slices.Clone(ingredients)
+		for i := range tagged {
+			tagged[i].LocationID = l.ID
+		}
+		return tagged, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return lo.UniqBy(lo.Flatten(perStore), func(ing ai.InputIngredient) string {
+		return ing.ProductID
+	}), nil
+}
+
+// shoppingListForStores groups the shopping list by aisle within each store when
+// a list draws on more than one. Items without a store, like wine, are bought
+// at the first one.
+func shoppingListForStores(p *generatorParams, ingredients []ai.Ingredient) []shoppingListGroup {
+	if len(p.ExtraLocations) == 0 {
+		return shoppingListForDisplay(ingredients)
+	}
+	byStore := lo.GroupBy(ingredients, func(ing ai.Ingredient) string {
+		if ing.LocationID == "" {
+			return p.Location.ID
+		}
+		return ing.LocationID
+	})
+	var groups []shoppingListGroup
+	for _, l := range p.allLocations() {
+		storeGroups := shoppingListForDisplay(byStore[l.ID])
+		for i := range storeGroups {
+			storeGroups[i].Store = l.Name
+		}
+		groups = append(groups, storeGroups...)
+	}
+	return groups
+}
+
+// markStoreHeadings flags the first group of each store for a heading. It runs
+// after the pantry has taken out what's on hand so a store's heading isn't lost
+// with its first aisle.
+func markStoreHeadings(groups []shoppingListGroup) []shoppingListGroup {
+	for i := range groups {
+		groups[i].StoreHeading = groups[i].Store != "" && (i == 0 || groups[i-1].Store != groups[i].Store)
+	}
+	return groups
+}
@@ -0,0 +1,168 @@
+package recipes
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"careme/internal/ai"
+	"careme/internal/cache"
+	"careme/internal/locations"
+	utypes "careme/internal/users/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// perLocationStaplesProvider hands each location its own inventory.
+type perLocationStaplesProvider struct {
+	mu          sync.Mutex
+	ingredients map[string][]ai.InputIngredient
+	calls       []string
+}
+
+func (s *perLocationStaplesProvider) FetchStaples(_ context.Context, locationID string) ([]ai.InputIngredient, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, locationID)
+	return slices.Clone(s.ingredients[locationID]), nil
+}
+
+func (s *perLocationStaplesProvider) FetchWines(context.Context, string, []string) ([]ai.InputIngredient, error) {
+	return nil, nil
+}
+
+type passThroughGrader struct{}
+
+func (passThroughGrader) GradeIngredients(_ context.Context, ingredients []ai.InputIngredient) ([]ai.InputIngredient, error) {
+	return ingredients, nil
+}
+
+func TestFetchStaplesAcrossStoresKeepsProvenance(t *testing.T) {
+	provider := &perLocationStaplesProvider{ingredients: map[string][]ai.InputIngredient{
+		"70100023": {
+			{ProductID: "chicken-1", Description: "Chicken Thighs"},
+			{ProductID: "shared-1", Description: "Kroger Butter"},
+		},
+		"aldi_F219": {
+			{ProductID: "leek-1", Description: "Leeks"},
+			{ProductID: "shared-1", Description: "ALDI Butter"},
+		},
+	}}
+	cacheStore := cache.NewInMemoryCache()
+	s := &cachedStaplesService{cache: IO(cacheStore), provider: provider, grader: passThroughGrader{}}
+	kroger := &locations.Location{ID: "70100023", Name: "Kroger"}
+	aldi := &locations.Location{ID: "aldi_F219", Name: "ALDI"}
+	p := DefaultParams(kroger, time.Date(2026, time.May, 11, 0, 0, 0, 0, time.UTC))
+	p.ExtraLocations = []*locations.Location{aldi}
+
+	got, err := s.FetchStaples(t.Context(), p)
+	require.NoError(t, err)
+
+	stores := map[string]string{}
+	for _, ing := range got {
+		stores[ing.Description] = ing.LocationID
+	}
+	assert.Equal(t, map[string]string{
+		"Chicken Thighs": "70100023",
+		"Kroger Butter":  "70100023",
+		"Leeks":          "aldi_F219",
+	}, stores, "an ID already at the first store isn't added again")
+
+	cached, err := IO(cacheStore).IngredientsFromCache(t.Context(), DefaultParams(aldi, p.Date).LocationHash())
+	require.NoError(t, err, "each store's staples are cached like a single-store list")
+	assert.Len(t, cached, 2)
+
+	_, err = s.FetchStaples(t.Context(), p)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"70100023", "aldi_F219"}, provider.calls)
+}
+
+type multiLocationLookup map[string]*locations.Location
+
+func (m multiLocationLookup) GetLocationByID(_ context.Context, id string) (*locations.Location, error) {
+	l, ok := m[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown location %s", id)
+	}
+	return l, nil
+}
+
+func TestGenerationRequestAddsNearbyStores(t *testing.T) {
+	at := func(id string, lat, lon float64) *locations.Location {
+		return &locations.Location{ID: id, Name: "Store " + id, Lat: &lat, Lon: &lon}
+	}
+	ls := multiLocationLookup{
+		"70100023":        at("70100023", 47.61, -122.33),
+		"aldi_F219":       at("aldi_F219", 47.66, -122.31),
+		"publix_1847":     at("publix_1847", 47.62, -122.35),
+		"wholefoods_1015": at("wholefoods_1015", 45.52, -122.68),
+	}
+	req := generationRequest{Location: "70100023", AlsoLocations: []string{" aldi_F219", "", "70100023", "aldi_F219"}}
+
+	p, err := req.params(t.Context(), ls)
+	require.NoError(t, err)
+	require.Len(t, p.ExtraLocations, 1)
+	assert.Equal(t, "aldi_F219", p.ExtraLocations[0].ID)
+	assert.Equal(t, "70100023 + aldi_F219 on "+p.Date.Format("2006-01-02"), p.String())
+
+	single := DefaultParams(p.Location, p.Date)
+	assert.NotEqual(t, single.Hash(), p.Hash())
+	assert.Equal(t, single.LocationHash(), p.LocationHash())
+
+	req.AlsoLocations = []string{"wholefoods_1015"}
+	_, err = req.params(t.Context(), ls)
+	assert.ErrorContains(t, err, "within 30 miles")
+
+	req.AlsoLocations = []string{"aldi_F219", "publix_1847", "wholefoods_1015"}
+	_, err = req.params(t.Context(), ls)
+	assert.ErrorContains(t, err, "at most 3 stores")
+}
+
+func TestShoppingListSplitsByStore(t *testing.T) {
+	p := DefaultParams(&locations.Location{ID: "70100023", Name: "Kroger"}, time.Now())
+	p.ExtraLocations = []*locations.Location{{ID: "aldi_F219", Name: "ALDI"}}
+	ingredients := []ai.Ingredient{
+		{Name: "Leeks", Quantity: "2", AisleNumber: "1", LocationID: "aldi_F219"},
+		{Name: "Butter", Quantity: "4 tbsp", AisleNumber: "12", LocationID: "70100023"},
+		{Name: "Chicken Thighs", Quantity: "1 lb", AisleNumber: "3", LocationID: "70100023"},
+		{Name: "Riesling", Quantity: "1 bottle"},
+		{Name: "Butter", Quantity: "2 tbsp", AisleNumber: "7", LocationID: "aldi_F219"},
+	}
+
+	groups, _ := applyPantry(shoppingListForStores(p, ingredients), utypes.Pantry{Items: []utypes.PantryItem{{Name: "Chicken Thighs"}}})
+	groups = markStoreHeadings(groups)
+
+	type row struct {
+		Heading string
+		Store   string
+		Aisle   string
+		Items   []string
+	}
+	var got []row
+	for _, g := range groups {
+		r := row{Store: g.Store, Aisle: g.Aisle}
+		if g.StoreHeading {
+			r.Heading = g.Store
+		}
+		for _, item := range g.Items {
+			r.Items = append(r.Items, item.Name)
+		}
+		got = append(got, r)
+	}
+	assert.Equal(t, []row{
+		{Heading: "Kroger", Store: "Kroger", Aisle: "Aisle 12", Items: []string{"Butter"}},
+		{Store: "Kroger", Aisle: "Other items", Items: []string{"Riesling"}},
+		{Heading: "ALDI", Store: "ALDI", Aisle: "Aisle 1", Items: []string{"Leeks"}},
+		{Store: "ALDI", Aisle: "Aisle 7", Items: []string{"Butter"}},
+	}, got, "the pantry took Kroger's first aisle, not its heading")
+
+	single := DefaultParams(p.Location, p.Date)
+	for _, g := range markStoreHeadings(shoppingListForStores(single, ingredients)) {
+		assert.Empty(t, g.Store)
+		assert.False(t, g.StoreHeading)
+	}
+}
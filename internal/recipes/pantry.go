@@ -0,0 +1,195 @@
+package recipes
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"careme/internal/ai"
+	"careme/internal/cache"
+	utypes "careme/internal/users/types"
+)
+
+// pantrySignature only covers what's on hand, not how much, so using some up
+// when a recipe is cooked doesn't make a new list.
+func pantrySignature(items []utypes.PantryItem) string {
+	var sb strings.Builder
+	sb.WriteString("pantry")
+	for _, item := range items {
+		sb.WriteString("|" + item.Name)
+	}
+	return sb.String()
+}
+
+func pantryInstructions(items []utypes.PantryItem) []string {
+	if len(items) == 0 {
+		return nil
+	}
+	onHand := make([]string, 0, len(items))
+	for _, item := range items {
+		if item.Quantity == "" {
+			onHand = append(onHand, item.Name)
+			continue
+		}
+		onHand = append(onHand, item.Name+" ("+item.Quantity+")")
+	}
+	return []string{"Already on hand, no need to buy: " + strings.Join(onHand, ", ") + ". Use these where they fit instead of buying more."}
+}
+
+// userPantry is best effort; an unreadable pantry just means nothing is on hand.
+func (s *server) userPantry(ctx context.Context, user *utypes.User) utypes.Pantry {
+	if user == nil || user.ID == guestUser.ID {
+		return utypes.Pantry{}
+	}
+	pantry, err := s.storage.Pantry(ctx, user.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to load pantry", "user", user.ID, "error", err)
+		return utypes.Pantry{}
+	}
+	return pantry
+}
+
+// pantryMadeFrom are words that, in front of an ingredient, name something else it's
+// made from: "peanut butter" isn't butter and "sesame oil" isn't the oil in the pantry.
+var pantryMadeFrom = map[string]bool{
+	"almond": true, "apple": true, "avocado": true, "cashew": true, "cauliflower": true,
+	"chili": true, "coconut": true, "hazelnut": true, "olive": true, "peanut": true,
+	"pistachio": true, "sesame": true, "soy": true, "sunflower": true, "truffle": true,
+	"walnut": true,
+}
+
+// matchPantryItem finds the pantry item an ingredient comes out of. The pantry name
+// has to be the ingredient's head noun, the words it ends with, so "olive oil" covers
+// "Extra virgin olive oil" and "rice" covers "Basmati rice" but not "rice vinegar".
+// A head named for what it's made from, like "peanut butter", doesn't count.
+func matchPantryItem(items []utypes.PantryItem, ingredientName string) (int, bool) {
+	name := singularWords(normalizeShoppingListName(ingredientName))
+	if name == "" {
+		return 0, false
+	}
+	for i, item := range items {
+		pantryName := singularWords(normalizeShoppingListName(item.Name))
+		if pantryName == "" {
+			continue
+		}
+		if name == pantryName {
+			return i, true
+		}
+		modifiers, ok := strings.CutSuffix(name, " "+pantryName)
+		if !ok {
+			continue
+		}
+		words := strings.Fields(modifiers)
+		if !pantryMadeFrom[words[len(words)-1]] {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func singularWords(s string) string {
+	words := strings.Fields(s)
+	for i, w := range words {
+		if len(w) > 2 && !strings.HasSuffix(w, "ss") {
+			words[i] = strings.TrimSuffix(w, "s")
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+type pantryAmount struct {
+	have, need float64
+	// units as written on each side; they only differ by plural.
+	haveUnit, needUnit string
+}
+
+// pantryAmounts parses both quantities when they share a unit.
+func pantryAmounts(have, need string) (pantryAmount, bool) {
+	var a pantryAmount
+	var ok bool
+	if a.have, a.haveUnit, ok = ai.ParseQuantity(have); !ok {
+		return a, false
+	}
+	if a.need, a.needUnit, ok = ai.ParseQuantity(need); !ok {
+		return a, false
+	}
+	return a, singularWords(normalizeShoppingQuantitySuffix(a.haveUnit)) == singularWords(normalizeShoppingQuantitySuffix(a.needUnit))
+}
+
+// applyPantry splits the shopping list into what still needs buying and what the
+// pantry covers. When both sides have amounts in the same unit only the shortfall
+// stays on the list; otherwise having the item at all counts as covered.
+func applyPantry(groups []shoppingListGroup, pantry utypes.Pantry) ([]shoppingListGroup, []*ai.Ingredient) {
+	if pantry.IsZero() {
+		return groups, nil
+	}
+	var toBuy []shoppingListGroup
+	var onHand []*ai.Ingredient
+	for _, group := range groups {
+		remaining := shoppingListGroup{Store: group.Store, Aisle: group.Aisle}
+		for _, item := range group.Items {
+			i, ok := matchPantryItem(pantry.Items, item.Name)
+			if !ok {
+				remaining.Items = append(remaining.Items, item)
+				continue
+			}
+			amount, ok := pantryAmounts(pantry.Items[i].Quantity, item.Quantity)
+			if ok && amount.have < amount.need {
+				short := *item
+				short.Quantity = strings.TrimSpace(ai.FormatAmount(amount.need-amount.have) + " " + amount.needUnit)
+				remaining.Items = append(remaining.Items, &short)
+				continue
+			}
+			onHand = append(onHand, item)
+		}
+		if len(remaining.Items) > 0 {
+			toBuy = append(toBuy, remaining)
+		}
+	}
+	return toBuy, onHand
+}
+
+// usePantry takes a cooked recipe's ingredients out of the pantry. Items without an
+// amount, or measured in a different unit, are left alone since we can't tell how
+// much is left.
+func usePantry(pantry utypes.Pantry, recipe ai.Recipe) (utypes.Pantry, bool) {
+	items := append([]utypes.PantryItem(nil), pantry.Items...)
+	changed := false
+	for _, ingredient := range recipe.Ingredients {
+		i, ok := matchPantryItem(items, ingredient.Name)
+		if !ok || items[i].Quantity == "" {
+			continue
+		}
+		amount, ok := pantryAmounts(items[i].Quantity, ingredient.Quantity)
+		if !ok {
+			continue
+		}
+		changed = true
+		if amount.have <= amount.need {
+			items = append(items[:i], items[i+1:]...)
+			continue
+		}
+		items[i].Quantity = strings.TrimSpace(ai.FormatAmount(amount.have-amount.need) + " " + amount.haveUnit)
+	}
+	return utypes.Pantry{Items: items}, changed
+}
+
+// usePantryForCookedRecipe is best effort so a pantry hiccup never fails the feedback save.
+func (s *server) usePantryForCookedRecipe(ctx context.Context, userID, hash string) {
+	recipe, err := s.SingleFromCache(ctx, hash)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to load cooked recipe for pantry", "hash", hash, "error", err)
+		return
+	}
+	err = s.storage.UpdatePantry(ctx, userID, func(pantry *utypes.Pantry) error {
+		used, changed := usePantry(*pantry, *recipe)
+		if !changed {
+			return cache.ErrSkipUpdate
+		}
+		*pantry = used
+		return nil
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to update pantry after cooking", "user", userID, "hash", hash, "error", err)
+	}
+}
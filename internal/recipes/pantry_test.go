@@ -0,0 +1,171 @@
+package recipes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"careme/internal/ai"
+	"careme/internal/cache"
+	"careme/internal/locations"
+	"careme/internal/users"
+	utypes "careme/internal/users/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchPantryItem(t *testing.T) {
+	items := []utypes.PantryItem{{Name: "olive oil"}, {Name: "eggs"}, {Name: "rice"}, {Name: "butter"}, {Name: "oil"}}
+	for name, want := range map[string]int{
+		"Extra virgin olive oil": 0,
+		"Large egg":              1,
+		"Jasmine rice":           2,
+		"rice":                   2,
+		"Unsalted butter":        3,
+		"Vegetable oil":          4,
+	} {
+		got, ok := matchPantryItem(items, name)
+		assert.True(t, ok, name)
+		assert.Equal(t, want, got, name)
+	}
+	for _, name := range []string{"Kalamata olives", "Eggplant", "rice vinegar", "peanut butter", "sesame oil", "egg noodles", "Cauliflower rice", ""} {
+		_, ok := matchPantryItem(items, name)
+		assert.False(t, ok, name)
+	}
+}
+
+func TestApplyPantry(t *testing.T) {
+	groups := shoppingListForDisplay([]ai.Ingredient{
+		{Name: "Olive oil", Quantity: "2 tbsp"},
+		{Name: "Basmati rice", Quantity: "2 cups"},
+		{Name: "Chicken thighs", Quantity: "1 lb"},
+	})
+	pantry := utypes.Pantry{Items: []utypes.PantryItem{
+		{Name: "olive oil"},
+		{Name: "rice", Quantity: "1/2 cup"},
+	}}
+
+	toBuy, onHand := applyPantry(groups, pantry)
+
+	require.Len(t, onHand, 1)
+	assert.Equal(t, "Olive oil", onHand[0].Name)
+	var bought []string
+	for _, group := range toBuy {
+		for _, item := range group.Items {
+			bought = append(bought, item.Name+": "+item.Quantity)
+		}
+	}
+	assert.ElementsMatch(t, []string{"Basmati rice: 1 1/2 cups", "Chicken thighs: 1 lb"}, bought)
+	assert.Equal(t, "2 cups", groups[0].Items[1].Quantity, "applying the pantry must not rewrite the full list")
+}
+
+func TestUsePantry(t *testing.T) {
+	pantry := utypes.Pantry{Items: []utypes.PantryItem{
+		{Name: "salt"},
+		{Name: "rice", Quantity: "3 cups"},
+		{Name: "butter", Quantity: "2 tbsp"},
+		{Name: "onions", Quantity: "2"},
+	}}
+	recipe := ai.Recipe{Ingredients: []ai.Ingredient{
+		{Name: "Kosher salt", Quantity: "1 tsp"},
+		{Name: "Long grain rice", Quantity: "1 cup"},
+		{Name: "Unsalted butter", Quantity: "4 tbsp"},
+		{Name: "Onion", Quantity: "1 large"},
+	}}
+
+	got, changed := usePantry(pantry, recipe)
+	require.True(t, changed)
+	assert.Equal(t, []utypes.PantryItem{
+		{Name: "salt"},
+		{Name: "rice", Quantity: "2 cups"},
+		{Name: "onions", Quantity: "2"},
+	}, got.Items)
+	assert.Equal(t, "3 cups", pantry.Items[1].Quantity)
+
+	_, changed = usePantry(utypes.Pantry{Items: []utypes.PantryItem{{Name: "salt"}}}, recipe)
+	assert.False(t, changed)
+}
+
+func TestHash_PantryOnlyHashedWhenSet(t *testing.T) {
+	p := DefaultParams(&locations.Location{ID: "70004001"}, time.Now())
+	before := p.Hash()
+	p.Pantry = []utypes.PantryItem{}
+	assert.Equal(t, before, p.Hash())
+	p.Pantry = []utypes.PantryItem{{Name: "rice", Quantity: "2 cups"}}
+	assert.NotEqual(t, before, p.Hash())
+	withRice := p.Hash()
+	p.Pantry = []utypes.PantryItem{{Name: "rice", Quantity: "1 cup"}}
+	assert.Equal(t, withRice, p.Hash(), "cooking from the pantry doesn't make a new list")
+}
+
+func TestGenerateRecipes_SendsPantryToMenuPlan(t *testing.T) {
+	params := DefaultParams(&locations.Location{ID: "70004001", Name: "Store"}, time.Now())
+	params.Pantry = []utypes.PantryItem{{Name: "olive oil"}, {Name: "rice", Quantity: "2 lb"}}
+	aiStub := &sequenceAIClient{}
+	g := newTestGenerator(t, aiStub, &captureCritiqueService{}, seededStaples(t, params), noopstatuswriter{}, nil)
+
+	_, err := g.GenerateRecipes(t.Context(), params)
+	require.NoError(t, err)
+	require.Len(t, aiStub.menuPlanInstructions, 1)
+	assert.Contains(t, aiStub.menuPlanInstructions[0], "Already on hand, no need to buy: olive oil, rice (2 lb). Use these where they fit instead of buying more.")
+}
+
+func TestHandleFeedback_CookedUsesPantry(t *testing.T) {
+	cacheStore := cache.NewFileCache(filepath.Join(t.TempDir(), "cache"))
+	storage := users.NewStorage(cacheStore)
+	s := newTestServer(t, withTestCache(cacheStore), withTestStorage(storage))
+
+	recipe := ai.Recipe{Title: "Rice Bowl", Ingredients: []ai.Ingredient{{Name: "Rice", Quantity: "1 cup"}}}
+	require.NoError(t, s.SaveRecipe(t.Context(), recipe))
+	hash := recipe.ComputeHash()
+	userID := "mock-clerk-user-id"
+	require.NoError(t, storage.SavePantry(t.Context(), userID, utypes.Pantry{Items: []utypes.PantryItem{{Name: "rice", Quantity: "3 cups"}}}))
+
+	post := func(cooked string) {
+		form := url.Values{"cooked": {cooked}}
+		req := httptest.NewRequest(http.MethodPost, "/recipe/"+hash+"/feedback", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("HX-Request", "true")
+		req.SetPathValue("hash", hash)
+		rr := httptest.NewRecorder()
+		s.handleFeedback(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+	}
+
+	post("true")
+	pantry, err := storage.Pantry(t.Context(), userID)
+	require.NoError(t, err)
+	assert.Equal(t, []utypes.PantryItem{{Name: "rice", Quantity: "2 cups"}}, pantry.Items)
+
+	// already cooked, so saving it again shouldn't use more rice.
+	post("true")
+	pantry, err = storage.Pantry(t.Context(), userID)
+	require.NoError(t, err)
+	assert.Equal(t, "2 cups", pantry.Items[0].Quantity)
+}
+
+func TestFormatShoppingList_ShowsPantryItemsSeparately(t *testing.T) {
+	loc := locations.Location{ID: "70000001", Name: "Store", Address: "1 Main St"}
+	p := DefaultParams(&loc, time.Now())
+	recipe := ai.Recipe{Title: "Rice Bowl", Ingredients: []ai.Ingredient{
+		{Name: "Olive oil", Quantity: "1 tbsp"},
+		{Name: "Scallions", Quantity: "2"},
+	}}
+	selection := recipeSelection{SavedHashes: []string{recipe.ComputeHash()}}
+	w := httptest.NewRecorder()
+
+	FormatShoppingListHTMLForHashWithHelp(t.Context(), p, ai.ShoppingList{Recipes: []ai.Recipe{recipe}}, nil, nil, renderTestUser(true),
+		utypes.Pantry{Items: []utypes.PantryItem{{Name: "olive oil"}}}, p.Hash(), selection, shoppingChecklist{}, "", "", 0, w)
+	html := assertHTTPSuccess(t, w)
+	isValidHTML(t, html)
+
+	assert.Contains(t, html, "Already in your pantry")
+	pantrySection := html[strings.Index(html, "Already in your pantry"):]
+	assert.Contains(t, pantrySection, "Olive oil")
+	assert.NotContains(t, pantrySection, "Scallions")
+}
@@ -10,11 +10,13 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 
 	"careme/internal/ai"
 	"careme/internal/locations"
 	"careme/internal/locations/geo"
+	utypes "careme/internal/users/types"
 
 	"github.com/samber/lo"
 )
@@ -28,11 +30,25 @@ const (
 var nowFn = time.Now
 
 type generatorParams struct {
-	Location     *locations.Location `json:"location,omitempty"`
-	Date         time.Time           `json:"date"`
-	Instructions string              `json:"instructions,omitempty"`
-	Directive    string              `json:"directive,omitempty"` // this is the new one that will be used. Can remove GenerationPrompt after a while.
-	LastRecipes  []string            `json:"-"`                   // this doesn't get populated until after save.
+	Location *locations.Location `json:"location,omitempty"`
+	// ExtraLocations are nearby stores shopped on the same trip; their staples
+	// are merged with Location's and the shopping list is split by store.
+	ExtraLocations []*locations.Location `json:"extra_locations,omitempty"`
+	Date           time.Time             `json:"date"`
+	Instructions   string                `json:"instructions,omitempty"`
+	Directive      string                `json:"directive,omitempty"` // this is the new one that will be used. Can remove GenerationPrompt after a while.
+	LastRecipes    []string              `json:"-"`                   // this doesn't get populated until after save.
+	Household      utypes.Household      `json:"household,omitzero"`
+	// Days switches generation to a week plan with one dinner per weekday listed.
+	Days   []string            `json:"days,omitempty"`
+	Pantry []utypes.PantryItem `json:"pantry,omitempty"`
+	Budget utypes.Budget       `json:"budget,omitzero"`
+	// IngredientPreferences are the user's grade threshold and category boosts and bans for staples.
+	IngredientPreferences utypes.IngredientPreferences `json:"ingredient_preferences,omitzero"`
+	// Taste is what the user's history says they like, relearned in the
+	// background as they save, dismiss and rate recipes. Only a profile the
+	// user edited is hashed; a learned one shifts too often to key lists on.
+	Taste utypes.TasteProfile `json:"taste,omitzero"`
 	// UserID         string      `json:"user_id,omitempty"`
 	// ideally this would be a section and we'd fetch titles and other things as needed
 	// as is this records a selectio at the time of a regeneration
@@ -40,9 +56,12 @@ type generatorParams struct {
 	Dismissed []ai.Recipe `json:"dismissed_recipes,omitempty"`
 
 	// regeneration-only context from the origin params; not hashed
-	PriorSavedHashes               []string `json:"-"`
-	PreviousMenuPlanResponseID     string   `json:"previous_menu_plan_response_id,omitempty"`
-	PreviousMenuPlanPromptCacheKey string   `json:"previous_menu_plan_prompt_cache_key,omitempty"`
+	PriorSavedHashes []string `json:"-"`
+	// Neighbors are recipes like ones the user starred; looked up at
+	// generation like LastRecipes, so not hashed.
+	Neighbors                      recipeNeighbors `json:"-"`
+	PreviousMenuPlanResponseID     string          `json:"previous_menu_plan_response_id,omitempty"`
+	PreviousMenuPlanPromptCacheKey string          `json:"previous_menu_plan_prompt_cache_key,omitempty"`
 }
 
 func (g *generatorParams) previousMenuPlanResponse() ai.ResponseRef {
@@ -65,8 +84,25 @@ func DefaultParams(l *locations.Location, date time.Time) *generatorParams {
 	}
 }
 
+// ApplyUser copies the user's standing preferences onto the params: their
+// directive, household, budget, ingredient preferences, taste and pantry. The
+// site and the weekly mail both plan from these so the same user gets the
+// same list either way.
+func (g *generatorParams) ApplyUser(u *utypes.User, pantry utypes.Pantry) {
+	g.Directive = u.Directive
+	g.Household = u.Household
+	g.Budget = u.Budget
+	g.IngredientPreferences = u.IngredientPreferences
+	g.Taste = u.Taste
+	g.Pantry = pantry.Items
+}
+
 func (g *generatorParams) String() string {
-	return fmt.Sprintf("%s on %s", g.Location.ID, g.Date.Format("2006-01-02"))
+	ids := []string{g.Location.ID}
+	for _, l := range g.ExtraLocations {
+		ids = append(ids, l.ID)
+	}
+	return fmt.Sprintf("%s on %s", strings.Join(ids, " + "), g.Date.Format("2006-01-02"))
 }
 
 // Hash this is how we find shoppinglists and params
@@ -76,8 +112,30 @@ func (g *generatorParams) Hash() string {
 	lo.Must(io.WriteString(fnv, g.Location.ID))
 	lo.Must(io.WriteString(fnv, g.Date.Format("2006-01-02")))
 	lo.Must(io.WriteString(fnv, staplesSignatureForLocation(g.Location.ID)))
+	for _, l := range g.ExtraLocations {
+		lo.Must(io.WriteString(fnv, "also"+l.ID+staplesSignatureForLocation(l.ID)))
+	}
 	lo.Must(io.WriteString(fnv, g.Instructions)) // rethink this? if they're all in convo should we have one id and ability to walk back?
 	lo.Must(io.WriteString(fnv, g.Directive))
+	// only hashed when set so hashes from before households existed still resolve.
+	if !g.Household.IsZero() {
+		lo.Must(io.WriteString(fnv, householdSignature(g.Household)))
+	}
+	if len(g.Days) > 0 {
+		lo.Must(io.WriteString(fnv, "week"+strings.Join(g.Days, ",")))
+	}
+	if len(g.Pantry) > 0 {
+		lo.Must(io.WriteString(fnv, pantrySignature(g.Pantry)))
+	}
+	if !g.Budget.IsZero() {
+		lo.Must(io.WriteString(fnv, budgetSignature(g.Budget)))
+	}
+	if !g.IngredientPreferences.IsZero() {
+		lo.Must(io.WriteString(fnv, ingredientPreferencesSignature(g.IngredientPreferences)))
+	}
+	if g.Taste.Edited && !g.Taste.IsZero() {
+		lo.Must(io.WriteString(fnv, tasteSignature(g.Taste)))
+	}
 	for _, saved := range g.Saved {
 		lo.Must(io.WriteString(fnv, "saved"+saved.ComputeHash()))
 	}
@@ -109,15 +167,38 @@ func legacyHashToCurrent(hash string, seed string) (string, bool) {
 }
 
 func ParseGenerationForm(ctx context.Context, r *http.Request, ls locServer) (*generatorParams, error) {
-	loc := r.FormValue("location")
-	if loc == "" {
+	// FormValue parses the form so it has to come before reading r.Form.
+	location := r.FormValue("location")
+	req := generationRequest{
+		Location:      location,
+		Date:          r.FormValue("date"),
+		Instructions:  r.FormValue("instructions"),
+		Days:          r.Form["day"],
+		AlsoLocations: r.Form["also_location"],
+	}
+	return req.params(ctx, ls)
+}
+
+// generationRequest is what someone asks for when generating a shopping list,
+// either from the home page form or the JSON API.
+type generationRequest struct {
+	Location     string   `json:"location"`
+	Date         string   `json:"date,omitempty"` // YYYY-MM-DD in the store's time zone
+	Instructions string   `json:"instructions,omitempty"`
+	Days         []string `json:"days,omitempty"`
+	// AlsoLocations are other stores to shop at on the same trip.
+	AlsoLocations []string `json:"also_locations,omitempty"`
+}
+
+func (req generationRequest) params(ctx context.Context, ls locServer) (*generatorParams, error) {
+	if req.Location == "" {
 		return nil, errors.New("must provide location id")
 	}
 	if ls == nil {
 		return nil, errors.New("location lookup is required")
 	}
 
-	l, err := ls.GetLocationByID(ctx, loc)
+	l, err := ls.GetLocationByID(ctx, req.Location)
 	if err != nil {
 		return nil, err
 	}
@@ -125,10 +206,9 @@ func ParseGenerationForm(ctx context.Context, r *http.Request, ls locServer) (*g
 	if err != nil {
 		return nil, err
 	}
-	dateStr := r.FormValue("date")
 	date := defaultRecipeDate(nowFn(), storeLoc)
-	if dateStr != "" {
-		parsedDate, err := time.ParseInLocation("2006-01-02", dateStr, storeLoc)
+	if req.Date != "" {
+		parsedDate, err := time.ParseInLocation("2006-01-02", req.Date, storeLoc)
 		if err != nil {
 			return nil, err
 		}
@@ -136,7 +216,13 @@ func ParseGenerationForm(ctx context.Context, r *http.Request, ls locServer) (*g
 	}
 
 	p := DefaultParams(l, date)
-	p.Instructions = r.FormValue("instructions")
+	p.Instructions = req.Instructions
+	if err := p.SetWeekPlanDays(req.Days); err != nil {
+		return nil, err
+	}
+	if p.ExtraLocations, err = extraLocations(ctx, ls, l, req.AlsoLocations); err != nil {
+		return nil, err
+	}
 
 	return p, nil
 }
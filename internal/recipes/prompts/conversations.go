@@ -0,0 +1,58 @@
+package prompts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"careme/internal/ai"
+	"careme/internal/cache"
+)
+
+const ConversationPrefix = "chat_conversations/"
+
+type cacheConversationStore struct {
+	cache cache.Cache
+}
+
+// NewCacheConversationStore keeps local model conversations next to the recipes
+// that point at them, so questions and regenerations work from any replica.
+func NewCacheConversationStore(c cache.Cache) ai.ConversationStore {
+	if c == nil {
+		return ai.NewMemoryConversationStore()
+	}
+	return cacheConversationStore{cache: c}
+}
+
+func (s cacheConversationStore) SaveConversation(ctx context.Context, responseID string, messages []ai.PromptMessage) error {
+	body, err := json.Marshal(messages)
+	if err != nil {
+		return fmt.Errorf("marshal conversation: %w", err)
+	}
+	if err := s.cache.Put(ctx, ConversationPrefix+responseID, string(body), cache.IfNoneMatch()); err != nil {
+		return fmt.Errorf("write conversation: %w", err)
+	}
+	return nil
+}
+
+func (s cacheConversationStore) LoadConversation(ctx context.Context, responseID string) ([]ai.PromptMessage, error) {
+	r, err := s.cache.Get(ctx, ConversationPrefix+responseID)
+	if err != nil {
+		if errors.Is(err, cache.ErrNotFound) {
+			return nil, ai.ErrConversationNotFound
+		}
+		return nil, err
+	}
+	defer func() {
+		if err := r.Close(); err != nil {
+			slog.ErrorContext(ctx, "failed to close cached conversation reader", "response_id", responseID, "error", err)
+		}
+	}()
+	var messages []ai.PromptMessage
+	if err := json.NewDecoder(r).Decode(&messages); err != nil {
+		return nil, fmt.Errorf("decode conversation: %w", err)
+	}
+	return messages, nil
+}
@@ -0,0 +1,32 @@
+package prompts
+
+import (
+	"errors"
+	"testing"
+
+	"careme/internal/ai"
+	"careme/internal/cache"
+)
+
+func TestCacheConversationStoreRoundTrip(t *testing.T) {
+	store := NewCacheConversationStore(cache.NewInMemoryCache())
+	messages := []ai.PromptMessage{
+		{Role: "user", Content: "plan dinner"},
+		{Role: "assistant", Content: `{"plans":[]}`},
+	}
+
+	if err := store.SaveConversation(t.Context(), "chat_abc", messages); err != nil {
+		t.Fatalf("SaveConversation returned error: %v", err)
+	}
+	got, err := store.LoadConversation(t.Context(), "chat_abc")
+	if err != nil {
+		t.Fatalf("LoadConversation returned error: %v", err)
+	}
+	if len(got) != 2 || got[1].Role != "assistant" || got[1].Content != `{"plans":[]}` {
+		t.Fatalf("unexpected conversation: %+v", got)
+	}
+
+	if _, err := store.LoadConversation(t.Context(), "chat_missing"); !errors.Is(err, ai.ErrConversationNotFound) {
+		t.Fatalf("expected ErrConversationNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,451 @@
+package recipes
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmlstd "html"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"careme/internal/ai"
+
+	"github.com/samber/lo"
+	"golang.org/x/net/html"
+)
+
+// maxImportPageBytes is as much of a recipe page as we read. Recipe blogs are
+// heavy but the JSON-LD is near the top.
+const maxImportPageBytes = 4 << 20
+
+var (
+	// errImportFetch is returned when the recipe page can't be fetched.
+	errImportFetch = errors.New("failed to fetch recipe page")
+	// errNoRecipe is returned when neither the page nor the model found a recipe.
+	errNoRecipe = errors.New("no recipe found")
+)
+
+// importHTTPClient only dials public addresses so a pasted link can't reach
+// anything inside our network. Tests swap it for one that can reach httptest.
+var importHTTPClient = &http.Client{
+	Timeout: 20 * time.Second,
+	Transport: &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: 5 * time.Second, Control: dialPublicOnly}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 5 {
+			return errors.New("too many redirects")
+		}
+		return nil
+	},
+}
+
+func dialPublicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return fmt.Errorf("refusing to dial non-public address %s", host)
+	}
+	return nil
+}
+
+// ImportRecipe turns a recipe found elsewhere, a link or pasted text, into a
+// careme recipe priced against p's staples. The schema.org Recipe a page embeds
+// is the source of truth; the model structures it, or the raw text when there
+// isn't one, and fills in what careme adds like wine styles. The caller saves it.
+func (g *generatorService) ImportRecipe(ctx context.Context, p *generatorParams, sourceURL, text string) (*ai.Recipe, error) {
+	ctx, span := tracer.Start(ctx, "recipes.import")
+	defer span.End()
+
+	sourceURL = strings.TrimSpace(sourceURL)
+	page := []byte(text)
+	if sourceURL != "" {
+		fetched, err := fetchRecipePage(ctx, sourceURL)
+		if err != nil {
+			return nil, err
+		}
+		page = fetched
+	}
+
+	input := text
+	found, ok := recipeFromJSONLD(page)
+	if ok {
+		input = recipeText(*found)
+	} else if sourceURL != "" {
+		input = pageText(page)
+	}
+	recipe, err := g.aiClient.StructureRecipe(ctx, input)
+	switch {
+	case err != nil && !ok:
+		return nil, err
+	case err != nil:
+		// the page's own recipe is still worth having without careme's extras.
+		slog.ErrorContext(ctx, "failed to structure imported recipe, keeping the page's", "url", sourceURL, "title", found.Title, "error", err)
+		recipe = found
+	case ok:
+		recipe.Title = found.Title
+	}
+	if strings.TrimSpace(recipe.Title) == "" || len(recipe.Ingredients) == 0 {
+		return nil, errNoRecipe
+	}
+	recipe.SourceURL = sourceURL
+
+	staples, err := g.staples.FetchStaples(ctx, p)
+	if err != nil {
+		// an import without prices beats no import.
+		slog.ErrorContext(ctx, "failed to get staples for imported recipe", "location", p.String(), "error", err)
+	}
+	matchStaples(recipe.Ingredients, staples, newStapleFilter(p.IngredientPreferences))
+	enrichRecipe(recipe, inputIngredientMap(staples))
+	// don't block
+	g.critiquer.CritiqueRecipeInBackground(ctx, *recipe)
+	return recipe, nil
+}
+
+func fetchRecipePage(ctx context.Context, rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: %q is not a web address", errImportFetch, rawURL)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errImportFetch, err)
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; careme recipe import)")
+	resp, err := importHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errImportFetch, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s returned %s", errImportFetch, u.Host, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxImportPageBytes))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errImportFetch, err)
+	}
+	return body, nil
+}
+
+// recipeFromJSONLD finds the first schema.org Recipe in a page's JSON-LD scripts.
+func recipeFromJSONLD(page []byte) (*ai.Recipe, bool) {
+	if !bytes.Contains(page, []byte("ld+json")) {
+		return nil, false
+	}
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return nil, false
+	}
+	var recipe *ai.Recipe
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if recipe != nil {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "script" && strings.Contains(strings.ToLower(attr(n, "type")), "ld+json") {
+			if n.FirstChild != nil {
+				var v any
+				if err := json.Unmarshal([]byte(n.FirstChild.Data), &v); err == nil {
+					if node := findSchemaRecipe(v, 0); node != nil {
+						recipe = schemaToRecipe(node)
+					}
+				}
+			}
+			return
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+	if recipe == nil || recipe.Title == "" || len(recipe.Ingredients) == 0 {
+		return nil, false
+	}
+	return recipe, true
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if strings.EqualFold(a.Key, key) {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// findSchemaRecipe looks through @graph, arrays and nested entities for a node typed Recipe.
+func findSchemaRecipe(v any, depth int) map[string]any {
+	if depth > 6 {
+		return nil
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		if lo.ContainsBy(schemaStrings(v["@type"]), func(t string) bool { return strings.EqualFold(t, "Recipe") }) {
+			return v
+		}
+		for _, child := range v {
+			if found := findSchemaRecipe(child, depth+1); found != nil {
+				return found
+			}
+		}
+	case []any:
+		for _, child := range v {
+			if found := findSchemaRecipe(child, depth+1); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
+
+// schemaStrings reads a JSON-LD value that may be a string, a number or a list of them.
+func schemaStrings(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case float64:
+		return []string{strconv.FormatFloat(v, 'f', -1, 64)}
+	case []any:
+		return lo.FlatMap(v, func(item any, _ int) []string { return schemaStrings(item) })
+	}
+	return nil
+}
+
+func schemaText(v any) string {
+	return cleanSchemaText(strings.Join(schemaStrings(v), " "))
+}
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// cleanSchemaText strips the markup and entities sites leave in their JSON-LD.
+func cleanSchemaText(s string) string {
+	s = htmlstd.UnescapeString(htmlTag.ReplaceAllString(s, " "))
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func schemaToRecipe(node map[string]any) *ai.Recipe {
+	r := &ai.Recipe{
+		Title:       schemaText(node["name"]),
+		Description: schemaText(node["description"]),
+		Servings:    schemaServings(node["recipeYield"]),
+		CookTime:    schemaDuration(node["totalTime"]),
+	}
+	if r.CookTime == "" {
+		r.CookTime = schemaDuration(node["cookTime"])
+	}
+	ingredients := node["recipeIngredient"]
+	if ingredients == nil {
+		ingredients = node["ingredients"] // pre-2017 markup
+	}
+	for _, line := range schemaStrings(ingredients) {
+		if line = cleanSchemaText(line); line != "" {
+			r.Ingredients = append(r.Ingredients, splitIngredientLine(line))
+		}
+	}
+	r.Instructions = schemaSteps(node["recipeInstructions"], 0)
+	return r
+}
+
+// schemaSteps flattens recipeInstructions, which may be text, a list of
+// strings, HowToSteps or HowToSections of HowToSteps.
+func schemaSteps(v any, depth int) []string {
+	if depth > 4 {
+		return nil
+	}
+	switch v := v.(type) {
+	case string:
+		return lo.FilterMap(strings.Split(v, "\n"), func(line string, _ int) (string, bool) {
+			line = cleanSchemaText(line)
+			return line, line != ""
+		})
+	case []any:
+		return lo.FlatMap(v, func(item any, _ int) []string { return schemaSteps(item, depth+1) })
+	case map[string]any:
+		if items, ok := v["itemListElement"]; ok {
+			return schemaSteps(items, depth+1)
+		}
+		if text := schemaText(v["text"]); text != "" {
+			return []string{text}
+		}
+		if name := schemaText(v["name"]); name != "" {
+			return []string{name}
+		}
+	}
+	return nil
+}
+
+var firstNumber = regexp.MustCompile(`\d+`)
+
+// schemaServings reads recipeYield, which is "4", "4 servings", 4 or ["4", "4 servings"].
+func schemaServings(v any) int {
+	for _, s := range schemaStrings(v) {
+		if m := firstNumber.FindString(s); m != "" {
+			if n, err := strconv.Atoi(m); err == nil && n > 0 {
+				return n
+			}
+		}
+	}
+	return 0
+}
+
+var isoDurationRe = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:\d+(?:\.\d+)?S)?)?$`)
+
+// schemaDuration reads an ISO 8601 duration like PT1H15M as "1 hour 15 minutes".
+func schemaDuration(v any) string {
+	m := isoDurationRe.FindStringSubmatch(strings.ToUpper(schemaText(v)))
+	if m == nil {
+		return ""
+	}
+	days, _ := strconv.Atoi(m[1])
+	hours, _ := strconv.Atoi(m[2])
+	minutes, _ := strconv.Atoi(m[3])
+	hours += days*24 + minutes/60
+	minutes %= 60
+	var parts []string
+	if hours > 0 {
+		parts = append(parts, plural(hours, "hour"))
+	}
+	if minutes > 0 {
+		parts = append(parts, plural(minutes, "minute"))
+	}
+	return strings.Join(parts, " ")
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return strconv.Itoa(n) + " " + unit + "s"
+}
+
+var ingredientQuantity = regexp.MustCompile(`(?i)^([\d¼½¾⅓⅔⅛/.,\-–\s]+(?:(?:cups?|tablespoons?|tbsps?|teaspoons?|tsps?|pounds?|lbs?|ounces?|oz|grams?|g|kg|ml|liters?|l|cloves?|cans?|pinch(?:es)?|dash(?:es)?|sprigs?|bunch(?:es)?|sticks?|slices?|heads?|pieces?)\b\.?)?)\s*(.+)$`)
+
+// splitIngredientLine splits "1 1/2 cups flour, sifted" into its quantity and name.
+func splitIngredientLine(line string) ai.Ingredient {
+	m := ingredientQuantity.FindStringSubmatch(line)
+	if m == nil || strings.TrimSpace(m[1]) == "" {
+		return ai.Ingredient{Name: line}
+	}
+	return ai.Ingredient{Name: m[2], Quantity: strings.TrimSpace(m[1])}
+}
+
+// recipeText writes a recipe out as plain text for the model.
+func recipeText(r ai.Recipe) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", r.Title)
+	if r.Description != "" {
+		fmt.Fprintf(&b, "%s\n", r.Description)
+	}
+	if r.Servings > 0 {
+		fmt.Fprintf(&b, "Serves %d\n", r.Servings)
+	}
+	if r.CookTime != "" {
+		fmt.Fprintf(&b, "Total time: %s\n", r.CookTime)
+	}
+	b.WriteString("\nIngredients:\n")
+	for _, ing := range r.Ingredients {
+		fmt.Fprintf(&b, "- %s\n", strings.TrimSpace(ing.Quantity+" "+ing.Name))
+	}
+	b.WriteString("\nInstructions:\n")
+	for i, step := range r.Instructions {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, step)
+	}
+	return b.String()
+}
+
+// pageText is the readable text of a page without JSON-LD, for the model to structure.
+func pageText(page []byte) string {
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return string(page)
+	}
+	var lines []string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "script", "style", "noscript", "svg", "nav", "header", "footer", "form", "iframe":
+				return
+			}
+		}
+		if n.Type == html.TextNode {
+			if line := strings.Join(strings.Fields(n.Data), " "); line != "" {
+				lines = append(lines, line)
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+	return strings.Join(lines, "\n")
+}
+
+// matchStaples points each imported ingredient at the staple that shares the
+// most words with it, so enrichRecipe can fill in its aisle and price. One-word
+// ingredients need that word; longer ones need two so "olive oil" doesn't land
+// on olives. Staples the user's ingredient preferences keep out of generation
+// are skipped here too.
+func matchStaples(ingredients []ai.Ingredient, staples []ai.InputIngredient, filter stapleFilter) {
+	type candidate struct {
+		ingredient ai.InputIngredient
+		words      []string
+	}
+	var candidates []candidate
+	for _, staple := range staples {
+		if !filter.allows(staple) {
+			continue
+		}
+		candidates = append(candidates, candidate{ingredient: staple, words: substituteWords(staple.Description)})
+	}
+	for i := range ingredients {
+		if strings.TrimSpace(ingredients[i].ProductID) != "" {
+			continue
+		}
+		words := substituteWords(searchTerm(ingredients[i].Name))
+		if len(words) == 0 {
+			continue
+		}
+		need := min(2, len(words))
+		var best *candidate
+		bestShared := 0
+		for j := range candidates {
+			c := &candidates[j]
+			shared := len(lo.Intersect(words, c.words))
+			if shared < need || shared < bestShared {
+				continue
+			}
+			if shared == bestShared && best != nil && !betterStaple(c.ingredient, best.ingredient) {
+				continue
+			}
+			best, bestShared = c, shared
+		}
+		if best != nil {
+			ingredients[i].ProductID = best.ingredient.ProductID
+		}
+	}
+}
+
+// betterStaple prefers the better graded staple, then the cheaper one.
+func betterStaple(a, b ai.InputIngredient) bool {
+	if c := cmp.Compare(a.Grade.GetScore(), b.Grade.GetScore()); c != 0 {
+		return c > 0
+	}
+	return substitutePrice(a) < substitutePrice(b)
+}
@@ -0,0 +1,161 @@
+package recipes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"careme/internal/ai"
+	"careme/internal/locations"
+	utypes "careme/internal/users/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const potRoastPage = `<!doctype html>
+<html><head>
+<script type="application/ld+json">
+{"@context":"https://schema.org","@graph":[
+  {"@type":"WebSite","name":"Grandma Cooks"},
+  {"@type":["Recipe","NewsArticle"],
+   "name":"Grandma&#39;s Pot Roast",
+   "description":"<p>Sunday dinner.</p>",
+   "recipeYield":["6","6 servings"],
+   "totalTime":"PT3H30M",
+   "recipeIngredient":["3 lb beef chuck roast","2 tablespoons olive oil","4 carrots, cut into chunks","Salt and pepper"],
+   "recipeInstructions":[
+     {"@type":"HowToSection","name":"Sear","itemListElement":[{"@type":"HowToStep","text":"Season and brown the roast."}]},
+     {"@type":"HowToStep","text":"Add the carrots and braise for 3 hours."}
+   ]}
+]}
+</script>
+</head><body><h1>Grandma's Pot Roast</h1><p>My grandma made this every Sunday.</p></body></html>`
+
+// structuringAIClient structures imported recipes and panics on everything else.
+type structuringAIClient struct {
+	captureRegenerateAIClient
+	recipe *ai.Recipe
+	err    error
+	text   string
+}
+
+func (c *structuringAIClient) StructureRecipe(_ context.Context, text string) (*ai.Recipe, error) {
+	c.text = text
+	if c.err != nil {
+		return nil, c.err
+	}
+	recipe := *c.recipe
+	return &recipe, nil
+}
+
+func TestRecipeFromJSONLD(t *testing.T) {
+	got, ok := recipeFromJSONLD([]byte(potRoastPage))
+	require.True(t, ok)
+	assert.Equal(t, "Grandma's Pot Roast", got.Title)
+	assert.Equal(t, "Sunday dinner.", got.Description)
+	assert.Equal(t, 6, got.Servings)
+	assert.Equal(t, "3 hours 30 minutes", got.CookTime)
+	assert.Equal(t, []ai.Ingredient{
+		{Name: "beef chuck roast", Quantity: "3 lb"},
+		{Name: "olive oil", Quantity: "2 tablespoons"},
+		{Name: "carrots, cut into chunks", Quantity: "4"},
+		{Name: "Salt and pepper"},
+	}, got.Ingredients)
+	assert.Equal(t, []string{"Season and brown the roast.", "Add the carrots and braise for 3 hours."}, got.Instructions)
+
+	_, ok = recipeFromJSONLD([]byte(`<script type="application/ld+json">{"@type":"WebSite","name":"Grandma Cooks"}</script>`))
+	assert.False(t, ok)
+}
+
+func TestMatchStaples(t *testing.T) {
+	staples := []ai.InputIngredient{
+		{ProductID: "chuck-1", Description: "Beef Chuck Roast", Grade: grade(7), PriceRegular: new(float32(15))},
+		{ProductID: "chuck-2", Description: "Organic Beef Chuck Roast", Grade: grade(7), PriceRegular: new(float32(19))},
+		{ProductID: "olive-1", Description: "Kalamata Olives", Grade: grade(9)},
+		{ProductID: "carrot-1", Description: "Carrots", Grade: grade(IngredientGradeCutoff)},
+	}
+	ingredients := []ai.Ingredient{
+		{Name: "beef chuck roast"},
+		{Name: "olive oil"},
+		{Name: "carrots, cut into chunks"},
+		{Name: "Bay leaf", ProductID: "bay-1"},
+	}
+
+	matchStaples(ingredients, staples, newStapleFilter(utypes.IngredientPreferences{}))
+
+	assert.Equal(t, "chuck-1", ingredients[0].ProductID, "equal grades go to the cheaper one")
+	assert.Empty(t, ingredients[1].ProductID, "one shared word isn't enough for a two word ingredient")
+	assert.Empty(t, ingredients[2].ProductID, "staples under the grade cutoff are skipped")
+	assert.Equal(t, "bay-1", ingredients[3].ProductID)
+
+	ingredients = []ai.Ingredient{{Name: "beef chuck roast"}}
+	matchStaples(ingredients, staples, newStapleFilter(utypes.IngredientPreferences{Bans: []string{"beef"}}))
+	assert.Empty(t, ingredients[0].ProductID, "banned staples aren't matched")
+}
+
+func TestImportRecipeKeepsPageRecipeWhenStructuringFails(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(potRoastPage))
+	}))
+	defer srv.Close()
+	previous := importHTTPClient
+	importHTTPClient = srv.Client()
+	defer func() { importHTTPClient = previous }()
+
+	aiStub := &structuringAIClient{err: errors.New("model unavailable")}
+	staples := &fixedStaplesService{ingredients: []ai.InputIngredient{
+		{ProductID: "chuck-1", Description: "Beef Chuck Roast", AisleNumber: "12", Grade: grade(8), PriceRegular: new(float32(14.99))},
+	}}
+	g := newTestGenerator(t, aiStub, nil, staples, noopstatuswriter{}, nil)
+	p := DefaultParams(&locations.Location{ID: "70004001", Name: "Store"}, time.Now())
+
+	recipe, err := g.ImportRecipe(t.Context(), p, srv.URL+"/pot-roast", "")
+	require.NoError(t, err)
+	assert.Contains(t, aiStub.text, "- 3 lb beef chuck roast", "the model gets the page's recipe, not the page")
+	assert.Equal(t, "Grandma's Pot Roast", recipe.Title)
+	assert.Equal(t, srv.URL+"/pot-roast", recipe.SourceURL)
+	assert.Equal(t, "chuck-1", recipe.Ingredients[0].ProductID)
+	assert.Equal(t, "12", recipe.Ingredients[0].AisleNumber)
+	assert.Equal(t, "$14.99", recipe.Ingredients[0].Price)
+}
+
+func TestImportRecipeStructuresPastedText(t *testing.T) {
+	aiStub := &structuringAIClient{recipe: &ai.Recipe{
+		Title:       "Weeknight Dal",
+		Ingredients: []ai.Ingredient{{Name: "Red lentils", Quantity: "1 cup"}},
+		WineStyles:  []string{"Riesling"},
+		ResponseID:  "resp-dal",
+	}}
+	staples := &fixedStaplesService{ingredients: []ai.InputIngredient{
+		{ProductID: "lentil-1", Description: "Red Lentils", AisleNumber: "7", PriceRegular: new(float32(2.49))},
+	}}
+	g := newTestGenerator(t, aiStub, nil, staples, noopstatuswriter{}, nil)
+	p := DefaultParams(&locations.Location{ID: "70004001", Name: "Store"}, time.Now())
+
+	recipe, err := g.ImportRecipe(t.Context(), p, "", "Weeknight dal\n1 cup red lentils\nSimmer until soft.")
+	require.NoError(t, err)
+	assert.Equal(t, "Weeknight dal\n1 cup red lentils\nSimmer until soft.", aiStub.text)
+	assert.Equal(t, "resp-dal", recipe.ResponseID)
+	assert.Equal(t, "lentil-1", recipe.Ingredients[0].ProductID)
+	assert.Equal(t, "$2.49", recipe.Ingredients[0].Price)
+
+	aiStub.recipe = &ai.Recipe{}
+	_, err = g.ImportRecipe(t.Context(), p, "", "just some words")
+	assert.ErrorIs(t, err, errNoRecipe)
+}
+
+func TestFetchRecipePageRefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(potRoastPage))
+	}))
+	defer srv.Close()
+
+	_, err := fetchRecipePage(t.Context(), srv.URL)
+	assert.ErrorIs(t, err, errImportFetch)
+	_, err = fetchRecipePage(t.Context(), "file:///etc/passwd")
+	assert.ErrorIs(t, err, errImportFetch)
+}
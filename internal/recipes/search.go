@@ -0,0 +1,203 @@
+package recipes
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"careme/internal/auth"
+	"careme/internal/cache"
+	"careme/internal/httpx"
+	"careme/internal/parallelism"
+	"careme/internal/recipes/critique"
+	"careme/internal/recipes/search"
+	"careme/internal/seasons"
+	"careme/internal/templates"
+	utypes "careme/internal/users/types"
+
+	"github.com/samber/lo"
+)
+
+const (
+	// searchIndexMaxAge is how long a recipe saved on another replica can take
+	// to show up in search here.
+	searchIndexMaxAge = 2 * time.Minute
+	maxSearchLimit    = 200
+
+	searchScopeAll  = "all"
+	searchScopeMine = "mine"
+)
+
+type apiSearchResults struct {
+	Query   search.Query `json:"query"`
+	Scope   string       `json:"scope"`
+	Results []search.Doc `json:"results"`
+}
+
+// parseSearchRequest reads q plus the explicit max_minutes, min_score, uses
+// and limit filters, which win over any typed into q.
+func parseSearchRequest(r *http.Request) (search.Query, string, error) {
+	values := r.URL.Query()
+	q := search.ParseQuery(values.Get("q"))
+	for _, f := range []struct {
+		name string
+		dst  *int
+	}{{"max_minutes", &q.MaxMinutes}, {"min_score", &q.MinScore}, {"limit", &q.Limit}} {
+		raw := strings.TrimSpace(values.Get(f.name))
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return search.Query{}, "", fmt.Errorf("invalid %s", f.name)
+		}
+		*f.dst = n
+	}
+	q.Limit = min(q.Limit, maxSearchLimit)
+	for _, term := range values["uses"] {
+		if term = strings.ToLower(strings.TrimSpace(term)); term != "" {
+			q.Uses = append(q.Uses, term)
+		}
+	}
+	scope := cmp.Or(strings.TrimSpace(values.Get("scope")), searchScopeAll)
+	if scope != searchScopeAll && scope != searchScopeMine {
+		return search.Query{}, "", fmt.Errorf("invalid scope %q", scope)
+	}
+	return q, scope, nil
+}
+
+// savedRecipeHashes scopes a search to what the user saved. An empty set still
+// scopes, so nothing saved finds nothing rather than everything.
+func savedRecipeHashes(u *utypes.User) map[string]bool {
+	hashes := make(map[string]bool, len(u.LastRecipes))
+	for _, saved := range u.LastRecipes {
+		hashes[saved.Hash] = true
+	}
+	return hashes
+}
+
+func (s *server) searchRecipes(ctx context.Context, q search.Query) ([]search.Doc, error) {
+	results, err := s.searchIndex.Search(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return lo.Ternary(results == nil, []search.Doc{}, results), nil
+}
+
+func (s *server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	q, scope, err := parseSearchRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if scope == searchScopeMine {
+		currentUser, err := s.storage.FromRequest(ctx, r, s.clerk)
+		if err != nil {
+			if errors.Is(err, auth.ErrNoSession) {
+				redirectToSignIn(w, r, http.StatusUnauthorized)
+				return
+			}
+			slog.ErrorContext(ctx, "failed to load user for search", "error", err)
+			http.Error(w, "unable to load account", http.StatusInternalServerError)
+			return
+		}
+		q.Within = savedRecipeHashes(currentUser)
+	}
+	results, err := s.searchRecipes(ctx, q)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to search recipes", "error", err)
+		http.Error(w, "failed to search recipes", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := templates.Search.Execute(w, struct {
+		ClarityScript   template.HTML
+		GoogleTagScript template.HTML
+		Style           seasons.Style
+		Text            string
+		Scope           string
+		Results         []search.Doc
+	}{
+		ClarityScript:   templates.ClarityScript(ctx),
+		GoogleTagScript: templates.GoogleTagScript(),
+		Style:           seasons.GetCurrentStyle(),
+		Text:            r.URL.Query().Get("q"),
+		Scope:           scope,
+		Results:         results,
+	}); err != nil {
+		slog.ErrorContext(ctx, "failed to render search page", "error", err)
+		http.Error(w, "unable to render search", http.StatusInternalServerError)
+	}
+}
+
+func (s *server) handleAPISearch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	q, scope, err := parseSearchRequest(r)
+	if err != nil {
+		httpx.JSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if scope == searchScopeMine {
+		currentUser, ok := s.apiUser(w, r)
+		if !ok {
+			return
+		}
+		q.Within = savedRecipeHashes(currentUser)
+	}
+	results, err := s.searchRecipes(ctx, q)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to search recipes for api", "error", err)
+		httpx.JSONError(w, "failed to search recipes", http.StatusInternalServerError)
+		return
+	}
+	writeAPIJSON(w, r, http.StatusOK, apiSearchResults{Query: q, Scope: scope, Results: results})
+}
+
+const rebuildBatch = 50
+
+// RebuildSearch indexes every cached recipe with its critique score, for
+// recipes saved before search existed or while indexing was failing. It
+// returns how many recipes it indexed; ones that fail to load are skipped.
+func RebuildSearch(ctx context.Context, c cache.ListCache) (int, error) {
+	keys, err := c.List(ctx, recipeCachePrefix, "")
+	if err != nil {
+		return 0, fmt.Errorf("list recipes: %w", err)
+	}
+	rio := IO(c)
+	critiques := critique.NewStore(c)
+	indexed := 0
+	for _, batch := range lo.Chunk(keys, rebuildBatch) {
+		docs, err := parallelism.MapWithErrors(batch, func(hash string) (search.Doc, error) {
+			recipe, err := rio.SingleFromCache(ctx, hash)
+			if err != nil {
+				return search.Doc{}, fmt.Errorf("load recipe %s: %w", hash, err)
+			}
+			doc := search.DocFor(*recipe)
+			// older recipes hash differently now; the key is what links resolve.
+			doc.Hash = hash
+			if crit, err := critiques.Load(ctx, hash); err == nil {
+				doc.Score = crit.OverallScore
+			} else if !errors.Is(err, cache.ErrNotFound) {
+				return search.Doc{}, fmt.Errorf("load critique %s: %w", hash, err)
+			}
+			return doc, nil
+		})
+		if err != nil {
+			slog.WarnContext(ctx, "skipping recipes that failed to load for search", "error", err)
+		}
+		if err := rio.search.Put(ctx, docs...); err != nil {
+			return indexed, err
+		}
+		indexed += len(docs)
+	}
+	return indexed, nil
+}
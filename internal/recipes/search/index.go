@@ -0,0 +1,279 @@
+package search
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"careme/internal/cache"
+
+	"github.com/samber/lo"
+)
+
+const (
+	DefaultLimit  = 50
+	reloadTimeout = time.Minute
+)
+
+// a word in the title says more about a recipe than one in its description.
+const (
+	titleWeight       = 3
+	cuisineWeight     = 2
+	ingredientWeight  = 2
+	descriptionWeight = 1
+)
+
+type entry struct {
+	doc         Doc
+	ingredients map[string]bool
+}
+
+// Index is an inverted index over every doc in a Store. It loads on first
+// search and reloads in the background once it's older than maxAge, so a
+// recipe saved on another replica shows up within maxAge. A reload only reads
+// docs it hasn't seen or that were still waiting on a critique score.
+type Index struct {
+	store  Store
+	keys   cache.ListCache
+	maxAge time.Duration
+	now    func() time.Time
+
+	mu       sync.RWMutex
+	entries  map[string]*entry
+	postings map[string]map[string]int // word -> hash -> weight
+	loadedAt time.Time
+
+	reloading atomic.Bool
+}
+
+func NewIndex(c cache.ListCache, maxAge time.Duration) *Index {
+	return &Index{store: NewStore(c), keys: c, maxAge: maxAge, now: time.Now}
+}
+
+// Refresh reloads the index from the store. A doc that fails to read is tried
+// again next time.
+func (idx *Index) Refresh(ctx context.Context) error {
+	hashes, err := idx.keys.List(ctx, docPrefix, "")
+	if err != nil {
+		return fmt.Errorf("list search docs: %w", err)
+	}
+	docs := make(map[string]Doc, len(hashes))
+	var stale []string
+	idx.mu.RLock()
+	for _, hash := range hashes {
+		// a score is the only thing that changes once a recipe is indexed.
+		if e, ok := idx.entries[hash]; ok && e.doc.Score != 0 {
+			docs[hash] = e.doc
+			continue
+		}
+		stale = append(stale, hash)
+	}
+	idx.mu.RUnlock()
+	fetched, err := idx.store.Get(ctx, stale...)
+	if err != nil {
+		slog.WarnContext(ctx, "skipping search docs that failed to load", "error", err)
+	}
+	for _, d := range fetched {
+		docs[d.Hash] = d
+	}
+
+	entries := make(map[string]*entry, len(docs))
+	postings := map[string]map[string]int{}
+	for _, d := range docs {
+		// a doc with only a critique score is waiting on its recipe.
+		if d.Title == "" {
+			continue
+		}
+		e := &entry{doc: d, ingredients: map[string]bool{}}
+		entries[d.Hash] = e
+		weights := map[string]int{}
+		addField := func(text string, weight int) {
+			for _, word := range lo.Uniq(tokenize(text)) {
+				weights[word] += weight
+			}
+		}
+		addField(d.Title, titleWeight)
+		addField(d.Cuisine, cuisineWeight)
+		addField(d.Description, descriptionWeight)
+		ingredientWords := lo.Uniq(lo.FlatMap(d.Ingredients, func(name string, _ int) []string { return tokenize(name) }))
+		for _, word := range ingredientWords {
+			weights[word] += ingredientWeight
+			e.ingredients[word] = true
+		}
+		for word, weight := range weights {
+			if postings[word] == nil {
+				postings[word] = map[string]int{}
+			}
+			postings[word][d.Hash] = weight
+		}
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.entries = entries
+	idx.postings = postings
+	idx.loadedAt = idx.now()
+	return nil
+}
+
+func (idx *Index) ensureLoaded(ctx context.Context) error {
+	idx.mu.RLock()
+	loadedAt := idx.loadedAt
+	idx.mu.RUnlock()
+	if loadedAt.IsZero() {
+		return idx.Refresh(ctx)
+	}
+	if idx.now().Sub(loadedAt) < idx.maxAge || !idx.reloading.CompareAndSwap(false, true) {
+		return nil
+	}
+	go func() {
+		defer idx.reloading.Store(false)
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), reloadTimeout)
+		defer cancel()
+		if err := idx.Refresh(ctx); err != nil {
+			slog.ErrorContext(ctx, "failed to reload recipe search index", "error", err)
+		}
+	}()
+	return nil
+}
+
+// Search returns the docs matching q, best first: the most text matched, then
+// the best critique score.
+func (idx *Index) Search(ctx context.Context, q Query) ([]Doc, error) {
+	if err := idx.ensureLoaded(ctx); err != nil {
+		return nil, err
+	}
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	relevance := idx.match(tokenize(q.Text))
+	uses := lo.Map(q.Uses, func(term string, _ int) []string { return tokenize(term) })
+	type hit struct {
+		doc       Doc
+		relevance int
+	}
+	var hits []hit
+	for hash, weight := range relevance {
+		e := idx.entries[hash]
+		if !q.allows(e, uses) {
+			continue
+		}
+		hits = append(hits, hit{doc: e.doc, relevance: weight})
+	}
+	slices.SortFunc(hits, func(a, b hit) int {
+		return cmp.Or(
+			cmp.Compare(b.relevance, a.relevance),
+			cmp.Compare(b.doc.Score, a.doc.Score),
+			strings.Compare(a.doc.Title, b.doc.Title),
+			strings.Compare(a.doc.Hash, b.doc.Hash),
+		)
+	})
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return lo.Map(hits, func(h hit, _ int) Doc { return h.doc }), nil
+}
+
+// Docs is every searchable doc, imports included, in no particular order.
+func (idx *Index) Docs(ctx context.Context) ([]Doc, error) {
+	if err := idx.ensureLoaded(ctx); err != nil {
+		return nil, err
+	}
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	docs := make([]Doc, 0, len(idx.entries))
+	for _, e := range idx.entries {
+		docs = append(docs, e.doc)
+	}
+	return docs, nil
+}
+
+// match finds the docs with every word, with how well they matched. No words
+// matches everything equally.
+func (idx *Index) match(words []string) map[string]int {
+	if len(words) == 0 {
+		return lo.MapValues(idx.entries, func(*entry, string) int { return 0 })
+	}
+	matched := maps.Clone(idx.postings[words[0]])
+	if matched == nil {
+		return nil
+	}
+	for _, word := range words[1:] {
+		postings := idx.postings[word]
+		for hash, weight := range matched {
+			if extra, ok := postings[hash]; ok {
+				matched[hash] = weight + extra
+			} else {
+				delete(matched, hash)
+			}
+		}
+	}
+	return matched
+}
+
+func (q Query) allows(e *entry, uses [][]string) bool {
+	d := e.doc
+	if q.Within != nil {
+		if !q.Within[d.Hash] {
+			return false
+		}
+	} else if d.Imported {
+		return false
+	}
+	if q.MaxMinutes > 0 && (d.CookMinutes == 0 || d.CookMinutes > q.MaxMinutes) {
+		return false
+	}
+	if d.Score < q.MinScore {
+		return false
+	}
+	for _, words := range uses {
+		if len(words) == 0 || !lo.EveryBy(words, func(w string) bool { return e.ingredients[w] }) {
+			return false
+		}
+	}
+	return true
+}
+
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "the": true, "of": true, "in": true, "on": true, "for": true, "to": true,
+}
+
+// tokenize lowercases s into words, dropping stop words and plurals so
+// "Tomatoes" finds "tomato".
+func tokenize(s string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	words := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if len(f) < 2 || stopWords[f] {
+			continue
+		}
+		words = append(words, singular(f))
+	}
+	return words
+}
+
+func singular(w string) string {
+	switch {
+	case len(w) > 4 && strings.HasSuffix(w, "ies"):
+		return w[:len(w)-3] + "y"
+	case len(w) > 4 && strings.HasSuffix(w, "oes"):
+		return w[:len(w)-2]
+	case len(w) > 3 && strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss") && !strings.HasSuffix(w, "us"):
+		return w[:len(w)-1]
+	}
+	return w
+}
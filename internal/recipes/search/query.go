@@ -0,0 +1,55 @@
+package search
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Query is a search. Text has to match all its words; the filters narrow what
+// matches. A query with no text lists everything that passes the filters.
+type Query struct {
+	Text       string   `json:"text,omitempty"`
+	MaxMinutes int      `json:"max_minutes,omitempty"`
+	Uses       []string `json:"uses,omitempty"`
+	MinScore   int      `json:"min_score,omitempty"`
+	// Within limits results to these hashes, like a user's saved recipes.
+	// Without it the public corpus is searched, which leaves out imports.
+	Within map[string]bool `json:"-"`
+	Limit  int             `json:"-"`
+}
+
+var (
+	underPattern = regexp.MustCompile(`(?i)\bunder\s+(\d+)\s*(?:m|mins?|minutes?)\b`)
+	scorePattern = regexp.MustCompile(`(?i)\b(?:score|rated)\s*(>=|≥|>|=)?\s*(\d+)\+?`)
+	usesPattern  = regexp.MustCompile(`(?i)\b(?:uses|using|with)\s+([\p{L}'-]+)`)
+)
+
+// ParseQuery pulls filters like "under 30 minutes", "uses salmon" and
+// "score ≥ 9" out of what someone typed; the rest is the text.
+func ParseQuery(s string) Query {
+	var q Query
+	s = underPattern.ReplaceAllStringFunc(s, func(m string) string {
+		q.MaxMinutes, _ = strconv.Atoi(underPattern.FindStringSubmatch(m)[1])
+		return " "
+	})
+	s = scorePattern.ReplaceAllStringFunc(s, func(m string) string {
+		match := scorePattern.FindStringSubmatch(m)
+		q.MinScore, _ = strconv.Atoi(match[2])
+		if match[1] == ">" {
+			q.MinScore++
+		}
+		return " "
+	})
+	s = usesPattern.ReplaceAllStringFunc(s, func(m string) string {
+		q.Uses = append(q.Uses, strings.ToLower(usesPattern.FindStringSubmatch(m)[1]))
+		return " "
+	})
+	q.Text = strings.Join(strings.Fields(s), " ")
+	return q
+}
+
+// IsZero is true for a query that would list everything.
+func (q Query) IsZero() bool {
+	return q.Text == "" && q.MaxMinutes == 0 && len(q.Uses) == 0 && q.MinScore == 0
+}
@@ -0,0 +1,95 @@
+package search
+
+import (
+	"testing"
+	"time"
+
+	"careme/internal/ai"
+	"careme/internal/cache"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseQuery(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want Query
+	}{
+		{in: "salmon under 30 minutes", want: Query{Text: "salmon", MaxMinutes: 30}},
+		{in: "uses salmon", want: Query{Uses: []string{"salmon"}}},
+		{in: "Tacos score ≥ 9", want: Query{Text: "Tacos", MinScore: 9}},
+		{in: "score>8 with Leeks under 45m", want: Query{MaxMinutes: 45, MinScore: 9, Uses: []string{"leeks"}}},
+		{in: "rated 7+ curry", want: Query{Text: "curry", MinScore: 7}},
+		{in: "  weeknight   pasta ", want: Query{Text: "weeknight pasta"}},
+	} {
+		t.Run(tc.in, func(t *testing.T) {
+			assert.Equal(t, tc.want, ParseQuery(tc.in))
+		})
+	}
+}
+
+func TestStore_ScoreSurvivesResave(t *testing.T) {
+	store := NewStore(cache.NewInMemoryCache())
+	recipe := ai.Recipe{Title: "Salmon Tacos", CookTime: "1 hour 10 minutes", Cuisine: "Baja"}
+	doc := DocFor(recipe)
+	assert.Equal(t, 70, doc.CookMinutes)
+
+	require.NoError(t, store.SetScore(t.Context(), doc.Hash, 9), "critiques can land before the recipe is indexed")
+	require.NoError(t, store.Put(t.Context(), doc))
+	require.NoError(t, store.SetScore(t.Context(), "Zother", 4))
+
+	docs, err := store.Get(t.Context(), doc.Hash, "Zother", "missing")
+	require.NoError(t, err)
+	require.Len(t, docs, 2)
+	got, ok := lo.Find(docs, func(d Doc) bool { return d.Hash == doc.Hash })
+	require.True(t, ok)
+	assert.Equal(t, "Salmon Tacos", got.Title)
+	assert.Equal(t, "Baja", got.Cuisine)
+	assert.Equal(t, 9, got.Score)
+}
+
+func TestIndex_Search(t *testing.T) {
+	c := cache.NewInMemoryCache()
+	store := NewStore(c)
+	docs := []Doc{
+		{Hash: "a1", Title: "Salmon Tacos", Cuisine: "Mexican", Ingredients: []string{"Salmon fillets", "Corn tortillas"}, CookMinutes: 25, Score: 8},
+		{Hash: "b2", Title: "Miso Glazed Fish", Description: "Salmon with a sweet glaze.", Ingredients: []string{"Salmon", "White miso"}, CookMinutes: 20, Score: 9},
+		{Hash: "c3", Title: "Braised Leeks", Ingredients: []string{"Leeks", "Butter"}, CookMinutes: 50, Score: 9},
+		{Hash: "d4", Title: "Grandma's Salmon Loaf", Ingredients: []string{"Canned salmon"}, CookMinutes: 15, Imported: true},
+		{Hash: "e5", Title: "Potato Salad", Ingredients: []string{"Potatoes"}},
+	}
+	require.NoError(t, store.Put(t.Context(), docs...))
+	require.NoError(t, store.SetScore(t.Context(), "f6", 10))
+	idx := NewIndex(c, time.Hour)
+
+	hashes := func(q Query) []string {
+		t.Helper()
+		results, err := idx.Search(t.Context(), q)
+		require.NoError(t, err)
+		return lo.Map(results, func(d Doc, _ int) string { return d.Hash })
+	}
+
+	assert.Equal(t, []string{"a1", "b2"}, hashes(ParseQuery("salmon")), "a title match outranks a better critique score; imports stay out")
+	assert.Equal(t, []string{"b2"}, hashes(ParseQuery("salmon score >= 9")))
+	assert.Equal(t, []string{"b2", "a1"}, hashes(ParseQuery("uses salmon under 30 minutes")))
+	assert.Equal(t, []string{"a1"}, hashes(ParseQuery("mexican salmon taco")))
+	assert.Equal(t, []string{"e5"}, hashes(ParseQuery("potato")), "plurals match singulars")
+	assert.Empty(t, hashes(ParseQuery("salmon leeks")), "every word has to match")
+	assert.Equal(t, []string{"c3", "b2", "a1", "e5"}, hashes(Query{}), "no query lists the best first, then by title, leaving out score-only docs")
+	assert.Equal(t, []string{"d4"}, hashes(Query{Text: "salmon", Within: map[string]bool{"d4": true}}), "your own imports are yours to find")
+	assert.Empty(t, hashes(Query{Within: map[string]bool{}}))
+	assert.Len(t, hashes(Query{Limit: 2}), 2)
+
+	// saves after the index loaded show up once it's reloaded.
+	require.NoError(t, store.Put(t.Context(), Doc{Hash: "g7", Title: "Salmon Curry"}))
+	assert.NotContains(t, hashes(ParseQuery("curry")), "g7")
+	require.NoError(t, idx.Refresh(t.Context()))
+	assert.Equal(t, []string{"g7"}, hashes(ParseQuery("curry")))
+
+	// a critique that lands after the recipe was loaded shows up on reload too.
+	require.NoError(t, store.SetScore(t.Context(), "g7", 9))
+	require.NoError(t, idx.Refresh(t.Context()))
+	assert.Equal(t, []string{"g7"}, hashes(ParseQuery("curry score >= 9")))
+}
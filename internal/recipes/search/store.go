@@ -0,0 +1,146 @@
+// Package search finds generated recipes by what's in them. Each saved recipe
+// gets a small Doc in the cache, and an in-memory Index built from those docs
+// answers queries like "salmon under 30 minutes score >= 9".
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"careme/internal/ai"
+	"careme/internal/cache"
+	"careme/internal/parallelism"
+	"careme/internal/recipes/export"
+
+	"github.com/samber/lo"
+)
+
+const (
+	Prefix = "search/"
+	// each recipe's doc is its own blob, so saves never fight over a shared one.
+	docPrefix = Prefix + "recipes/"
+	// how many docs are read at once when loading.
+	loadBatch = 64
+)
+
+// Doc is what search knows about a recipe.
+type Doc struct {
+	Hash        string   `json:"hash"`
+	Title       string   `json:"title"`
+	Description string   `json:"description,omitempty"`
+	Ingredients []string `json:"ingredients,omitempty"`
+	Cuisine     string   `json:"cuisine,omitempty"`
+	CookMinutes int      `json:"cook_minutes,omitempty"`
+	// Score is the critique's overall score, 0 until the recipe is critiqued.
+	Score int `json:"score,omitempty"`
+	// Imported recipes came from someone's URL or paste and stay out of the
+	// public corpus.
+	Imported bool `json:"imported,omitempty"`
+}
+
+// DocFor indexes a recipe without its critique score.
+func DocFor(r ai.Recipe) Doc {
+	return Doc{
+		Hash:        r.ComputeHash(),
+		Title:       r.Title,
+		Description: r.Description,
+		Ingredients: lo.Map(r.Ingredients, func(ing ai.Ingredient, _ int) string { return ing.Name }),
+		Cuisine:     r.Cuisine,
+		CookMinutes: int(export.CookDuration(r.CookTime) / time.Minute),
+		Imported:    r.SourceURL != "",
+	}
+}
+
+// Store keeps docs in the cache so every replica searches the same recipes.
+type Store struct {
+	cache cache.Cache
+}
+
+func NewStore(c cache.Cache) Store {
+	return Store{cache: c}
+}
+
+func docKey(hash string) string {
+	return docPrefix + hash
+}
+
+// Put adds or replaces docs. A doc without a score keeps the one already
+// indexed, since recipes are usually critiqued after they're saved.
+func (s Store) Put(ctx context.Context, docs ...Doc) error {
+	for _, d := range docs {
+		if d.Hash == "" {
+			continue
+		}
+		_, err := cache.UpdateJSON(ctx, s.cache, docKey(d.Hash), func(stored *Doc, _ bool) error {
+			if d.Score == 0 {
+				d.Score = stored.Score
+			}
+			*stored = d
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("update search doc %s: %w", d.Hash, err)
+		}
+	}
+	return nil
+}
+
+// SetScore records a critique score. A recipe that isn't indexed yet gets a
+// doc with just the score, which Put fills in later.
+func (s Store) SetScore(ctx context.Context, hash string, score int) error {
+	if hash == "" {
+		return errors.New("recipe hash is required")
+	}
+	_, err := cache.UpdateJSON(ctx, s.cache, docKey(hash), func(d *Doc, _ bool) error {
+		if d.Score == score {
+			return cache.ErrSkipUpdate
+		}
+		d.Hash = hash
+		d.Score = score
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("update search score for %s: %w", hash, err)
+	}
+	return nil
+}
+
+// Get reads the docs for hashes. Ones that are missing are left out; ones that
+// fail to read are left out too and reported in the error.
+func (s Store) Get(ctx context.Context, hashes ...string) ([]Doc, error) {
+	var docs []Doc
+	var errs []error
+	for _, batch := range lo.Chunk(hashes, loadBatch) {
+		got, err := parallelism.MapWithErrors(batch, func(hash string) (Doc, error) {
+			d, err := s.get(ctx, hash)
+			if err != nil && !errors.Is(err, cache.ErrNotFound) {
+				return Doc{}, fmt.Errorf("load search doc %s: %w", hash, err)
+			}
+			return d, nil
+		})
+		docs = append(docs, lo.Filter(got, func(d Doc, _ int) bool { return d.Hash != "" })...)
+		errs = append(errs, err)
+	}
+	return docs, errors.Join(errs...)
+}
+
+func (s Store) get(ctx context.Context, hash string) (Doc, error) {
+	r, err := s.cache.Get(ctx, docKey(hash))
+	if err != nil {
+		return Doc{}, err
+	}
+	defer func() {
+		if err := r.Close(); err != nil {
+			slog.ErrorContext(ctx, "failed to close search doc", "hash", hash, "error", err)
+		}
+	}()
+	var d Doc
+	if err := json.NewDecoder(r).Decode(&d); err != nil {
+		return Doc{}, err
+	}
+	return d, nil
+}
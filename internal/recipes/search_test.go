@@ -0,0 +1,96 @@
+package recipes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"careme/internal/ai"
+	"careme/internal/cache"
+	"careme/internal/recipes/critique"
+	"careme/internal/recipes/search"
+	"careme/internal/users"
+	utypes "careme/internal/users/types"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearch_SavedAndCritiquedRecipes(t *testing.T) {
+	cacheStore := cache.NewFileCache(filepath.Join(t.TempDir(), "cache"))
+	storage := users.NewStorage(cacheStore)
+	s := newTestServer(t, withTestCache(cacheStore), withTestStorage(storage))
+
+	tacos := ai.Recipe{Title: "Salmon Tacos", CookTime: "25 minutes", Cuisine: "Mexican", Ingredients: []ai.Ingredient{{Name: "Salmon fillets"}}}
+	glazed := ai.Recipe{Title: "Miso Glazed Salmon", CookTime: "40 minutes", Ingredients: []ai.Ingredient{{Name: "Salmon"}}}
+	imported := ai.Recipe{Title: "Salmon Loaf", CookTime: "20 minutes", SourceURL: "https://example.com/loaf"}
+	for _, r := range []ai.Recipe{tacos, glazed, imported} {
+		require.NoError(t, s.SaveRecipe(t.Context(), r))
+	}
+	require.NoError(t, critique.NewStore(cacheStore).Save(t.Context(), glazed.ComputeHash(), &ai.RecipeCritique{OverallScore: 9}))
+	require.NoError(t, storage.Update(&utypes.User{
+		ID:          "mock-clerk-user-id",
+		Email:       []string{"you@careme.cooking"},
+		CreatedAt:   time.Now(),
+		ShoppingDay: time.Saturday.String(),
+		LastRecipes: []utypes.Recipe{{Title: imported.Title, Hash: imported.ComputeHash(), CreatedAt: time.Now()}},
+	}))
+
+	titles := func(target string) []string {
+		t.Helper()
+		rr := serveAPI(t, s, http.MethodGet, target, "")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var got apiSearchResults
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+		return lo.Map(got.Results, func(d search.Doc, _ int) string { return d.Title })
+	}
+	assert.Equal(t, []string{"Miso Glazed Salmon", "Salmon Tacos"}, titles("/api/v1/recipes/search?q=salmon"))
+	assert.Equal(t, []string{"Salmon Tacos"}, titles("/api/v1/recipes/search?q=salmon+under+30+minutes"))
+	assert.Equal(t, []string{"Miso Glazed Salmon"}, titles("/api/v1/recipes/search?q=uses+salmon&min_score=9"))
+	assert.Equal(t, []string{"Salmon Loaf"}, titles("/api/v1/recipes/search?q=salmon&scope=mine"))
+
+	rr := serveAPI(t, s, http.MethodGet, "/api/v1/recipes/search?scope=friends", "")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/search?q=mexican", nil)
+	rr = httptest.NewRecorder()
+	s.handleSearch(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `href="/recipe/`+tacos.ComputeHash()+`"`)
+	assert.Contains(t, rr.Body.String(), "Mexican &middot; 25 min")
+}
+
+func TestSearch_MineRequiresSession(t *testing.T) {
+	s := newTestServer(t, withTestClerk(noSessionAuth{}))
+
+	rr := serveAPI(t, s, http.MethodGet, "/api/v1/recipes/search?scope=mine", "")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	rr = serveAPI(t, s, http.MethodGet, "/api/v1/recipes/search?q=anything", "")
+	assert.Equal(t, http.StatusOK, rr.Code, "everyone's recipes are public")
+	assert.JSONEq(t, `{"query":{"text":"anything"},"scope":"all","results":[]}`, rr.Body.String())
+}
+
+func TestRebuildSearch(t *testing.T) {
+	cacheStore := cache.NewFileCache(filepath.Join(t.TempDir(), "cache"))
+	recipe := ai.Recipe{Title: "Braised Leeks", Ingredients: []ai.Ingredient{{Name: "Leeks"}}}
+	hash := recipe.ComputeHash()
+	// saved straight to the cache the way recipes were before search.
+	require.NoError(t, cacheStore.Put(t.Context(), recipeCachePrefix+hash, string(lo.Must(json.Marshal(recipe))), cache.Unconditional()))
+	require.NoError(t, cacheStore.Put(t.Context(), recipeCachePrefix+"broken", "{", cache.Unconditional()))
+	require.NoError(t, cacheStore.Put(t.Context(), "recipe_critiques/"+hash, `{"overall_score":8}`, cache.Unconditional()))
+
+	indexed, err := RebuildSearch(t.Context(), cacheStore)
+	require.NoError(t, err)
+	assert.Equal(t, 1, indexed)
+
+	results, err := search.NewIndex(cacheStore, time.Hour).Search(t.Context(), search.ParseQuery("leek"))
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, hash, results[0].Hash)
+	assert.Equal(t, 8, results[0].Score)
+}
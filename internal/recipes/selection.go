@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"slices"
 	"strings"
 	"time"
@@ -15,7 +16,12 @@ import (
 	"github.com/samber/lo"
 )
 
-const recipeSelectionCachePrefix = "recipe_selection/"
+const (
+	recipeSelectionCachePrefix = "recipe_selection/"
+	// recentSelectionsCachePrefix keeps each user's newest selections, newest
+	// first, so taste is learned without listing every list they've touched.
+	recentSelectionsCachePrefix = "recipe_selection_recent/"
+)
 
 // recipeSelection tracks which recipes have been saved and dismisse by a user between regeneration/finalization.
 // After that they are merged back into params.
@@ -76,13 +82,69 @@ func (rio recipeio) saveRecipeSelection(ctx context.Context, userID, originHash
 	if err != nil {
 		return fmt.Errorf("failed to marshal recipe selection: %w", err)
 	}
-	// good place for etags :)
 	if err := rio.Cache.Put(ctx, recipeSelectionKey(userID, originHash), string(body), cache.Unconditional()); err != nil {
 		return fmt.Errorf("failed to save recipe selection: %w", err)
 	}
 	return nil
 }
 
+// updateRecipeSelection applies fn to the stored selection, re-running it on a
+// fresh read if a save or dismiss from another tab landed in between.
+func (rio recipeio) updateRecipeSelection(ctx context.Context, userID, originHash string, fn func(*recipeSelection)) error {
+	_, err := cache.UpdateJSON(ctx, rio.Cache, recipeSelectionKey(userID, originHash), func(selection *recipeSelection, _ bool) error {
+		fn(selection)
+		selection.UpdatedAt = time.Now()
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save recipe selection: %w", err)
+	}
+	if err := rio.touchRecentSelection(ctx, userID, originHash); err != nil {
+		// taste just learns from an older set of lists.
+		slog.WarnContext(ctx, "failed to update recent recipe selections", "user_id", userID, "origin_hash", originHash, "error", err)
+	}
+	return nil
+}
+
+func recentSelectionsKey(userID string) string {
+	return recentSelectionsCachePrefix + strings.TrimSpace(userID)
+}
+
+// touchRecentSelection moves originHash to the front of the user's recent
+// selections, keeping the newest tasteSelectionHistory.
+func (rio recipeio) touchRecentSelection(ctx context.Context, userID, originHash string) error {
+	_, err := cache.UpdateJSON(ctx, rio.Cache, recentSelectionsKey(userID), func(recent *[]string, _ bool) error {
+		if len(*recent) > 0 && (*recent)[0] == originHash {
+			return cache.ErrSkipUpdate
+		}
+		*recent = append([]string{originHash}, lo.Without(*recent, originHash)...)
+		*recent = (*recent)[:min(len(*recent), tasteSelectionHistory)]
+		return nil
+	})
+	return err
+}
+
+// recentSelections is the origin hashes of the user's newest selections,
+// newest first.
+func (rio recipeio) recentSelections(ctx context.Context, userID string) ([]string, error) {
+	reader, err := rio.Cache.Get(ctx, recentSelectionsKey(userID))
+	if err != nil {
+		if errors.Is(err, cache.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("load recent recipe selections: %w", err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	var recent []string
+	if err := json.NewDecoder(reader).Decode(&recent); err != nil {
+		return nil, fmt.Errorf("failed to decode recent recipe selections: %w", err)
+	}
+	return recent, nil
+}
+
 func selectionFromSaved(saved []ai.Recipe) recipeSelection {
 	var selection recipeSelection
 	for _, s := range saved {
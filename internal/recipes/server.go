@@ -10,6 +10,7 @@ import (
 	"html/template"
 	"io"
 	"log/slog"
+	"mime"
 	"net/http"
 	"net/url"
 	"slices"
@@ -19,6 +20,7 @@ import (
 	"time"
 
 	"careme/internal/ai"
+	"careme/internal/ai/spend"
 	"careme/internal/auth"
 	"careme/internal/cache"
 	"careme/internal/config"
@@ -27,7 +29,10 @@ import (
 	"careme/internal/locations"
 	"careme/internal/parallelism"
 	"careme/internal/recipes/critique"
+	"careme/internal/recipes/export"
 	"careme/internal/recipes/feedback"
+	"careme/internal/recipes/search"
+	"careme/internal/recipes/similar"
 	recipestatus "careme/internal/recipes/status"
 	"careme/internal/routing"
 	"careme/internal/seasons"
@@ -75,6 +80,9 @@ type generator interface {
 	RegenerateRecipe(ctx context.Context, instructions []string, previous ai.ResponseRef) (*ai.Recipe, error)
 	AskQuestion(ctx context.Context, question string, previous ai.ResponseRef) (*ai.QuestionResponse, error)
 	PickAWine(ctx context.Context, location string, recipe ai.Recipe, date time.Time) (*ai.WineSelection, error)
+	FindSubstitutes(ctx context.Context, p *generatorParams, missing ai.Ingredient) ([]ai.InputIngredient, error)
+	SubstituteIngredient(ctx context.Context, p *generatorParams, recipe ai.Recipe, missing ai.Ingredient, substitute ai.InputIngredient) (*ai.Recipe, error)
+	ImportRecipe(ctx context.Context, p *generatorParams, sourceURL, text string) (*ai.Recipe, error)
 }
 
 type ExtGenerator = generator
@@ -96,7 +104,13 @@ type server struct {
 	locServer    locServer
 	wg           sync.WaitGroup
 	clerk        auth.AuthClient
+	// tokens resolves calendar feed links, which calendar apps poll without a session.
+	tokens       *auth.TokenStore
 	critiques    critiqueStore
+	searchIndex  *search.Index
+	similarIndex *similar.Index
+	// listCache walks a user's recipe selections to learn their taste.
+	listCache cache.ListCache
 }
 
 type critiqueStore interface {
@@ -105,8 +119,9 @@ type critiqueStore interface {
 
 // NewHandler returns an http.Handler serving the recipe endpoints under /recipes.
 // cache must be connected to generator or this will not work. Should we enfroce that by getting cache from generator?
-func NewHandler(cfg *config.Config, storage *users.Storage, generator generator, locServer locServer, c cache.ListCache, imageCache cache.Cache, clerkClient auth.AuthClient, imagegen ImageGen) *server {
+func NewHandler(cfg *config.Config, storage *users.Storage, generator generator, locServer locServer, c cache.ListCache, imageCache cache.Cache, clerkClient auth.AuthClient, imagegen ImageGen, aiHTTPClient *http.Client) *server {
 	statusStore := StatusStore(c)
+	searchIndex := search.NewIndex(c, searchIndexMaxAge)
 	return &server{
 		recipeio:     IO(c),
 		imageio:      imageio{Cache: imageCache},
@@ -118,23 +133,38 @@ func NewHandler(cfg *config.Config, storage *users.Storage, generator generator,
 		generator:    generator,
 		locServer:    locServer,
 		clerk:        clerkClient,
+		tokens:       auth.NewTokenStore(c),
 		critiques:    critique.NewStore(c),
+		searchIndex:  searchIndex,
+		similarIndex: similar.NewIndex(searchIndex, c, ai.NewEmbedderFromConfig(cfg, aiHTTPClient), similarIndexMaxAge),
+		listCache:    c,
 	}
 }
 
 func (s *server) Register(mux routing.Registrar) {
 	mux.HandleFunc("GET /recipes", s.handleRecipes)
 	mux.HandleFunc("POST /recipes", s.handleGenerate)
+	mux.HandleFunc("POST /recipes/import", s.handleImportRecipe)
 	mux.HandleFunc("POST /recipes/{hash}/retry", s.handleRetryGeneration)
 	mux.HandleFunc("POST /recipes/{hash}/regenerate", s.handleRegenerate)
 	mux.HandleFunc("POST /recipes/{hash}/finalize", s.handleFinalize)
+	mux.HandleFunc("GET /recipes/{hash}/week", s.handleWeekPlan)
+	mux.HandleFunc("POST /recipes/{hash}/checklist", s.handleChecklistToggle)
+	mux.HandleFunc("POST /recipes/{hash}/checklist/sync", s.handleChecklistSync)
+	mux.HandleFunc("GET /recipes/{hash}/events", s.handleEvents)
 	mux.HandleFunc("GET /recipe/{hash}", s.handleSingle)
 	mux.HandleFunc("GET /recipe/{hash}/image", s.handleRecipeImage)
+	mux.HandleFunc("GET /recipe/{hash}/export", s.handleRecipeExport)
+	mux.HandleFunc("GET /user/recipes/export", s.handleSavedRecipesExport)
+	mux.HandleFunc("GET /user/calendar.ics", s.handleCalendar)
 	mux.HandleFunc("POST /recipe/{hash}/question", s.handleQuestion)
 	mux.HandleFunc("POST /recipe/{hash}/regenerate", s.handleRegenerateSingleRecipe)
+	mux.HandleFunc("GET /recipe/{hash}/substitutes", s.handleSubstitutes)
+	mux.HandleFunc("POST /recipe/{hash}/substitute", s.handleSubstituteIngredient)
 	mux.HandleFunc("POST /recipe/{hash}/feedback", s.handleFeedback)
 	mux.HandleFunc("POST /recipe/{hash}/save", s.handleSaveRecipe)
 	mux.HandleFunc("POST /recipe/{hash}/dismiss", s.handleDismissRecipe)
+	mux.HandleFunc("GET /search", s.handleSearch)
 }
 
 func (s *server) handleSingle(w http.ResponseWriter, r *http.Request) {
@@ -166,6 +196,7 @@ func (s *server) handleSingle(w http.ResponseWriter, r *http.Request) {
 	var thread []RecipeThreadEntry
 	var wineRecommendation *ai.WineSelection
 	var hasRecipeImage bool
+	var moreLikeThis []similar.Match
 	var loadWG sync.WaitGroup
 	loadWG.Go(func() {
 		existing, err := s.FeedbackFromCache(ctx, hash)
@@ -216,6 +247,9 @@ func (s *server) handleSingle(w http.ResponseWriter, r *http.Request) {
 		score := result.OverallScore
 		critiqueScore = &score
 	})
+	loadWG.Go(func() {
+		moreLikeThis = s.moreLikeThis(ctx, hash, *recipe)
+	})
 	loadWG.Wait()
 
 	if recipe.OriginHash == "" {
@@ -227,7 +261,7 @@ func (s *server) handleSingle(w http.ResponseWriter, r *http.Request) {
 				ID:   "",
 				Name: "Unknown Location",
 			}, time.Now())
-			FormatRecipeHTML(ctx, p, *recipe, false, currentUser, critiqueScore, hasRecipeImage, thread, feedback, wineRecommendation, w)
+			FormatRecipeHTML(ctx, p, *recipe, false, currentUser, critiqueScore, hasRecipeImage, thread, feedback, wineRecommendation, moreLikeThis, requestedServings(r), w)
 			return
 		}
 		slog.ErrorContext(ctx, "No origin hash for recipe", "hash", hash, "error", err)
@@ -255,7 +289,7 @@ func (s *server) handleSingle(w http.ResponseWriter, r *http.Request) {
 	}
 
 	slog.InfoContext(ctx, "serving recipe by hash", "hash", hash, "signedIn", signedIn)
-	FormatRecipeHTML(ctx, p, *recipe, saved, currentUser, critiqueScore, hasRecipeImage, thread, feedback, wineRecommendation, w)
+	FormatRecipeHTML(ctx, p, *recipe, saved, currentUser, critiqueScore, hasRecipeImage, thread, feedback, wineRecommendation, moreLikeThis, requestedServings(r), w)
 }
 
 func (s *server) handleRecipeImage(w http.ResponseWriter, r *http.Request) {
@@ -301,6 +335,95 @@ func (s *server) handleRecipeImage(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *server) handleRecipeExport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	hash := strings.TrimSpace(r.PathValue("hash"))
+	if hash == "" {
+		http.Error(w, "missing recipe hash", http.StatusBadRequest)
+		return
+	}
+	format, err := export.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	exported, err := s.exportRecipe(ctx, hash)
+	if err != nil {
+		if errors.Is(err, cache.ErrNotFound) {
+			http.Error(w, "recipe not found", http.StatusNotFound)
+			return
+		}
+		slog.ErrorContext(ctx, "failed to load recipe for export", "hash", hash, "error", err)
+		http.Error(w, "failed to load recipe", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": exported.Filename(format)}))
+	if err := exported.Write(w, format, s.cfg.ResolvedPublicOrigin()); err != nil {
+		slog.ErrorContext(ctx, "failed to write recipe export", "hash", hash, "format", format, "error", err)
+	}
+}
+
+// handleSavedRecipesExport zips up every recipe the user has saved in one format.
+func (s *server) handleSavedRecipesExport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	currentUser, err := s.storage.FromRequest(ctx, r, s.clerk)
+	if err != nil {
+		if errors.Is(err, auth.ErrNoSession) {
+			redirectToSignIn(w, r, http.StatusUnauthorized)
+			return
+		}
+		slog.ErrorContext(ctx, "failed to load user for recipe export", "error", err)
+		http.Error(w, "unable to load account", http.StatusInternalServerError)
+		return
+	}
+	format, err := export.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	exports := make([]export.Recipe, 0, len(currentUser.LastRecipes))
+	for _, saved := range currentUser.LastRecipes {
+		exported, err := s.exportRecipe(ctx, saved.Hash)
+		if errors.Is(err, cache.ErrNotFound) {
+			slog.WarnContext(ctx, "saved recipe missing from cache, leaving it out of export", "user_id", currentUser.ID, "hash", saved.Hash)
+			continue
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to load saved recipe for export", "user_id", currentUser.ID, "hash", saved.Hash, "error", err)
+			http.Error(w, "failed to load saved recipes", http.StatusInternalServerError)
+			return
+		}
+		exported.SavedAt = saved.CreatedAt
+		exports = append(exports, exported)
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": format.ArchiveName()}))
+	w.Header().Set("Cache-Control", "no-store")
+	if err := export.WriteZip(w, format, s.cfg.ResolvedPublicOrigin(), exports); err != nil {
+		slog.ErrorContext(ctx, "failed to write saved recipes export", "user_id", currentUser.ID, "format", format, "error", err)
+	}
+}
+
+func (s *server) exportRecipe(ctx context.Context, hash string) (export.Recipe, error) {
+	recipe, err := s.SingleFromCache(ctx, hash)
+	if err != nil {
+		return export.Recipe{}, err
+	}
+	if recipe.Nutrition == nil {
+		// saved before nutrition was computed at generation
+		recipe.ComputeNutrition(recipeServings(*recipe))
+	}
+	hasImage, err := s.RecipeImageExists(ctx, hash)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to check cached recipe image for export", "hash", hash, "error", err)
+	}
+	return export.Recipe{Recipe: *recipe, Hash: hash, HasImage: hasImage}, nil
+}
+
 func (s *server) handleQuestion(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	if !httpx.IsHTMX(r) {
@@ -337,6 +460,16 @@ func (s *server) handleQuestion(w http.ResponseWriter, r *http.Request) {
 
 	responseID := strings.TrimSpace(r.FormValue("response_id"))
 	promptCacheKey := strings.TrimSpace(r.FormValue("prompt_cache_key"))
+	if responseID == "" {
+		// recipes imported without the model have no conversation to continue, so send the recipe along.
+		recipe, err := s.SingleFromCache(ctx, hash)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to load recipe for question", "hash", hash, "error", err)
+			http.Error(w, "recipe not found", http.StatusNotFound)
+			return
+		}
+		questionForModel = recipeText(*recipe) + "\n" + question
+	}
 
 	// this is going to take a while. Start a go routine? and spin?
 	// can't use request context because it will be canceled when request finishes but we want to finish processing question and save it to cache.
@@ -344,25 +477,23 @@ func (s *server) handleQuestion(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 	previous := ai.ResponseRef{ID: responseID, PromptCacheKey: promptCacheKey}
 	answer, err := s.generator.AskQuestion(ctx, questionForModel, previous)
+	if errors.Is(err, ai.ErrSpendCapReached) {
+		http.Error(w, recipestatus.SpendCapReached, http.StatusTooManyRequests)
+		return
+	}
 	if err != nil {
 		slog.ErrorContext(ctx, "failed to answer question", "hash", hash, "error", err)
 		http.Error(w, "failed to answer question", http.StatusInternalServerError)
 		return
 	}
 
-	thread, err := s.ThreadFromCache(ctx, hash)
-	if err != nil && !errors.Is(err, cache.ErrNotFound) {
-		slog.ErrorContext(ctx, "failed to load recipe thread", "hash", hash, "error", err)
-		http.Error(w, "failed to load recipe thread", http.StatusInternalServerError)
-		return
-	}
-	thread = append(thread, RecipeThreadEntry{
+	thread, err := s.AppendThread(ctx, hash, RecipeThreadEntry{
 		Question:   question,
 		Answer:     answer.Answer,
 		ResponseID: answer.ResponseID,
 		CreatedAt:  time.Now(),
 	})
-	if err := s.SaveThread(ctx, hash, thread); err != nil {
+	if err != nil {
 		http.Error(w, "failed to save question", http.StatusInternalServerError)
 		return
 	}
@@ -457,43 +588,305 @@ func (s *server) handleRegenerateSingleRecipe(w http.ResponseWriter, r *http.Req
 		instructions = append(instructions, critiqueFixes...)
 	}
 	previous := ai.ResponseRef{ID: responseID, PromptCacheKey: recipe.PromptCacheKey}
-	replacement, err := s.generator.RegenerateRecipe(ctx, instructions, previous)
+	replacement, err := s.generator.RegenerateRecipe(spend.WithShoppingList(ctx, recipe.OriginHash), instructions, previous)
+	if errors.Is(err, ai.ErrSpendCapReached) {
+		http.Error(w, recipestatus.SpendCapReached, http.StatusTooManyRequests)
+		return
+	}
 	if err != nil {
 		slog.ErrorContext(ctx, "failed to regenerate single recipe", "hash", hash, "error", err)
 		http.Error(w, "failed to refresh recipe", http.StatusInternalServerError)
 		return
 	}
 	// TODO generate a new shoppinglist? only if ingredients changed or user asked?
-	replacement.OriginHash = recipe.OriginHash
-	replacement.ParentHash = hash
-	newHash := replacement.ComputeHash()
-	if err := s.SaveRecipe(ctx, *replacement); err != nil {
-		slog.ErrorContext(ctx, "failed to save regenerated single recipe", "hash", hash, "new_hash", newHash, "error", err)
+	newHash, err := s.saveChildRecipe(ctx, currentUser, hash, *recipe, replacement)
+	if err != nil {
 		http.Error(w, "failed to save refreshed recipe", http.StatusInternalServerError)
 		return
 	}
-	replaced, err := s.storage.ReplaceRecipe(currentUser, hash, utypes.Recipe{
-		Title:     replacement.Title,
+	http.Redirect(w, r, "/recipe/"+url.PathEscape(newHash), http.StatusSeeOther)
+}
+
+// saveChildRecipe saves child as a new version of the recipe at parentHash and
+// swaps it in for the parent in the user's saved recipes. The shopping list is left as-is.
+func (s *server) saveChildRecipe(ctx context.Context, currentUser *utypes.User, parentHash string, parent ai.Recipe, child *ai.Recipe) (string, error) {
+	child.OriginHash = parent.OriginHash
+	child.ParentHash = parentHash
+	child.Cuisine = parent.Cuisine
+	newHash := child.ComputeHash()
+	if err := s.SaveRecipe(ctx, *child); err != nil {
+		slog.ErrorContext(ctx, "failed to save child recipe", "hash", parentHash, "new_hash", newHash, "error", err)
+		return "", err
+	}
+	s.addSimilar(ctx, *child)
+	replaced, err := s.storage.ReplaceRecipe(ctx, currentUser, parentHash, utypes.Recipe{
+		Title:     child.Title,
 		Hash:      newHash,
 		CreatedAt: time.Now(),
 	})
 	if err != nil {
-		slog.ErrorContext(ctx, "failed to replace user saved recipe", "hash", hash, "new_hash", newHash, "error", err)
-		http.Error(w, "failed to save refreshed recipe", http.StatusInternalServerError)
-		return
+		slog.ErrorContext(ctx, "failed to replace user saved recipe", "hash", parentHash, "new_hash", newHash, "error", err)
+		return "", err
 	}
 	// this is wierd. Excite to move to spin
 	if replaced {
-		if params, err := s.ParamsFromCache(ctx, recipe.OriginHash); err != nil {
-			slog.ErrorContext(ctx, "couldn't look up params", "hash", newHash, "origin", recipe.OriginHash)
+		if params, err := s.ParamsFromCache(ctx, parent.OriginHash); err != nil {
+			slog.ErrorContext(ctx, "couldn't look up params", "hash", newHash, "origin", parent.OriginHash)
 		} else {
-			s.startSavedRecipeBackgroundGeneration(ctx, newHash, *replacement, params.Location.ID, params.Date)
+			s.startSavedRecipeBackgroundGeneration(ctx, newHash, *child, params.Location.ID, params.Date)
+		}
+	}
+	return newHash, nil
+}
+
+// substituteTarget loads the recipe, its shopping list params and the ingredient
+// picked by the form's ingredient index. It writes the error response when it can't.
+func (s *server) substituteTarget(w http.ResponseWriter, r *http.Request, hash string) (*ai.Recipe, *generatorParams, int, bool) {
+	ctx := r.Context()
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return nil, nil, 0, false
+	}
+	recipe, err := s.SingleFromCache(ctx, hash)
+	if err != nil {
+		if errors.Is(err, cache.ErrNotFound) {
+			http.Error(w, "recipe not found", http.StatusNotFound)
+			return nil, nil, 0, false
+		}
+		slog.ErrorContext(ctx, "failed to load recipe for substitution", "hash", hash, "error", err)
+		http.Error(w, "failed to load recipe", http.StatusInternalServerError)
+		return nil, nil, 0, false
+	}
+	index, err := strconv.Atoi(strings.TrimSpace(r.FormValue("ingredient")))
+	if err != nil || index < 0 || index >= len(recipe.Ingredients) {
+		http.Error(w, "invalid ingredient", http.StatusBadRequest)
+		return nil, nil, 0, false
+	}
+	p, err := s.ParamsFromCache(ctx, recipe.OriginHash)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to load params for substitution", "origin_hash", recipe.OriginHash, "hash", hash, "error", err)
+		http.Error(w, "recipe's shopping list not found or expired", http.StatusInternalServerError)
+		return nil, nil, 0, false
+	}
+	return recipe, p, index, true
+}
+
+// offeredSubstitutes are the substitutes offered for missing on the recipe's
+// shopping list. They're found and graded once and cached, so a pick is
+// checked against what was shown even if the store's shelves have moved on.
+func (s *server) offeredSubstitutes(ctx context.Context, p *generatorParams, recipe *ai.Recipe, missing ai.Ingredient) ([]ai.InputIngredient, error) {
+	substitutes, err := s.SubstitutesFromCache(ctx, recipe.OriginHash, missing)
+	if err == nil {
+		return substitutes, nil
+	}
+	if !errors.Is(err, cache.ErrNotFound) {
+		slog.ErrorContext(ctx, "failed to load cached substitutes", "origin_hash", recipe.OriginHash, "ingredient", missing.Name, "error", err)
+	}
+	substitutes, err = s.generator.FindSubstitutes(spend.WithShoppingList(ctx, recipe.OriginHash), p, missing)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.SaveSubstitutes(ctx, recipe.OriginHash, missing, substitutes); err != nil {
+		slog.ErrorContext(ctx, "failed to cache substitutes", "origin_hash", recipe.OriginHash, "ingredient", missing.Name, "error", err)
+	}
+	return substitutes, nil
+}
+
+func (s *server) handleSubstitutes(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if !httpx.IsHTMX(r) {
+		http.Error(w, "htmx request required", http.StatusBadRequest)
+		return
+	}
+	hash := strings.TrimSpace(r.PathValue("hash"))
+	if hash == "" {
+		http.Error(w, "missing recipe hash", http.StatusBadRequest)
+		return
+	}
+	if _, err := s.clerk.GetUserIDFromRequest(r); errors.Is(err, auth.ErrNoSession) {
+		redirectToSignIn(w, r, http.StatusUnauthorized)
+		return
+	}
+	recipe, p, index, ok := s.substituteTarget(w, r, hash)
+	if !ok {
+		return
+	}
+
+	missing := recipe.Ingredients[index]
+	substitutes, err := s.offeredSubstitutes(ctx, p, recipe, missing)
+	if errors.Is(err, ai.ErrSpendCapReached) {
+		http.Error(w, recipestatus.SpendCapReached, http.StatusTooManyRequests)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to find substitutes", "hash", hash, "ingredient", missing.Name, "error", err)
+		http.Error(w, "failed to find substitutes", http.StatusInternalServerError)
+		return
+	}
+	FormatSubstitutesHTML(hash, index, missing, substitutes, w)
+}
+
+func (s *server) handleSubstituteIngredient(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	hash := strings.TrimSpace(r.PathValue("hash"))
+	if hash == "" {
+		http.Error(w, "missing recipe hash", http.StatusBadRequest)
+		return
+	}
+	currentUser, err := s.storage.FromRequest(ctx, r, s.clerk)
+	if err != nil {
+		if errors.Is(err, auth.ErrNoSession) {
+			redirectToSignIn(w, r, http.StatusUnauthorized)
+			return
 		}
+		slog.ErrorContext(ctx, "failed to load user for substitution", "hash", hash, "error", err)
+		http.Error(w, "unable to load account", http.StatusInternalServerError)
+		return
+	}
+	recipe, p, index, ok := s.substituteTarget(w, r, hash)
+	if !ok {
+		return
+	}
+	substituteID := strings.TrimSpace(r.FormValue("substitute"))
+	if substituteID == "" {
+		http.Error(w, "missing substitute", http.StatusBadRequest)
+		return
 	}
 
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 90*time.Second)
+	defer cancel()
+	s.wg.Add(1)
+	defer s.wg.Done()
+	missing := recipe.Ingredients[index]
+	offered, err := s.offeredSubstitutes(ctx, p, recipe, missing)
+	if errors.Is(err, ai.ErrSpendCapReached) {
+		http.Error(w, recipestatus.SpendCapReached, http.StatusTooManyRequests)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to find substitutes", "hash", hash, "ingredient", missing.Name, "error", err)
+		http.Error(w, "failed to find substitutes", http.StatusInternalServerError)
+		return
+	}
+	substitute, ok := lo.Find(offered, func(ing ai.InputIngredient) bool { return ing.ProductID == substituteID })
+	if !ok {
+		http.Error(w, "that substitute is no longer available", http.StatusBadRequest)
+		return
+	}
+	replacement, err := s.generator.SubstituteIngredient(spend.WithShoppingList(ctx, recipe.OriginHash), p, *recipe, missing, substitute)
+	if errors.Is(err, ai.ErrSpendCapReached) {
+		http.Error(w, recipestatus.SpendCapReached, http.StatusTooManyRequests)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to substitute ingredient", "hash", hash, "ingredient", missing.Name, "substitute", substituteID, "error", err)
+		http.Error(w, "failed to substitute ingredient", http.StatusInternalServerError)
+		return
+	}
+	newHash, err := s.saveChildRecipe(ctx, currentUser, hash, *recipe, replacement)
+	if err != nil {
+		http.Error(w, "failed to save substituted recipe", http.StatusInternalServerError)
+		return
+	}
 	http.Redirect(w, r, "/recipe/"+url.PathEscape(newHash), http.StatusSeeOther)
 }
 
+// handleImportRecipe brings in a recipe from a link or pasted text. It's saved
+// to the user's recipes on its own shopping list at their favorite store so it
+// gets the same shopping list, wine, questions and critique as a generated one.
+func (s *server) handleImportRecipe(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	currentUser, err := s.storage.FromRequest(ctx, r, s.clerk)
+	if err != nil {
+		if errors.Is(err, auth.ErrNoSession) {
+			redirectToSignIn(w, r, http.StatusUnauthorized)
+			return
+		}
+		slog.ErrorContext(ctx, "failed to load user for recipe import", "error", err)
+		http.Error(w, "unable to load account", http.StatusInternalServerError)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	sourceURL := strings.TrimSpace(r.FormValue("url"))
+	text := strings.TrimSpace(r.FormValue("text"))
+	if sourceURL == "" && text == "" {
+		http.Error(w, "missing recipe link or text", http.StatusBadRequest)
+		return
+	}
+	locationID := strings.TrimSpace(currentUser.FavoriteStore)
+	if locationID == "" {
+		http.Error(w, "pick a favorite store before importing recipes", http.StatusBadRequest)
+		return
+	}
+	loc, err := s.locServer.GetLocationByID(ctx, locationID)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to load favorite store for recipe import", "location", locationID, "error", err)
+		http.Error(w, "failed to load favorite store", http.StatusInternalServerError)
+		return
+	}
+	date, err := StoreToDate(ctx, nowFn(), loc)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to resolve store date for recipe import", "location", locationID, "error", err)
+	}
+	p := DefaultParams(loc, date)
+	// prices and aisles only come from staples the user would plan with.
+	p.IngredientPreferences = currentUser.IngredientPreferences
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 90*time.Second)
+	defer cancel()
+	s.wg.Add(1)
+	defer s.wg.Done()
+	recipe, err := s.generator.ImportRecipe(ctx, p, sourceURL, text)
+	if errors.Is(err, ai.ErrSpendCapReached) {
+		http.Error(w, recipestatus.SpendCapReached, http.StatusTooManyRequests)
+		return
+	}
+	if errors.Is(err, errImportFetch) {
+		http.Error(w, "couldn't load that page", http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, errNoRecipe) {
+		http.Error(w, "no recipe found to import", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to import recipe", "url", sourceURL, "error", err)
+		http.Error(w, "failed to import recipe", http.StatusInternalServerError)
+		return
+	}
+
+	// the imported recipe is the list's one saved recipe, which also keeps each import's hash apart.
+	p.Saved = []ai.Recipe{*recipe}
+	hash := p.Hash()
+	recipe.OriginHash = hash
+	p.Saved[0].OriginHash = hash
+	recipeHash := recipe.ComputeHash()
+	if err := s.SaveRecipe(ctx, *recipe); err != nil {
+		slog.ErrorContext(ctx, "failed to save imported recipe", "hash", recipeHash, "error", err)
+		http.Error(w, "failed to save imported recipe", http.StatusInternalServerError)
+		return
+	}
+	if err := s.SaveParams(ctx, p); err != nil && !errors.Is(err, ErrAlreadyExists) {
+		slog.ErrorContext(ctx, "failed to save imported recipe params", "hash", hash, "error", err)
+		http.Error(w, "failed to save imported recipe", http.StatusInternalServerError)
+		return
+	}
+	if err := s.SaveShoppingList(ctx, &ai.ShoppingList{Recipes: []ai.Recipe{*recipe}}, hash); err != nil {
+		slog.ErrorContext(ctx, "failed to save imported recipe shopping list", "hash", hash, "error", err)
+		http.Error(w, "failed to save imported recipe", http.StatusInternalServerError)
+		return
+	}
+	if _, err := s.saveRecipeForUser(ctx, currentUser, hash, recipeHash); err != nil {
+		slog.ErrorContext(ctx, "failed to save imported recipe for user", "hash", hash, "recipe_hash", recipeHash, "error", err)
+		http.Error(w, "failed to save imported recipe", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/recipe/"+url.PathEscape(recipeHash), http.StatusSeeOther)
+}
+
 func (s *server) handleFeedback(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	if !httpx.IsHTMX(r) {
@@ -505,7 +898,8 @@ func (s *server) handleFeedback(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "missing recipe hash", http.StatusBadRequest)
 		return
 	}
-	if _, err := s.clerk.GetUserIDFromRequest(r); errors.Is(err, auth.ErrNoSession) {
+	userID, err := s.clerk.GetUserIDFromRequest(r)
+	if errors.Is(err, auth.ErrNoSession) {
 		redirectToSignIn(w, r, http.StatusUnauthorized)
 		return
 	}
@@ -526,6 +920,8 @@ func (s *server) handleFeedback(w http.ResponseWriter, r *http.Request) {
 		feedback = *existing
 	}
 
+	wasCooked := feedback.Cooked
+	starsBefore := feedback.Stars
 	changed := false
 	if values, ok := r.PostForm["cooked"]; ok && len(values) > 0 {
 		cooked, err := parseFeedbackBool(values[len(values)-1])
@@ -564,6 +960,12 @@ func (s *server) handleFeedback(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "failed to save feedback", http.StatusInternalServerError)
 		return
 	}
+	if feedback.Cooked && !wasCooked && userID != "" {
+		s.usePantryForCookedRecipe(ctx, userID, hash)
+	}
+	if feedback.Stars != starsBefore && userID != "" {
+		s.relearnTasteLater(ctx, userID)
+	}
 
 	httpx.SetHTMLContentType(w)
 	_, err = fmt.Fprint(w, `<span class="inline-flex items-center gap-1 text-sm font-medium text-green-700"><span aria-hidden="true">✓</span>Saved</span>`)
@@ -626,12 +1028,9 @@ func (s *server) handleSaveRecipe(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *server) saveRecipeForUser(ctx context.Context, currentUser *utypes.User, shoppingListHash, recipeHash string) (*ai.Recipe, error) {
-	selection, err := s.loadRecipeSelection(ctx, currentUser.ID, shoppingListHash)
-	if err != nil {
-		return nil, fmt.Errorf("load recipe selection: %w", err)
-	}
-	selection.markSaved(recipeHash)
-	if err := s.saveRecipeSelection(ctx, currentUser.ID, shoppingListHash, selection); err != nil {
+	if err := s.updateRecipeSelection(ctx, currentUser.ID, shoppingListHash, func(selection *recipeSelection) {
+		selection.markSaved(recipeHash)
+	}); err != nil {
 		return nil, fmt.Errorf("save recipe selection: %w", err)
 	}
 
@@ -648,6 +1047,7 @@ func (s *server) saveRecipeForUser(ctx context.Context, currentUser *utypes.User
 		return nil, fmt.Errorf("load recipe params: %w", err)
 	}
 	s.startSavedRecipeBackgroundGeneration(ctx, recipeHash, *recipe, params.Location.ID, params.Date)
+	s.relearnTasteLater(ctx, currentUser.ID)
 
 	return recipe, nil
 }
@@ -684,21 +1084,8 @@ func (s *server) handleDismissRecipe(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "recipe list hash not found", http.StatusBadRequest)
 		return
 	}
-	selection, err := s.loadRecipeSelection(ctx, currentUser.ID, selectionHash)
-	if err != nil {
-		slog.ErrorContext(ctx, "failed to load recipe selection for dismiss", "selection_hash", selectionHash, "error", err)
-		http.Error(w, "failed to dismiss recipe", http.StatusInternalServerError)
-		return
-	}
-	selection.markDismissed(recipeHash)
-	if err := s.saveRecipeSelection(ctx, currentUser.ID, selectionHash, selection); err != nil {
-		slog.ErrorContext(ctx, "failed to save recipe selection for dismiss", "selection_hash", selectionHash, "error", err)
-		http.Error(w, "failed to dismiss recipe", http.StatusInternalServerError)
-		return
-	}
-
-	if _, err := s.storage.RemoveRecipe(currentUser, recipeHash); err != nil {
-		slog.ErrorContext(ctx, "failed to remove recipe from storage", "hash", recipeHash, "error", err)
+	if err := s.dismissRecipeForUser(ctx, currentUser, selectionHash, recipeHash); err != nil {
+		slog.ErrorContext(ctx, "failed to dismiss recipe", "selection_hash", selectionHash, "hash", recipeHash, "error", err)
 		http.Error(w, "failed to dismiss recipe", http.StatusInternalServerError)
 		return
 	}
@@ -719,6 +1106,19 @@ func (s *server) handleDismissRecipe(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *server) dismissRecipeForUser(ctx context.Context, currentUser *utypes.User, selectionHash, recipeHash string) error {
+	if err := s.updateRecipeSelection(ctx, currentUser.ID, selectionHash, func(selection *recipeSelection) {
+		selection.markDismissed(recipeHash)
+	}); err != nil {
+		return fmt.Errorf("save recipe selection: %w", err)
+	}
+	if _, err := s.storage.RemoveRecipe(ctx, currentUser, recipeHash); err != nil {
+		return fmt.Errorf("remove recipe from user profile: %w", err)
+	}
+	s.relearnTasteLater(ctx, currentUser.ID)
+	return nil
+}
+
 func (s *server) writeRecipeSelectionResponse(ctx context.Context, w http.ResponseWriter, r *http.Request, recipeHash string, recipe ai.Recipe, shoppingListHash string, saved bool) error {
 	var response bytes.Buffer
 	if isSingleRecipeAction(r) {
@@ -804,7 +1204,7 @@ func (s *server) ensureSavedRecipeWine(ctx context.Context, recipeHash, location
 
 func (s *server) ensureRecipeImage(ctx context.Context, recipeHash string, recipe ai.Recipe) {
 	// 4 minutes is a magical number here. neeed to look at data.
-	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 4*time.Minute)
+	ctx, cancel := context.WithTimeout(spend.WithShoppingList(context.WithoutCancel(ctx), recipe.OriginHash), 4*time.Minute)
 	defer cancel()
 
 	exists, err := s.RecipeImageExists(ctx, recipeHash)
@@ -884,6 +1284,7 @@ func (s *server) handleRegenerate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	p.LastRecipes = s.recentCookedTitles(ctx, currentUser.LastRecipes)
+	p.Neighbors = s.ratedNeighbors(ctx, currentUser.LastRecipes)
 	s.kickgeneration(ctx, p)
 	redirectToHash(w, r, newHash, queryArgStart)
 }
@@ -961,6 +1362,14 @@ func (s *server) handleFinalize(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "failed to finalize recipes", http.StatusInternalServerError)
 		return
 	}
+	if p.isWeekPlan() {
+		previous, err := s.WeekPlanFromCache(ctx, hash)
+		if err != nil && !errors.Is(err, cache.ErrNotFound) {
+			slog.ErrorContext(ctx, "failed to load week plan for finalize", "hash", hash, "error", err)
+		}
+		// the old menu plan no longer lines up with the saved recipes so only keep days from the old week plan.
+		s.saveWeekPlan(ctx, p, &ai.ShoppingList{Recipes: p.Saved}, newHash, previous)
+	}
 
 	redirectToHash(w, r, newHash)
 }
@@ -1098,7 +1507,7 @@ func (s *server) handleRecipes(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if currentUser != nil {
-			p.Directive = currentUser.Directive
+			p.ApplyUser(currentUser, s.userPantry(ctx, currentUser))
 		}
 		redirectToHash(w, r, p.Hash(), QueryArgHelp)
 		return
@@ -1159,7 +1568,13 @@ func (s *server) handleRecipes(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
-	if !signedIn {
+	var checklist shoppingChecklist
+	if signedIn {
+		// the list still works without check marks, so don't fail the page over them.
+		if checklist, err = s.loadChecklist(ctx, currentUser.ID, hashParam); err != nil {
+			slog.ErrorContext(ctx, "failed to load checklist for render", "hash", hashParam, "error", err)
+		}
+	} else {
 		guest.EnsureShoppingListCount(w, r)
 	}
 	wines := parallelism.NewSafeMap[string, *ai.WineSelection](len(slist.Recipes))
@@ -1187,8 +1602,8 @@ func (s *server) handleRecipes(w http.ResponseWriter, r *http.Request) {
 
 	help := r.URL.Query().Get(QueryArgHelp)
 	instructions := strings.TrimSpace(r.URL.Query().Get(queryArgInstructions))
-	FormatShoppingListHTMLForHashWithHelp(ctx, p, *slist, wines.Clone(), images.Clone(), currentUser,
-		hashParam, selection, help, instructions, w)
+	FormatShoppingListHTMLForHashWithHelp(ctx, p, *slist, wines.Clone(), images.Clone(), currentUser, s.userPantry(ctx, currentUser),
+		hashParam, selection, checklist, help, instructions, requestedServings(r), w)
 }
 
 func (s *server) handleGenerate(w http.ResponseWriter, r *http.Request) {
@@ -1231,13 +1646,7 @@ func (s *server) handleGenerate(w http.ResponseWriter, r *http.Request) {
 		currentUser = guestUser
 	}
 
-	s.setFavoriteStore(ctx, currentUser, p.Location)
-
-	p.Directive = currentUser.Directive
-	p.LastRecipes = s.recentCookedTitles(ctx, currentUser.LastRecipes)
-	// if params are already saved redirect and assume someone kicks off genration
-
-	if err := s.SaveParams(ctx, p); err != nil {
+	if err := s.startGeneration(ctx, currentUser, p); err != nil {
 		if errors.Is(err, ErrAlreadyExists) {
 			slog.InfoContext(ctx, "params already existed redirecting", "hash", p.Hash())
 			redirectToHash(w, r, p.Hash(), QueryArgHelp)
@@ -1248,11 +1657,24 @@ func (s *server) handleGenerate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	hash := p.Hash()
+	redirectToHash(w, r, p.Hash(), queryArgStart, QueryArgHelp)
+}
 
-	s.kickgeneration(ctx, p)
+// startGeneration fills in the user's preferences, saves params and kicks off
+// generation. If params are already saved it returns ErrAlreadyExists and assumes
+// someone else kicked off generation.
+func (s *server) startGeneration(ctx context.Context, currentUser *utypes.User, p *generatorParams) error {
+	s.setFavoriteStore(ctx, currentUser, p.Location)
 
-	redirectToHash(w, r, hash, queryArgStart, QueryArgHelp)
+	p.ApplyUser(currentUser, s.userPantry(ctx, currentUser))
+	p.LastRecipes = s.recentCookedTitles(ctx, currentUser.LastRecipes)
+	p.Neighbors = s.ratedNeighbors(ctx, currentUser.LastRecipes)
+
+	if err := s.SaveParams(ctx, p); err != nil {
+		return err
+	}
+	s.kickgeneration(ctx, p)
+	return nil
 }
 
 func (s *server) handleRetryGeneration(w http.ResponseWriter, r *http.Request) {
@@ -1295,9 +1717,16 @@ func (s *server) setFavoriteStore(ctx context.Context, currentUser *utypes.User,
 		return
 	}
 
-	currentUser.FavoriteStore = strings.TrimSpace(loc.ID)
-	if err := s.storage.Update(currentUser); err != nil {
-		slog.ErrorContext(ctx, "failed to set favorite store from generated recipes location", "location_id", currentUser.FavoriteStore, "error", err)
+	err := s.storage.Modify(ctx, currentUser, func(stored *utypes.User) error {
+		// another request may have picked one since this user was loaded.
+		if strings.TrimSpace(stored.FavoriteStore) != "" {
+			return cache.ErrSkipUpdate
+		}
+		stored.FavoriteStore = strings.TrimSpace(loc.ID)
+		return nil
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to set favorite store from generated recipes location", "location_id", loc.ID, "error", err)
 		return
 	}
 	slog.InfoContext(ctx, "set favorite store from recipe generation", "user_id", currentUser.ID, "location_id", currentUser.FavoriteStore)
@@ -1330,13 +1759,18 @@ func (s *server) kickgeneration(ctx context.Context, p *generatorParams) {
 		if err != nil {
 			slog.ErrorContext(ctx, "generate error", "error", err)
 			s.writeGenerationStatus(ctx, hash, recipestatus.Error(err))
+			generationEvents.publish(hash, eventErrorData{Message: recipestatus.Error(err)})
 			return
 		}
 
 		if err := s.SaveShoppingList(ctx, shoppingList, hash); err != nil {
 			slog.ErrorContext(ctx, "save error", "error", err)
+			generationEvents.publish(hash, eventErrorData{Message: "failed to save recipes"})
 			return
 		}
+		s.addSimilar(ctx, shoppingList.Recipes...)
+		s.saveWeekPlan(ctx, p, shoppingList, hash, nil)
+		generationEvents.publish(hash, doneEvent(hash))
 	})
 }
 
@@ -1371,6 +1805,8 @@ func (s *server) KickGenerationIfNotPresent(ctx context.Context, p *GeneratorPar
 			slog.ErrorContext(ctx, "save error", "error", err)
 			return
 		}
+		s.addSimilar(ctx, shoppingList.Recipes...)
+		s.saveWeekPlan(ctx, p, shoppingList, hash, nil)
 
 		// don't really need to wait on full shopping list but generator doesn't have a channel
 		for _, recipe := range shoppingList.Recipes {
@@ -1381,6 +1817,50 @@ func (s *server) KickGenerationIfNotPresent(ctx context.Context, p *GeneratorPar
 	})
 }
 
+// saveWeekPlan is best effort; the shopping list is already saved and the week
+// page sends people back to it when there is no plan.
+func (s *server) saveWeekPlan(ctx context.Context, p *generatorParams, list *ai.ShoppingList, hash string, previous *WeekPlan) {
+	if !p.isWeekPlan() {
+		return
+	}
+	if err := s.SaveWeekPlan(ctx, NewWeekPlan(p, list, previous), hash); err != nil {
+		slog.ErrorContext(ctx, "failed to save week plan", "hash", hash, "error", err)
+	}
+}
+
+func (s *server) handleWeekPlan(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	hash := r.PathValue("hash")
+	if hash == "" {
+		http.Error(w, "missing shopping list hash", http.StatusBadRequest)
+		return
+	}
+	plan, err := s.WeekPlanFromCache(ctx, hash)
+	if err != nil {
+		if errors.Is(err, cache.ErrNotFound) {
+			// still generating or not a week plan; the list page knows how to handle both.
+			redirectToHash(w, r, hash)
+			return
+		}
+		slog.ErrorContext(ctx, "failed to load week plan", "hash", hash, "error", err)
+		http.Error(w, "failed to load week plan", http.StatusInternalServerError)
+		return
+	}
+	slist, err := s.FromCache(ctx, hash)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to load shopping list for week plan", "hash", hash, "error", err)
+		http.Error(w, "failed to load week plan", http.StatusInternalServerError)
+		return
+	}
+	p, err := s.ParamsFromCache(ctx, hash)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to load params for week plan", "hash", hash, "error", err)
+		http.Error(w, "failed to load recipe parameters", http.StatusInternalServerError)
+		return
+	}
+	FormatWeekPlanHTML(ctx, p, *plan, *slist, hash, requestedServings(r), w)
+}
+
 func (s *server) writeGenerationStatus(ctx context.Context, hash, status string) {
 	if s.statusWriter == nil || strings.TrimSpace(hash) == "" {
 		return
@@ -1406,6 +1886,7 @@ func (s *server) spin(ctx context.Context, w http.ResponseWriter, r *http.Reques
 		StatusMessage   string
 		ServerSignedIn  bool
 		CurrentPath     string
+		EventsPath      string
 	}{
 		ClarityScript:   templates.ClarityScript(ctx),
 		GoogleTagScript: templates.GoogleTagScript(),
@@ -1414,6 +1895,7 @@ func (s *server) spin(ctx context.Context, w http.ResponseWriter, r *http.Reques
 		StatusMessage:   status,
 		ServerSignedIn:  true, // clerk refresh doesn't need to reload because spin will just do it anwyays
 		CurrentPath:     r.URL.RequestURI(),
+		EventsPath:      "/recipes/" + url.PathEscape(hash) + "/events",
 	}
 
 	if httpx.IsHTMX(r) {
@@ -1508,24 +1990,24 @@ func (s *server) saveRecipesToUserProfile(ctx context.Context, currentUser *utyp
 		return fmt.Errorf("invalid user")
 	}
 
-	// Check if reciProfilepe already exists in user's last recipes
 	hash := recipe.ComputeHash()
-
-	_, exists := lo.Find(currentUser.LastRecipes, func(r utypes.Recipe) bool {
-		return r.Hash == hash
-	})
-	if exists {
-		return nil
-	}
 	newRecipe := utypes.Recipe{
 		Title:     recipe.Title,
 		Hash:      hash,
 		CreatedAt: time.Now(),
 	}
-	currentUser.LastRecipes = append(currentUser.LastRecipes, newRecipe)
-
-	// etag mismatch fun!
-	if err := s.storage.Update(currentUser); err != nil {
+	err := s.storage.Modify(ctx, currentUser, func(stored *utypes.User) error {
+		// Check if recipe already exists in user's last recipes
+		_, exists := lo.Find(stored.LastRecipes, func(r utypes.Recipe) bool {
+			return r.Hash == hash
+		})
+		if exists {
+			return cache.ErrSkipUpdate
+		}
+		stored.LastRecipes = append(stored.LastRecipes, newRecipe)
+		return nil
+	})
+	if err != nil {
 		return fmt.Errorf("failed to update user with saved recipes: %w", err)
 	}
 	slog.InfoContext(ctx, "added saved recipe to user profile", "title", recipe.Title)
@@ -1,6 +1,7 @@
 package recipes
 
 import (
+	"archive/zip"
 	"bytes"
 	"context"
 	"encoding/base64"
@@ -20,6 +21,7 @@ import (
 	"careme/internal/ai"
 	"careme/internal/auth"
 	"careme/internal/cache"
+	"careme/internal/config"
 	"careme/internal/guest"
 	"careme/internal/locations"
 	"careme/internal/recipes/feedback"
@@ -1101,6 +1103,217 @@ func TestHandleRegenerateSingleRecipe_ReplacesSavedRecipeWithoutChangingShopping
 	assert.Equal(t, originalHash, shoppingList.Recipes[0].ComputeHash())
 }
 
+func TestHandleSubstituteIngredient_SavesChildRecipe(t *testing.T) {
+	cacheStore := cache.NewFileCache(filepath.Join(t.TempDir(), "cache"))
+	storage := users.NewStorage(cacheStore)
+	generator := &captureQuestionGenerator{}
+	s := newTestServer(t,
+		withTestCache(cacheStore),
+		withTestStorage(storage),
+		withTestGenerator(generator),
+	)
+	t.Cleanup(s.Wait)
+
+	now := time.Now()
+	params := DefaultParams(&locations.Location{ID: "70001001", Name: "Store"}, now)
+	original := ai.Recipe{
+		Title:        "Skirt Steak Dinner",
+		Ingredients:  []ai.Ingredient{{Name: "Shallots", Quantity: "2"}, {Name: "Skirt steak", Quantity: "1 lb"}},
+		Instructions: []string{"Cook steak.", "Serve."},
+		OriginHash:   params.Hash(),
+		ResponseID:   "resp-original",
+	}
+	originalHash := original.ComputeHash()
+	require.NoError(t, s.SaveParams(t.Context(), params))
+	require.NoError(t, s.SaveRecipe(t.Context(), original))
+	require.NoError(t, storage.Update(&utypes.User{
+		ID:          "mock-clerk-user-id",
+		Email:       []string{"you@careme.cooking"},
+		CreatedAt:   now,
+		ShoppingDay: time.Saturday.String(),
+		LastRecipes: []utypes.Recipe{{Title: original.Title, Hash: originalHash, CreatedAt: now}},
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/recipe/"+url.PathEscape(originalHash)+"/substitutes?ingredient=1", nil)
+	req.SetPathValue("hash", originalHash)
+	req.Header.Set("HX-Request", "true")
+	rr := httptest.NewRecorder()
+	s.handleSubstitutes(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Instead of Skirt steak")
+	assert.Contains(t, rr.Body.String(), `value="flank-1"`)
+
+	form := url.Values{"ingredient": {"1"}, "substitute": {"hanger-1"}}
+	req = httptest.NewRequest(http.MethodPost, "/recipe/"+url.PathEscape(originalHash)+"/substitute", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetPathValue("hash", originalHash)
+	rr = httptest.NewRecorder()
+	s.handleSubstituteIngredient(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code, "only what was offered can be picked")
+
+	form = url.Values{"ingredient": {"3"}, "substitute": {"flank-1"}}
+	req = httptest.NewRequest(http.MethodPost, "/recipe/"+url.PathEscape(originalHash)+"/substitute", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetPathValue("hash", originalHash)
+	rr = httptest.NewRecorder()
+	s.handleSubstituteIngredient(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	form.Set("ingredient", "1")
+	req = httptest.NewRequest(http.MethodPost, "/recipe/"+url.PathEscape(originalHash)+"/substitute", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetPathValue("hash", originalHash)
+	rr = httptest.NewRecorder()
+	s.handleSubstituteIngredient(rr, req)
+
+	require.Equal(t, http.StatusSeeOther, rr.Code)
+	assert.Equal(t, "Skirt steak", generator.lastMissing)
+	assert.Equal(t, "flank-1", generator.lastSubstitute)
+	assert.Equal(t, 1, generator.substituteSearches, "the pick is checked against the substitutes offered")
+	newHash := strings.TrimPrefix(rr.Header().Get("Location"), "/recipe/")
+	substituted, err := s.SingleFromCache(t.Context(), newHash)
+	require.NoError(t, err)
+	assert.Equal(t, "Flank Steak Dinner", substituted.Title)
+	assert.Equal(t, originalHash, substituted.ParentHash)
+	assert.Equal(t, params.Hash(), substituted.OriginHash)
+
+	updatedUser, err := storage.GetByID("mock-clerk-user-id")
+	require.NoError(t, err)
+	require.Len(t, updatedUser.LastRecipes, 1)
+	assert.Equal(t, newHash, updatedUser.LastRecipes[0].Hash)
+}
+
+func TestHandleImportRecipe_SavesToFavoriteStoreList(t *testing.T) {
+	cacheStore := cache.NewFileCache(filepath.Join(t.TempDir(), "cache"))
+	storage := users.NewStorage(cacheStore)
+	generator := &captureQuestionGenerator{}
+	s := newTestServer(t,
+		withTestCache(cacheStore),
+		withTestStorage(storage),
+		withTestGenerator(generator),
+		withTestLocationServer(staticLocationLookup{location: &locations.Location{ID: "70001001", Name: "Store"}}),
+	)
+	t.Cleanup(s.Wait)
+
+	form := url.Values{"url": {"https://example.com/pot-roast"}}
+	req := httptest.NewRequest(http.MethodPost, "/recipes/import", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	s.handleImportRecipe(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code, "no favorite store yet")
+
+	require.NoError(t, storage.Update(&utypes.User{
+		ID:            "mock-clerk-user-id",
+		Email:         []string{"you@careme.cooking"},
+		CreatedAt:     time.Now(),
+		ShoppingDay:   time.Saturday.String(),
+		FavoriteStore: "70001001",
+	}))
+	req = httptest.NewRequest(http.MethodPost, "/recipes/import", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = httptest.NewRecorder()
+	s.handleImportRecipe(rr, req)
+
+	require.Equal(t, http.StatusSeeOther, rr.Code)
+	assert.Equal(t, "70001001", generator.lastImport.location)
+	assert.Equal(t, "https://example.com/pot-roast", generator.lastImport.sourceURL)
+	recipeHash := strings.TrimPrefix(rr.Header().Get("Location"), "/recipe/")
+	imported, err := s.SingleFromCache(t.Context(), recipeHash)
+	require.NoError(t, err)
+	assert.Equal(t, "Grandma's Pot Roast", imported.Title)
+	assert.Equal(t, "https://example.com/pot-roast", imported.SourceURL)
+
+	list, err := s.FromCache(t.Context(), imported.OriginHash)
+	require.NoError(t, err)
+	require.Len(t, list.Recipes, 1)
+	assert.Equal(t, "12", list.Recipes[0].Ingredients[0].AisleNumber)
+	p, err := s.ParamsFromCache(t.Context(), imported.OriginHash)
+	require.NoError(t, err)
+	assert.Equal(t, "70001001", p.Location.ID)
+
+	updatedUser, err := storage.GetByID("mock-clerk-user-id")
+	require.NoError(t, err)
+	require.Len(t, updatedUser.LastRecipes, 1)
+	assert.Equal(t, recipeHash, updatedUser.LastRecipes[0].Hash)
+}
+
+func TestHandleQuestion_SendsRecipeWithoutResponseID(t *testing.T) {
+	generator := &captureQuestionGenerator{}
+	s := newTestServer(t, withTestGenerator(generator))
+	recipe := ai.Recipe{
+		Title:        "Grandma's Pot Roast",
+		Ingredients:  []ai.Ingredient{{Name: "beef chuck roast", Quantity: "3 lb"}},
+		Instructions: []string{"Braise for 3 hours."},
+	}
+	hash := recipe.ComputeHash()
+	require.NoError(t, s.SaveRecipe(t.Context(), recipe))
+
+	form := url.Values{"question": {"Can I use a slow cooker?"}}
+	req := httptest.NewRequest(http.MethodPost, "/recipe/"+url.PathEscape(hash)+"/question", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+	req.SetPathValue("hash", hash)
+	rr := httptest.NewRecorder()
+	s.handleQuestion(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, generator.lastQuestion, "- 3 lb beef chuck roast")
+	assert.True(t, strings.HasSuffix(generator.lastQuestion, "Can I use a slow cooker?"))
+	assert.Empty(t, generator.lastResponseID)
+}
+
+func TestHandleSavedRecipesExport_ZipsSavedRecipes(t *testing.T) {
+	cacheStore := cache.NewFileCache(filepath.Join(t.TempDir(), "cache"))
+	storage := users.NewStorage(cacheStore)
+	s := newTestServer(t,
+		withTestCache(cacheStore),
+		withTestStorage(storage),
+		withTestConfig(&config.Config{PublicOrigin: "https://careme.cooking"}),
+	)
+
+	now := time.Now()
+	saved := ai.Recipe{
+		Title:        "Skirt Steak Dinner",
+		Ingredients:  []ai.Ingredient{{Name: "Skirt steak", Quantity: "1 lb"}},
+		Instructions: []string{"Cook steak.", "Serve."},
+	}
+	savedHash := saved.ComputeHash()
+	require.NoError(t, s.SaveRecipe(t.Context(), saved))
+	require.NoError(t, storage.Update(&utypes.User{
+		ID:          "mock-clerk-user-id",
+		Email:       []string{"you@careme.cooking"},
+		CreatedAt:   now,
+		ShoppingDay: time.Saturday.String(),
+		LastRecipes: []utypes.Recipe{
+			{Title: saved.Title, Hash: savedHash, CreatedAt: now},
+			{Title: "Expired", Hash: "missing-hash", CreatedAt: now},
+		},
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/recipe/"+url.PathEscape(savedHash)+"/export?format=paprika", nil)
+	req.SetPathValue("hash", savedHash)
+	rr := httptest.NewRecorder()
+	s.handleRecipeExport(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `attachment; filename=skirt-steak-dinner.paprikarecipe`, rr.Header().Get("Content-Disposition"))
+
+	req = httptest.NewRequest(http.MethodGet, "/user/recipes/export?format=markdown", nil)
+	rr = httptest.NewRecorder()
+	s.handleSavedRecipesExport(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/zip", rr.Header().Get("Content-Type"))
+
+	zr, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+	require.NoError(t, err)
+	require.Len(t, zr.File, 1, "recipes no longer in the cache are left out")
+	assert.Equal(t, "skirt-steak-dinner.md", zr.File[0].Name)
+
+	req = httptest.NewRequest(http.MethodGet, "/user/recipes/export?format=pdf", nil)
+	rr = httptest.NewRecorder()
+	s.handleSavedRecipesExport(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
 type captureKickgenerationGenerator struct {
 	mu           sync.Mutex
 	last         *generatorParams
@@ -1149,6 +1362,18 @@ func (c *captureKickgenerationGenerator) PickAWine(ctx context.Context, location
 	panic("unexpected call to PickAWine")
 }
 
+func (c *captureKickgenerationGenerator) FindSubstitutes(ctx context.Context, p *generatorParams, missing ai.Ingredient) ([]ai.InputIngredient, error) {
+	panic("unexpected call to FindSubstitutes")
+}
+
+func (c *captureKickgenerationGenerator) SubstituteIngredient(ctx context.Context, p *generatorParams, recipe ai.Recipe, missing ai.Ingredient, substitute ai.InputIngredient) (*ai.Recipe, error) {
+	panic("unexpected call to SubstituteIngredient")
+}
+
+func (c *captureKickgenerationGenerator) ImportRecipe(ctx context.Context, p *generatorParams, sourceURL, text string) (*ai.Recipe, error) {
+	panic("unexpected call to ImportRecipe")
+}
+
 func (c *captureKickgenerationGenerator) Ready(ctx context.Context) error {
 	return nil
 }
@@ -1350,6 +1575,14 @@ type captureQuestionGenerator struct {
 	wineRecommendation string
 	winePickCalls      int
 	panicOnWine        bool
+	lastMissing        string
+	lastSubstitute     string
+	substituteSearches int
+	lastImport         struct {
+		location  string
+		sourceURL string
+		text      string
+	}
 }
 
 func (c *captureQuestionGenerator) GenerateRecipes(ctx context.Context, p *generatorParams) (*ai.ShoppingList, error) {
@@ -1391,6 +1624,42 @@ func (c *captureQuestionGenerator) PickAWine(ctx context.Context, location strin
 	return &ai.WineSelection{Commentary: "Try a chilled sauvignon blanc.", Wines: []ai.Ingredient{}}, nil
 }
 
+func (c *captureQuestionGenerator) FindSubstitutes(ctx context.Context, p *generatorParams, missing ai.Ingredient) ([]ai.InputIngredient, error) {
+	c.substituteSearches++
+	if c.substituteSearches > 1 {
+		// the shelves moved on since the first search.
+		return []ai.InputIngredient{{ProductID: "hanger-1", Description: "Hanger Steak"}}, nil
+	}
+	return []ai.InputIngredient{{ProductID: "flank-1", Description: "Flank Steak", PriceRegular: new(float32(9.99))}}, nil
+}
+
+func (c *captureQuestionGenerator) SubstituteIngredient(ctx context.Context, p *generatorParams, recipe ai.Recipe, missing ai.Ingredient, substitute ai.InputIngredient) (*ai.Recipe, error) {
+	c.lastMissing = missing.Name
+	c.lastSubstitute = substitute.ProductID
+	return &ai.Recipe{
+		Title:        "Flank Steak Dinner",
+		Description:  "Swapped in flank steak.",
+		Ingredients:  []ai.Ingredient{{Name: "Flank steak", Quantity: "1 lb", ProductID: substitute.ProductID}},
+		Instructions: []string{"Cook the flank steak.", "Serve."},
+		ResponseID:   "resp-substituted",
+	}, nil
+}
+
+func (c *captureQuestionGenerator) ImportRecipe(ctx context.Context, p *generatorParams, sourceURL, text string) (*ai.Recipe, error) {
+	c.lastImport.location = p.Location.ID
+	c.lastImport.sourceURL = sourceURL
+	c.lastImport.text = text
+	return &ai.Recipe{
+		Title:        "Grandma's Pot Roast",
+		Description:  "Sunday pot roast.",
+		Ingredients:  []ai.Ingredient{{Name: "Chuck roast", Quantity: "3 lb", ProductID: "chuck-1", AisleNumber: "12", Price: "$14.99"}},
+		Instructions: []string{"Brown the roast.", "Braise for 3 hours."},
+		WineStyles:   []string{"Cabernet Sauvignon"},
+		ResponseID:   "resp-imported",
+		SourceURL:    sourceURL,
+	}, nil
+}
+
 func (c *captureQuestionGenerator) Ready(ctx context.Context) error {
 	return nil
 }
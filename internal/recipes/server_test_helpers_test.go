@@ -1,6 +1,7 @@
 package recipes
 
 import (
+	"net/http"
 	"path/filepath"
 	"testing"
 
@@ -48,7 +49,7 @@ func newTestServer(t testing.TB, opts ...testServerOption) *server {
 		cfg.imagegen = mock{}
 	}
 
-	return NewHandler(cfg.cfg, cfg.storage, cfg.generator, cfg.locServer, cfg.cache, cfg.imageCache, cfg.clerk, cfg.imagegen)
+	return NewHandler(cfg.cfg, cfg.storage, cfg.generator, cfg.locServer, cfg.cache, cfg.imageCache, cfg.clerk, cfg.imagegen, http.DefaultClient)
 }
 
 func withTestCache(c cache.ListCache) testServerOption {
@@ -86,3 +87,9 @@ func withTestClerk(clerk auth.AuthClient) testServerOption {
 		cfg.clerk = clerk
 	}
 }
+
+func withTestConfig(c *config.Config) testServerOption {
+	return func(cfg *testServerConfig) {
+		cfg.cfg = c
+	}
+}
@@ -0,0 +1,79 @@
+package recipes
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"careme/internal/ai"
+)
+
+const (
+	queryArgServings = "servings"
+	// the menu planner defaults to two people, so recipes from before we asked
+	// for servings are assumed to be for two.
+	defaultServings = 2
+	maxServings     = 50
+)
+
+func recipeServings(r ai.Recipe) int {
+	if r.Servings > 0 {
+		return r.Servings
+	}
+	return defaultServings
+}
+
+// requestedServings reads ?servings=N. Zero means show the recipe as generated.
+func requestedServings(r *http.Request) int {
+	n, err := strconv.Atoi(strings.TrimSpace(r.URL.Query().Get(queryArgServings)))
+	if err != nil || n < 1 || n > maxServings {
+		return 0
+	}
+	return n
+}
+
+// scaleRecipe rescales ingredient quantities and the amounts called out in each
+// instruction step. In steps only amounts followed by a weight or volume unit or
+// an ingredient name are touched, so temperatures, times, pan sizes and counts
+// like "cut into 4 pieces" survive.
+func scaleRecipe(recipe ai.Recipe, servings int) ai.Recipe {
+	base := recipeServings(recipe)
+	if servings <= 0 || servings == base {
+		return recipe
+	}
+	factor := float64(servings) / float64(base)
+
+	ingredientWords := map[string]struct{}{}
+	ingredients := make([]ai.Ingredient, len(recipe.Ingredients))
+	for i, ing := range recipe.Ingredients {
+		ing.Quantity = ai.ScaleQuantity(ing.Quantity, factor)
+		ing.Amount *= factor
+		ingredients[i] = ing
+		for _, w := range strings.Fields(normalizeShoppingListName(ing.Name)) {
+			// "large" in "Large eggs" would scale "2 large bowls" too.
+			if !ai.IsMeasurementUnit(w) {
+				ingredientWords[w] = struct{}{}
+			}
+		}
+	}
+	measured := func(word string) bool {
+		if ai.IsMassOrVolumeUnit(word) {
+			return true
+		}
+		_, ok := ingredientWords[word]
+		if !ok {
+			_, ok = ingredientWords[strings.TrimSuffix(word, "s")]
+		}
+		return ok
+	}
+	instructions := make([]string, len(recipe.Instructions))
+	for i, step := range recipe.Instructions {
+		instructions[i] = ai.ScaleAmounts(step, factor, measured)
+	}
+
+	recipe.Ingredients = ingredients
+	recipe.Instructions = instructions
+	recipe.Servings = servings
+	recipe.StoreCost *= factor
+	return recipe
+}
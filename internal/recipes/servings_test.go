@@ -0,0 +1,116 @@
+package recipes
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"careme/internal/ai"
+	"careme/internal/locations"
+	"careme/internal/recipes/feedback"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var tacoRecipe = ai.Recipe{
+	Title:    "Chicken Tacos",
+	Servings: 2,
+	Ingredients: []ai.Ingredient{
+		{Name: "Chicken thighs", Quantity: "1 lb", Amount: 1, Unit: "lb"},
+		{Name: "Tortillas", Quantity: "4"},
+		{Name: "Lime", Quantity: "1/2, juiced"},
+		{Name: "Salt", Quantity: "to taste"},
+	},
+	Instructions: []string{
+		"Heat oven to 425°F and slice 1 lb chicken thighs.",
+		"Warm 4 tortillas in a 10-inch skillet for 2 minutes.",
+	},
+}
+
+func TestScaleRecipe(t *testing.T) {
+	got := scaleRecipe(tacoRecipe, 6)
+
+	assert.Equal(t, 6, got.Servings)
+	assert.Equal(t, []ai.Ingredient{
+		{Name: "Chicken thighs", Quantity: "3 lb", Amount: 3, Unit: "lb"},
+		{Name: "Tortillas", Quantity: "12"},
+		{Name: "Lime", Quantity: "1 1/2, juiced"},
+		{Name: "Salt", Quantity: "to taste"},
+	}, got.Ingredients)
+	assert.Equal(t, []string{
+		"Heat oven to 425°F and slice 3 lb chicken thighs.",
+		"Warm 12 tortillas in a 10-inch skillet for 2 minutes.",
+	}, got.Instructions)
+	assert.Equal(t, "1 lb", tacoRecipe.Ingredients[0].Quantity, "scaling must not mutate the stored recipe")
+}
+
+func TestScaleRecipe_DefaultsOldRecipesToTwoServings(t *testing.T) {
+	old := tacoRecipe
+	old.Servings = 0
+	assert.Equal(t, old, scaleRecipe(old, 2))
+	assert.Equal(t, "2 lb", scaleRecipe(old, 4).Ingredients[0].Quantity)
+	assert.Equal(t, old, scaleRecipe(old, 0))
+}
+
+func TestScaleRecipe_LeavesCountsInStepsAlone(t *testing.T) {
+	frittata := ai.Recipe{
+		Servings: 2,
+		Ingredients: []ai.Ingredient{
+			{Name: "Large eggs", Quantity: "4 large"},
+			{Name: "Potatoes", Quantity: "2 medium"},
+		},
+		Instructions: []string{
+			"Cut the potatoes into 4 pieces each.",
+			"Whisk 4 large eggs in 2 large bowls with 2 tbsp milk.",
+		},
+	}
+	got := scaleRecipe(frittata, 4)
+
+	assert.Equal(t, "8 large", got.Ingredients[0].Quantity)
+	assert.Equal(t, "4 medium", got.Ingredients[1].Quantity)
+	assert.Equal(t, []string{
+		"Cut the potatoes into 4 pieces each.",
+		"Whisk 8 large eggs in 2 large bowls with 4 tbsp milk.",
+	}, got.Instructions)
+}
+
+func TestRequestedServings(t *testing.T) {
+	for query, want := range map[string]int{
+		"":             0,
+		"?servings=6":  6,
+		"?servings=0":  0,
+		"?servings=x":  0,
+		"?servings=99": 0,
+	} {
+		r := httptest.NewRequest("GET", "/recipe/abc"+query, nil)
+		assert.Equal(t, want, requestedServings(r), query)
+	}
+}
+
+func TestFormatRecipeHTML_ScalesServingsButKeepsRecipeHash(t *testing.T) {
+	loc := locations.Location{ID: "70000001", Name: "Store", Address: "1 Main St"}
+	p := DefaultParams(&loc, time.Now())
+	recipe := tacoRecipe
+	hash := recipe.ComputeHash()
+	w := httptest.NewRecorder()
+
+	FormatRecipeHTML(t.Context(), p, recipe, false, renderTestUser(true), nil, false, []RecipeThreadEntry{}, feedback.Feedback{}, nil, nil, 6, w)
+	html := assertHTTPSuccess(t, w)
+	isValidHTML(t, html)
+
+	assert.Contains(t, html, "3 lb")
+	assert.Contains(t, html, "slice 3 lb chicken thighs")
+	assert.Contains(t, html, "Amounts scaled for 6.")
+	assert.Contains(t, html, `action="/recipe/`+hash+`"`)
+}
+
+func TestShoppingListForDisplay_MergesScaledFractions(t *testing.T) {
+	groups := shoppingListForDisplay([]ai.Ingredient{
+		{Name: "Olive oil", Quantity: "1/2 cup"},
+		{Name: "olive oil", Quantity: "1/4 cup"},
+	})
+	require.Len(t, groups, 1)
+	require.Len(t, groups[0].Items, 1)
+	assert.Equal(t, "3/4 cup", groups[0].Items[0].Quantity)
+}
@@ -0,0 +1,143 @@
+package recipes
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"careme/internal/ai"
+	"careme/internal/recipes/critique"
+	"careme/internal/recipes/search"
+	"careme/internal/recipes/similar"
+	utypes "careme/internal/users/types"
+
+	"github.com/samber/lo"
+)
+
+const (
+	// similarIndexMaxAge is how often recipes that weren't embedded when they
+	// were saved are picked up.
+	similarIndexMaxAge  = 10 * time.Minute
+	moreLikeThisCount   = 4
+	moreLikeThisTimeout = 2 * time.Second
+	addSimilarTimeout   = time.Minute
+
+	// stars at or above likedStars pull menus toward a recipe's neighbors;
+	// at or below dislikedStars push them away.
+	likedStars    = 4
+	dislikedStars = 2
+	// ratedHistory is how many of the most recent saved recipes are checked
+	// for stars.
+	ratedHistory      = 50
+	neighborsPerRated = 3
+	maxNeighbors      = 6
+	neighborsTimeout  = 3 * time.Second
+)
+
+// moreLikeThis is the recipes most like the one on the page, or none if
+// they can't be worked out in time.
+func (s *server) moreLikeThis(ctx context.Context, hash string, recipe ai.Recipe) []similar.Match {
+	ctx, cancel := context.WithTimeout(ctx, moreLikeThisTimeout)
+	defer cancel()
+	doc := search.DocFor(recipe)
+	doc.Hash = hash
+	matches, err := s.similarIndex.Like(ctx, doc, moreLikeThisCount, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to find similar recipes", "hash", hash, "error", err)
+		return nil
+	}
+	return matches
+}
+
+// addSimilar embeds newly saved recipes in the background so their pages can
+// show more like this without embedding on a view. Anything that fails here is
+// picked up by the index's next refresh.
+func (s *server) addSimilar(ctx context.Context, recipes ...ai.Recipe) {
+	ctx = context.WithoutCancel(ctx)
+	s.wg.Go(func() {
+		ctx, cancel := context.WithTimeout(ctx, addSimilarTimeout)
+		defer cancel()
+		if err := s.similarIndex.Add(ctx, lo.Map(recipes, func(r ai.Recipe, _ int) search.Doc { return search.DocFor(r) })...); err != nil {
+			slog.ErrorContext(ctx, "failed to embed saved recipes", "count", len(recipes), "error", err)
+		}
+	})
+}
+
+// recipeNeighbors are titles of recipes like ones the user starred, to steer
+// the menu plan toward or away from.
+type recipeNeighbors struct {
+	Like  []string
+	Avoid []string
+}
+
+// ratedNeighbors looks up recipes like the ones the user rated well or badly.
+// Liked neighbors skip recipes the user already saved and ones the critic
+// scored poorly; avoided neighbors skip anything the user liked.
+func (s *server) ratedNeighbors(ctx context.Context, lastRecipes []utypes.Recipe) recipeNeighbors {
+	ctx, cancel := context.WithTimeout(ctx, neighborsTimeout)
+	defer cancel()
+	// saved recipes are kept newest first.
+	recent := lastRecipes[:min(len(lastRecipes), ratedHistory)]
+	rated := s.FeedbackByHash(ctx, lo.Map(recent, func(r utypes.Recipe, _ int) string { return r.Hash }))
+	var liked, disliked []string
+	for _, r := range recent {
+		switch stars := rated[r.Hash].Stars; {
+		case stars >= likedStars:
+			liked = append(liked, r.Hash)
+		case stars > 0 && stars <= dislikedStars:
+			disliked = append(disliked, r.Hash)
+		}
+	}
+	if len(liked) == 0 && len(disliked) == 0 {
+		return recipeNeighbors{}
+	}
+
+	docs, err := s.searchIndex.Docs(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to load recipes for neighbors", "error", err)
+		return recipeNeighbors{}
+	}
+	byHash := lo.SliceToMap(docs, func(d search.Doc) (string, search.Doc) { return d.Hash, d })
+	saved := savedRecipeHashes(&utypes.User{LastRecipes: lastRecipes})
+	likedSet := lo.SliceToMap(liked, func(hash string) (string, bool) { return hash, true })
+
+	neighbors := func(hashes []string, exclude map[string]bool, keep func(similar.Match) bool) []string {
+		var titles []string
+		for _, hash := range hashes {
+			doc, ok := byHash[hash]
+			if !ok {
+				continue
+			}
+			matches, err := s.similarIndex.Like(ctx, doc, neighborsPerRated, exclude)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to find neighbors of rated recipe", "hash", hash, "error", err)
+				return titles
+			}
+			for _, m := range matches {
+				if keep(m) {
+					titles = append(titles, m.Title)
+				}
+			}
+		}
+		titles = lo.UniqBy(titles, strings.ToLower)
+		return titles[:min(len(titles), maxNeighbors)]
+	}
+	return recipeNeighbors{
+		Like: neighbors(liked, saved, func(m similar.Match) bool {
+			return m.Score == 0 || m.Score >= critique.MinimumRecipeScore
+		}),
+		Avoid: neighbors(disliked, likedSet, func(similar.Match) bool { return true }),
+	}
+}
+
+func neighborInstructions(n recipeNeighbors) []string {
+	var instructions []string
+	if len(n.Like) > 0 {
+		instructions = append(instructions, "The user rated dishes like these highly; lean toward similar ideas: "+strings.Join(n.Like, ", ")+".")
+	}
+	if len(n.Avoid) > 0 {
+		instructions = append(instructions, "The user rated dishes like these poorly; steer away from similar ones: "+strings.Join(n.Avoid, ", ")+".")
+	}
+	return instructions
+}
@@ -0,0 +1,241 @@
+package similar
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"careme/internal/ai"
+	"careme/internal/cache"
+	"careme/internal/recipes/search"
+
+	"github.com/samber/lo"
+)
+
+const (
+	refreshTimeout = 5 * time.Minute
+	// a refresh embeds at most this many new recipes so a backlog after
+	// switching models is worked off over a few refreshes, not one long one.
+	maxEmbedPerRefresh = 256
+	embedBatch         = 64
+)
+
+// Match is a recipe with how similar it is, 1 being the same.
+type Match struct {
+	search.Doc
+	Similarity float64 `json:"similarity"`
+}
+
+// Index ranks the recipes in a search index by embedding similarity. The first
+// lookup loads the vectors already stored; once they're older than maxAge the
+// ones stored since are read in the background and recipes without one are
+// embedded. New recipes are embedded with Add when they're saved; lookups never
+// embed.
+type Index struct {
+	docs     *search.Index
+	store    Store
+	embedder ai.Embedder
+	maxAge   time.Duration
+	now      func() time.Time
+
+	mu       sync.RWMutex
+	vectors  map[string][]float32
+	loadedAt time.Time
+
+	refreshing atomic.Bool
+}
+
+func NewIndex(docs *search.Index, c cache.ListCache, embedder ai.Embedder, maxAge time.Duration) *Index {
+	return &Index{
+		docs:     docs,
+		store:    NewStore(c, embedder.Version()),
+		embedder: embedder,
+		maxAge:   maxAge,
+		now:      time.Now,
+	}
+}
+
+// Refresh reads vectors stored since the last load and embeds recipes that don't
+// have one yet.
+func (idx *Index) Refresh(ctx context.Context) error {
+	if err := idx.load(ctx); err != nil {
+		return err
+	}
+	docs, err := idx.docs.Docs(ctx)
+	if err != nil {
+		return err
+	}
+	idx.mu.RLock()
+	missing := lo.Filter(docs, func(d search.Doc, _ int) bool {
+		_, ok := idx.vectors[d.Hash]
+		return !ok && !d.Imported
+	})
+	idx.mu.RUnlock()
+	if len(missing) > maxEmbedPerRefresh {
+		missing = missing[:maxEmbedPerRefresh]
+	}
+	for _, batch := range lo.Chunk(missing, embedBatch) {
+		if _, err := idx.embed(ctx, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Add embeds and stores the docs that don't have a vector yet, so they're
+// ready to compare as soon as they're viewed. Imports aren't embedded.
+func (idx *Index) Add(ctx context.Context, docs ...search.Doc) error {
+	if err := idx.ensureLoaded(ctx); err != nil {
+		return err
+	}
+	idx.mu.RLock()
+	missing := lo.Filter(docs, func(d search.Doc, _ int) bool {
+		_, ok := idx.vectors[d.Hash]
+		return !ok && !d.Imported
+	})
+	idx.mu.RUnlock()
+	for _, batch := range lo.Chunk(lo.UniqBy(missing, func(d search.Doc) string { return d.Hash }), embedBatch) {
+		if _, err := idx.embed(ctx, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// load reads the vectors this replica doesn't have yet, like ones another
+// replica embedded. A vector that fails to read is tried again next load.
+func (idx *Index) load(ctx context.Context) error {
+	hashes, err := idx.store.Hashes(ctx)
+	if err != nil {
+		return err
+	}
+	idx.mu.RLock()
+	unseen := lo.Filter(hashes, func(hash string, _ int) bool {
+		_, ok := idx.vectors[hash]
+		return !ok
+	})
+	idx.mu.RUnlock()
+	vectors, err := idx.store.Get(ctx, unseen)
+	if err != nil {
+		slog.WarnContext(ctx, "skipping recipe embeddings that failed to load", "error", err)
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.vectors == nil {
+		idx.vectors = make(map[string][]float32, len(vectors))
+	}
+	for hash, v := range vectors {
+		idx.vectors[hash] = v
+	}
+	idx.loadedAt = idx.now()
+	return nil
+}
+
+// embed embeds, stores and indexes docs, returning their vectors by hash.
+func (idx *Index) embed(ctx context.Context, docs []search.Doc) (map[string][]float32, error) {
+	vectors, err := idx.embedder.Embed(ctx, lo.Map(docs, func(d search.Doc, _ int) string { return DocText(d) }))
+	if err != nil {
+		return nil, fmt.Errorf("embed %d recipes: %w", len(docs), err)
+	}
+	byHash := make(map[string][]float32, len(docs))
+	for i, d := range docs {
+		byHash[d.Hash] = vectors[i]
+	}
+	if err := idx.store.Put(ctx, byHash); err != nil {
+		return nil, err
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for hash, v := range byHash {
+		idx.vectors[hash] = v
+	}
+	return byHash, nil
+}
+
+func (idx *Index) ensureLoaded(ctx context.Context) error {
+	idx.mu.RLock()
+	loadedAt := idx.loadedAt
+	idx.mu.RUnlock()
+	if loadedAt.IsZero() {
+		return idx.load(ctx)
+	}
+	if idx.now().Sub(loadedAt) < idx.maxAge || !idx.refreshing.CompareAndSwap(false, true) {
+		return nil
+	}
+	go func() {
+		defer idx.refreshing.Store(false)
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), refreshTimeout)
+		defer cancel()
+		if err := idx.Refresh(ctx); err != nil {
+			slog.ErrorContext(ctx, "failed to refresh recipe embeddings", "error", err)
+		}
+	}()
+	return nil
+}
+
+// Like returns up to k recipes most like doc, leaving out doc itself, anything
+// in exclude, imports and recipes titled the same as one already picked. A doc
+// without a vector yet has no matches until it's added or the next refresh.
+func (idx *Index) Like(ctx context.Context, doc search.Doc, k int, exclude map[string]bool) ([]Match, error) {
+	if k <= 0 {
+		return nil, nil
+	}
+	if err := idx.ensureLoaded(ctx); err != nil {
+		return nil, err
+	}
+	idx.mu.RLock()
+	target, ok := idx.vectors[doc.Hash]
+	idx.mu.RUnlock()
+	if !ok {
+		return nil, nil
+	}
+	docs, err := idx.docs.Docs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	idx.mu.RLock()
+	var matches []Match
+	for _, d := range docs {
+		if d.Hash == doc.Hash || d.Imported || exclude[d.Hash] {
+			continue
+		}
+		v, ok := idx.vectors[d.Hash]
+		if !ok {
+			continue
+		}
+		if sim := ai.CosineSimilarity(target, v); sim > 0 {
+			matches = append(matches, Match{Doc: d, Similarity: sim})
+		}
+	}
+	idx.mu.RUnlock()
+
+	slices.SortFunc(matches, func(a, b Match) int {
+		return cmp.Or(
+			cmp.Compare(b.Similarity, a.Similarity),
+			cmp.Compare(b.Score, a.Score),
+			strings.Compare(a.Hash, b.Hash),
+		)
+	})
+	// the same dish generated twice is one suggestion, not two.
+	seen := map[string]bool{strings.ToLower(strings.TrimSpace(doc.Title)): true}
+	picked := make([]Match, 0, k)
+	for _, m := range matches {
+		title := strings.ToLower(strings.TrimSpace(m.Title))
+		if seen[title] {
+			continue
+		}
+		seen[title] = true
+		picked = append(picked, m)
+		if len(picked) == k {
+			break
+		}
+	}
+	return picked, nil
+}
@@ -0,0 +1,78 @@
+package similar
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"careme/internal/ai"
+	"careme/internal/cache"
+	"careme/internal/recipes/search"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingEmbedder struct {
+	ai.HashingEmbedder
+	texts int
+}
+
+func (e *countingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	e.texts += len(texts)
+	return e.HashingEmbedder.Embed(ctx, texts)
+}
+
+func TestIndex_Like(t *testing.T) {
+	c := cache.NewInMemoryCache()
+	docs := []search.Doc{
+		{Hash: "a1", Title: "Salmon Tacos", Cuisine: "Mexican", Ingredients: []string{"Salmon fillets", "Corn tortillas", "Lime"}},
+		{Hash: "b2", Title: "Fish Tacos", Cuisine: "Mexican", Ingredients: []string{"Cod fillets", "Corn tortillas", "Lime"}, Score: 8},
+		{Hash: "c3", Title: "fish tacos", Cuisine: "Mexican", Ingredients: []string{"Cod fillets", "Corn tortillas", "Limes"}},
+		{Hash: "d4", Title: "Braised Leeks", Ingredients: []string{"Leeks", "Butter"}},
+		{Hash: "e5", Title: "Shrimp Tacos", Cuisine: "Mexican", Ingredients: []string{"Shrimp", "Corn tortillas", "Lime"}, Imported: true},
+		{Hash: "f6", Title: "Salmon Tostadas", Cuisine: "Mexican", Ingredients: []string{"Salmon fillets", "Tostada shells", "Lime"}},
+	}
+	require.NoError(t, search.NewStore(c).Put(t.Context(), docs...))
+	embedder := &countingEmbedder{HashingEmbedder: ai.NewHashingEmbedder()}
+	idx := NewIndex(search.NewIndex(c, time.Hour), c, embedder, time.Hour)
+	require.NoError(t, idx.Refresh(t.Context()))
+	assert.Equal(t, 5, embedder.texts, "imports aren't embedded")
+
+	hashes := func(doc search.Doc, k int, exclude map[string]bool) []string {
+		t.Helper()
+		matches, err := idx.Like(t.Context(), doc, k, exclude)
+		require.NoError(t, err)
+		return lo.Map(matches, func(m Match, _ int) string { return m.Hash })
+	}
+
+	got := hashes(docs[0], 2, nil)
+	assert.ElementsMatch(t, []string{"b2", "f6"}, got, "tacos and salmon beat leeks; the second fish tacos and the import are left out")
+	assert.Equal(t, []string{"f6"}, hashes(docs[0], 1, map[string]bool{"b2": true, "c3": true}))
+
+	// a recipe saved since the last refresh isn't embedded by looking at it,
+	// only once it's added.
+	fresh := search.Doc{Hash: "g7", Title: "Leek Gratin", Ingredients: []string{"Leeks", "Gruyere"}}
+	assert.Empty(t, hashes(fresh, 1, nil))
+	assert.Equal(t, 5, embedder.texts, "lookups don't embed")
+	require.NoError(t, idx.Add(t.Context(), fresh, docs[0], docs[4]))
+	assert.Equal(t, 6, embedder.texts, "only the new recipe is embedded")
+	assert.Equal(t, []string{"d4"}, hashes(fresh, 1, nil))
+
+	stored, err := NewStore(c, embedder.Version()).Hashes(t.Context())
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a1", "b2", "c3", "d4", "f6", "g7"}, stored, "one vector per recipe outlives the index")
+	assert.Empty(t, lo.Must(NewStore(c, "text-embedding-3-small").Hashes(t.Context())), "another model's vectors are kept apart")
+
+	// another replica loads what's stored instead of embedding it again, and
+	// picks up recipes added elsewhere on its next refresh.
+	other := &countingEmbedder{HashingEmbedder: ai.NewHashingEmbedder()}
+	replica := NewIndex(search.NewIndex(c, time.Hour), c, other, time.Hour)
+	assert.Equal(t, []string{"d4"}, lo.Map(lo.Must(replica.Like(t.Context(), fresh, 1, nil)), func(m Match, _ int) string { return m.Hash }))
+	later := search.Doc{Hash: "h8", Title: "Leek Soup", Ingredients: []string{"Leeks", "Potatoes"}}
+	require.NoError(t, idx.Add(t.Context(), later))
+	require.NoError(t, replica.Refresh(t.Context()))
+	assert.Equal(t, 0, other.texts, "stored vectors are read, not re-embedded")
+	assert.NotEmpty(t, lo.Must(replica.Like(t.Context(), later, 1, nil)))
+}
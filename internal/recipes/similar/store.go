@@ -0,0 +1,115 @@
+// Package similar finds recipes like one another. Each recipe's search doc is
+// embedded into a vector, kept in the cache per embedder version, and an
+// in-memory Index ranks every recipe by how closely its vector points.
+package similar
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"careme/internal/cache"
+	"careme/internal/parallelism"
+	"careme/internal/recipes/search"
+
+	"github.com/samber/lo"
+)
+
+const (
+	Prefix = "recipe_embeddings/"
+	// how many vectors are read at once when loading.
+	loadBatch = 64
+)
+
+// Store keeps one vector per recipe in the cache under the embedder's version,
+// so switching models starts a fresh set instead of comparing vectors that
+// don't mix. A recipe's vector never changes once written, so saves don't
+// contend with each other and a reload only reads the ones it hasn't seen.
+type Store struct {
+	cache  cache.ListCache
+	prefix string
+}
+
+func NewStore(c cache.ListCache, version string) Store {
+	version = strings.NewReplacer("/", "-", ":", "-", " ", "-").Replace(version)
+	return Store{cache: c, prefix: Prefix + version + "/"}
+}
+
+// Put adds or replaces vectors by recipe hash.
+func (s Store) Put(ctx context.Context, vectors map[string][]float32) error {
+	for hash, v := range vectors {
+		if hash == "" {
+			continue
+		}
+		body, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if err := s.cache.Put(ctx, s.prefix+hash, string(body), cache.Unconditional()); err != nil {
+			return fmt.Errorf("save embedding %s: %w", hash, err)
+		}
+	}
+	return nil
+}
+
+// Hashes lists the recipes that have a stored vector.
+func (s Store) Hashes(ctx context.Context) ([]string, error) {
+	hashes, err := s.cache.List(ctx, s.prefix, "")
+	if err != nil {
+		return nil, fmt.Errorf("list embeddings: %w", err)
+	}
+	return hashes, nil
+}
+
+// Get reads the stored vectors for hashes. Ones that are missing are left out;
+// ones that fail to read are left out too and reported in the error.
+func (s Store) Get(ctx context.Context, hashes []string) (map[string][]float32, error) {
+	type stored struct {
+		hash   string
+		vector []float32
+	}
+	vectors := make(map[string][]float32, len(hashes))
+	var errs []error
+	for _, batch := range lo.Chunk(hashes, loadBatch) {
+		got, err := parallelism.MapWithErrors(batch, func(hash string) (stored, error) {
+			v, err := s.get(ctx, hash)
+			if err != nil && !errors.Is(err, cache.ErrNotFound) {
+				return stored{}, fmt.Errorf("load embedding %s: %w", hash, err)
+			}
+			return stored{hash: hash, vector: v}, nil
+		})
+		for _, st := range got {
+			if st.vector != nil {
+				vectors[st.hash] = st.vector
+			}
+		}
+		errs = append(errs, err)
+	}
+	return vectors, errors.Join(errs...)
+}
+
+func (s Store) get(ctx context.Context, hash string) ([]float32, error) {
+	r, err := s.cache.Get(ctx, s.prefix+hash)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := r.Close(); err != nil {
+			slog.ErrorContext(ctx, "failed to close embedding", "hash", hash, "error", err)
+		}
+	}()
+	var v []float32
+	if err := json.NewDecoder(r).Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// DocText is what gets embedded for a recipe: what it's called, where it's
+// from, how it's described and what goes in it.
+func DocText(d search.Doc) string {
+	return strings.Join(lo.Compact([]string{d.Title, d.Cuisine, d.Description, strings.Join(d.Ingredients, ", ")}), "\n")
+}
@@ -0,0 +1,99 @@
+package recipes
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"careme/internal/ai"
+	"careme/internal/cache"
+	"careme/internal/locations"
+	"careme/internal/recipes/critique"
+	"careme/internal/recipes/feedback"
+	"careme/internal/recipes/similar"
+	utypes "careme/internal/users/types"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRatedNeighbors_SteerMenuPlan(t *testing.T) {
+	cacheStore := cache.NewFileCache(filepath.Join(t.TempDir(), "cache"))
+	s := newTestServer(t, withTestCache(cacheStore))
+	recipe := func(title string, ingredients ...string) ai.Recipe {
+		return ai.Recipe{Title: title, Ingredients: lo.Map(ingredients, func(name string, _ int) ai.Ingredient { return ai.Ingredient{Name: name} })}
+	}
+	salmonTacos := recipe("Salmon Tacos", "Salmon", "Corn tortillas", "Lime")
+	tostadas := recipe("Salmon Tostadas", "Salmon", "Tostada shells", "Lime")
+	fishTacos := recipe("Fish Tacos", "Cod", "Corn tortillas", "Lime")
+	shrimpTacos := recipe("Shrimp Tacos", "Shrimp", "Corn tortillas", "Lime")
+	braisedLeeks := recipe("Braised Leeks", "Leeks", "Butter")
+	gratin := recipe("Leek Gratin", "Leeks", "Gruyere")
+	for _, r := range []ai.Recipe{salmonTacos, tostadas, fishTacos, shrimpTacos, braisedLeeks, gratin} {
+		require.NoError(t, s.SaveRecipe(t.Context(), r))
+	}
+	critiques := critique.NewStore(cacheStore)
+	require.NoError(t, critiques.Save(t.Context(), tostadas.ComputeHash(), &ai.RecipeCritique{OverallScore: 9}))
+	require.NoError(t, critiques.Save(t.Context(), fishTacos.ComputeHash(), &ai.RecipeCritique{OverallScore: 4}))
+	require.NoError(t, s.SaveFeedback(t.Context(), salmonTacos.ComputeHash(), feedback.Feedback{Cooked: true, Stars: 5}))
+	require.NoError(t, s.SaveFeedback(t.Context(), braisedLeeks.ComputeHash(), feedback.Feedback{Cooked: true, Stars: 1}))
+	require.NoError(t, s.similarIndex.Refresh(t.Context()))
+
+	saved := lo.Map([]ai.Recipe{salmonTacos, shrimpTacos, braisedLeeks}, func(r ai.Recipe, _ int) utypes.Recipe {
+		return utypes.Recipe{Title: r.Title, Hash: r.ComputeHash(), CreatedAt: time.Now()}
+	})
+	neighbors := s.ratedNeighbors(t.Context(), saved)
+	assert.Equal(t, []string{"Salmon Tostadas"}, neighbors.Like, "saved recipes and poorly critiqued ones aren't suggested")
+	assert.Equal(t, []string{"Leek Gratin"}, neighbors.Avoid)
+	assert.Equal(t, recipeNeighbors{}, s.ratedNeighbors(t.Context(), saved[1:2]), "nothing rated, nothing to steer by")
+
+	aiStub := &captureGenerateAIClient{}
+	params := DefaultParams(&locations.Location{ID: "70004001", Name: "Store"}, time.Now())
+	hash := params.Hash()
+	params.Neighbors = neighbors
+	assert.Equal(t, hash, params.Hash(), "neighbors are looked up each time, not part of the list")
+	g := newTestGenerator(t, aiStub, nil, fixedStaplesService{}, &statusCounter{}, nil)
+	_, err := g.GenerateRecipes(t.Context(), params)
+	require.NoError(t, err)
+	require.Len(t, aiStub.instructions, 1)
+	assert.Contains(t, aiStub.instructions[0], "The user rated dishes like these highly; lean toward similar ideas: Salmon Tostadas.")
+	assert.Contains(t, aiStub.instructions[0], "The user rated dishes like these poorly; steer away from similar ones: Leek Gratin.")
+}
+
+func TestMoreLikeThis(t *testing.T) {
+	s := newTestServer(t)
+	tacos := ai.Recipe{Title: "Salmon Tacos", Ingredients: []ai.Ingredient{{Name: "Salmon"}, {Name: "Corn tortillas"}}}
+	tostadas := ai.Recipe{Title: "Salmon Tostadas", Cuisine: "Mexican", CookTime: "20 minutes", Ingredients: []ai.Ingredient{{Name: "Salmon"}, {Name: "Tostada shells"}}}
+	leeks := ai.Recipe{Title: "Braised Leeks", Ingredients: []ai.Ingredient{{Name: "Leeks"}}}
+	for _, r := range []ai.Recipe{tacos, tostadas, leeks} {
+		require.NoError(t, s.SaveRecipe(t.Context(), r))
+	}
+	require.NoError(t, s.similarIndex.Refresh(t.Context()))
+
+	matches := s.moreLikeThis(t.Context(), tacos.ComputeHash(), tacos)
+	require.Len(t, matches, 1)
+	assert.Equal(t, tostadas.ComputeHash(), matches[0].Hash)
+
+	// a recipe saved after the refresh has nothing like it until it's added.
+	tinga := ai.Recipe{Title: "Salmon Tinga Tacos", Ingredients: []ai.Ingredient{{Name: "Salmon"}, {Name: "Corn tortillas"}, {Name: "Chipotle"}}}
+	require.NoError(t, s.SaveRecipe(t.Context(), tinga))
+	assert.Empty(t, s.moreLikeThis(t.Context(), tinga.ComputeHash(), tinga), "viewing a recipe doesn't embed it")
+	s.addSimilar(t.Context(), tinga)
+	s.wg.Wait()
+	assert.NotEmpty(t, s.moreLikeThis(t.Context(), tinga.ComputeHash(), tinga))
+
+	w := httptest.NewRecorder()
+	p := DefaultParams(&locations.Location{ID: "70000001", Name: "Store"}, time.Now())
+	FormatRecipeHTML(t.Context(), p, tacos, false, nil, nil, false, nil, feedback.Feedback{}, nil, matches, 0, w)
+	html := assertHTTPSuccess(t, w)
+	isValidHTML(t, html)
+	assert.Contains(t, html, `id="more-like-this"`)
+	assert.Contains(t, html, `href="/recipe/`+tostadas.ComputeHash()+`"`)
+	assert.Contains(t, html, "Mexican &middot; 20 min")
+
+	w = httptest.NewRecorder()
+	FormatRecipeHTML(t.Context(), p, tacos, false, nil, nil, false, nil, feedback.Feedback{}, nil, []similar.Match{}, 0, w)
+	assert.NotContains(t, assertHTTPSuccess(t, w), `id="more-like-this"`)
+}
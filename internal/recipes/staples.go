@@ -22,6 +22,7 @@ import (
 	"careme/internal/config"
 	"careme/internal/farmersmarket"
 	"careme/internal/heb"
+	"careme/internal/ingredients/pricehistory"
 	"careme/internal/kroger"
 	"careme/internal/locations"
 	"careme/internal/parallelism"
@@ -85,6 +86,23 @@ func (p routingStaplesProvider) FetchWines(ctx context.Context, locationID strin
 	return provider.FetchWines(ctx, locationID, styles)
 }
 
+// SearchStaples searches the location's backend live. Backends that can't
+// search return nothing rather than an error.
+func (p routingStaplesProvider) SearchStaples(ctx context.Context, locationID, term string) ([]ai.InputIngredient, error) {
+	provider, err := p.providerForLocation(locationID)
+	if err != nil {
+		return nil, err
+	}
+	searcher, ok := provider.(staplesSearcher)
+	if !ok {
+		return nil, nil
+	}
+	ctx, span := tracer.Start(ctx, "staples.searchstaples")
+	span.SetAttributes(attribute.String("backend", fmt.Sprintf("%T", provider)))
+	defer span.End()
+	return searcher.SearchStaples(ctx, locationID, term)
+}
+
 func (p dedupingStaplesProvider) FetchStaples(ctx context.Context, locationID string) ([]ai.InputIngredient, error) {
 	ingredients, err := p.provider.FetchStaples(ctx, locationID)
 	if err != nil {
@@ -101,6 +119,18 @@ func (p dedupingStaplesProvider) FetchWines(ctx context.Context, locationID stri
 	return dedupeInputIngredients(ingredients)
 }
 
+func (p dedupingStaplesProvider) SearchStaples(ctx context.Context, locationID, term string) ([]ai.InputIngredient, error) {
+	searcher, ok := p.provider.(staplesSearcher)
+	if !ok {
+		return nil, nil
+	}
+	ingredients, err := searcher.SearchStaples(ctx, locationID, term)
+	if err != nil {
+		return nil, err
+	}
+	return dedupeInputIngredients(ingredients)
+}
+
 type ingredientio interface {
 	SaveIngredients(ctx context.Context, hash string, ingredients []ai.InputIngredient) error
 	IngredientsFromCache(ctx context.Context, hash string) ([]ai.InputIngredient, error)
@@ -118,10 +148,15 @@ type staplesFetcher interface {
 	FetchStaples(ctx context.Context, p *GeneratorParams) ([]ai.InputIngredient, error)
 }
 
+type priceRecorder interface {
+	Record(ctx context.Context, locationID string, ingredients []ai.InputIngredient) ([]ai.InputIngredient, error)
+}
+
 type cachedStaplesService struct {
 	provider staplesProvider
 	cache    ingredientio
 	grader   grader
+	prices   priceRecorder
 }
 
 type staplesProvider interface {
@@ -129,6 +164,12 @@ type staplesProvider interface {
 	FetchWines(ctx context.Context, locationID string, styles []string) ([]ai.InputIngredient, error)
 }
 
+// staplesSearcher is implemented by backends that can look up a single term
+// live instead of fetching every staple.
+type staplesSearcher interface {
+	SearchStaples(ctx context.Context, locationID, term string) ([]ai.InputIngredient, error)
+}
+
 func dedupeInputIngredients(ingredients []ai.InputIngredient) ([]ai.InputIngredient, error) {
 	seen := map[string]bool{}
 	var deduped []ai.InputIngredient
@@ -155,10 +196,14 @@ func NewCachedStaplesService(cfg *config.Config, c cache.Cache, grader grader) (
 		provider: provider,
 		cache:    rio,
 		grader:   grader,
+		prices:   pricehistory.NewStore(c),
 	}, nil
 }
 
 func (s *cachedStaplesService) FetchStaples(ctx context.Context, p *GeneratorParams) ([]ai.InputIngredient, error) {
+	if len(p.ExtraLocations) > 0 {
+		return s.fetchStaplesAcrossStores(ctx, p)
+	}
 	lochash := p.LocationHash()
 	locationID := p.Location.ID
 
@@ -186,6 +231,16 @@ func (s *cachedStaplesService) FetchStaples(ctx context.Context, p *GeneratorPar
 		return nil, err
 	}
 
+	// the day's cached ingredients keep their price history so we only record once a fetch.
+	if s.prices != nil {
+		priced, err := s.prices.Record(ctx, locationID, graded)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to record price history", "location", locationID, "error", err)
+		} else {
+			graded = priced
+		}
+	}
+
 	if err := s.cache.SaveIngredients(ctx, lochash, graded); err != nil {
 		slog.ErrorContext(ctx, "failed to cache ingredients", "location", p.String(), "error", err)
 		return nil, err
@@ -264,6 +319,20 @@ func (s *cachedStaplesService) FetchWines(ctx context.Context, locationID string
 	return wines, nil
 }
 
+// SearchStaples grades a live search so results can be ranked like cached staples.
+// It isn't cached; it's for what's on the shelf now.
+func (s *cachedStaplesService) SearchStaples(ctx context.Context, locationID, term string) ([]ai.InputIngredient, error) {
+	searcher, ok := s.provider.(staplesSearcher)
+	if !ok {
+		return nil, nil
+	}
+	found, err := searcher.SearchStaples(ctx, locationID, term)
+	if err != nil || len(found) == 0 {
+		return nil, err
+	}
+	return s.grader.GradeIngredients(ctx, found)
+}
+
 type StaplesWatchdog struct {
 	locations locationByID
 	staples   staplesFetcher
@@ -375,6 +375,55 @@ func TestFetchStaples_GradesCachedIngredientsBeforeReturning(t *testing.T) {
 	}
 }
 
+type stubPriceRecorder struct {
+	locations []string
+}
+
+func (s *stubPriceRecorder) Record(_ context.Context, locationID string, ingredients []ai.InputIngredient) ([]ai.InputIngredient, error) {
+	s.locations = append(s.locations, locationID)
+	priced := slices.Clone(ingredients)
+	for i := range priced {
+		priced[i].PriceHistory = &ai.PriceHistory{Days: 3, Low: 4, Average: 5, Deal: true}
+	}
+	return priced, nil
+}
+
+func TestFetchStaples_RecordsPricesOncePerFetch(t *testing.T) {
+	cacheStore := cache.NewInMemoryCache()
+	prices := &stubPriceRecorder{}
+	s := &cachedStaplesService{
+		cache:  IO(cacheStore),
+		grader: &stubIngredientGrader{},
+		prices: prices,
+		provider: &stubRoutingStaplesProvider{
+			ingredients: []ai.InputIngredient{{ProductID: "leek-1", Description: "Leeks", PriceRegular: new(float32(3.99))}},
+		},
+	}
+	params := &generatorParams{
+		Location: &locations.Location{ID: "70100023", Name: "Test Store"},
+		Date:     time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC),
+	}
+
+	got, err := s.FetchStaples(t.Context(), params)
+	if err != nil {
+		t.Fatalf("FetchStaples returned error: %v", err)
+	}
+	if len(got) != 1 || !got[0].Deal() {
+		t.Fatalf("expected price history on fetched staples, got %+v", got)
+	}
+
+	cached, err := s.FetchStaples(t.Context(), params)
+	if err != nil {
+		t.Fatalf("FetchStaples returned error on cached call: %v", err)
+	}
+	if len(cached) != 1 || !cached[0].Deal() {
+		t.Fatalf("expected cached staples to keep price history, got %+v", cached)
+	}
+	if !slices.Equal(prices.locations, []string{"70100023"}) {
+		t.Fatalf("expected one price recording for the fresh fetch, got %v", prices.locations)
+	}
+}
+
 func TestWatchdogUsesStoreLocalDateForCacheKey(t *testing.T) {
 	cacheStore := cache.NewInMemoryCache()
 	provider := &stubRoutingStaplesProvider{
@@ -2,7 +2,9 @@ package status
 
 import (
 	"cmp"
+	"errors"
 	"fmt"
+	"maps"
 	"slices"
 	"strings"
 
@@ -11,22 +13,77 @@ import (
 	"github.com/samber/lo"
 )
 
+// Sales lists the best buys: items well under what the store usually charges
+// first, then the store's own sales.
 func Sales(ings []ai.InputIngredient) []string {
 	sales := lo.Filter(ings, func(ing ai.InputIngredient, _ int) bool {
-		return ing.PercentOff() > 0
+		return ing.Deal() || ing.PercentOff() > 0
 	})
 	slices.SortFunc(sales, func(a, b ai.InputIngredient) int {
-		return cmp.Compare(b.PercentOff(), a.PercentOff()) // descending
+		if a.Deal() != b.Deal() {
+			if a.Deal() {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Compare(discount(b), discount(a)) // descending
 	})
 
 	return lo.Take(lo.Map(sales, func(ing ai.InputIngredient, _ int) string {
+		if ing.Deal() {
+			return fmt.Sprintf("%s %.0f%% below usual at %.2f", ing.Description, ing.PercentBelowUsual(), *ing.Price())
+		}
 		return fmt.Sprintf("%s %.0f%% off at %.2f", ing.Description, ing.PercentOff(), *ing.PriceSale)
 	}), 5)
 }
 
-func Ingredients(ings []ai.InputIngredient, originalCount int) string {
+func discount(ing ai.InputIngredient) float32 {
+	if ing.Deal() {
+		return ing.PercentBelowUsual()
+	}
+	return ing.PercentOff()
+}
+
+// Filtered is what a user's ingredient preferences left out of planning,
+// beyond what we'd leave out for everyone.
+type Filtered struct {
+	MinGrade   int
+	BelowGrade int
+	Banned     map[string]int
+}
+
+func (f *Filtered) Ban(term string) {
+	if f.Banned == nil {
+		f.Banned = map[string]int{}
+	}
+	f.Banned[term]++
+}
+
+func (f Filtered) lines() []string {
+	var lines []string
+	if f.BelowGrade > 0 {
+		lines = append(lines, fmt.Sprintf("Left out %s graded below your %d", ingredientCount(f.BelowGrade), f.MinGrade))
+	}
+	for _, term := range slices.Sorted(maps.Keys(f.Banned)) {
+		lines = append(lines, fmt.Sprintf("Left out %s matching %q", ingredientCount(f.Banned[term]), term))
+	}
+	return lines
+}
+
+func ingredientCount(n int) string {
+	if n == 1 {
+		return "1 ingredient"
+	}
+	return fmt.Sprintf("%d ingredients", n)
+}
+
+func Ingredients(ings []ai.InputIngredient, originalCount int, filtered Filtered) string {
 	var b strings.Builder
 	fmt.Fprintf(&b, "Considering %d out of %d ingredients\n", len(ings), originalCount)
+	for _, line := range filtered.lines() {
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
 	for _, sale := range Sales(ings) {
 		b.WriteString(sale)
 		b.WriteString("\n")
@@ -35,10 +92,15 @@ func Ingredients(ings []ai.InputIngredient, originalCount int) string {
 	return b.String()
 }
 
+const SpendCapReached = "We've cooked up all the recipes we can for today. Please try again tomorrow."
+
 func Error(err error) string {
 	if err == nil {
 		return ""
 	}
+	if errors.Is(err, ai.ErrSpendCapReached) {
+		return SpendCapReached
+	}
 	return "Something went wrong: " + err.Error()
 }
 
@@ -1,6 +1,8 @@
 package status
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 
 	"careme/internal/ai"
@@ -44,7 +46,44 @@ func TestIngredientsIncludesCountAndSales(t *testing.T) {
 			PriceRegular: new(float32(10)),
 			PriceSale:    new(float32(5)),
 		},
-	}, 3)
+	}, 3, Filtered{})
 
 	assert.Equal(t, "Considering 1 out of 3 ingredients\nHalf Off Spinach 50% off at 5.00\n", got)
 }
+
+func TestIngredientsReportsUserFilters(t *testing.T) {
+	filtered := Filtered{MinGrade: 8, BelowGrade: 4}
+	filtered.Ban("pork")
+	filtered.Ban("pork")
+	filtered.Ban("lamb")
+
+	got := Ingredients(nil, 10, filtered)
+
+	assert.Equal(t, "Considering 0 out of 10 ingredients\nLeft out 4 ingredients graded below your 8\nLeft out 1 ingredient matching \"lamb\"\nLeft out 2 ingredients matching \"pork\"\n", got)
+}
+
+func TestErrorExplainsSpendCap(t *testing.T) {
+	assert.Equal(t, SpendCapReached, Error(fmt.Errorf("failed to create menu plan: %w", ai.ErrSpendCapReached)))
+	assert.Equal(t, "Something went wrong: boom", Error(errors.New("boom")))
+}
+
+func TestSalesPutsRealDealsFirst(t *testing.T) {
+	got := Sales([]ai.InputIngredient{
+		{
+			Description:  "Always On Sale Salmon",
+			PriceRegular: new(float32(14)),
+			PriceSale:    new(float32(10)),
+			PriceHistory: &ai.PriceHistory{Days: 20, Low: 10, Average: 10},
+		},
+		{
+			Description:  "Cheap Today Leeks",
+			PriceRegular: new(float32(2)),
+			PriceHistory: &ai.PriceHistory{Days: 20, Low: 2, Average: 3, Deal: true},
+		},
+	})
+
+	assert.Equal(t, []string{
+		"Cheap Today Leeks 33% below usual at 2.00",
+		"Always On Sale Salmon 29% off at 10.00",
+	}, got)
+}
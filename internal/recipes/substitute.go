@@ -0,0 +1,205 @@
+package recipes
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"unicode"
+
+	"careme/internal/ai"
+	"careme/internal/cache"
+
+	"github.com/samber/lo"
+)
+
+const (
+	// maxSubstitutes is how many alternatives are offered for a missing ingredient.
+	maxSubstitutes         = 5
+	substitutesCachePrefix = "substitutes/"
+)
+
+// FindSubstitutes offers what to buy instead of an ingredient that isn't on the
+// shelf: the day's cached staples plus a live search of the store it was coming
+// from, ranked by how related they are, then by grade. Nothing the household
+// can't eat is offered.
+func (g *generatorService) FindSubstitutes(ctx context.Context, p *generatorParams, missing ai.Ingredient) ([]ai.InputIngredient, error) {
+	ctx, span := tracer.Start(ctx, "recipes.findsubstitutes")
+	defer span.End()
+
+	staples, err := g.staples.FetchStaples(ctx, p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get staples: %w", err)
+	}
+	pool := slices.Clone(staples)
+	if searcher, ok := g.staples.(staplesSearcher); ok {
+		locationID := lo.CoalesceOrEmpty(missing.LocationID, p.Location.ID)
+		live, err := searcher.SearchStaples(ctx, locationID, searchTerm(missing.Name))
+		if err != nil {
+			// the cached staples are still worth offering.
+			slog.ErrorContext(ctx, "failed to search staples for substitutes", "location", locationID, "ingredient", missing.Name, "error", err)
+		}
+		for i := range live {
+			live[i].LocationID = missing.LocationID
+		}
+		pool = append(pool, live...)
+	}
+	pool = filterRestrictedStaples(pool, householdMatcher(p.Household))
+	return rankSubstitutes(missing, pool, newStapleFilter(p.IngredientPreferences)), nil
+}
+
+// SubstituteIngredient rewrites recipe around swapping missing for substitute,
+// which the caller has checked was on offer, and holds the rewrite to the
+// household's restrictions like any generated recipe. The caller saves the
+// result as a child of recipe.
+func (g *generatorService) SubstituteIngredient(ctx context.Context, p *generatorParams, recipe ai.Recipe, missing ai.Ingredient, substitute ai.InputIngredient) (*ai.Recipe, error) {
+	ctx, span := tracer.Start(ctx, "recipes.substitute")
+	defer span.End()
+	if strings.TrimSpace(recipe.ResponseID) == "" {
+		return nil, fmt.Errorf("recipe %q is missing response ID for substitution", recipe.Title)
+	}
+	staples, err := g.staples.FetchStaples(ctx, p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get staples: %w", err)
+	}
+
+	replacement, err := g.RegenerateRecipe(ctx, substituteInstructions(missing, substitute), recipe.ResponseRef())
+	if err != nil {
+		return nil, fmt.Errorf("failed to adapt %q to %s: %w", recipe.Title, substitute.Description, err)
+	}
+	ingMap := inputIngredientMap(staples)
+	ingMap[substitute.ProductID] = substitute
+	enrichRecipe(replacement, ingMap)
+	// no status to report to; the list finished generating long ago.
+	return g.enforceRestrictions(ctx, "", replacement, ingMap, householdMatcher(p.Household))
+}
+
+func substituteInstructions(missing ai.Ingredient, substitute ai.InputIngredient) []string {
+	name := strings.TrimSpace(strings.Join([]string{substitute.Brand, substitute.Description}, " "))
+	if substitute.Size != "" {
+		name += " (" + substitute.Size + ")"
+	}
+	return []string{
+		fmt.Sprintf("The store is out of %s. Use %s instead, product id %s.", missing.Name, name, substitute.ProductID),
+		"Adjust quantities, timing and technique for the swap so the dish still works, and keep the rest of the recipe as close as possible. Return a complete updated recipe.",
+	}
+}
+
+// rankSubstitutes keeps staples that share a category or a word with missing,
+// most related first, then best graded, then cheapest. Anything the user's
+// ingredient preferences would keep out of generation is left out here too.
+func rankSubstitutes(missing ai.Ingredient, pool []ai.InputIngredient, filter stapleFilter) []ai.InputIngredient {
+	original, found := lo.Find(pool, func(ing ai.InputIngredient) bool {
+		return ing.ProductID == missing.ProductID
+	})
+	words := substituteWords(missing.Name)
+	if found {
+		words = lo.Union(words, substituteWords(original.Description))
+	}
+
+	type candidate struct {
+		ingredient ai.InputIngredient
+		related    int
+	}
+	var candidates []candidate
+	seen := map[string]bool{missing.ProductID: true}
+	for _, ing := range pool {
+		if seen[ing.ProductID] || !filter.allows(ing) {
+			continue
+		}
+		related := len(lo.Intersect(words, substituteWords(ing.Description)))
+		if found {
+			related += 2 * len(lo.Intersect(original.Categories, ing.Categories))
+		}
+		if related == 0 {
+			continue
+		}
+		seen[ing.ProductID] = true
+		candidates = append(candidates, candidate{ingredient: ing, related: related})
+	}
+	slices.SortStableFunc(candidates, func(a, b candidate) int {
+		if c := cmp.Compare(b.related, a.related); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(b.ingredient.Grade.GetScore(), a.ingredient.Grade.GetScore()); c != 0 {
+			return c
+		}
+		return cmp.Compare(substitutePrice(a.ingredient), substitutePrice(b.ingredient))
+	})
+	return lo.Map(lo.Slice(candidates, 0, maxSubstitutes), func(c candidate, _ int) ai.InputIngredient {
+		return c.ingredient
+	})
+}
+
+func substitutePrice(ing ai.InputIngredient) float32 {
+	if price := ing.Price(); price != nil {
+		return *price
+	}
+	return 0
+}
+
+// substituteWords are the lowercase, singular words in a name worth matching on.
+func substituteWords(name string) []string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for i, w := range words {
+		words[i] = singularWords(w)
+	}
+	return lo.Uniq(lo.Filter(words, func(w string, _ int) bool {
+		return len(w) > 2 && !substituteStopWords[w]
+	}))
+}
+
+var substituteStopWords = map[string]bool{
+	"and": true, "the": true, "with": true, "fresh": true, "organic": true, "natural": true, "large": true, "small": true,
+}
+
+// searchTerm trims a recipe ingredient name down to something a store search handles.
+func searchTerm(name string) string {
+	if before, _, ok := strings.Cut(name, ","); ok {
+		name = before
+	}
+	return strings.Join(substituteWords(name), " ")
+}
+
+// substitutesCacheKey is per shopping list and missing ingredient.
+func substitutesCacheKey(originHash string, missing ai.Ingredient) string {
+	fnv := fnv.New64a()
+	lo.Must(io.WriteString(fnv, missing.LocationID))
+	lo.Must(io.WriteString(fnv, missing.ProductID))
+	lo.Must(io.WriteString(fnv, strings.ToLower(strings.TrimSpace(missing.Name))))
+	return substitutesCachePrefix + originHash + "/" + base64.RawURLEncoding.EncodeToString(fnv.Sum(nil))
+}
+
+// SubstitutesFromCache returns the substitutes offered for missing on a shopping list.
+func (rio recipeio) SubstitutesFromCache(ctx context.Context, originHash string, missing ai.Ingredient) ([]ai.InputIngredient, error) {
+	reader, err := rio.Cache.Get(ctx, substitutesCacheKey(originHash, missing))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			slog.ErrorContext(ctx, "failed to close cached substitutes", "origin_hash", originHash, "ingredient", missing.Name, "error", err)
+		}
+	}()
+	var substitutes []ai.InputIngredient
+	if err := json.NewDecoder(reader).Decode(&substitutes); err != nil {
+		return nil, err
+	}
+	return substitutes, nil
+}
+
+func (rio recipeio) SaveSubstitutes(ctx context.Context, originHash string, missing ai.Ingredient, substitutes []ai.InputIngredient) error {
+	body, err := json.Marshal(substitutes)
+	if err != nil {
+		return err
+	}
+	return rio.Cache.Put(ctx, substitutesCacheKey(originHash, missing), string(body), cache.Unconditional())
+}
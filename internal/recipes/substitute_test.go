@@ -0,0 +1,132 @@
+package recipes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"careme/internal/ai"
+	"careme/internal/locations"
+	utypes "careme/internal/users/types"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// searchableStaplesService has cached staples and a live search that finds more.
+type searchableStaplesService struct {
+	fixedStaplesService
+	live     []ai.InputIngredient
+	searches []string
+}
+
+func (s *searchableStaplesService) SearchStaples(_ context.Context, locationID, term string) ([]ai.InputIngredient, error) {
+	s.searches = append(s.searches, locationID+":"+term)
+	return s.live, nil
+}
+
+func grade(score int) *ai.IngredientGrade {
+	return &ai.IngredientGrade{Score: score}
+}
+
+func TestRankSubstitutes(t *testing.T) {
+	missing := ai.Ingredient{Name: "Leeks, sliced", ProductID: "leek-1"}
+	pool := []ai.InputIngredient{
+		{ProductID: "leek-1", Description: "Fresh Leeks", Categories: []string{"Produce", "Onions"}, Grade: grade(8)},
+		{ProductID: "shallot-1", Description: "Shallots", Categories: []string{"Produce", "Onions"}, Grade: grade(7), PriceRegular: new(float32(3))},
+		{ProductID: "onion-1", Description: "Yellow Onions", Categories: []string{"Produce", "Onions"}, Grade: grade(7), PriceRegular: new(float32(1.5))},
+		{ProductID: "leek-2", Description: "Baby Leeks", Categories: []string{"Produce"}, Grade: grade(9)},
+		{ProductID: "leek-3", Description: "Leek Soup Mix", Categories: []string{"Soup"}, Grade: grade(IngredientGradeCutoff)},
+		{ProductID: "salmon-1", Description: "Salmon Fillet", Categories: []string{"Seafood"}, Grade: grade(9)},
+		{ProductID: "onion-1", Description: "Yellow Onions", Categories: []string{"Produce", "Onions"}, Grade: grade(7)},
+	}
+
+	ids := func(got []ai.InputIngredient) []string {
+		return lo.Map(got, func(ing ai.InputIngredient, _ int) string { return ing.ProductID })
+	}
+	got := rankSubstitutes(missing, pool, newStapleFilter(utypes.IngredientPreferences{}))
+	assert.Equal(t, []string{"onion-1", "shallot-1", "leek-2"}, ids(got), "shared categories count double, then cheaper wins a tie")
+
+	got = rankSubstitutes(missing, pool, newStapleFilter(utypes.IngredientPreferences{MinGrade: 8, Bans: []string{"onions"}}))
+	assert.Equal(t, []string{"leek-2"}, ids(got), "the user's bans and minimum grade apply to substitutes")
+}
+
+func TestSearchTerm(t *testing.T) {
+	assert.Equal(t, "boneless chicken thigh", searchTerm("Boneless chicken thighs, cut into 1-inch pieces"))
+	assert.Equal(t, "leek", searchTerm("Fresh leeks"))
+}
+
+func TestSubstituteIngredientAdaptsRecipe(t *testing.T) {
+	adapted := ai.Recipe{
+		Title:       "Braised Shallots and Chicken",
+		Ingredients: []ai.Ingredient{{Name: "Shallots", Quantity: "6", ProductID: "shallot-1"}},
+		ResponseID:  "resp-adapted",
+	}
+	aiStub := &captureRegenerateAIClient{recipe: &adapted}
+	staples := &searchableStaplesService{
+		fixedStaplesService: fixedStaplesService{ingredients: []ai.InputIngredient{
+			{ProductID: "leek-1", Description: "Leeks", Categories: []string{"Produce", "Onions"}},
+		}},
+		live: []ai.InputIngredient{
+			{ProductID: "shallot-1", Description: "Shallots", Size: "8 oz", Categories: []string{"Produce", "Onions"}, AisleNumber: "4", PriceRegular: new(float32(3.49))},
+		},
+	}
+	g := newTestGenerator(t, aiStub, nil, staples, noopstatuswriter{}, nil)
+	p := DefaultParams(&locations.Location{ID: "70004001", Name: "Store"}, time.Now())
+	recipe := ai.Recipe{
+		Title:       "Braised Leeks and Chicken",
+		Ingredients: []ai.Ingredient{{Name: "Leeks", Quantity: "3", ProductID: "leek-1"}},
+		ResponseID:  "resp-original",
+	}
+
+	substitutes, err := g.FindSubstitutes(t.Context(), p, recipe.Ingredients[0])
+	require.NoError(t, err)
+	require.Len(t, substitutes, 1)
+	assert.Equal(t, "shallot-1", substitutes[0].ProductID)
+	assert.Equal(t, []string{"70004001:leek"}, staples.searches)
+
+	got, err := g.SubstituteIngredient(t.Context(), p, recipe, recipe.Ingredients[0], substitutes[0])
+	require.NoError(t, err)
+	assert.Equal(t, "resp-original", aiStub.responseID)
+	require.NotEmpty(t, aiStub.instructions)
+	assert.Contains(t, aiStub.instructions[0], "Use Shallots (8 oz) instead, product id shallot-1")
+	assert.Equal(t, "$3.49", got.Ingredients[0].Price, "the substitute's details come from the live search")
+	assert.Equal(t, "4", got.Ingredients[0].AisleNumber)
+}
+
+func TestSubstituteIngredientKeepsHouseholdRestrictions(t *testing.T) {
+	withPeanuts := ai.Recipe{
+		Title:       "Satay Noodles",
+		Ingredients: []ai.Ingredient{{Name: "Peanut butter", Quantity: "1/4 cup"}, {Name: "Sunflower seed butter", Quantity: "2 tbsp", ProductID: "sunbutter-1"}},
+		ResponseID:  "resp-adapted",
+	}
+	without := ai.Recipe{
+		Title:       "Sunbutter Noodles",
+		Ingredients: []ai.Ingredient{{Name: "Sunflower seed butter", Quantity: "1/4 cup", ProductID: "sunbutter-1"}},
+		ResponseID:  "resp-fixed",
+	}
+	aiStub := &sequenceAIClient{regenerateResponses: []*ai.Recipe{&withPeanuts, &without}}
+	staples := fixedStaplesService{ingredients: []ai.InputIngredient{
+		{ProductID: "almond-1", Description: "Almond Butter", Categories: []string{"Nut Butters"}},
+		{ProductID: "peanut-1", Description: "Creamy Peanut Butter", Categories: []string{"Nut Butters"}},
+		{ProductID: "sunbutter-1", Description: "Sunflower Seed Butter", Categories: []string{"Nut Butters"}},
+	}}
+	g := newTestGenerator(t, aiStub, nil, staples, noopstatuswriter{}, nil)
+	p := DefaultParams(&locations.Location{ID: "70004001", Name: "Store"}, time.Now())
+	p.Household = utypes.Household{Allergens: []string{"peanuts"}}
+	recipe := ai.Recipe{
+		Title:       "Almond Butter Noodles",
+		Ingredients: []ai.Ingredient{{Name: "Almond butter", Quantity: "1/4 cup", ProductID: "almond-1"}},
+		ResponseID:  "resp-original",
+	}
+
+	substitutes, err := g.FindSubstitutes(t.Context(), p, recipe.Ingredients[0])
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sunbutter-1"}, lo.Map(substitutes, func(ing ai.InputIngredient, _ int) string { return ing.ProductID }), "peanut butter isn't offered to a peanut allergy")
+
+	got, err := g.SubstituteIngredient(t.Context(), p, recipe, recipe.Ingredients[0], substitutes[0])
+	require.NoError(t, err)
+	assert.Equal(t, "Sunbutter Noodles", got.Title, "a rewrite that brings peanuts back is reworked")
+	assert.Equal(t, []string{"resp-original", "resp-adapted"}, aiStub.regenerateResponseIDs)
+}
@@ -0,0 +1,332 @@
+package recipes
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"careme/internal/ai"
+	"careme/internal/cache"
+	"careme/internal/parallelism"
+	"careme/internal/recipes/export"
+	utypes "careme/internal/users/types"
+
+	"github.com/samber/lo"
+)
+
+const (
+	// tasteSavedHistory and tasteSelectionHistory bound how far back taste is
+	// learned from: the newest saved recipes and the newest shopping lists
+	// with saves or dismissals.
+	tasteSavedHistory     = 50
+	tasteSelectionHistory = 20
+	relearnTasteTimeout   = 30 * time.Second
+	// a cuisine, protein or technique needs this much weight either way to
+	// make the profile, and only the strongest few do.
+	tasteThreshold = 2
+	tasteTopN      = 3
+	// cook time and cost are only learned from this many liked recipes.
+	tasteMinLiked = 3
+)
+
+// what each signal says about a recipe. A one star rating outweighs saving it.
+const (
+	savedWeight     = 1
+	likedWeight     = 2
+	dislikedWeight  = -3
+	dismissedWeight = -1
+)
+
+// tasteProteins are the anchor proteins we look for in ingredient names, as
+// singular words.
+var tasteProteins = []string{
+	"chicken", "beef", "pork", "lamb", "turkey", "duck", "sausage", "salmon", "tuna", "cod", "halibut",
+	"trout", "tilapia", "shrimp", "scallop", "mussel", "clam", "crab", "tofu", "tempeh", "chickpea", "lentil", "bean",
+}
+
+// tasteTechniques maps each technique to the word stems that give it away in
+// a title or step, like "roasted" or "roasting".
+var tasteTechniques = []struct {
+	name  string
+	stems []string
+}{
+	{"roasting", []string{"roast"}},
+	{"braising", []string{"brais"}},
+	{"grilling", []string{"grill"}},
+	{"searing", []string{"sear"}},
+	{"stir-frying", []string{"stirfr", "stir-fr"}},
+	{"baking", []string{"bake", "baki"}},
+	{"poaching", []string{"poach"}},
+	{"steaming", []string{"steam"}},
+	{"broiling", []string{"broil"}},
+	{"smoking", []string{"smok"}},
+	{"stewing", []string{"stew"}},
+}
+
+// tasteSignal is one recipe from the user's history and how much they liked it.
+type tasteSignal struct {
+	recipe ai.Recipe
+	weight int
+}
+
+// relearnTaste rebuilds the user's taste profile from their history and saves
+// it if it changed. Profiles the user edited are theirs and left alone. Failing
+// to learn keeps the old one.
+func (s *server) relearnTaste(ctx context.Context, currentUser *utypes.User) {
+	if currentUser.Taste.Edited || currentUser.ID == guestUser.ID {
+		return
+	}
+	learned, err := s.learnTaste(ctx, currentUser)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to learn taste profile", "user_id", currentUser.ID, "error", err)
+		return
+	}
+	if learned.SameTaste(currentUser.Taste) {
+		return
+	}
+	if err := s.storage.Modify(ctx, currentUser, func(stored *utypes.User) error {
+		if stored.Taste.Edited {
+			return cache.ErrSkipUpdate
+		}
+		stored.Taste = learned
+		return nil
+	}); err != nil {
+		slog.ErrorContext(ctx, "failed to save taste profile", "user_id", currentUser.ID, "error", err)
+	}
+}
+
+// relearnTasteLater relearns the user's taste in the background after they
+// save, dismiss or rate a recipe, so their next list is planned with it
+// without waiting on it.
+func (s *server) relearnTasteLater(ctx context.Context, userID string) {
+	if userID == guestUser.ID {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	s.wg.Go(func() {
+		ctx, cancel := context.WithTimeout(ctx, relearnTasteTimeout)
+		defer cancel()
+		currentUser, err := s.storage.GetByID(userID)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to load user to relearn taste", "user_id", userID, "error", err)
+			return
+		}
+		s.relearnTaste(ctx, currentUser)
+	})
+}
+
+func (s *server) learnTaste(ctx context.Context, currentUser *utypes.User) (utypes.TasteProfile, error) {
+	weights := map[string]int{}
+	// saved recipes are kept newest first.
+	saved := currentUser.LastRecipes[:min(len(currentUser.LastRecipes), tasteSavedHistory)]
+	for _, r := range saved {
+		weights[r.Hash] += savedWeight
+	}
+	dismissed, err := s.recentlyDismissed(ctx, currentUser.ID)
+	if err != nil {
+		return utypes.TasteProfile{}, err
+	}
+	for _, hash := range dismissed {
+		if _, ok := weights[hash]; !ok {
+			weights[hash] = dismissedWeight
+		}
+	}
+	for hash, fb := range s.FeedbackByHash(ctx, lo.Keys(weights)) {
+		switch {
+		case fb.Stars >= likedStars:
+			weights[hash] += likedWeight
+		case fb.Stars > 0 && fb.Stars <= dislikedStars:
+			weights[hash] += dislikedWeight
+		}
+	}
+
+	signals, err := parallelism.MapWithErrors(lo.Keys(weights), func(hash string) (tasteSignal, error) {
+		recipe, err := s.SingleFromCache(ctx, hash)
+		if err != nil {
+			return tasteSignal{}, fmt.Errorf("load recipe %s: %w", hash, err)
+		}
+		return tasteSignal{recipe: *recipe, weight: weights[hash]}, nil
+	})
+	if err != nil {
+		// a recipe that's gone just doesn't count.
+		slog.WarnContext(ctx, "skipping recipes that failed to load for taste", "user_id", currentUser.ID, "error", err)
+	}
+	return buildTasteProfile(signals), nil
+}
+
+// recentlyDismissed is the recipes the user passed on in their newest
+// shopping lists.
+func (s *server) recentlyDismissed(ctx context.Context, userID string) ([]string, error) {
+	originHashes, err := s.recentSelections(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	selections, err := parallelism.MapWithErrors(originHashes, func(originHash string) (recipeSelection, error) {
+		return s.loadRecipeSelection(ctx, userID, originHash)
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "skipping recipe selections that failed to load", "user_id", userID, "error", err)
+	}
+	return lo.Uniq(lo.FlatMap(selections, func(sel recipeSelection, _ int) []string { return sel.DismissedHashes })), nil
+}
+
+// buildTasteProfile adds up what each recipe says about the user, weighted by
+// how much they liked it.
+func buildTasteProfile(signals []tasteSignal) utypes.TasteProfile {
+	cuisines, proteins, techniques := map[string]int{}, map[string]int{}, map[string]int{}
+	var cookMinutes []int
+	var servingCosts []float64
+	for _, sig := range signals {
+		if cuisine := strings.ToLower(strings.TrimSpace(sig.recipe.Cuisine)); cuisine != "" {
+			cuisines[cuisine] += sig.weight
+		}
+		if protein := anchorProtein(sig.recipe); protein != "" {
+			proteins[protein] += sig.weight
+		}
+		for _, technique := range recipeTechniques(sig.recipe) {
+			techniques[technique] += sig.weight
+		}
+		if sig.weight <= 0 {
+			continue
+		}
+		if minutes := int(export.CookDuration(sig.recipe.CookTime) / time.Minute); minutes > 0 {
+			cookMinutes = append(cookMinutes, minutes)
+		}
+		if sig.recipe.StoreCost > 0 {
+			servingCosts = append(servingCosts, sig.recipe.StoreCost/float64(recipeServings(sig.recipe)))
+		}
+	}
+
+	var taste utypes.TasteProfile
+	taste.Cuisines = strongest(cuisines, 1)
+	taste.Proteins = strongest(proteins, 1)
+	taste.Techniques = strongest(techniques, 1)
+	all := map[string]int{}
+	for _, m := range []map[string]int{cuisines, proteins, techniques} {
+		for k, v := range m {
+			all[k] += v
+		}
+	}
+	taste.Avoid = strongest(all, -1)
+	// the usual upper end of what they like, so one long project doesn't
+	// make every night a project.
+	if len(cookMinutes) >= tasteMinLiked {
+		taste.MaxCookMinutes = int(math.Ceil(float64(upperQuartile(cookMinutes))/5) * 5)
+	}
+	if len(servingCosts) >= tasteMinLiked {
+		taste.MaxServingCost = math.Ceil(upperQuartile(servingCosts))
+	}
+	return taste.Normalize()
+}
+
+// strongest is the top few keys weighted at least tasteThreshold in sign's
+// direction, strongest first.
+func strongest(weights map[string]int, sign int) []string {
+	keys := lo.Filter(lo.Keys(weights), func(k string, _ int) bool { return weights[k]*sign >= tasteThreshold })
+	slices.SortFunc(keys, func(a, b string) int {
+		return cmp.Or(cmp.Compare(weights[b]*sign, weights[a]*sign), strings.Compare(a, b))
+	})
+	return keys[:min(len(keys), tasteTopN)]
+}
+
+func upperQuartile[T int | float64](values []T) T {
+	slices.Sort(values)
+	return values[(len(values)*3)/4]
+}
+
+// anchorProtein is the first protein in the ingredient list, which is usually
+// the one the dish is built around.
+func anchorProtein(r ai.Recipe) string {
+	for _, ing := range r.Ingredients {
+		for _, word := range tasteWords(ing.Name) {
+			if slices.Contains(tasteProteins, word) {
+				return word
+			}
+		}
+	}
+	return ""
+}
+
+func recipeTechniques(r ai.Recipe) []string {
+	words := tasteWords(r.Title + " " + strings.Join(r.Instructions, " "))
+	var found []string
+	for _, t := range tasteTechniques {
+		if lo.SomeBy(words, func(w string) bool {
+			return lo.SomeBy(t.stems, func(stem string) bool { return strings.HasPrefix(w, stem) })
+		}) {
+			found = append(found, t.name)
+		}
+	}
+	return found
+}
+
+// tasteWords lowercases s into words, hyphens kept, with a plural s dropped.
+func tasteWords(s string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '-'
+	})
+	return lo.Map(fields, func(w string, _ int) string {
+		if len(w) > 3 && strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss") {
+			return w[:len(w)-1]
+		}
+		return w
+	})
+}
+
+func tasteSignature(t utypes.TasteProfile) string {
+	t = t.Normalize()
+	return "taste" + strings.Join(t.Cuisines, ",") +
+		"|" + strings.Join(t.Proteins, ",") +
+		"|" + strings.Join(t.Techniques, ",") +
+		"|" + strings.Join(t.Avoid, ",") +
+		"|" + strconv.Itoa(t.MaxCookMinutes) +
+		"|" + strconv.FormatFloat(t.MaxServingCost, 'f', 2, 64)
+}
+
+// tasteInstructions summarizes the profile for the menu planner. It's a
+// leaning, not a rule, so menus still have variety.
+func tasteInstructions(t utypes.TasteProfile) []string {
+	t = t.Normalize()
+	var likes []string
+	if len(t.Cuisines) > 0 {
+		likes = append(likes, joinAnd(t.Cuisines)+" cooking")
+	}
+	if len(t.Proteins) > 0 {
+		likes = append(likes, joinAnd(t.Proteins))
+	}
+	if len(t.Techniques) > 0 {
+		likes = append(likes, joinAnd(t.Techniques))
+	}
+	var instructions []string
+	if len(likes) > 0 {
+		instructions = append(instructions, "From what they've saved and rated, the user tends to enjoy "+strings.Join(likes, "; ")+". Lean toward these without making every recipe the same.")
+	}
+	if len(t.Avoid) > 0 {
+		instructions = append(instructions, "They've mostly passed on "+joinAnd(t.Avoid)+"; use these sparingly.")
+	}
+	if t.MaxCookMinutes > 0 {
+		instructions = append(instructions, fmt.Sprintf("Their favorites usually take %d minutes or less.", t.MaxCookMinutes))
+	}
+	if t.MaxServingCost > 0 {
+		instructions = append(instructions, fmt.Sprintf("Their favorites usually cost $%.2f a serving or less.", t.MaxServingCost))
+	}
+	return instructions
+}
+
+func joinAnd(terms []string) string {
+	switch len(terms) {
+	case 0:
+		return ""
+	case 1:
+		return terms[0]
+	default:
+		return strings.Join(terms[:len(terms)-1], ", ") + " and " + terms[len(terms)-1]
+	}
+}
@@ -0,0 +1,168 @@
+package recipes
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"careme/internal/ai"
+	"careme/internal/cache"
+	"careme/internal/locations"
+	"careme/internal/recipes/feedback"
+	"careme/internal/users"
+	utypes "careme/internal/users/types"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tasteRecipe(title, cuisine, cookTime string, storeCost float64, ingredients ...string) ai.Recipe {
+	return ai.Recipe{
+		Title:       title,
+		Cuisine:     cuisine,
+		CookTime:    cookTime,
+		Servings:    2,
+		StoreCost:   storeCost,
+		Ingredients: lo.Map(ingredients, func(name string, _ int) ai.Ingredient { return ai.Ingredient{Name: name} }),
+	}
+}
+
+func TestBuildTasteProfile(t *testing.T) {
+	taste := buildTasteProfile([]tasteSignal{
+		{recipe: tasteRecipe("Roasted Salmon", "Thai", "30 minutes", 12, "Salmon fillets", "Lime"), weight: savedWeight + likedWeight},
+		{recipe: tasteRecipe("Grilled Salmon Skewers", "Thai", "25 minutes", 10, "Salmon", "Chicken stock"), weight: savedWeight},
+		{recipe: tasteRecipe("Roast Chicken", "French", "1 hour 20 minutes", 16, "Whole chicken"), weight: savedWeight},
+		{recipe: tasteRecipe("Braised Pork Shoulder", "Mexican", "3 hours", 20, "Pork shoulder"), weight: savedWeight + dislikedWeight},
+		{recipe: tasteRecipe("Pork Carnitas", "Mexican", "2 hours", 18, "Pork"), weight: dismissedWeight},
+	})
+	assert.Equal(t, utypes.TasteProfile{
+		Cuisines:       []string{"thai"},
+		Proteins:       []string{"salmon"},
+		Techniques:     []string{"roasting"},
+		Avoid:          []string{"mexican", "pork", "braising"},
+		MaxCookMinutes: 80,
+		MaxServingCost: 8,
+	}, taste, "the salmon's chicken stock isn't its anchor; cook time and cost come from what was liked")
+
+	assert.Equal(t, utypes.TasteProfile{}, buildTasteProfile([]tasteSignal{
+		{recipe: tasteRecipe("Roasted Salmon", "Thai", "30 minutes", 12, "Salmon"), weight: savedWeight},
+	}), "one save isn't a taste yet")
+}
+
+func TestRelearnTaste(t *testing.T) {
+	cacheStore := cache.NewFileCache(filepath.Join(t.TempDir(), "cache"))
+	storage := users.NewStorage(cacheStore)
+	s := newTestServer(t, withTestCache(cacheStore), withTestStorage(storage))
+
+	salmon := tasteRecipe("Roasted Salmon", "Thai", "30 minutes", 12, "Salmon")
+	curry := tasteRecipe("Salmon Curry", "Thai", "40 minutes", 10, "Salmon")
+	carnitas := tasteRecipe("Pork Carnitas", "Mexican", "2 hours", 18, "Pork")
+	tinga := tasteRecipe("Pork Tinga", "Mexican", "1 hour", 14, "Pork")
+	for _, r := range []ai.Recipe{salmon, curry, carnitas, tinga} {
+		require.NoError(t, s.SaveRecipe(t.Context(), r))
+	}
+	require.NoError(t, s.SaveFeedback(t.Context(), salmon.ComputeHash(), feedback.Feedback{Cooked: true, Stars: 5}))
+	for i, r := range []ai.Recipe{carnitas, tinga} {
+		originHash := "list" + string(rune('a'+i))
+		require.NoError(t, s.updateRecipeSelection(t.Context(), "mock-clerk-user-id", originHash, func(sel *recipeSelection) {
+			sel.markDismissed(r.ComputeHash())
+		}))
+	}
+	currentUser := &utypes.User{
+		ID:          "mock-clerk-user-id",
+		Email:       []string{"you@careme.cooking"},
+		ShoppingDay: time.Saturday.String(),
+		LastRecipes: lo.Map([]ai.Recipe{salmon, curry}, func(r ai.Recipe, _ int) utypes.Recipe {
+			return utypes.Recipe{Title: r.Title, Hash: r.ComputeHash(), CreatedAt: time.Now()}
+		}),
+	}
+	require.NoError(t, storage.Update(currentUser))
+
+	want := utypes.TasteProfile{Cuisines: []string{"thai"}, Proteins: []string{"salmon"}, Techniques: []string{"roasting"}, Avoid: []string{"mexican", "pork"}}
+	s.relearnTaste(t.Context(), currentUser)
+	stored, err := storage.GetByID(currentUser.ID)
+	require.NoError(t, err)
+	assert.Equal(t, want, stored.Taste, "learned taste is saved for /user")
+
+	edited := utypes.TasteProfile{Cuisines: []string{"korean"}, Edited: true}
+	require.NoError(t, storage.Modify(t.Context(), currentUser, func(u *utypes.User) error {
+		u.Taste = edited
+		return nil
+	}))
+	s.relearnTaste(t.Context(), currentUser)
+	stored, err = storage.GetByID(currentUser.ID)
+	require.NoError(t, err)
+	assert.Equal(t, edited, stored.Taste, "the user's own edits aren't relearned")
+
+	require.NoError(t, storage.Modify(t.Context(), currentUser, func(u *utypes.User) error {
+		u.Taste = utypes.TasteProfile{}
+		return nil
+	}))
+	require.NoError(t, s.dismissRecipeForUser(t.Context(), currentUser, "listc", curry.ComputeHash()))
+	s.wg.Wait()
+	stored, err = storage.GetByID(currentUser.ID)
+	require.NoError(t, err)
+	assert.Equal(t, want, stored.Taste, "dismissing relearns in the background")
+
+	// generation plans with the stored taste, so the list it saves is the one
+	// the redirect pointed at.
+	stale := utypes.TasteProfile{Cuisines: []string{"korean"}}
+	require.NoError(t, storage.Modify(t.Context(), currentUser, func(u *utypes.User) error {
+		u.Taste = stale
+		return nil
+	}))
+	p := DefaultParams(&locations.Location{ID: "70004001", Name: "Store"}, time.Now())
+	redirected := *p
+	redirected.ApplyUser(currentUser, s.userPantry(t.Context(), currentUser))
+	require.NoError(t, s.startGeneration(t.Context(), currentUser, p))
+	s.wg.Wait()
+	assert.Equal(t, stale, p.Taste)
+	assert.Equal(t, redirected.Hash(), p.Hash())
+}
+
+func TestRecentSelections(t *testing.T) {
+	s := newTestServer(t)
+	for i := range tasteSelectionHistory + 5 {
+		require.NoError(t, s.updateRecipeSelection(t.Context(), "u-1", "list"+strconv.Itoa(i), func(sel *recipeSelection) {
+			sel.markDismissed("recipe" + strconv.Itoa(i))
+		}))
+	}
+	require.NoError(t, s.updateRecipeSelection(t.Context(), "u-1", "list10", func(sel *recipeSelection) {
+		sel.markSaved("recipe10")
+	}))
+
+	recent, err := s.recentSelections(t.Context(), "u-1")
+	require.NoError(t, err)
+	require.Len(t, recent, tasteSelectionHistory)
+	assert.Equal(t, []string{"list10", "list24", "list23"}, recent[:3], "newest first")
+	assert.NotContains(t, recent, "list4", "the oldest fall off")
+
+	dismissed, err := s.recentlyDismissed(t.Context(), "u-1")
+	require.NoError(t, err)
+	assert.Len(t, dismissed, tasteSelectionHistory-1, "list10's recipe was saved after all")
+	assert.Contains(t, dismissed, "recipe24")
+}
+
+func TestGenerateRecipes_SummarizesTaste(t *testing.T) {
+	aiStub := &captureGenerateAIClient{}
+	params := DefaultParams(&locations.Location{ID: "70004001", Name: "Store"}, time.Now())
+	hash := params.Hash()
+	params.Taste = utypes.TasteProfile{Edited: true}
+	assert.Equal(t, hash, params.Hash(), "an empty profile doesn't change the list")
+	params.Taste = utypes.TasteProfile{Cuisines: []string{"thai", "korean"}, Proteins: []string{"salmon"}, Techniques: []string{"roasting"}, Avoid: []string{"pork"}, MaxCookMinutes: 40, MaxServingCost: 6}
+	assert.Equal(t, hash, params.Hash(), "a learned profile is relearned too often to key the list on")
+	params.Taste.Edited = true
+	assert.NotEqual(t, hash, params.Hash(), "the user's own edits make a new list")
+	params.Taste.Edited = false
+
+	g := newTestGenerator(t, aiStub, nil, fixedStaplesService{}, &statusCounter{}, nil)
+	_, err := g.GenerateRecipes(t.Context(), params)
+	require.NoError(t, err)
+	require.Len(t, aiStub.instructions, 1)
+	assert.Contains(t, aiStub.instructions[0], "From what they've saved and rated, the user tends to enjoy thai and korean cooking; salmon; roasting. Lean toward these without making every recipe the same.")
+	assert.Contains(t, aiStub.instructions[0], "They've mostly passed on pork; use these sparingly.")
+	assert.Contains(t, aiStub.instructions[0], "Their favorites usually take 40 minutes or less.")
+	assert.Contains(t, aiStub.instructions[0], "Their favorites usually cost $6.00 a serving or less.")
+}
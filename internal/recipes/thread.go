@@ -38,6 +38,20 @@ func (rio recipeio) ThreadFromCache(ctx context.Context, hash string) ([]RecipeT
 	return entries, nil
 }
 
+// AppendThread adds entry to the end of the thread and returns the whole thread,
+// keeping any question answered concurrently.
+func (rio recipeio) AppendThread(ctx context.Context, hash string, entry RecipeThreadEntry) ([]RecipeThreadEntry, error) {
+	thread, err := cache.UpdateJSON(ctx, rio.Cache, recipeThreadPrefix+hash, func(entries *[]RecipeThreadEntry, _ bool) error {
+		*entries = append(*entries, entry)
+		return nil
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to cache recipe thread", "hash", hash, "error", err)
+		return nil, err
+	}
+	return thread, nil
+}
+
 func (rio recipeio) SaveThread(ctx context.Context, hash string, entries []RecipeThreadEntry) error {
 	threadJSON := lo.Must(json.Marshal(entries))
 	if err := rio.Cache.Put(ctx, recipeThreadPrefix+hash, string(threadJSON), cache.Unconditional()); err != nil {
@@ -66,3 +66,10 @@ func (c *tracingAIClient) PickWine(ctx context.Context, recipe ai.Recipe, wines
 
 	return c.next.PickWine(ctx, recipe, wines)
 }
+
+func (c *tracingAIClient) StructureRecipe(ctx context.Context, text string) (*ai.Recipe, error) {
+	ctx, span := tracer.Start(ctx, "recipes.ai.structure_recipe")
+	defer span.End()
+
+	return c.next.StructureRecipe(ctx, text)
+}
@@ -0,0 +1,213 @@
+package recipes
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"careme/internal/ai"
+	"careme/internal/httpx"
+	"careme/internal/locations"
+	"careme/internal/seasons"
+	"careme/internal/templates"
+	utypes "careme/internal/users/types"
+)
+
+// WeekPlan pins each recipe of a week-plan shopping list to the day it gets cooked.
+// Stored under its own prefix keyed by the shopping list hash.
+type WeekPlan struct {
+	Days []WeekPlanDay `json:"days"`
+}
+
+// WeekPlanDay is one dinner. Extra recipes the planner returned beyond the
+// requested days have a zero Date.
+type WeekPlanDay struct {
+	Date       time.Time `json:"date"`
+	RecipeHash string    `json:"recipe_hash,omitempty"`
+	Title      string    `json:"title,omitempty"`
+	Leftovers  string    `json:"leftovers,omitempty"`
+}
+
+func (p *generatorParams) isWeekPlan() bool {
+	return len(p.Days) > 0
+}
+
+// SetWeekPlanDays validates the requested weekdays and stores them in the order
+// they fall in the week starting on the params date. No days means the usual
+// three-recipe list.
+func (p *generatorParams) SetWeekPlanDays(values []string) error {
+	var weekdays []time.Weekday
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		wd, err := utypes.ParseWeekday(v)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(weekdays, wd) {
+			weekdays = append(weekdays, wd)
+		}
+	}
+	slices.SortFunc(weekdays, func(a, b time.Weekday) int {
+		return daysFrom(p.Date, a) - daysFrom(p.Date, b)
+	})
+	p.Days = nil
+	for _, wd := range weekdays {
+		p.Days = append(p.Days, wd.String())
+	}
+	return nil
+}
+
+func daysFrom(start time.Time, wd time.Weekday) int {
+	return (int(wd) - int(start.Weekday()) + 7) % 7
+}
+
+func (p *generatorParams) weekPlanDates() []time.Time {
+	dates := make([]time.Time, 0, len(p.Days))
+	for _, day := range p.Days {
+		wd, err := utypes.ParseWeekday(day)
+		if err != nil {
+			continue
+		}
+		dates = append(dates, p.Date.AddDate(0, 0, daysFrom(p.Date, wd)))
+	}
+	return dates
+}
+
+func weekPlanInstructions(p *generatorParams) []string {
+	dates := p.weekPlanDates()
+	if len(dates) == 0 {
+		return nil
+	}
+	labels := make([]string, 0, len(dates))
+	for _, date := range dates {
+		labels = append(labels, date.Format("Monday January 2"))
+	}
+	return []string{
+		fmt.Sprintf("This is a week plan. Plan exactly one dinner for each of these days: %s. Set day on each plan to just the weekday name, e.g. %q.", strings.Join(labels, ", "), dates[0].Weekday().String()),
+		"Plan the week as a whole so one shopping trip covers it. Reuse leftovers and share ingredients across days, e.g. roast a whole chicken Monday and use the rest in tacos Wednesday. Describe each carry-over in leftovers on both days it touches.",
+	}
+}
+
+func (w *WeekPlan) day(recipeHash string) (WeekPlanDay, bool) {
+	if w == nil {
+		return WeekPlanDay{}, false
+	}
+	for _, d := range w.Days {
+		if d.RecipeHash == recipeHash && !d.Date.IsZero() {
+			return d, true
+		}
+	}
+	return WeekPlanDay{}, false
+}
+
+// NewWeekPlan assigns the list's recipes to the requested days. A recipe keeps
+// its day from previous if it had one, otherwise it takes the day its menu plan
+// asked for. Anything left fills the open days in order.
+func NewWeekPlan(p *generatorParams, list *ai.ShoppingList, previous *WeekPlan) *WeekPlan {
+	dates := p.weekPlanDates()
+	plan := &WeekPlan{Days: make([]WeekPlanDay, len(dates))}
+	slot := make(map[time.Weekday]int, len(dates))
+	for i, date := range dates {
+		plan.Days[i].Date = date
+		slot[date.Weekday()] = i
+	}
+
+	var unplaced []WeekPlanDay
+	for i, recipe := range list.Recipes {
+		day := WeekPlanDay{RecipeHash: recipe.ComputeHash(), Title: recipe.Title}
+		var weekday string
+		if prev, ok := previous.day(day.RecipeHash); ok {
+			weekday, day.Leftovers = prev.Date.Weekday().String(), prev.Leftovers
+		} else if list.Plan != nil && i < len(list.Plan.Plans) {
+			// recipes come back in plan order, saved ones trail after.
+			weekday, day.Leftovers = list.Plan.Plans[i].Day, strings.TrimSpace(list.Plan.Plans[i].Leftovers)
+		}
+		if wd, err := utypes.ParseWeekday(strings.TrimSpace(weekday)); err == nil {
+			if j, ok := slot[wd]; ok && plan.Days[j].RecipeHash == "" {
+				day.Date = plan.Days[j].Date
+				plan.Days[j] = day
+				continue
+			}
+		}
+		unplaced = append(unplaced, day)
+	}
+	for i := range plan.Days {
+		if len(unplaced) == 0 {
+			break
+		}
+		if plan.Days[i].RecipeHash != "" {
+			continue
+		}
+		day := unplaced[0]
+		unplaced = unplaced[1:]
+		day.Date = plan.Days[i].Date
+		plan.Days[i] = day
+	}
+	plan.Days = append(plan.Days, unplaced...)
+	return plan
+}
+
+func weekPlanURL(hash string) string {
+	return "/recipes/" + hash + "/week"
+}
+
+type weekPlanDayView struct {
+	WeekPlanDay
+	Label       string
+	Description string
+}
+
+// FormatWeekPlanHTML renders the week plan with one consolidated shopping list for every dinner.
+func FormatWeekPlanHTML(ctx context.Context, p *generatorParams, plan WeekPlan, l ai.ShoppingList, hash string, servings int, writer http.ResponseWriter) {
+	byHash := make(map[string]ai.Recipe, len(l.Recipes))
+	for _, recipe := range l.Recipes {
+		byHash[recipe.ComputeHash()] = recipe
+	}
+	days := make([]weekPlanDayView, 0, len(plan.Days))
+	var combinedIngredients []ai.Ingredient
+	for _, day := range plan.Days {
+		view := weekPlanDayView{WeekPlanDay: day, Label: "Extra"}
+		if !day.Date.IsZero() {
+			view.Label = day.Date.Format("Monday, January 2")
+		}
+		if recipe, ok := byHash[day.RecipeHash]; ok {
+			view.Description = recipe.Description
+			combinedIngredients = append(combinedIngredients, scaleRecipe(recipe, servings).Ingredients...)
+		}
+		days = append(days, view)
+	}
+
+	data := struct {
+		Location        locations.Location
+		DateDisplay     string
+		ClarityScript   template.HTML
+		GoogleTagScript template.HTML
+		Hash            string
+		Days            []weekPlanDayView
+		ShoppingList    []shoppingListGroup
+		Style           seasons.Style
+		Servings        int
+	}{
+		Location:        *p.Location,
+		DateDisplay:     p.Date.Format("January 2, 2006"),
+		ClarityScript:   templates.ClarityScript(ctx),
+		GoogleTagScript: templates.GoogleTagScript(),
+		Hash:            hash,
+		Days:            days,
+		ShoppingList:    markStoreHeadings(shoppingListForStores(p, combinedIngredients)),
+		Style:           seasons.GetCurrentStyle(),
+		Servings:        servings,
+	}
+
+	httpx.SetHTMLContentType(writer)
+	if err := templates.WeekPlan.Execute(writer, data); err != nil {
+		http.Error(writer, "week plan template error: "+err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,158 @@
+package recipes
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"careme/internal/ai"
+	"careme/internal/cache"
+	"careme/internal/locations"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// a Saturday
+var weekPlanStart = time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+
+func TestSetWeekPlanDays_OrdersFromParamsDate(t *testing.T) {
+	p := DefaultParams(&locations.Location{ID: "70004001"}, weekPlanStart)
+
+	require.NoError(t, p.SetWeekPlanDays([]string{"wednesday", "Saturday", " ", "Monday", "Wednesday"}))
+	assert.Equal(t, []string{"Saturday", "Monday", "Wednesday"}, p.Days)
+	assert.Equal(t, []time.Time{
+		weekPlanStart,
+		weekPlanStart.AddDate(0, 0, 2),
+		weekPlanStart.AddDate(0, 0, 4),
+	}, p.weekPlanDates())
+
+	require.Error(t, p.SetWeekPlanDays([]string{"Funday"}))
+	require.NoError(t, p.SetWeekPlanDays(nil))
+	assert.False(t, p.isWeekPlan())
+}
+
+func TestHash_WeekPlanDaysOnlyHashedWhenSet(t *testing.T) {
+	p := DefaultParams(&locations.Location{ID: "70004001"}, weekPlanStart)
+	before := p.Hash()
+	require.NoError(t, p.SetWeekPlanDays(nil))
+	assert.Equal(t, before, p.Hash())
+
+	require.NoError(t, p.SetWeekPlanDays([]string{"Monday"}))
+	monday := p.Hash()
+	assert.NotEqual(t, before, monday)
+	require.NoError(t, p.SetWeekPlanDays([]string{"Monday", "Wednesday"}))
+	assert.NotEqual(t, monday, p.Hash())
+}
+
+func TestNewWeekPlan_UsesPlanDaysThenFillsOpenDays(t *testing.T) {
+	p := DefaultParams(&locations.Location{ID: "70004001"}, weekPlanStart)
+	require.NoError(t, p.SetWeekPlanDays([]string{"Monday", "Wednesday", "Friday"}))
+
+	roast := ai.Recipe{Title: "Roast Chicken"}
+	tacos := ai.Recipe{Title: "Chicken Tacos"}
+	soup := ai.Recipe{Title: "Squash Soup"}
+	extra := ai.Recipe{Title: "Extra Pasta"}
+	list := &ai.ShoppingList{
+		Recipes: []ai.Recipe{tacos, roast, soup, extra},
+		Plan: &ai.MenuPlan{Plans: []ai.RecipePlan{
+			{Day: "Wednesday", Leftovers: "uses Monday's chicken"},
+			{Day: "monday", Leftovers: "roast two chickens"},
+			{Day: "Sometime"},
+		}},
+	}
+
+	plan := NewWeekPlan(p, list, nil)
+	require.Len(t, plan.Days, 4)
+	assert.Equal(t, WeekPlanDay{Date: weekPlanStart.AddDate(0, 0, 2), RecipeHash: roast.ComputeHash(), Title: roast.Title, Leftovers: "roast two chickens"}, plan.Days[0])
+	assert.Equal(t, WeekPlanDay{Date: weekPlanStart.AddDate(0, 0, 4), RecipeHash: tacos.ComputeHash(), Title: tacos.Title, Leftovers: "uses Monday's chicken"}, plan.Days[1])
+	assert.Equal(t, soup.ComputeHash(), plan.Days[2].RecipeHash)
+	assert.Equal(t, weekPlanStart.AddDate(0, 0, 6), plan.Days[2].Date)
+	assert.True(t, plan.Days[3].Date.IsZero(), "recipes beyond the requested days are extras")
+	assert.Equal(t, extra.ComputeHash(), plan.Days[3].RecipeHash)
+}
+
+func TestNewWeekPlan_KeepsPreviousDays(t *testing.T) {
+	p := DefaultParams(&locations.Location{ID: "70004001"}, weekPlanStart)
+	require.NoError(t, p.SetWeekPlanDays([]string{"Monday", "Wednesday"}))
+	roast := ai.Recipe{Title: "Roast Chicken"}
+	previous := &WeekPlan{Days: []WeekPlanDay{
+		{Date: weekPlanStart.AddDate(0, 0, 4), RecipeHash: roast.ComputeHash(), Leftovers: "makes extra"},
+	}}
+
+	plan := NewWeekPlan(p, &ai.ShoppingList{Recipes: []ai.Recipe{roast}}, previous)
+	require.Len(t, plan.Days, 2)
+	assert.Empty(t, plan.Days[0].RecipeHash)
+	assert.Equal(t, roast.ComputeHash(), plan.Days[1].RecipeHash)
+	assert.Equal(t, "makes extra", plan.Days[1].Leftovers)
+}
+
+func TestGenerateRecipes_WeekPlanAsksForOneDinnerPerDay(t *testing.T) {
+	params := DefaultParams(&locations.Location{ID: "70004001", Name: "Store"}, weekPlanStart)
+	require.NoError(t, params.SetWeekPlanDays([]string{"Monday", "Tuesday", "Thursday", "Friday"}))
+	aiStub := &sequenceAIClient{}
+	g := newTestGenerator(t, aiStub, &captureCritiqueService{}, seededStaples(t, params), noopstatuswriter{}, nil)
+
+	_, err := g.GenerateRecipes(t.Context(), params)
+	require.NoError(t, err)
+	require.Equal(t, []int{4}, aiStub.menuPlanCounts)
+	instructions := strings.Join(aiStub.menuPlanInstructions[0], "\n")
+	assert.Contains(t, instructions, "Monday October 19, Tuesday October 20, Thursday October 22, Friday October 23")
+	assert.Contains(t, instructions, "Reuse leftovers and share ingredients across days")
+}
+
+func TestHandleWeekPlan(t *testing.T) {
+	cacheStore := cache.NewFileCache(filepath.Join(t.TempDir(), "cache"))
+	s := newTestServer(t, withTestCache(cacheStore))
+	p := DefaultParams(&locations.Location{ID: "70004001", Name: "Store", Address: "1 Main St"}, weekPlanStart)
+	require.NoError(t, p.SetWeekPlanDays([]string{"Monday", "Wednesday"}))
+	hash := p.Hash()
+	require.NoError(t, s.SaveParams(t.Context(), p))
+
+	get := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/recipes/"+hash+"/week", nil)
+		req.SetPathValue("hash", hash)
+		rr := httptest.NewRecorder()
+		s.handleWeekPlan(rr, req)
+		return rr
+	}
+
+	rr := get()
+	require.Equal(t, http.StatusSeeOther, rr.Code)
+	assert.Contains(t, rr.Header().Get("Location"), "/recipes?h="+hash)
+
+	roast := ai.Recipe{Title: "Roast Chicken", Ingredients: []ai.Ingredient{{Name: "Whole chicken", Quantity: "2"}}}
+	tacos := ai.Recipe{Title: "Chicken Tacos", Ingredients: []ai.Ingredient{{Name: "Tortillas", Quantity: "8"}}}
+	list := &ai.ShoppingList{
+		Recipes: []ai.Recipe{roast, tacos},
+		Plan:    &ai.MenuPlan{Plans: []ai.RecipePlan{{Day: "Monday", Leftovers: "roast two, save one for tacos"}, {Day: "Wednesday"}}},
+	}
+	require.NoError(t, s.SaveShoppingList(t.Context(), list, hash))
+	s.saveWeekPlan(t.Context(), p, list, hash, nil)
+
+	rr = get()
+	html := assertHTTPSuccess(t, rr)
+	isValidHTML(t, html)
+	assert.Contains(t, html, "Monday, October 19")
+	assert.Contains(t, html, "Wednesday, October 21")
+	assert.Contains(t, html, "/recipe/"+roast.ComputeHash())
+	assert.Contains(t, html, "roast two, save one for tacos")
+	assert.Contains(t, html, "Whole chicken")
+	assert.Contains(t, html, "Tortillas")
+}
+
+func TestFormatMail_LinksWeekPlan(t *testing.T) {
+	p := DefaultParams(&locations.Location{ID: "70004001", Name: "Store"}, weekPlanStart)
+	var buf bytes.Buffer
+	require.NoError(t, FormatMail(p, ai.ShoppingList{}, "https://careme.test", "", &buf))
+	assert.NotContains(t, buf.String(), "/week")
+
+	require.NoError(t, p.SetWeekPlanDays([]string{"Monday"}))
+	buf.Reset()
+	require.NoError(t, FormatMail(p, ai.ShoppingList{}, "https://careme.test", "", &buf))
+	assert.Contains(t, buf.String(), "https://careme.test/recipes/"+p.Hash()+"/week")
+}
@@ -0,0 +1,129 @@
+// Shopping list check marks save through htmx as they are tapped. Taps that
+// cannot reach the server, like with no signal in the store, queue here and
+// replay when the phone reconnects. Every sync also picks up what another
+// phone on the same account checked off, so two people can split one list.
+const PENDING_KEY = "careme-checklist-pending";
+const SYNC_INTERVAL_MS = 30000;
+
+// Items whose toggle is on its way to the server. A sync that returns in the
+// meantime must not flip them back to the older state.
+const inFlight = new Set();
+
+function checklistHash() {
+  const root = document.querySelector("[data-checklist]");
+  return root ? root.dataset.checklist : "";
+}
+
+// Pending toggles are kept per list hash, latest tap per item.
+function loadPending() {
+  try {
+    return JSON.parse(localStorage.getItem(PENDING_KEY)) || {};
+  } catch {
+    return {};
+  }
+}
+
+function savePending(pending) {
+  try {
+    localStorage.setItem(PENDING_KEY, JSON.stringify(pending));
+  } catch {
+    // Private browsing can refuse storage; the tap is still shown on screen.
+  }
+}
+
+function toggleFor(form) {
+  const box = form.querySelector("[data-checklist-item]");
+  return { item: box.dataset.checklistItem, checked: box.checked, at: Date.now() };
+}
+
+function queueToggle(hash, toggle) {
+  const pending = loadPending();
+  pending[hash] = pending[hash] || {};
+  pending[hash][toggle.item] = toggle;
+  savePending(pending);
+}
+
+function checklistForm(event) {
+  const elt = event.detail && event.detail.elt;
+  return elt && elt.closest ? elt.closest("[data-checklist-form]") : null;
+}
+
+document.addEventListener("htmx:configRequest", (event) => {
+  const form = checklistForm(event);
+  if (!form) return;
+  const toggle = toggleFor(form);
+  form.checklistToggle = toggle;
+  inFlight.add(toggle.item);
+  // Stamp the tap, not the arrival, so a replayed offline tap loses to a later one.
+  event.detail.parameters.at = String(toggle.at);
+});
+
+document.addEventListener("htmx:afterRequest", (event) => {
+  const form = checklistForm(event);
+  if (!form || !form.checklistToggle) return;
+  inFlight.delete(form.checklistToggle.item);
+  // Queue only taps that never got an answer or hit a server error; a rejected
+  // tap would be rejected again on replay.
+  const status = event.detail.xhr ? event.detail.xhr.status : 0;
+  if (status === 0 || status >= 500) {
+    queueToggle(checklistHash(), form.checklistToggle);
+  }
+});
+
+async function syncChecklist() {
+  const hash = checklistHash();
+  if (!hash) return;
+  const toggles = Object.values(loadPending()[hash] || {});
+
+  let body;
+  try {
+    const response = await fetch("/recipes/" + encodeURIComponent(hash) + "/checklist/sync", {
+      method: "POST",
+      credentials: "same-origin",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ toggles }),
+    });
+    if (response.status === 400) {
+      // Something queued is malformed; drop the batch rather than retry it forever.
+      const pending = loadPending();
+      delete pending[hash];
+      savePending(pending);
+      return;
+    }
+    if (!response.ok) return;
+    body = await response.json();
+  } catch {
+    return; // still offline; try again on the next reconnect
+  }
+
+  // Only clear what was sent; taps made while the sync was out stay queued.
+  const pending = loadPending();
+  const queued = pending[hash] || {};
+  for (const toggle of toggles) {
+    if (queued[toggle.item] && queued[toggle.item].at === toggle.at) {
+      delete queued[toggle.item];
+    }
+  }
+  if (Object.keys(queued).length === 0) {
+    delete pending[hash];
+  }
+  savePending(pending);
+
+  const checked = new Set(body.checked || []);
+  document.querySelectorAll("[data-checklist-item]").forEach((box) => {
+    const item = box.dataset.checklistItem;
+    if (inFlight.has(item) || queued[item]) return;
+    box.checked = checked.has(item);
+  });
+}
+
+if (checklistHash()) {
+  syncChecklist();
+  window.addEventListener("online", syncChecklist);
+  document.addEventListener("visibilitychange", () => {
+    if (document.visibilityState === "visible") syncChecklist();
+  });
+  window.setInterval(() => {
+    if (document.visibilityState === "visible" && navigator.onLine) syncChecklist();
+  }, SYNC_INTERVAL_MS);
+}
@@ -23,6 +23,9 @@ var userClerkBillingJS []byte
 //go:embed share.js
 var shareJS []byte
 
+//go:embed checklist.js
+var checklistJS []byte
+
 //go:embed fonts/*.woff2
 var fontFiles embed.FS
 
@@ -92,6 +95,14 @@ func Register(mux routing.Registrar) {
 		}
 	})
 
+	mux.HandleFunc("/static/checklist.js", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		if _, err := w.Write(checklistJS); err != nil {
+			slog.ErrorContext(r.Context(), "failed to write checklist js", "error", err)
+		}
+	})
+
 	fontServer := http.FileServer(http.FS(fontFiles))
 	mux.Handle("/static/fonts/", http.StripPrefix("/static/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "font/woff2")
@@ -141,6 +141,26 @@ func TestRegisterServesShareJS(t *testing.T) {
 	}
 }
 
+func TestRegisterServesChecklistJS(t *testing.T) {
+	Init()
+	mux := http.NewServeMux()
+	Register(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/static/checklist.js", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("checklist js response status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/javascript; charset=utf-8" {
+		t.Fatalf("checklist js content type = %q, want application/javascript; charset=utf-8", got)
+	}
+	if !strings.Contains(rec.Body.String(), "/checklist/sync") {
+		t.Fatal("checklist js response should include offline sync logic")
+	}
+}
+
 func TestRegisterServesSeasonalBackgroundFromEnv(t *testing.T) {
 	t.Setenv(seasons.EnvSeason, "spring")
 	Init()
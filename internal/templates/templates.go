@@ -13,6 +13,7 @@ import (
 
 	"careme/internal/config"
 	"careme/internal/logsetup"
+	utypes "careme/internal/users/types"
 )
 
 const clerkJSVersion = "5.99.0"
@@ -37,10 +38,12 @@ var Home,
 	ShoppingList,
 	Recipe,
 	Critique,
+	Search,
 	About,
 	Privacy,
 	Location,
 	FarmersMarket,
+	WeekPlan,
 	Mail *template.Template
 
 func Init(config *config.Config, tailwindAssetPath string) error {
@@ -62,6 +65,7 @@ func Init(config *config.Config, tailwindAssetPath string) error {
 		"SignupCompletedConversion": func() ConversionEvent { return SignupCompletedConversion },
 		"TailwindAssetPath":         func() string { return tailwindAssetPath },
 		"UserInitial":               userInitial,
+		"Weekdays":                  utypes.Weekdays,
 	}
 	tmpls, err := template.New("all").Funcs(funcs).ParseFS(htmlFiles, "*.html")
 	if err != nil {
@@ -75,10 +79,12 @@ func Init(config *config.Config, tailwindAssetPath string) error {
 	ShoppingList = ensure(tmpls, "shoppinglist.html")
 	Recipe = ensure(tmpls, "recipe.html")
 	Critique = ensure(tmpls, "critique.html")
+	Search = ensure(tmpls, "search.html")
 	About = ensure(tmpls, "about.html")
 	Privacy = ensure(tmpls, "privacy.html")
 	Location = ensure(tmpls, "locations.html")
 	FarmersMarket = ensure(tmpls, "farmersmarket.html")
+	WeekPlan = ensure(tmpls, "weekplan.html")
 	Mail = ensure(tmpls, "mail.html")
 
 	// todo pull from config.
@@ -89,6 +89,7 @@ func TestFullPageTemplatesIncludeSeasonalBackground(t *testing.T) {
 		"locations.html",
 		"privacy.html",
 		"recipe.html",
+		"search.html",
 		"shoppinglist.html",
 		"spinner.html",
 		"user.html",
@@ -211,6 +212,7 @@ func TestTemplatePageTitlesAreUnique(t *testing.T) {
 		"mail.html",
 		"privacy.html",
 		"recipe.html",
+		"search.html",
 		"shoppinglist.html",
 		"spinner.html",
 		"user.html",
@@ -372,6 +374,7 @@ func TestSpinTemplateIncludesClerkRefreshWhenEnabled(t *testing.T) {
 		RefreshInterval string
 		StatusMessage   string
 		CurrentPath     string
+		EventsPath      string
 	}{
 		Style:           seasons.GetCurrentStyle(),
 		ServerSignedIn:  false,
@@ -489,6 +492,26 @@ func TestUserTemplateLoadsClerkBillingScriptWhenEnabled(t *testing.T) {
 		ActiveTab         string
 		PastRecipes       []utypes.Recipe
 		ServerSignedIn    bool
+		Household         struct {
+			Members   int
+			Allergens []struct {
+				Value   string
+				Checked bool
+			}
+			OtherAllergens string
+			Diets          []struct {
+				Value   string
+				Checked bool
+			}
+			Dislikes string
+		}
+		WeekPlanDays []struct {
+			Value   string
+			Checked bool
+		}
+		Pantry            string
+		CalendarURL       string
+		CalendarWebcalURL template.URL
 	}{
 		Style:          seasons.GetCurrentStyle(),
 		User:           &utypes.User{Email: []string{"chef@example.com"}},
@@ -526,6 +549,7 @@ func TestSpinTemplatePreservesStatusLineBreaks(t *testing.T) {
 		RefreshInterval string
 		StatusMessage   string
 		CurrentPath     string
+		EventsPath      string
 	}{
 		Style:           seasons.GetCurrentStyle(),
 		ServerSignedIn:  false,
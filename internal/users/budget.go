@@ -0,0 +1,32 @@
+package users
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	utypes "careme/internal/users/types"
+)
+
+func parseBudgetForm(r *http.Request) (utypes.Budget, error) {
+	var b utypes.Budget
+	for field, dst := range map[string]*float64{
+		"budget_per_meal": &b.PerMeal,
+		"budget_per_week": &b.PerWeek,
+	} {
+		v := strings.TrimPrefix(strings.TrimSpace(r.FormValue(field)), "$")
+		if v == "" {
+			continue
+		}
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return b, fmt.Errorf("budget must be a dollar amount")
+		}
+		*dst = n
+	}
+	if err := b.Validate(); err != nil {
+		return b, err
+	}
+	return b, nil
+}
@@ -0,0 +1,78 @@
+package users
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"careme/internal/dietary"
+	utypes "careme/internal/users/types"
+)
+
+// formOption is one checkbox on the preferences form.
+type formOption struct {
+	Value   string
+	Checked bool
+}
+
+// householdView splits the stored household back into form fields: known allergens
+// and diets become checkboxes, anything else goes in the free-text boxes.
+type householdView struct {
+	Members        int
+	Allergens      []formOption
+	OtherAllergens string
+	Diets          []formOption
+	Dislikes       string
+}
+
+func newHouseholdView(h utypes.Household) householdView {
+	view := householdView{
+		Members:  h.Members,
+		Dislikes: strings.Join(h.Dislikes, ", "),
+	}
+	for _, allergen := range dietary.Allergens {
+		view.Allergens = append(view.Allergens, formOption{Value: allergen, Checked: slices.Contains(h.Allergens, allergen)})
+	}
+	var other []string
+	for _, allergen := range h.Allergens {
+		if !slices.Contains(dietary.Allergens, allergen) {
+			other = append(other, allergen)
+		}
+	}
+	view.OtherAllergens = strings.Join(other, ", ")
+	for _, diet := range dietary.Diets {
+		view.Diets = append(view.Diets, formOption{Value: diet, Checked: slices.Contains(h.Diets, diet)})
+	}
+	return view
+}
+
+func parseHouseholdForm(r *http.Request) (utypes.Household, error) {
+	var h utypes.Household
+	if members := strings.TrimSpace(r.FormValue("household_members")); members != "" {
+		n, err := strconv.Atoi(members)
+		if err != nil || n < 0 || n > utypes.MaxHouseholdMembers {
+			return h, fmt.Errorf("household size must be between 0 and %d", utypes.MaxHouseholdMembers)
+		}
+		h.Members = n
+	}
+	h.Allergens = append(r.Form["allergen"], splitList(r.FormValue("other_allergens"))...)
+	h.Diets = r.Form["diet"]
+	h.Dislikes = splitList(r.FormValue("dislikes"))
+	return h.Normalize(), nil
+}
+
+func splitList(v string) []string {
+	return strings.FieldsFunc(v, func(r rune) bool {
+		return r == ',' || r == '\n' || r == ';'
+	})
+}
+
+func weekPlanDayOptions(days []string) []formOption {
+	var options []formOption
+	for _, day := range utypes.Weekdays() {
+		options = append(options, formOption{Value: day, Checked: slices.Contains(days, day)})
+	}
+	return options
+}
@@ -0,0 +1,27 @@
+package users
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	utypes "careme/internal/users/types"
+)
+
+func parseIngredientPreferencesForm(r *http.Request) (utypes.IngredientPreferences, error) {
+	var prefs utypes.IngredientPreferences
+	if grade := strings.TrimSpace(r.FormValue("min_grade")); grade != "" {
+		n, err := strconv.Atoi(grade)
+		if err != nil {
+			return prefs, fmt.Errorf("ingredient grade must be between 0 and %d", utypes.MaxIngredientGrade)
+		}
+		prefs.MinGrade = n
+	}
+	prefs.Boosts = splitList(r.FormValue("boosts"))
+	prefs.Bans = splitList(r.FormValue("bans"))
+	if err := prefs.Validate(); err != nil {
+		return prefs, err
+	}
+	return prefs.Normalize(), nil
+}
@@ -0,0 +1,91 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"careme/internal/cache"
+	utypes "careme/internal/users/types"
+)
+
+const pantryPrefix = "pantry/"
+
+// Pantry returns the user's pantry. A user who never saved one has an empty pantry.
+func (s *Storage) Pantry(ctx context.Context, userID string) (utypes.Pantry, error) {
+	blob, err := s.cache.Get(ctx, pantryPrefix+userID)
+	if err != nil {
+		if errors.Is(err, cache.ErrNotFound) {
+			return utypes.Pantry{}, nil
+		}
+		return utypes.Pantry{}, err
+	}
+	defer func() {
+		if err := blob.Close(); err != nil {
+			slog.ErrorContext(ctx, "failed to close pantry reader", "user", userID, "error", err)
+		}
+	}()
+
+	var pantry utypes.Pantry
+	if err := json.NewDecoder(blob).Decode(&pantry); err != nil {
+		return utypes.Pantry{}, fmt.Errorf("failed to unmarshal pantry: %w", err)
+	}
+	return pantry, nil
+}
+
+// SavePantry replaces the user's pantry.
+func (s *Storage) SavePantry(ctx context.Context, userID string, pantry utypes.Pantry) error {
+	return s.UpdatePantry(ctx, userID, func(stored *utypes.Pantry) error {
+		*stored = pantry
+		return nil
+	})
+}
+
+// UpdatePantry changes the user's pantry in place. fn sees the latest stored
+// pantry and is retried if another write lands first, so concurrent edits
+// aren't lost. Returning cache.ErrSkipUpdate leaves it as it is.
+func (s *Storage) UpdatePantry(ctx context.Context, userID string, fn func(pantry *utypes.Pantry) error) error {
+	_, err := cache.UpdateJSON(ctx, s.cache, pantryPrefix+userID, func(pantry *utypes.Pantry, _ bool) error {
+		if err := fn(pantry); err != nil {
+			return err
+		}
+		if err := pantry.Validate(); err != nil {
+			return fmt.Errorf("invalid pantry: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update pantry: %w", err)
+	}
+	return nil
+}
+
+// parsePantryForm reads one item per line as "name" or "name, quantity".
+func parsePantryForm(r *http.Request) (utypes.Pantry, error) {
+	var pantry utypes.Pantry
+	for _, line := range strings.Split(r.FormValue("pantry"), "\n") {
+		name, quantity, _ := strings.Cut(line, ",")
+		pantry.Items = append(pantry.Items, utypes.PantryItem{Name: name, Quantity: quantity})
+	}
+	pantry = pantry.Normalize()
+	if err := pantry.Validate(); err != nil {
+		return pantry, err
+	}
+	return pantry, nil
+}
+
+func pantryText(pantry utypes.Pantry) string {
+	lines := make([]string, 0, len(pantry.Items))
+	for _, item := range pantry.Items {
+		if item.Quantity == "" {
+			lines = append(lines, item.Name)
+			continue
+		}
+		lines = append(lines, item.Name+", "+item.Quantity)
+	}
+	return strings.Join(lines, "\n")
+}
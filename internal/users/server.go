@@ -9,6 +9,7 @@ import (
 	"html/template"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -37,6 +38,7 @@ type server struct {
 	locGetter          locationGetter
 	clerk              auth.AuthClient // make an interface
 	unsubscribeFactory UnsubscribeTokenFactory
+	tokens             *auth.TokenStore
 	publicOrigin       string
 }
 
@@ -53,13 +55,14 @@ const (
 )
 
 // NewHandler returns an http.Handler that serves the user related routes under /user.
-func NewHandler(storage *Storage, locGetter locationGetter, clerkClient auth.AuthClient, unsubscribe UnsubscribeTokenFactory, publicOrigin string) *server {
+func NewHandler(storage *Storage, locGetter locationGetter, clerkClient auth.AuthClient, unsubscribe UnsubscribeTokenFactory, tokens *auth.TokenStore, publicOrigin string) *server {
 	return &server{
 		storage:            storage,
 		userTmpl:           templates.User,
 		locGetter:          locGetter,
 		clerk:              clerkClient,
 		unsubscribeFactory: unsubscribe,
+		tokens:             tokens,
 		publicOrigin:       strings.TrimRight(publicOrigin, "/"),
 	}
 }
@@ -69,6 +72,7 @@ func (s *server) Register(mux routing.Registrar) {
 	mux.HandleFunc("GET /user/recipes/offline-cache", s.handleOfflineRecipeCache)
 	mux.HandleFunc("POST /user/recipes/remove", s.handleRemoveUserRecipe)
 	mux.HandleFunc("POST /user/favorite", s.handleFavorite)
+	mux.HandleFunc("POST /user/calendar", s.handleCalendarLink)
 	mux.HandleFunc("GET /user/unsubscribe", s.handleUnsubscribe)
 	mux.HandleFunc("GET /user/exists", s.handleExists)
 }
@@ -158,7 +162,7 @@ func (s *server) handleRemoveUserRecipe(w http.ResponseWriter, r *http.Request)
 	}
 
 	recipeHash := strings.TrimSpace(r.FormValue("hash"))
-	removed, err := s.storage.RemoveRecipe(currentUser, recipeHash)
+	removed, err := s.storage.RemoveRecipe(ctx, currentUser, recipeHash)
 	if err != nil {
 		slog.ErrorContext(ctx, "failed to update user when removing recipe", "error", err)
 		http.Error(w, "unable to save preferences", http.StatusInternalServerError)
@@ -220,12 +224,80 @@ func (s *server) handleUser(w http.ResponseWriter, r *http.Request) {
 			generationPrompt := strings.TrimSpace(r.FormValue("directive"))
 			currentUser.Directive = generationPrompt
 		}
+		if r.Form.Has("household") {
+			household, err := parseHouseholdForm(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			currentUser.Household = household
+		}
+		if r.Form.Has("week_plan") {
+			days := r.Form["week_plan_day"]
+			for _, day := range days {
+				if _, err := utypes.ParseWeekday(day); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+			currentUser.WeekPlanDays = days
+		}
+		if r.Form.Has("budget") {
+			budget, err := parseBudgetForm(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			currentUser.Budget = budget
+		}
+		if r.Form.Has("ingredient_preferences") {
+			prefs, err := parseIngredientPreferencesForm(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			currentUser.IngredientPreferences = prefs
+		}
+		if r.Form.Has("taste") {
+			taste, err := parseTasteForm(r, currentUser.Taste)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			currentUser.Taste = taste
+		}
+		if r.Form.Has("pantry") {
+			pantry, err := parsePantryForm(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := s.storage.SavePantry(ctx, currentUser.ID, pantry); err != nil {
+				slog.ErrorContext(ctx, "failed to save pantry", "error", err)
+				http.Error(w, "unable to save pantry", http.StatusInternalServerError)
+				return
+			}
+		}
 		currentUser.MailOptIn = r.FormValue("mail_opt_in") == "1"
 		if !favoriteBefore && strings.TrimSpace(currentUser.FavoriteStore) != "" {
 			currentUser.MailOptIn = true
 		}
 
-		if err := s.storage.Update(currentUser); err != nil {
+		// the form only owns the preferences; anything else, like recipes saved
+		// from another tab, keeps whatever is stored now.
+		prefs := *currentUser
+		if err := s.storage.Modify(ctx, currentUser, func(stored *utypes.User) error {
+			stored.FavoriteStore = prefs.FavoriteStore
+			stored.ShoppingDay = prefs.ShoppingDay
+			stored.Directive = prefs.Directive
+			stored.Household = prefs.Household
+			stored.WeekPlanDays = prefs.WeekPlanDays
+			stored.Budget = prefs.Budget
+			stored.IngredientPreferences = prefs.IngredientPreferences
+			stored.Taste = prefs.Taste
+			stored.MailOptIn = prefs.MailOptIn
+			return nil
+		}); err != nil {
 			slog.ErrorContext(ctx, "failed to update user", "error", err)
 			http.Error(w, "unable to save preferences", http.StatusInternalServerError)
 			return
@@ -249,6 +321,11 @@ func (s *server) handleUser(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	pantry, err := s.storage.Pantry(ctx, userForTemplate.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to load pantry", "error", err)
+	}
+
 	data := struct {
 		ClarityScript     template.HTML
 		GoogleTagScript   template.HTML
@@ -259,6 +336,11 @@ func (s *server) handleUser(w http.ResponseWriter, r *http.Request) {
 		PastRecipes       []pastRecipeView
 		Style             seasons.Style
 		ServerSignedIn    bool
+		Household         householdView
+		WeekPlanDays      []formOption
+		Pantry            string
+		CalendarURL       string
+		CalendarWebcalURL template.URL
 	}{
 		ClarityScript:     templates.ClarityScript(ctx),
 		GoogleTagScript:   templates.GoogleTagScript(),
@@ -269,13 +351,30 @@ func (s *server) handleUser(w http.ResponseWriter, r *http.Request) {
 		PastRecipes:       pastRecipeViews(ctx, s.storage.cache, userForTemplate.LastRecipes),
 		Style:             seasons.GetCurrentStyle(),
 		ServerSignedIn:    true,
+		Household:         newHouseholdView(userForTemplate.Household),
+		WeekPlanDays:      weekPlanDayOptions(userForTemplate.WeekPlanDays),
+		Pantry:            pantryText(pantry),
 	}
+	data.CalendarURL, data.CalendarWebcalURL = s.calendarLinks(userForTemplate.CalendarToken)
 	if err := s.userTmpl.Execute(w, data); err != nil {
 		slog.ErrorContext(ctx, "user template execute error", "error", err)
 		http.Error(w, "template error", http.StatusInternalServerError)
 	}
 }
 
+// calendarLinks is the user's calendar feed, as a link to copy and as a
+// webcal:// link that calendar apps open as a subscription. It's empty until
+// the user asks for one.
+func (s *server) calendarLinks(token string) (string, template.URL) {
+	if token == "" || s.publicOrigin == "" {
+		return "", ""
+	}
+	feed := s.publicOrigin + "/user/calendar.ics?" + url.Values{"token": []string{token}}.Encode()
+	_, rest, _ := strings.Cut(feed, "://")
+	// html/template won't let a webcal: href through unless it's marked safe; the link is all ours.
+	return feed, template.URL("webcal://" + rest)
+}
+
 func pastRecipeViews(ctx context.Context, c cache.Cache, recipes []utypes.Recipe) []pastRecipeView {
 	now := time.Now()
 	cookedCutoff := now.Add(-cookedPastRecipesWindow)
@@ -367,12 +466,14 @@ func (s *server) handleFavorite(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "missing favorite_store", http.StatusBadRequest)
 		return
 	}
-	favoriteBefore := strings.TrimSpace(currentUser.FavoriteStore) != ""
-	currentUser.FavoriteStore = favoriteStore
-	if !favoriteBefore && favoriteStore != "" {
-		currentUser.MailOptIn = true
-	}
-	if err := s.storage.Update(currentUser); err != nil {
+	if err := s.storage.Modify(ctx, currentUser, func(stored *utypes.User) error {
+		favoriteBefore := strings.TrimSpace(stored.FavoriteStore) != ""
+		stored.FavoriteStore = favoriteStore
+		if !favoriteBefore && favoriteStore != "" {
+			stored.MailOptIn = true
+		}
+		return nil
+	}); err != nil {
 		slog.ErrorContext(ctx, "failed to update user", "error", err)
 		http.Error(w, "unable to save preferences", http.StatusInternalServerError)
 		return
@@ -381,6 +482,51 @@ func (s *server) handleFavorite(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// handleCalendarLink gives the user a new calendar feed link and revokes the
+// old one, so a link that got out stops working.
+func (s *server) handleCalendarLink(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if !httpx.IsHTMX(r) {
+		http.Error(w, "htmx request required", http.StatusBadRequest)
+		return
+	}
+	currentUser, err := s.storage.FromRequest(ctx, r, s.clerk)
+	if err != nil {
+		if !errors.Is(err, auth.ErrNoSession) {
+			slog.ErrorContext(ctx, "failed to get clerk user ID", "error", err)
+			http.Error(w, "unable to load account", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("HX-Redirect", "/")
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	token, err := s.tokens.IssueScoped(ctx, currentUser.ID, auth.ScopeCalendar)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to issue calendar token", "user_id", currentUser.ID, "error", err)
+		http.Error(w, "unable to create calendar link", http.StatusInternalServerError)
+		return
+	}
+	var previous string
+	if err := s.storage.Modify(ctx, currentUser, func(stored *utypes.User) error {
+		previous = stored.CalendarToken
+		stored.CalendarToken = token
+		return nil
+	}); err != nil {
+		slog.ErrorContext(ctx, "failed to save calendar token", "user_id", currentUser.ID, "error", err)
+		http.Error(w, "unable to create calendar link", http.StatusInternalServerError)
+		return
+	}
+	if previous != "" {
+		if err := s.tokens.Revoke(ctx, previous); err != nil && !errors.Is(err, auth.ErrNoSession) {
+			slog.ErrorContext(ctx, "failed to revoke calendar token", "user_id", currentUser.ID, "error", err)
+		}
+	}
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (s *server) handleUnsubscribe(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	if err := r.ParseForm(); err != nil {
@@ -415,8 +561,10 @@ func (s *server) handleUnsubscribe(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid unsubscribe link", http.StatusBadRequest)
 		return
 	}
-	currentUser.MailOptIn = false
-	if err := s.storage.Update(currentUser); err != nil {
+	if err := s.storage.Modify(ctx, currentUser, func(stored *utypes.User) error {
+		stored.MailOptIn = false
+		return nil
+	}); err != nil {
 		slog.ErrorContext(ctx, "failed to disable mail opt in", "user_id", userID, "error", err)
 		http.Error(w, "unable to process request", http.StatusInternalServerError)
 		return
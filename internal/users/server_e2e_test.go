@@ -30,7 +30,7 @@ func TestUserPageUpdate_E2E(t *testing.T) {
 	storage := NewStorage(cacheStore)
 
 	tf := FakeUnsubscribeTokenFactory()
-	srv := NewHandler(storage, nil, auth.DefaultMock(), tf, "http://example.com")
+	srv := NewHandler(storage, nil, auth.DefaultMock(), tf, auth.NewTokenStore(cacheStore), "http://example.com")
 	mux := http.NewServeMux()
 	srv.Register(mux)
 
@@ -8,11 +8,13 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"path/filepath"
+	"reflect"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"careme/internal/auth"
 	"careme/internal/cache"
 	"careme/internal/locations"
 	"careme/internal/recipes/feedback"
@@ -105,7 +107,7 @@ func TestNewHandlerStoresConfiguredPublicOriginForOfflineRecipes(t *testing.T) {
 	t.Parallel()
 	cacheStore := cache.NewFileCache(filepath.Join(t.TempDir(), "cache"))
 	storage := NewStorage(cacheStore)
-	s := NewHandler(storage, failingLocationGetter{}, testAuthClient{}, nil, "https://configured.example/")
+	s := NewHandler(storage, failingLocationGetter{}, testAuthClient{}, nil, nil, "https://configured.example/")
 	now := time.Now()
 	err := storage.Update(&utypes.User{
 		ID:          "user-1",
@@ -241,6 +243,278 @@ func TestHandleUser_ClearsDirective(t *testing.T) {
 	}
 }
 
+func TestHandleUser_SavesHousehold(t *testing.T) {
+	t.Parallel()
+	cacheStore := cache.NewFileCache(filepath.Join(t.TempDir(), "cache"))
+	storage := NewStorage(cacheStore)
+	s := &server{
+		storage:  storage,
+		userTmpl: template.Must(template.New("user").Parse("ok")),
+		clerk:    testAuthClient{},
+	}
+
+	form := url.Values{
+		"household":         {"1"},
+		"household_members": {"4"},
+		"allergen":          {"tree nuts", "shellfish"},
+		"other_allergens":   {"Kiwi, peanut"},
+		"diet":              {"vegetarian"},
+		"dislikes":          {"Cilantro, olives"},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+
+	s.handleUser(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	user, err := storage.GetByID("user-1")
+	if err != nil {
+		t.Fatalf("expected user to be stored, got error %v", err)
+	}
+	want := utypes.Household{
+		Members:   4,
+		Allergens: []string{"kiwi", "peanuts", "shellfish", "tree nuts"},
+		Diets:     []string{"vegetarian"},
+		Dislikes:  []string{"cilantro", "olives"},
+	}
+	if !reflect.DeepEqual(user.Household, want) {
+		t.Fatalf("expected household %+v, got %+v", want, user.Household)
+	}
+
+	view := newHouseholdView(user.Household)
+	if view.OtherAllergens != "kiwi" {
+		t.Fatalf("expected only unlisted allergens in free text, got %q", view.OtherAllergens)
+	}
+}
+
+func TestHandleUser_SavesWeekPlanDays(t *testing.T) {
+	t.Parallel()
+	cacheStore := cache.NewFileCache(filepath.Join(t.TempDir(), "cache"))
+	storage := NewStorage(cacheStore)
+	s := &server{
+		storage:  storage,
+		userTmpl: template.Must(template.New("user").Parse("ok")),
+		clerk:    testAuthClient{},
+	}
+
+	post := func(form url.Values) int {
+		req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		s.handleUser(rr, req)
+		return rr.Code
+	}
+
+	if code := post(url.Values{"week_plan": {"1"}, "week_plan_day": {"Monday", "Thursday"}}); code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, code)
+	}
+	user, err := storage.GetByID("user-1")
+	if err != nil {
+		t.Fatalf("expected user to be stored, got error %v", err)
+	}
+	if want := []string{"Monday", "Thursday"}; !reflect.DeepEqual(user.WeekPlanDays, want) {
+		t.Fatalf("expected week plan days %v, got %v", want, user.WeekPlanDays)
+	}
+
+	if code := post(url.Values{"week_plan": {"1"}, "week_plan_day": {"Someday"}}); code != http.StatusBadRequest {
+		t.Fatalf("expected status %d for invalid day, got %d", http.StatusBadRequest, code)
+	}
+
+	if code := post(url.Values{"week_plan": {"1"}}); code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, code)
+	}
+	user, err = storage.GetByID("user-1")
+	if err != nil {
+		t.Fatalf("expected user to be stored, got error %v", err)
+	}
+	if len(user.WeekPlanDays) != 0 {
+		t.Fatalf("expected unchecking every day to clear the week plan, got %v", user.WeekPlanDays)
+	}
+}
+
+func TestHandleUser_SavesBudget(t *testing.T) {
+	t.Parallel()
+	cacheStore := cache.NewFileCache(filepath.Join(t.TempDir(), "cache"))
+	storage := NewStorage(cacheStore)
+	s := &server{
+		storage:  storage,
+		userTmpl: template.Must(template.New("user").Parse("ok")),
+		clerk:    testAuthClient{},
+	}
+
+	post := func(form url.Values) int {
+		req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		s.handleUser(rr, req)
+		return rr.Code
+	}
+
+	if code := post(url.Values{"budget": {"1"}, "budget_per_meal": {"$18.50"}, "budget_per_week": {""}}); code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, code)
+	}
+	user, err := storage.GetByID("user-1")
+	if err != nil {
+		t.Fatalf("expected user to be stored, got error %v", err)
+	}
+	if want := (utypes.Budget{PerMeal: 18.5}); user.Budget != want {
+		t.Fatalf("expected budget %+v, got %+v", want, user.Budget)
+	}
+
+	for _, bad := range []string{"cheap", "-5", "NaN"} {
+		if code := post(url.Values{"budget": {"1"}, "budget_per_week": {bad}}); code != http.StatusBadRequest {
+			t.Fatalf("expected status %d for budget %q, got %d", http.StatusBadRequest, bad, code)
+		}
+	}
+}
+
+func TestHandleUser_SavesIngredientPreferences(t *testing.T) {
+	t.Parallel()
+	cacheStore := cache.NewFileCache(filepath.Join(t.TempDir(), "cache"))
+	storage := NewStorage(cacheStore)
+	s := &server{
+		storage:  storage,
+		userTmpl: template.Must(template.New("user").Parse("ok")),
+		clerk:    testAuthClient{},
+	}
+
+	post := func(form url.Values) int {
+		req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		s.handleUser(rr, req)
+		return rr.Code
+	}
+
+	if code := post(url.Values{"ingredient_preferences": {"1"}, "min_grade": {"8"}, "boosts": {"Seafood, greens"}, "bans": {"pork"}}); code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, code)
+	}
+	user, err := storage.GetByID("user-1")
+	if err != nil {
+		t.Fatalf("expected user to be stored, got error %v", err)
+	}
+	want := utypes.IngredientPreferences{MinGrade: 8, Boosts: []string{"greens", "seafood"}, Bans: []string{"pork"}}
+	if !reflect.DeepEqual(user.IngredientPreferences, want) {
+		t.Fatalf("expected ingredient preferences %+v, got %+v", want, user.IngredientPreferences)
+	}
+
+	for _, bad := range []string{"great", "11", "-1"} {
+		if code := post(url.Values{"ingredient_preferences": {"1"}, "min_grade": {bad}}); code != http.StatusBadRequest {
+			t.Fatalf("expected status %d for grade %q, got %d", http.StatusBadRequest, bad, code)
+		}
+	}
+}
+
+func TestHandleUser_EditsTaste(t *testing.T) {
+	t.Parallel()
+	cacheStore := cache.NewFileCache(filepath.Join(t.TempDir(), "cache"))
+	storage := NewStorage(cacheStore)
+	s := &server{
+		storage:  storage,
+		userTmpl: template.Must(template.New("user").Parse("ok")),
+		clerk:    testAuthClient{},
+	}
+	learned := utypes.TasteProfile{Cuisines: []string{"thai"}, Proteins: []string{"salmon"}, MaxCookMinutes: 40}
+	if err := storage.Update(&utypes.User{ID: "user-1", Email: []string{"user-1@example.com"}, ShoppingDay: "Saturday", Taste: learned}); err != nil {
+		t.Fatalf("failed to store user: %v", err)
+	}
+
+	post := func(form url.Values) (int, utypes.TasteProfile) {
+		t.Helper()
+		form.Set("taste", "1")
+		req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		s.handleUser(rr, req)
+		user, err := storage.GetByID("user-1")
+		if err != nil {
+			t.Fatalf("expected user to be stored, got error %v", err)
+		}
+		return rr.Code, user.Taste
+	}
+
+	code, got := post(url.Values{"taste_cuisines": {"Thai"}, "taste_proteins": {"salmon"}, "taste_max_minutes": {"40"}})
+	if code != http.StatusOK || got.Edited {
+		t.Fatalf("resaving the learned profile should keep learning it, got %d %+v", code, got)
+	}
+
+	code, got = post(url.Values{"taste_cuisines": {"Thai, Korean"}, "taste_avoid": {"Pork"}, "taste_max_minutes": {"30"}, "taste_max_serving_cost": {"$7.50"}})
+	want := utypes.TasteProfile{Cuisines: []string{"thai", "korean"}, Avoid: []string{"pork"}, MaxCookMinutes: 30, MaxServingCost: 7.5, Edited: true}
+	if code != http.StatusOK || !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected taste %+v, got %d %+v", want, code, got)
+	}
+
+	code, got = post(url.Values{"taste_cuisines": {"thai, korean"}, "taste_avoid": {"pork"}, "taste_max_minutes": {"30"}, "taste_max_serving_cost": {"7.5"}, "taste_relearn": {"1"}})
+	if code != http.StatusOK || got.Edited {
+		t.Fatalf("relearn should hand the profile back, got %d %+v", code, got)
+	}
+
+	for _, bad := range []url.Values{{"taste_max_minutes": {"soon"}}, {"taste_max_minutes": {"-5"}}, {"taste_max_serving_cost": {"cheap"}}} {
+		if code, _ := post(bad); code != http.StatusBadRequest {
+			t.Fatalf("expected status %d for %v, got %d", http.StatusBadRequest, bad, code)
+		}
+	}
+}
+
+func TestHandleUser_SavesPantry(t *testing.T) {
+	t.Parallel()
+	cacheStore := cache.NewFileCache(filepath.Join(t.TempDir(), "cache"))
+	storage := NewStorage(cacheStore)
+	s := &server{
+		storage:  storage,
+		userTmpl: template.Must(template.New("user").Parse("ok")),
+		clerk:    testAuthClient{},
+	}
+
+	form := url.Values{"pantry": {"Olive Oil\nrice, 2 cups\n\nolive oil, 1 bottle"}}
+	req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	s.handleUser(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	pantry, err := storage.Pantry(t.Context(), "user-1")
+	if err != nil {
+		t.Fatalf("expected pantry to be stored, got error %v", err)
+	}
+	want := []utypes.PantryItem{{Name: "olive oil"}, {Name: "rice", Quantity: "2 cups"}}
+	if !reflect.DeepEqual(pantry.Items, want) {
+		t.Fatalf("expected pantry %v, got %v", want, pantry.Items)
+	}
+	if got := pantryText(pantry); got != "olive oil\nrice, 2 cups" {
+		t.Fatalf("unexpected pantry text %q", got)
+	}
+}
+
+func TestHandleUser_RejectsInvalidHouseholdSize(t *testing.T) {
+	t.Parallel()
+	cacheStore := cache.NewFileCache(filepath.Join(t.TempDir(), "cache"))
+	s := &server{
+		storage:  NewStorage(cacheStore),
+		userTmpl: template.Must(template.New("user").Parse("ok")),
+		clerk:    testAuthClient{},
+	}
+
+	form := url.Values{
+		"household":         {"1"},
+		"household_members": {"99"},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+
+	s.handleUser(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
 func TestHandleUser_BlanksFavoriteStoreInHTMLWhenLocationLookupFails(t *testing.T) {
 	t.Parallel()
 	cacheStore := cache.NewFileCache(filepath.Join(t.TempDir(), "cache"))
@@ -514,3 +788,56 @@ func TestHandleRemoveUserRecipe_HTMXRemovesMatchingRecipeWithoutRedirect(t *test
 		t.Fatalf("expected kept recipe %q, got %q", keep.Title, updated.LastRecipes[0].Title)
 	}
 }
+
+func TestHandleCalendarLink_ResetRevokesTheOldLink(t *testing.T) {
+	t.Parallel()
+	cacheStore := cache.NewFileCache(filepath.Join(t.TempDir(), "cache"))
+	storage := NewStorage(cacheStore)
+	tokens := auth.NewTokenStore(cacheStore)
+	s := NewHandler(storage, nil, testAuthClient{}, nil, tokens, "https://careme.cooking/")
+	if err := storage.Update(&utypes.User{ID: "user-1", Email: []string{"user@example.com"}, CreatedAt: time.Now(), ShoppingDay: "Saturday"}); err != nil {
+		t.Fatalf("failed to seed user: %v", err)
+	}
+	if feed, _ := s.calendarLinks(""); feed != "" {
+		t.Fatalf("expected no calendar link before one is asked for, got %q", feed)
+	}
+
+	link := func() string {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/user/calendar", nil)
+		req.Header.Set("HX-Request", "true")
+		rr := httptest.NewRecorder()
+		s.handleCalendarLink(rr, req)
+		if rr.Code != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d", http.StatusNoContent, rr.Code)
+		}
+		updated, err := storage.GetByID("user-1")
+		if err != nil {
+			t.Fatalf("failed to fetch updated user: %v", err)
+		}
+		return updated.CalendarToken
+	}
+	first := link()
+	userID, err := tokens.ScopedUserID(t.Context(), first, auth.ScopeCalendar)
+	if err != nil || userID != "user-1" {
+		t.Fatalf("expected calendar token for user-1, got %q, %v", userID, err)
+	}
+	feed, webcal := s.calendarLinks(first)
+	if feed != "https://careme.cooking/user/calendar.ics?token="+first {
+		t.Fatalf("unexpected feed link %q", feed)
+	}
+	if string(webcal) != "webcal://careme.cooking/user/calendar.ics?token="+first {
+		t.Fatalf("unexpected webcal link %q", webcal)
+	}
+
+	second := link()
+	if second == first {
+		t.Fatal("expected a new calendar token")
+	}
+	if _, err := tokens.ScopedUserID(t.Context(), first, auth.ScopeCalendar); !errors.Is(err, auth.ErrNoSession) {
+		t.Fatalf("expected the old calendar link to be revoked, got %v", err)
+	}
+	if _, err := tokens.ScopedUserID(t.Context(), second, auth.ScopeCalendar); err != nil {
+		t.Fatalf("expected the new calendar link to work: %v", err)
+	}
+}
@@ -154,7 +154,34 @@ func (s *Storage) Update(user *utypes.User) error {
 	return nil
 }
 
-func (s *Storage) RemoveRecipe(user *utypes.User, recipeHash string) (bool, error) {
+// Modify applies fn to the stored user and saves the result without clobbering
+// a concurrent change from another request or replica; fn is re-run on the fresh
+// user when that happens. fn can return cache.ErrSkipUpdate to save nothing.
+// user is updated to what's stored on success.
+func (s *Storage) Modify(ctx context.Context, user *utypes.User, fn func(*utypes.User) error) error {
+	if user == nil {
+		return fmt.Errorf("user is required")
+	}
+	updated, err := cache.UpdateJSON(ctx, s.cache, userPrefix+user.ID, func(stored *utypes.User, exists bool) error {
+		if !exists {
+			return ErrNotFound
+		}
+		if err := fn(stored); err != nil {
+			return err
+		}
+		if err := stored.Validate(); err != nil {
+			return fmt.Errorf("invalid user: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	*user = updated
+	return nil
+}
+
+func (s *Storage) RemoveRecipe(ctx context.Context, user *utypes.User, recipeHash string) (bool, error) {
 	recipeHash = strings.TrimSpace(recipeHash)
 	if recipeHash == "" {
 		return false, fmt.Errorf("invalid recipe hash")
@@ -163,21 +190,25 @@ func (s *Storage) RemoveRecipe(user *utypes.User, recipeHash string) (bool, erro
 		return false, fmt.Errorf("user is required")
 	}
 
-	filtered := lo.Filter(user.LastRecipes, func(recipe utypes.Recipe, _ int) bool {
-		return recipe.Hash != recipeHash
+	removed := false
+	err := s.Modify(ctx, user, func(stored *utypes.User) error {
+		filtered := lo.Filter(stored.LastRecipes, func(recipe utypes.Recipe, _ int) bool {
+			return recipe.Hash != recipeHash
+		})
+		removed = len(filtered) != len(stored.LastRecipes)
+		if !removed {
+			return cache.ErrSkipUpdate
+		}
+		stored.LastRecipes = filtered
+		return nil
 	})
-	if len(filtered) == len(user.LastRecipes) {
-		return false, nil // not found
-	}
-
-	user.LastRecipes = filtered
-	if err := s.Update(user); err != nil {
+	if err != nil {
 		return false, err
 	}
-	return true, nil
+	return removed, nil
 }
 
-func (s *Storage) ReplaceRecipe(user *utypes.User, oldHash string, replacement utypes.Recipe) (bool, error) {
+func (s *Storage) ReplaceRecipe(ctx context.Context, user *utypes.User, oldHash string, replacement utypes.Recipe) (bool, error) {
 	oldHash = strings.TrimSpace(oldHash)
 	replacement.Hash = strings.TrimSpace(replacement.Hash)
 	if oldHash == "" || replacement.Hash == "" {
@@ -188,20 +219,24 @@ func (s *Storage) ReplaceRecipe(user *utypes.User, oldHash string, replacement u
 	}
 
 	replaced := false
-	for i := range user.LastRecipes {
-		if user.LastRecipes[i].Hash == oldHash {
-			user.LastRecipes[i] = replacement
-			replaced = true
-			break
+	err := s.Modify(ctx, user, func(stored *utypes.User) error {
+		replaced = false
+		for i := range stored.LastRecipes {
+			if stored.LastRecipes[i].Hash == oldHash {
+				stored.LastRecipes[i] = replacement
+				replaced = true
+				break
+			}
 		}
-	}
-	if !replaced {
-		return false, nil
-	}
-	if err := s.Update(user); err != nil {
+		if !replaced {
+			return cache.ErrSkipUpdate
+		}
+		return nil
+	})
+	if err != nil {
 		return false, err
 	}
-	return true, nil
+	return replaced, nil
 }
 
 func normalizeEmail(email string) string {
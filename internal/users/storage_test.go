@@ -2,8 +2,10 @@ package users
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 
@@ -243,7 +245,7 @@ func TestStorageRemoveRecipesByHash(t *testing.T) {
 		t.Fatalf("Update() error: %v", err)
 	}
 
-	removed, err := storage.RemoveRecipe(user, "remove-hash")
+	removed, err := storage.RemoveRecipe(t.Context(), user, "remove-hash")
 	if err != nil {
 		t.Fatalf("RemoveRecipe() error: %v", err)
 	}
@@ -254,3 +256,84 @@ func TestStorageRemoveRecipesByHash(t *testing.T) {
 		t.Fatalf("RemoveRecipe() LastRecipes = %#v, want only keep recipe", user.LastRecipes)
 	}
 }
+
+func TestStorageRemoveRecipeKeepsConcurrentChanges(t *testing.T) {
+	fc := cache.NewFileCache(t.TempDir())
+	storage := NewStorage(fc)
+
+	user := &utypes.User{
+		ID:          "user-stale",
+		Email:       []string{"stale@example.com"},
+		ShoppingDay: time.Saturday.String(),
+		LastRecipes: []utypes.Recipe{{Title: "Old", Hash: "old-hash"}},
+	}
+	if err := storage.Update(user); err != nil {
+		t.Fatalf("Update() error: %v", err)
+	}
+	stale := *user
+
+	// another request saves a recipe and picks a store after stale was loaded.
+	if err := storage.Modify(t.Context(), user, func(stored *utypes.User) error {
+		stored.LastRecipes = append(stored.LastRecipes, utypes.Recipe{Title: "New", Hash: "new-hash"})
+		stored.FavoriteStore = "70001001"
+		return nil
+	}); err != nil {
+		t.Fatalf("Modify() error: %v", err)
+	}
+
+	removed, err := storage.RemoveRecipe(t.Context(), &stale, "old-hash")
+	if err != nil {
+		t.Fatalf("RemoveRecipe() error: %v", err)
+	}
+	if !removed {
+		t.Fatalf("RemoveRecipe() removed = false, want true")
+	}
+
+	got, err := storage.GetByID(user.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error: %v", err)
+	}
+	if len(got.LastRecipes) != 1 || got.LastRecipes[0].Hash != "new-hash" {
+		t.Fatalf("LastRecipes = %#v, want only the concurrently saved recipe", got.LastRecipes)
+	}
+	if got.FavoriteStore != "70001001" {
+		t.Fatalf("FavoriteStore = %q, want the concurrent change kept", got.FavoriteStore)
+	}
+	if stale.FavoriteStore != "70001001" {
+		t.Fatalf("RemoveRecipe() should refresh the caller's user, got favorite %q", stale.FavoriteStore)
+	}
+}
+
+func TestStorageModifyMissingUser(t *testing.T) {
+	storage := NewStorage(cache.NewInMemoryCache())
+	err := storage.Modify(t.Context(), &utypes.User{ID: "nobody"}, func(*utypes.User) error { return nil })
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Modify() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStorageUpdatePantryKeepsConcurrentChanges(t *testing.T) {
+	storage := NewStorage(cache.NewFileCache(t.TempDir()))
+	items := []string{"rice", "beans", "olive oil", "flour"}
+
+	var wg sync.WaitGroup
+	for _, name := range items {
+		wg.Go(func() {
+			if err := storage.UpdatePantry(t.Context(), "user-1", func(pantry *utypes.Pantry) error {
+				pantry.Items = append(pantry.Items, utypes.PantryItem{Name: name})
+				return nil
+			}); err != nil {
+				t.Errorf("UpdatePantry(%q) error: %v", name, err)
+			}
+		})
+	}
+	wg.Wait()
+
+	pantry, err := storage.Pantry(t.Context(), "user-1")
+	if err != nil {
+		t.Fatalf("Pantry() error: %v", err)
+	}
+	if len(pantry.Items) != len(items) {
+		t.Fatalf("Pantry() items = %#v, want all %d concurrent additions", pantry.Items, len(items))
+	}
+}
@@ -0,0 +1,49 @@
+package users
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	utypes "careme/internal/users/types"
+)
+
+// parseTasteForm reads the taste fields over current. Changing anything marks
+// the profile edited so relearning leaves it alone; ticking taste_relearn hands
+// it back to be learned again.
+func parseTasteForm(r *http.Request, current utypes.TasteProfile) (utypes.TasteProfile, error) {
+	taste := utypes.TasteProfile{
+		Cuisines:   splitList(r.FormValue("taste_cuisines")),
+		Proteins:   splitList(r.FormValue("taste_proteins")),
+		Techniques: splitList(r.FormValue("taste_techniques")),
+		Avoid:      splitList(r.FormValue("taste_avoid")),
+	}
+	if minutes := strings.TrimSpace(r.FormValue("taste_max_minutes")); minutes != "" {
+		n, err := strconv.Atoi(minutes)
+		if err != nil {
+			return taste, fmt.Errorf("cook time must be between 0 and %d minutes", utypes.MaxTasteCookMinutes)
+		}
+		taste.MaxCookMinutes = n
+	}
+	if cost := strings.TrimPrefix(strings.TrimSpace(r.FormValue("taste_max_serving_cost")), "$"); cost != "" {
+		n, err := strconv.ParseFloat(cost, 64)
+		if err != nil {
+			return taste, fmt.Errorf("cost per serving must be a dollar amount")
+		}
+		taste.MaxServingCost = n
+	}
+	if err := taste.Validate(); err != nil {
+		return taste, err
+	}
+	taste = taste.Normalize()
+	switch {
+	case r.FormValue("taste_relearn") == "1":
+		taste.Edited = false
+	case taste.SameTaste(current):
+		taste.Edited = current.Edited
+	default:
+		taste.Edited = true
+	}
+	return taste, nil
+}
@@ -0,0 +1,57 @@
+package types
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// MaxPantryItems keeps the pantry small enough to send with every menu prompt.
+const MaxPantryItems = 100
+
+// Pantry is what a user already has at home. It lives outside User under its own
+// prefix because cooking a recipe rewrites it.
+type Pantry struct {
+	Items []PantryItem `json:"items"`
+}
+
+// PantryItem is one thing on hand. An empty Quantity means "plenty", which is how
+// most people think about salt and spices.
+type PantryItem struct {
+	Name     string `json:"name"`
+	Quantity string `json:"quantity,omitempty"`
+}
+
+func (p Pantry) IsZero() bool {
+	return len(p.Items) == 0
+}
+
+// Normalize trims and lowercases names and drops blank or repeated items, keeping
+// the first quantity given.
+func (p Pantry) Normalize() Pantry {
+	var out Pantry
+	for _, item := range p.Items {
+		item.Name = strings.ToLower(strings.Join(strings.Fields(item.Name), " "))
+		item.Quantity = strings.Join(strings.Fields(item.Quantity), " ")
+		if item.Name == "" {
+			continue
+		}
+		if slices.ContainsFunc(out.Items, func(existing PantryItem) bool { return existing.Name == item.Name }) {
+			continue
+		}
+		out.Items = append(out.Items, item)
+	}
+	return out
+}
+
+func (p Pantry) Validate() error {
+	if len(p.Items) > MaxPantryItems {
+		return fmt.Errorf("pantry can hold at most %d items", MaxPantryItems)
+	}
+	for _, item := range p.Items {
+		if strings.TrimSpace(item.Name) == "" {
+			return fmt.Errorf("pantry item is missing a name")
+		}
+	}
+	return nil
+}
@@ -7,6 +7,8 @@ import (
 	"slices"
 	"strings"
 	"time"
+
+	"careme/internal/dietary"
 )
 
 type Recipe struct {
@@ -24,6 +26,207 @@ type User struct {
 	ShoppingDay   string    `json:"shopping_day,omitempty"`
 	MailOptIn     bool      `json:"mail_opt_in,omitempty"`
 	Directive     string    `json:"directive,omitempty"`
+	Household     Household `json:"household,omitzero"`
+	// WeekPlanDays turns the weekly mail into a week plan with a dinner on each of these days.
+	WeekPlanDays []string `json:"week_plan_days,omitempty"`
+	Budget       Budget   `json:"budget,omitzero"`
+	// IngredientPreferences steer which store staples recipes are planned from.
+	IngredientPreferences IngredientPreferences `json:"ingredient_preferences,omitzero"`
+	// Taste is learned from what the user saves, dismisses and rates.
+	Taste TasteProfile `json:"taste,omitzero"`
+	// CalendarToken is the calendar-scoped API token in the user's feed link,
+	// kept so the user page can show the link again. Resetting it revokes it.
+	CalendarToken string `json:"calendar_token,omitempty"`
+}
+
+// MaxHouseholdMembers caps how many people a single recipe is sized for.
+const MaxHouseholdMembers = 20
+
+// Household is who we are cooking for. Allergens and diets are enforced on
+// generated recipes; dislikes only steer planning.
+type Household struct {
+	Members   int      `json:"members,omitempty"`
+	Allergens []string `json:"allergens,omitempty"`
+	Diets     []string `json:"diets,omitempty"`
+	Dislikes  []string `json:"dislikes,omitempty"`
+}
+
+// IsZero reports whether nothing about the household has been set.
+func (h Household) IsZero() bool {
+	return h.Members == 0 && len(h.Allergens) == 0 && len(h.Diets) == 0 && len(h.Dislikes) == 0
+}
+
+// Normalize lowercases, canonicalizes, dedupes and sorts the restriction lists
+// so equivalent households compare (and hash) the same.
+func (h Household) Normalize() Household {
+	out := Household{Members: h.Members}
+	for _, a := range h.Allergens {
+		if a = dietary.NormalizeAllergen(a); a != "" {
+			out.Allergens = append(out.Allergens, a)
+		}
+	}
+	for _, d := range h.Diets {
+		if d, ok := dietary.NormalizeDiet(d); ok {
+			out.Diets = append(out.Diets, d)
+		}
+	}
+	for _, d := range h.Dislikes {
+		if d = strings.ToLower(strings.Join(strings.Fields(d), " ")); d != "" {
+			out.Dislikes = append(out.Dislikes, d)
+		}
+	}
+	for _, list := range []*[]string{&out.Allergens, &out.Diets, &out.Dislikes} {
+		slices.Sort(*list)
+		*list = slices.Compact(*list)
+	}
+	return out
+}
+
+func (h Household) Validate() error {
+	if h.Members < 0 || h.Members > MaxHouseholdMembers {
+		return fmt.Errorf("household members must be between 0 and %d", MaxHouseholdMembers)
+	}
+	for _, d := range h.Diets {
+		if _, ok := dietary.NormalizeDiet(d); !ok {
+			return fmt.Errorf("unsupported diet %q", d)
+		}
+	}
+	return nil
+}
+
+// MaxBudget is the largest budget in dollars we accept; anything bigger is a typo.
+const MaxBudget = 10000
+
+// Budget caps what recipes cost at the store in dollars. Zero means no limit. A
+// weekly budget is split evenly across the dinners being planned.
+type Budget struct {
+	PerMeal float64 `json:"per_meal,omitempty"`
+	PerWeek float64 `json:"per_week,omitempty"`
+}
+
+func (b Budget) IsZero() bool {
+	return b.PerMeal == 0 && b.PerWeek == 0
+}
+
+func (b Budget) Validate() error {
+	for _, v := range []float64{b.PerMeal, b.PerWeek} {
+		if !(v >= 0 && v <= MaxBudget) { // also catches NaN
+			return fmt.Errorf("budget must be between 0 and %d dollars", MaxBudget)
+		}
+	}
+	return nil
+}
+
+// MaxIngredientGrade is the best grade the ingredient grader gives.
+const MaxIngredientGrade = 10
+
+// IngredientPreferences are a user's say in which staples get planned. MinGrade
+// is the lowest ingredient grade to plan with, zero for our default. Boosts are
+// things like "seafood" to see more of and Bans things like "pork" to leave out;
+// each matches store categories or words in an item's description.
+type IngredientPreferences struct {
+	MinGrade int      `json:"min_grade,omitempty"`
+	Boosts   []string `json:"boosts,omitempty"`
+	Bans     []string `json:"bans,omitempty"`
+}
+
+func (p IngredientPreferences) IsZero() bool {
+	return p.MinGrade == 0 && len(p.Boosts) == 0 && len(p.Bans) == 0
+}
+
+// Normalize lowercases, dedupes and sorts boosts and bans so equivalent
+// preferences hash the same. Something both boosted and banned stays banned.
+func (p IngredientPreferences) Normalize() IngredientPreferences {
+	out := IngredientPreferences{MinGrade: p.MinGrade}
+	for _, list := range []struct{ in, out *[]string }{{&p.Boosts, &out.Boosts}, {&p.Bans, &out.Bans}} {
+		for _, term := range *list.in {
+			if term = strings.ToLower(strings.Join(strings.Fields(term), " ")); term != "" {
+				*list.out = append(*list.out, term)
+			}
+		}
+		slices.Sort(*list.out)
+		*list.out = slices.Compact(*list.out)
+	}
+	out.Boosts = slices.DeleteFunc(out.Boosts, func(term string) bool {
+		return slices.Contains(out.Bans, term)
+	})
+	if len(out.Boosts) == 0 {
+		out.Boosts = nil
+	}
+	return out
+}
+
+func (p IngredientPreferences) Validate() error {
+	if p.MinGrade < 0 || p.MinGrade > MaxIngredientGrade {
+		return fmt.Errorf("ingredient grade must be between 0 and %d", MaxIngredientGrade)
+	}
+	return nil
+}
+
+// MaxTasteCookMinutes is the longest usual cook time we accept, a day.
+const MaxTasteCookMinutes = 24 * 60
+
+// TasteProfile is what a user's saves, dismissals and stars say they like:
+// cuisines, anchor proteins and techniques to lean toward, ones to Avoid, and
+// how long and how much a serving their favorites usually take. Zero cook
+// minutes or serving cost means no preference. It's relearned from their
+// history until they edit it; Edited profiles are left alone.
+type TasteProfile struct {
+	Cuisines       []string `json:"cuisines,omitempty"`
+	Proteins       []string `json:"proteins,omitempty"`
+	Techniques     []string `json:"techniques,omitempty"`
+	Avoid          []string `json:"avoid,omitempty"`
+	MaxCookMinutes int      `json:"max_cook_minutes,omitempty"`
+	MaxServingCost float64  `json:"max_serving_cost,omitempty"`
+	Edited         bool     `json:"edited,omitempty"`
+}
+
+// IsZero reports whether the profile says nothing about the user's taste,
+// edited or not.
+func (t TasteProfile) IsZero() bool {
+	return len(t.Cuisines) == 0 && len(t.Proteins) == 0 && len(t.Techniques) == 0 && len(t.Avoid) == 0 &&
+		t.MaxCookMinutes == 0 && t.MaxServingCost == 0
+}
+
+// Normalize lowercases and dedupes the lists, keeping their order since the
+// first entries are the strongest. Something both liked and avoided is avoided.
+func (t TasteProfile) Normalize() TasteProfile {
+	out := t
+	avoid := normalizeTerms(t.Avoid, nil)
+	out.Avoid = avoid
+	out.Cuisines = normalizeTerms(t.Cuisines, avoid)
+	out.Proteins = normalizeTerms(t.Proteins, avoid)
+	out.Techniques = normalizeTerms(t.Techniques, avoid)
+	return out
+}
+
+func normalizeTerms(terms, drop []string) []string {
+	var out []string
+	for _, term := range terms {
+		term = strings.ToLower(strings.Join(strings.Fields(term), " "))
+		if term != "" && !slices.Contains(out, term) && !slices.Contains(drop, term) {
+			out = append(out, term)
+		}
+	}
+	return out
+}
+
+// SameTaste reports whether two profiles say the same thing, however they got it.
+func (t TasteProfile) SameTaste(o TasteProfile) bool {
+	t, o = t.Normalize(), o.Normalize()
+	return slices.Equal(t.Cuisines, o.Cuisines) && slices.Equal(t.Proteins, o.Proteins) &&
+		slices.Equal(t.Techniques, o.Techniques) && slices.Equal(t.Avoid, o.Avoid) &&
+		t.MaxCookMinutes == o.MaxCookMinutes && t.MaxServingCost == o.MaxServingCost
+}
+
+func (t TasteProfile) Validate() error {
+	if t.MaxCookMinutes < 0 || t.MaxCookMinutes > MaxTasteCookMinutes {
+		return fmt.Errorf("cook time must be between 0 and %d minutes", MaxTasteCookMinutes)
+	}
+	if !(t.MaxServingCost >= 0 && t.MaxServingCost <= MaxBudget) { // also catches NaN
+		return fmt.Errorf("cost per serving must be between 0 and %d dollars", MaxBudget)
+	}
+	return nil
 }
 
 // need to take a look up to location cache?
@@ -44,6 +247,23 @@ func (u User) Validate() error {
 			return fmt.Errorf("invalid favorite store id %s", u.FavoriteStore)
 		}
 	}
+	if err := u.Household.Validate(); err != nil {
+		return err
+	}
+	for _, day := range u.WeekPlanDays {
+		if _, err := ParseWeekday(day); err != nil {
+			return err
+		}
+	}
+	if err := u.Budget.Validate(); err != nil {
+		return err
+	}
+	if err := u.IngredientPreferences.Validate(); err != nil {
+		return err
+	}
+	if err := u.Taste.Validate(); err != nil {
+		return err
+	}
 	// trim out recipes older than 2 months? store them in seperate file?
 	slices.SortFunc(u.LastRecipes, func(a, b Recipe) int {
 		return b.CreatedAt.Compare(a.CreatedAt)
@@ -84,6 +304,11 @@ var daysOfWeek = [...]string{
 	time.Saturday.String(),
 }
 
+// Weekdays lists day names starting with Sunday.
+func Weekdays() []string {
+	return slices.Clone(daysOfWeek[:])
+}
+
 func ParseWeekday(v string) (time.Weekday, error) {
 	for i := range daysOfWeek {
 		if strings.EqualFold(daysOfWeek[i], v) {
@@ -1,6 +1,7 @@
 package types
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -141,4 +142,132 @@ func TestUserValidate(t *testing.T) {
 			t.Fatalf("expected invalid favorite store error, got %v", err)
 		}
 	})
+	t.Run("invalid household members", func(t *testing.T) {
+		user := &User{
+			ShoppingDay: time.Sunday.String(),
+			Email:       []string{"dana@example.com"},
+			Household:   Household{Members: MaxHouseholdMembers + 1},
+		}
+
+		err := user.Validate()
+		if err == nil || !strings.Contains(err.Error(), "household members") {
+			t.Fatalf("expected household members error, got %v", err)
+		}
+	})
+
+	t.Run("unsupported diet", func(t *testing.T) {
+		user := &User{
+			ShoppingDay: time.Sunday.String(),
+			Email:       []string{"dana@example.com"},
+			Household:   Household{Diets: []string{"carnivore"}},
+		}
+
+		err := user.Validate()
+		if err == nil || !strings.Contains(err.Error(), "unsupported diet") {
+			t.Fatalf("expected unsupported diet error, got %v", err)
+		}
+	})
+
+	t.Run("negative budget", func(t *testing.T) {
+		user := &User{
+			ShoppingDay: time.Sunday.String(),
+			Email:       []string{"dana@example.com"},
+			Budget:      Budget{PerWeek: -5},
+		}
+
+		err := user.Validate()
+		if err == nil || !strings.Contains(err.Error(), "budget must be between") {
+			t.Fatalf("expected budget error, got %v", err)
+		}
+	})
+
+	t.Run("ingredient grade out of range", func(t *testing.T) {
+		user := &User{
+			ShoppingDay:           time.Sunday.String(),
+			Email:                 []string{"dana@example.com"},
+			IngredientPreferences: IngredientPreferences{MinGrade: 11},
+		}
+
+		err := user.Validate()
+		if err == nil || !strings.Contains(err.Error(), "ingredient grade must be between") {
+			t.Fatalf("expected ingredient grade error, got %v", err)
+		}
+	})
+}
+
+func TestHouseholdNormalize(t *testing.T) {
+	got := Household{
+		Members:   3,
+		Allergens: []string{" Tree  Nuts", "peanut", "tree nut", ""},
+		Diets:     []string{"Veggie", "vegetarian", "keto"},
+		Dislikes:  []string{"Cilantro ", "olives", "cilantro"},
+	}.Normalize()
+
+	want := Household{
+		Members:   3,
+		Allergens: []string{"peanuts", "tree nuts"},
+		Diets:     []string{"vegetarian"},
+		Dislikes:  []string{"cilantro", "olives"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Normalize() = %+v, want %+v", got, want)
+	}
+	if !(Household{}).IsZero() || got.IsZero() {
+		t.Fatalf("IsZero mismatch")
+	}
+}
+
+func TestIngredientPreferencesNormalize(t *testing.T) {
+	got := IngredientPreferences{
+		MinGrade: 8,
+		Boosts:   []string{" Seafood", "seafood", "pork", ""},
+		Bans:     []string{"Pork", "lamb  chops"},
+	}.Normalize()
+
+	want := IngredientPreferences{
+		MinGrade: 8,
+		Boosts:   []string{"seafood"},
+		Bans:     []string{"lamb chops", "pork"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Normalize() = %+v, want %+v", got, want)
+	}
+	if !(IngredientPreferences{}).IsZero() || got.IsZero() {
+		t.Fatalf("IsZero mismatch")
+	}
+}
+
+func TestTasteProfileNormalize(t *testing.T) {
+	got := TasteProfile{
+		Cuisines:       []string{"Thai", " thai", "Mexican", ""},
+		Proteins:       []string{"Salmon", "pork"},
+		Techniques:     []string{"Stir  fry"},
+		Avoid:          []string{"Pork"},
+		MaxCookMinutes: 40,
+		Edited:         true,
+	}.Normalize()
+
+	want := TasteProfile{
+		Cuisines:       []string{"thai", "mexican"},
+		Proteins:       []string{"salmon"},
+		Techniques:     []string{"stir fry"},
+		Avoid:          []string{"pork"},
+		MaxCookMinutes: 40,
+		Edited:         true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Normalize() = %+v, want %+v", got, want)
+	}
+	if !(TasteProfile{Edited: true}).IsZero() || got.IsZero() {
+		t.Fatalf("IsZero mismatch")
+	}
+	if !got.SameTaste(TasteProfile{Cuisines: []string{"Thai", "Mexican"}, Proteins: []string{"salmon"}, Techniques: []string{"stir fry"}, Avoid: []string{"pork"}, MaxCookMinutes: 40}) {
+		t.Fatalf("SameTaste should ignore case and Edited")
+	}
+	if got.SameTaste(TasteProfile{Cuisines: []string{"mexican", "thai"}, Proteins: []string{"salmon"}, Techniques: []string{"stir fry"}, Avoid: []string{"pork"}, MaxCookMinutes: 40}) {
+		t.Fatalf("SameTaste should care which cuisine comes first")
+	}
+	if err := (TasteProfile{MaxCookMinutes: -5}).Validate(); err == nil {
+		t.Fatalf("negative cook time should be invalid")
+	}
 }